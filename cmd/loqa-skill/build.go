@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/loqalabs/loqa-core/internal/skills/manifest"
+	skillrt "github.com/loqalabs/loqa-core/internal/skills/runtime"
+	"gopkg.in/yaml.v3"
+)
+
+type buildOptions struct {
+	dir       string
+	out       string
+	toolchain string // auto, tinygo, go
+}
+
+// runBuild compiles the skill in opts.dir to wasm, checks the module against
+// the manifest entrypoint and the host ABI, and points runtime.module at the
+// output.
+func runBuild(ctx context.Context, opts buildOptions) (string, error) {
+	manifestPath := filepath.Join(opts.dir, "skill.yaml")
+	m, err := manifest.Load(manifestPath)
+	if err != nil {
+		return "", err
+	}
+
+	out := opts.out
+	if out == "" {
+		if m.Runtime.Module == "" {
+			return "", errors.New("--out is required when the manifest has no runtime.module")
+		}
+		out = filepath.Join(opts.dir, m.Runtime.Module)
+	}
+	out, err = filepath.Abs(out)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		return "", fmt.Errorf("create output directory: %w", err)
+	}
+
+	cmd, err := compileCommand(ctx, opts.toolchain, out, sourcePackage(opts.dir))
+	if err != nil {
+		return "", err
+	}
+	cmd.Dir = opts.dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s failed: %w", filepath.Base(cmd.Path), err)
+	}
+
+	wasm, err := os.ReadFile(out)
+	if err != nil {
+		return "", fmt.Errorf("read built module: %w", err)
+	}
+	if err := skillrt.ValidateModule(ctx, wasm, m.Runtime.Exports()...); err != nil {
+		return "", err
+	}
+
+	if err := syncModulePath(manifestPath, m, opts.dir, out); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// sourcePackage prefers the src/ layout used by the example skills.
+func sourcePackage(dir string) string {
+	if info, err := os.Stat(filepath.Join(dir, "src")); err == nil && info.IsDir() {
+		return "./src"
+	}
+	return "."
+}
+
+func compileCommand(ctx context.Context, toolchain, out, pkg string) (*exec.Cmd, error) {
+	if toolchain == "auto" || toolchain == "" {
+		toolchain = "go"
+		if _, err := exec.LookPath("tinygo"); err == nil {
+			toolchain = "tinygo"
+		} else {
+			fmt.Fprintln(os.Stderr, "warning: tinygo not found in PATH; building with the Go toolchain, which produces a much larger module. Install TinyGo 0.39+ (https://tinygo.org) or pass --toolchain go to silence this warning.")
+		}
+	}
+	switch toolchain {
+	case "tinygo":
+		path, err := exec.LookPath("tinygo")
+		if err != nil {
+			return nil, errors.New("tinygo not found in PATH: install TinyGo 0.39+ (https://tinygo.org) or use --toolchain go")
+		}
+		return exec.CommandContext(ctx, path, "build", "-o", out, "-target=wasi", pkg), nil
+	case "go":
+		path, err := exec.LookPath("go")
+		if err != nil {
+			return nil, errors.New("go not found in PATH: install Go 1.24+ or use --toolchain tinygo")
+		}
+		cmd := exec.CommandContext(ctx, path, "build", "-o", out, pkg)
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		return cmd, nil
+	default:
+		return nil, fmt.Errorf("unknown toolchain %q (want auto, tinygo, or go)", toolchain)
+	}
+}
+
+// syncModulePath rewrites runtime.module when the build output differs from
+// the manifest, leaving the rest of the file (comments included) untouched.
+func syncModulePath(manifestPath string, m manifest.Manifest, dir, out string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(absDir, out)
+	if err != nil {
+		return err
+	}
+	rel = filepath.ToSlash(rel)
+	if filepath.Clean(m.Runtime.Module) == filepath.Clean(rel) {
+		return nil
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	updated, err := replaceRuntimeModule(data, rel)
+	if err != nil {
+		return fmt.Errorf("%s: %w; set it to %q", manifestPath, err, rel)
+	}
+	if err := os.WriteFile(manifestPath, updated, 0o644); err != nil {
+		return fmt.Errorf("update manifest: %w", err)
+	}
+	m, err = manifest.Load(manifestPath)
+	if err != nil {
+		return err
+	}
+	if err := manifest.Validate(m); err != nil {
+		return err
+	}
+	fmt.Printf("updated %s runtime.module to %s\n", manifestPath, rel)
+	return nil
+}
+
+// replaceRuntimeModule returns data with the value of runtime.module set to
+// module. The value is located through the YAML node tree, so module keys
+// elsewhere in the file are left alone, and only that value's text is
+// replaced so comments and formatting survive.
+func replaceRuntimeModule(data []byte, module string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	value := mappingValue(&doc, "runtime", "module")
+	if value == nil || value.Kind != yaml.ScalarNode {
+		return nil, errors.New("runtime.module not found")
+	}
+	if value.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return nil, errors.New("runtime.module is a block scalar")
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	idx := value.Line - 1
+	if idx < 0 || idx >= len(lines) {
+		return nil, errors.New("runtime.module not found")
+	}
+	line := lines[idx]
+	start := byteOffset(line, value.Column-1)
+	end := scalarEnd(line, start, value.Style, value.Value)
+	if end < 0 {
+		return nil, errors.New("runtime.module spans several lines")
+	}
+	replacement := module
+	if value.Style != 0 || strings.ContainsAny(module, ":#'\"") {
+		replacement = fmt.Sprintf("%q", module)
+	}
+	if start > 0 && line[start-1] == ':' {
+		// An empty value is positioned right after the key's colon.
+		replacement = " " + replacement
+	}
+	lines[idx] = line[:start] + replacement + line[end:]
+	return []byte(strings.Join(lines, "")), nil
+}
+
+// mappingValue walks the mapping keys in path from the document root and
+// returns the node they lead to, or nil.
+func mappingValue(node *yaml.Node, path ...string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// scalarEnd returns the byte offset just past the scalar that starts at
+// start in line, or -1 if the scalar does not end on this line.
+func scalarEnd(line string, start int, style yaml.Style, value string) int {
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+		return -1
+	case style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] != '\'' {
+				continue
+			}
+			if i+1 < len(line) && line[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+		return -1
+	default:
+		// A plain scalar on one line reads exactly as its value.
+		if strings.HasPrefix(line[start:], value) {
+			return start + len(value)
+		}
+		return -1
+	}
+}
+
+// byteOffset converts a rune column, as the YAML parser reports it, to a
+// byte offset in line.
+func byteOffset(line string, column int) int {
+	for i := range line {
+		if column == 0 {
+			return i
+		}
+		column--
+	}
+	return len(line)
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestReplaceRuntimeModule(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{
+			name: "only the runtime block changes",
+			in: `# example skill
+metadata:
+  module: keep-me
+runtime:
+  mode: wasm
+  module: build/old.wasm # compiled by loqa-skill build
+  entrypoint: run
+`,
+			want: `# example skill
+metadata:
+  module: keep-me
+runtime:
+  mode: wasm
+  module: dist/skill.wasm # compiled by loqa-skill build
+  entrypoint: run
+`,
+		},
+		{
+			name: "quoted values stay quoted",
+			in:   "runtime:\n  module: 'build/old.wasm'  # note\n",
+			want: "runtime:\n  module: \"dist/skill.wasm\"  # note\n",
+		},
+		{
+			name: "flow mappings",
+			in:   "runtime: {module: build/old.wasm, entrypoint: run}\n",
+			want: "runtime: {module: dist/skill.wasm, entrypoint: run}\n",
+		},
+	}
+	for _, tc := range cases {
+		got, err := replaceRuntimeModule([]byte(tc.in), "dist/skill.wasm")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestReplaceRuntimeModuleRequiresRuntimeModule(t *testing.T) {
+	for _, in := range []string{
+		"metadata:\n  module: build/old.wasm\n",
+		"runtime:\n  module: |\n    build/old.wasm\n",
+	} {
+		if _, err := replaceRuntimeModule([]byte(in), "dist/skill.wasm"); err == nil {
+			t.Errorf("expected an error for %q", in)
+		}
+	}
+}
+
+func TestReplaceRuntimeModuleFillsEmptyValue(t *testing.T) {
+	got, err := replaceRuntimeModule([]byte("runtime:\n  module:\n  entrypoint: run\n"), "dist/skill.wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "runtime:\n  module: dist/skill.wasm\n  entrypoint: run\n"; string(got) != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -1,33 +1,52 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/loqalabs/loqa-core/internal/skills/manifest"
+	skillrt "github.com/loqalabs/loqa-core/internal/skills/runtime"
 )
 
 var version = "0.1.0-dev"
 
 func main() {
 	var manifestPath string
+	var deep bool
 	validateCmd := flag.NewFlagSet("validate", flag.ExitOnError)
 	validateCmd.StringVar(&manifestPath, "file", "skill.yaml", "Path to skill manifest")
+	validateCmd.BoolVar(&deep, "deep", false, "Also compile the wasm module and check its entrypoint and host imports")
+
+	var build buildOptions
+	buildCmd := flag.NewFlagSet("build", flag.ExitOnError)
+	buildCmd.StringVar(&build.dir, "dir", ".", "Skill directory containing skill.yaml")
+	buildCmd.StringVar(&build.out, "out", "", "Output wasm path, relative to the working directory (defaults to the manifest's runtime.module)")
+	buildCmd.StringVar(&build.toolchain, "toolchain", "auto", "Compiler: auto (tinygo if installed, else go), tinygo, or go")
 
 	if len(os.Args) < 2 {
-		fmt.Fprintln(os.Stderr, "expected 'validate' or 'version'")
+		fmt.Fprintln(os.Stderr, "expected 'build', 'validate', or 'version'")
 		os.Exit(2)
 	}
 
 	switch os.Args[1] {
 	case "validate":
 		validateCmd.Parse(os.Args[2:])
-		if err := runValidate(manifestPath); err != nil {
+		if err := runValidate(manifestPath, deep); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		fmt.Println("manifest valid")
+	case "build":
+		buildCmd.Parse(os.Args[2:])
+		out, err := runBuild(context.Background(), build)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("built %s\n", out)
 	case "version":
 		fmt.Println(version)
 	default:
@@ -36,10 +55,30 @@ func main() {
 	}
 }
 
-func runValidate(path string) error {
+func runValidate(path string, deep bool) error {
 	m, err := manifest.Load(path)
 	if err != nil {
 		return err
 	}
-	return manifest.Validate(m)
+	if err := manifest.Validate(m); err != nil {
+		return err
+	}
+	if !deep {
+		return nil
+	}
+	module := m.Runtime.Module
+	if manifest.IsRemoteModule(module) {
+		return fmt.Errorf("--deep needs a local module; %s is remote", module)
+	}
+	if !filepath.IsAbs(module) {
+		module = filepath.Join(filepath.Dir(path), module)
+	}
+	wasm, err := os.ReadFile(module)
+	if err != nil {
+		return fmt.Errorf("read wasm module: %w", err)
+	}
+	if err := skillrt.ValidateModule(context.Background(), wasm, m.Runtime.Exports()...); err != nil {
+		return fmt.Errorf("%s:\n%w", module, err)
+	}
+	return nil
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+)
+
+// applyDevOverrides turns cfg into a self-contained local runtime: mock
+// STT/LLM/TTS behind the router, an in-memory event store, and the embedded
+// bus. It returns the overrides as "field=value" strings for logging.
+func applyDevOverrides(cfg *config.Config) []string {
+	cfg.STT.Enabled, cfg.STT.Mode = true, "mock"
+	cfg.LLM.Enabled, cfg.LLM.Mode = true, "mock"
+	cfg.TTS.Enabled, cfg.TTS.Mode = true, "mock"
+	cfg.Router.Enabled = true
+	cfg.EventStore.RetentionMode = "memory"
+	cfg.Bus.Embedded = true
+	server := fmt.Sprintf("nats://localhost:%d", cfg.Bus.Port)
+	cfg.Bus.Servers = []string{server}
+	return []string{
+		"stt.enabled=true", "stt.mode=mock",
+		"llm.enabled=true", "llm.mode=mock",
+		"tts.enabled=true", "tts.mode=mock",
+		"router.enabled=true",
+		"event_store.retention_mode=memory",
+		"bus.embedded=true", "bus.servers=" + server,
+	}
+}
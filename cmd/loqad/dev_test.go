@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+)
+
+func TestDevOverridesApplyBeforeValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "loqa.yaml")
+	// An exec LLM without a command and an external bus without servers do
+	// not validate on their own; dev mode replaces both.
+	data := "llm:\n  enabled: true\n  mode: exec\nbus:\n  embedded: false\n  servers: []\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Load(path); err == nil {
+		t.Fatal("expected the config to be invalid without dev overrides")
+	}
+	cfg, err := config.Load(path, func(cfg *config.Config) { applyDevOverrides(cfg) })
+	if err != nil {
+		t.Fatalf("expected dev overrides to make the config valid, got %v", err)
+	}
+	if cfg.LLM.Mode != "mock" || !cfg.Bus.Embedded {
+		t.Fatalf("expected dev overrides applied, got llm.mode=%q bus.embedded=%v", cfg.LLM.Mode, cfg.Bus.Embedded)
+	}
+}
+
+func TestDevOverridesAreValidated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "loqa.yaml")
+	// Dev mode embeds the bus, which needs a usable bus.port.
+	if err := os.WriteFile(path, []byte("bus:\n  port: 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Load(path, func(cfg *config.Config) { applyDevOverrides(cfg) }); err == nil {
+		t.Fatal("expected the overridden config to be validated")
+	}
+}
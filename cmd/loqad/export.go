@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/eventstore"
+)
+
+// runExport implements `loqad export`, which dumps one session timeline
+// from the configured event store.
+func runExport(args []string) error {
+	var (
+		configPath string
+		sessionID  string
+		out        string
+		format     string
+		scopes     string
+		redact     string
+	)
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.StringVar(&configPath, "config", "loqa.yaml", "Path to configuration file")
+	fs.StringVar(&sessionID, "session", "", "Session ID to export (required)")
+	fs.StringVar(&out, "out", "-", "Output file, or - for stdout")
+	fs.StringVar(&format, "format", "", "ndjson or json (defaults from the --out extension, else ndjson)")
+	fs.StringVar(&scopes, "scope", "", "Comma-separated privacy scopes to include (default all)")
+	fs.StringVar(&redact, "redact", "", "Comma-separated privacy scopes whose payloads are redacted")
+	fs.Parse(args)
+
+	if sessionID == "" {
+		return errors.New("--session is required")
+	}
+	if format == "" {
+		format = string(eventstore.ExportNDJSON)
+		if strings.HasSuffix(out, ".json") {
+			format = string(eventstore.ExportJSON)
+		}
+	}
+
+	ctx := context.Background()
+	store, err := openEventStore(ctx, configPath, eventstore.ReadOnly())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var opts []eventstore.ExportOption
+	if scopes != "" {
+		opts = append(opts, eventstore.WithScopes(splitList(scopes)...))
+	}
+	if redact != "" {
+		opts = append(opts, eventstore.WithRedactedScopes(splitList(redact)...))
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := store.ExportSession(ctx, sessionID, w, eventstore.ExportFormat(format), opts...); err != nil {
+		return fmt.Errorf("export session %s: %w", sessionID, err)
+	}
+	if f, ok := w.(*os.File); ok && f != os.Stdout {
+		return f.Close()
+	}
+	return nil
+}
+
+// openEventStore opens the on-disk event store named by the config file for
+// offline commands. It never vacuums or prunes, so a command only changes
+// what it was asked to; opts can further restrict the store, e.g. ReadOnly.
+func openEventStore(ctx context.Context, configPath string, opts ...eventstore.OpenOption) (*eventstore.Store, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	switch cfg.EventStore.RetentionMode {
+	case "ephemeral", "memory":
+		return nil, fmt.Errorf("event_store.retention_mode %s keeps no events on disk", cfg.EventStore.RetentionMode)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	opts = append([]eventstore.OpenOption{eventstore.WithoutMaintenance()}, opts...)
+	store, err := eventstore.Open(ctx, cfg.EventStore, logger, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("open event store: %w", err)
+	}
+	return store, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+)
+
+// runForget implements `loqad forget`, which erases a session or every
+// session and event belonging to an actor.
+func runForget(args []string) error {
+	var configPath, sessionID, actorID string
+	fs := flag.NewFlagSet("forget", flag.ExitOnError)
+	fs.StringVar(&configPath, "config", "loqa.yaml", "Path to configuration file")
+	fs.StringVar(&sessionID, "session", "", "Session ID to delete")
+	fs.StringVar(&actorID, "actor", "", "Actor ID whose sessions and events are deleted")
+	fs.Parse(args)
+
+	if (sessionID == "") == (actorID == "") {
+		return errors.New("exactly one of --session or --actor is required")
+	}
+
+	ctx := context.Background()
+	store, err := openEventStore(ctx, configPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if sessionID != "" {
+		n, err := store.DeleteSession(ctx, sessionID)
+		if err != nil {
+			return fmt.Errorf("delete session %s: %w", sessionID, err)
+		}
+		fmt.Printf("deleted session %s (%d events)\n", sessionID, n)
+		return nil
+	}
+	n, err := store.DeleteActor(ctx, actorID)
+	if err != nil {
+		return fmt.Errorf("delete actor %s: %w", actorID, err)
+	}
+	fmt.Printf("deleted %d events for actor %s\n", n, actorID)
+	return nil
+}
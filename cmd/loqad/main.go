@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -17,14 +19,34 @@ import (
 
 var version = "0.1.0-dev"
 
+// subcommands are one-shot commands run instead of the runtime; without one,
+// loqad runs the runtime.
+var subcommands = map[string]func(args []string) error{
+	"export":     runExport,
+	"forget":     runForget,
+	"transcribe": runTranscribe,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	var (
 		configPath  string
 		showVersion bool
+		dev         bool
 	)
 
 	flag.StringVar(&configPath, "config", "loqa.yaml", "Path to configuration file")
 	flag.BoolVar(&showVersion, "version", false, "Print version and exit")
+	flag.BoolVar(&dev, "dev", false, "Run mock STT/LLM/TTS with an in-memory store and embedded NATS, whatever the config says")
 	flag.Parse()
 
 	if showVersion {
@@ -32,13 +54,33 @@ func main() {
 		return
 	}
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	logLevel := new(slog.LevelVar)
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
 
-	cfg, err := config.Load(configPath)
+	// In dev mode a missing default config file is fine: the built-in
+	// defaults plus the dev overrides make a complete runtime.
+	if dev && !flagSet("config") {
+		if _, err := os.Stat(configPath); errors.Is(err, fs.ErrNotExist) {
+			configPath = ""
+		}
+	}
+	// Dev overrides go through Load so they are validated with the rest of
+	// the config and can replace settings that would not validate alone.
+	var overrides []func(*config.Config)
+	var devOverrides []string
+	if dev {
+		overrides = append(overrides, func(cfg *config.Config) { devOverrides = applyDevOverrides(cfg) })
+	}
+	cfg, err := config.Load(configPath, overrides...)
 	if err != nil {
 		logger.Error("failed to load config", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
+	if dev {
+		logger.Warn("dev mode: overriding config with mock backends, in-memory event store and embedded NATS",
+			slog.String("config", configPath),
+			slog.Any("overrides", devOverrides))
+	}
 
 	// Start embedded NATS server if configured
 	natsServer, err := natsserver.Start(cfg.Bus, logger)
@@ -50,11 +92,31 @@ func main() {
 		defer natsServer.Shutdown()
 	}
 
-	rt := runtime.New(cfg, logger)
+	rt := runtime.New(cfg, version, logger, runtime.WithLogLevel(logLevel))
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// SIGHUP re-reads the config file and applies the live-reloadable subset.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				reloaded, err := config.Load(configPath, overrides...)
+				if err != nil {
+					logger.Error("config reload failed; keeping current settings", slog.String("error", err.Error()))
+					continue
+				}
+				rt.Reload(reloaded)
+			}
+		}
+	}()
+
 	if err := rt.Start(ctx); err != nil {
 		logger.Error("runtime exited with error", slog.String("error", err.Error()))
 		time.Sleep(1 * time.Second)
@@ -63,3 +125,14 @@ func main() {
 
 	logger.Info("shutdown complete")
 }
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
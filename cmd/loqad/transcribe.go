@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/loqalabs/loqa-core/internal/bus"
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/loqalabs/loqa-core/internal/stt"
+	"github.com/nats-io/nats.go"
+)
+
+// transcribeOutput receives the transcripts printed by `loqad transcribe`.
+var transcribeOutput io.Writer = os.Stdout
+
+// runTranscribe implements `loqad transcribe`, which feeds recordings through
+// a running runtime's STT service and prints the final transcripts. Frames
+// are published at the pace they would be captured, frame-ms apart.
+func runTranscribe(args []string) error {
+	var (
+		configPath  string
+		file        string
+		sessionID   string
+		frameMS     int
+		rawRate     int
+		rawChannels int
+		timeout     time.Duration
+	)
+	fs := flag.NewFlagSet("transcribe", flag.ExitOnError)
+	fs.StringVar(&configPath, "config", "loqa.yaml", "Path to configuration file")
+	fs.StringVar(&file, "file", "", "Recording to transcribe; further files may follow as arguments")
+	fs.StringVar(&sessionID, "session", "", "Session ID (single file only; defaults to the file name)")
+	fs.IntVar(&frameMS, "frame-ms", 100, "Audio frame length in milliseconds")
+	fs.IntVar(&rawRate, "raw-rate", 0, "Treat input as raw 16-bit PCM at this sample rate instead of WAV")
+	fs.IntVar(&rawChannels, "raw-channels", 1, "Channel count of raw PCM input")
+	fs.DurationVar(&timeout, "timeout", time.Minute, "How long to wait for each transcript")
+	fs.Parse(args)
+
+	files := fs.Args()
+	if file != "" {
+		files = append([]string{file}, files...)
+	}
+	if len(files) == 0 {
+		return errors.New("--file is required")
+	}
+	if sessionID != "" && len(files) > 1 {
+		return errors.New("--session applies to a single file; sessions default to file names")
+	}
+	if strings.ContainsAny(sessionID, ". \t\r\n*>") {
+		return fmt.Errorf("--session must be a single subject token, got %q", sessionID)
+	}
+	if frameMS <= 0 {
+		return errors.New("--frame-ms must be positive")
+	}
+	if rawRate < 0 {
+		return errors.New("--raw-rate must be >= 0")
+	}
+	if rawRate > 0 && rawChannels <= 0 {
+		return errors.New("--raw-channels must be positive")
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	subjects, err := protocol.DefaultSubjects().WithOverrides(cfg.Bus.Subjects)
+	if err != nil {
+		return fmt.Errorf("invalid bus.subjects: %w", err)
+	}
+	subjects = subjects.WithPrefix(cfg.Bus.SubjectPrefix)
+	if cfg.STT.SampleRate <= 0 || cfg.STT.Channels <= 0 {
+		return errors.New("stt.sample_rate and stt.channels must be positive")
+	}
+
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	busCfg := cfg.Bus
+	busCfg.ClientName = bus.ClientName(cfg) + " transcribe"
+	client, err := bus.Connect(ctx, busCfg, logger)
+	if err != nil {
+		return fmt.Errorf("connect to message bus: %w", err)
+	}
+	defer client.Close()
+
+	transcripts := make(chan protocol.Transcript, 16)
+	sub, err := client.Conn().Subscribe(subjects.TranscriptFinal, func(msg *nats.Msg) {
+		var transcript protocol.Transcript
+		if err := client.Codec().Unmarshal(msg.Data, &transcript); err == nil {
+			transcripts <- transcript
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("subscribe to transcripts: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	for _, path := range files {
+		session := sessionID
+		if session == "" {
+			session = sessionFromPath(path)
+		}
+		pcm, err := readRecording(path, rawRate, rawChannels, cfg.STT.SampleRate, cfg.STT.Channels)
+		if err != nil {
+			return err
+		}
+		if err := publishFrames(client, subjects.AudioFramePrefix+"."+session, stt.FramesFromPCM(session, pcm, cfg.STT.SampleRate, cfg.STT.Channels, frameMS), frameMS); err != nil {
+			return fmt.Errorf("%s: publish audio: %w", path, err)
+		}
+		text, err := awaitTranscript(transcripts, session, timeout)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if len(files) > 1 {
+			fmt.Fprintf(transcribeOutput, "%s\t%s\n", path, text)
+		} else {
+			fmt.Fprintln(transcribeOutput, text)
+		}
+	}
+	return nil
+}
+
+// publishFrames publishes frames frameMS apart, as a microphone would, so
+// the STT service sees a realistic stream rather than a burst.
+func publishFrames(client *bus.Client, subject string, frames []protocol.AudioFrame, frameMS int) error {
+	ticker := time.NewTicker(time.Duration(frameMS) * time.Millisecond)
+	defer ticker.Stop()
+	for i, frame := range frames {
+		if i > 0 {
+			<-ticker.C
+		}
+		frame.V = protocol.SchemaVersion
+		if err := stt.PublishAudioFrame(client, subject, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionFromPath derives a session ID from a recording's file name,
+// replacing anything but letters, digits, '-' and '_' so the ID is a valid
+// subject token.
+func sessionFromPath(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if name == "" {
+		return "transcribe"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// readRecording loads path as WAV, or as raw 16-bit PCM when rawRate is set,
+// converted to the STT service's sample rate and channel count.
+func readRecording(path string, rawRate, rawChannels, sampleRate, channels int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if rawRate > 0 {
+		pcm, err := io.ReadAll(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return stt.ConvertPCM(pcm, rawRate, rawChannels, sampleRate, channels), nil
+	}
+	pcm, err := stt.ReadWAV(f, sampleRate, channels)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pcm, nil
+}
+
+func awaitTranscript(transcripts <-chan protocol.Transcript, session string, timeout time.Duration) (string, error) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case transcript := <-transcripts:
+			if transcript.SessionID == session {
+				return transcript.Text, nil
+			}
+		case <-deadline:
+			return "", fmt.Errorf("no transcript for session %s within %s (is a runtime with stt.enabled on the bus?)", session, timeout)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/loqalabs/loqa-core/internal/stt"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+)
+
+func TestRunTranscribePublishesRawRecording(t *testing.T) {
+	ns := testutil.StartServer(t, nil)
+	sttCfg := config.STTConfig{Enabled: true, SampleRate: 16000, Channels: 1, FrameDurationMS: 20}
+	svc := stt.NewService(context.Background(), sttCfg, testutil.Connect(t, ns, config.BusConfig{}), protocol.DefaultSubjects(), stt.NewMockRecognizer())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start stt service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "loqa.yaml")
+	cfg := fmt.Sprintf("bus:\n  servers: [%q]\nstt:\n  sample_rate: 16000\n  channels: 1\n", ns.ClientURL())
+	if err := os.WriteFile(configPath, []byte(cfg), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	// 100ms of 16 kHz mono audio, under a name that is not a subject token.
+	recording := filepath.Join(dir, "kitchen mic.v2.raw")
+	if err := os.WriteFile(recording, make([]byte, 3200), 0o644); err != nil {
+		t.Fatalf("write recording: %v", err)
+	}
+
+	var out bytes.Buffer
+	transcribeOutput = &out
+	t.Cleanup(func() { transcribeOutput = os.Stdout })
+	err := runTranscribe([]string{"--config", configPath, "--file", recording, "--raw-rate", "16000", "--frame-ms", "20", "--timeout", "5s"})
+	if err != nil {
+		t.Fatalf("transcribe: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "[final transcript length=3200]" {
+		t.Fatalf("unexpected transcript output %q", got)
+	}
+}
+
+func TestRunTranscribeRejectsInvalidInput(t *testing.T) {
+	cases := [][]string{
+		{"--file", "a.raw", "--raw-rate", "16000", "--raw-channels", "0"},
+		{"--file", "a.raw", "--raw-rate", "-1"},
+		{"--file", "a.raw", "--frame-ms", "0"},
+		{"--file", "a.raw", "--session", "kitchen.mic"},
+	}
+	for _, args := range cases {
+		if err := runTranscribe(args); err == nil {
+			t.Errorf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestSessionFromPath(t *testing.T) {
+	cases := map[string]string{
+		"/tmp/kitchen.wav":        "kitchen",
+		"/tmp/kitchen mic.v2.raw": "kitchen_mic_v2",
+		"rec-01_a>*.wav":          "rec-01_a__",
+		".wav":                    "transcribe",
+	}
+	for path, want := range cases {
+		if got := sessionFromPath(path); got != want {
+			t.Errorf("sessionFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -10,35 +10,52 @@ import (
 	"time"
 
 	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/protocol"
 	"github.com/nats-io/nats.go"
 )
 
 // Client wraps NATS connection and JetStream context with minimal helpers.
 type Client struct {
-	conn *nats.Conn
-	js   nats.JetStreamContext
-	log  *slog.Logger
+	conn     *nats.Conn
+	js       nats.JetStreamContext
+	codec    protocol.Codec
+	rawAudio bool
+	log      *slog.Logger
+	// pendingMsgs and pendingBytes are applied to every Subscribe; 0 keeps
+	// the library default.
+	pendingMsgs  int
+	pendingBytes int
 }
 
 func Connect(ctx context.Context, cfg config.BusConfig, log *slog.Logger) (*Client, error) {
 	if len(cfg.Servers) == 0 {
 		return nil, errors.New("no NATS servers configured")
 	}
+	codec, err := protocol.CodecByName(cfg.Codec)
+	if err != nil {
+		return nil, err
+	}
 
+	name := cfg.ClientName
+	if name == "" {
+		name = "loqa-runtime"
+	}
 	options := []nats.Option{
-		nats.Name("loqa-runtime"),
+		nats.Name(name),
 		nats.Timeout(time.Duration(cfg.ConnectTimeout) * time.Millisecond),
 	}
 
-	if cfg.Username != "" || cfg.Password != "" {
-		options = append(options, nats.UserInfo(cfg.Username, cfg.Password))
+	auth, err := authOption(cfg)
+	if err != nil {
+		return nil, err
 	}
-	if cfg.Token != "" {
-		options = append(options, nats.Token(cfg.Token))
+	if auth != nil {
+		options = append(options, auth)
 	}
 	if cfg.TLSInsecure {
 		options = append(options, nats.Secure(&tls.Config{InsecureSkipVerify: true}))
 	}
+	options = append(options, nats.ErrorHandler(asyncErrorHandler(log)))
 
 	url := strings.Join(cfg.Servers, ",")
 	conn, err := nats.Connect(url, options...)
@@ -52,15 +69,61 @@ func Connect(ctx context.Context, cfg config.BusConfig, log *slog.Logger) (*Clie
 		return nil, fmt.Errorf("create jetstream context: %w", err)
 	}
 
-	log.Info("connected to NATS", slog.String("servers", url))
+	log.Info("connected to NATS", slog.String("servers", url), slog.String("name", name), slog.String("codec", codec.Name()))
 
 	return &Client{
-		conn: conn,
-		js:   js,
-		log:  log,
+		conn:         conn,
+		js:           js,
+		codec:        codec,
+		rawAudio:     cfg.RawAudio,
+		log:          log,
+		pendingMsgs:  cfg.PendingMsgs,
+		pendingBytes: cfg.PendingBytes,
 	}, nil
 }
 
+// ClientName returns the NATS connection name for cfg: bus.client_name when
+// set, otherwise "loqa-<node.id>" tagged with the runtime name and
+// environment, e.g. "loqa-kitchen (loqa-runtime, production)". NATS has no
+// per-connection metadata, so the tags travel in the name.
+func ClientName(cfg config.Config) string {
+	if cfg.Bus.ClientName != "" {
+		return cfg.Bus.ClientName
+	}
+	name := "loqa-" + cfg.Node.ID
+	var tags []string
+	for _, tag := range []string{cfg.RuntimeName, cfg.Environment} {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > 0 {
+		name += " (" + strings.Join(tags, ", ") + ")"
+	}
+	return name
+}
+
+// authOption returns the connect option for the configured auth method, or
+// nil when the server needs no credentials. Config validation ensures at
+// most one method is set.
+func authOption(cfg config.BusConfig) (nats.Option, error) {
+	switch {
+	case cfg.CredsFile != "":
+		return nats.UserCredentials(cfg.CredsFile), nil
+	case cfg.NKeySeedFile != "":
+		opt, err := nats.NkeyOptionFromSeed(cfg.NKeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("load nkey seed: %w", err)
+		}
+		return opt, nil
+	case cfg.Token != "":
+		return nats.Token(cfg.Token), nil
+	case cfg.Username != "" || cfg.Password != "":
+		return nats.UserInfo(cfg.Username, cfg.Password), nil
+	}
+	return nil, nil
+}
+
 func (c *Client) Close() {
 	if c == nil {
 		return
@@ -82,6 +145,20 @@ func (c *Client) Conn() *nats.Conn {
 	return c.conn
 }
 
+// Codec returns the payload codec selected by bus.codec, defaulting to JSON.
+func (c *Client) Codec() protocol.Codec {
+	if c == nil || c.codec == nil {
+		return protocol.JSON
+	}
+	return c.codec
+}
+
+// RawAudio reports whether audio should be published as raw PCM with header
+// metadata (bus.raw_audio) instead of a codec-encoded payload.
+func (c *Client) RawAudio() bool {
+	return c != nil && c.rawAudio
+}
+
 func (c *Client) Logger() *slog.Logger {
 	return c.log
 }
@@ -0,0 +1,208 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+func applyOption(t *testing.T, opt nats.Option) nats.Options {
+	t.Helper()
+	var opts nats.Options
+	if opt != nil {
+		if err := opt(&opts); err != nil {
+			t.Fatalf("apply option: %v", err)
+		}
+	}
+	return opts
+}
+
+func TestAuthOptionSelection(t *testing.T) {
+	user, err := nkeys.CreateUser()
+	if err != nil {
+		t.Fatalf("create nkey: %v", err)
+	}
+	seed, _ := user.Seed()
+	pub, _ := user.PublicKey()
+	seedFile := filepath.Join(t.TempDir(), "user.nk")
+	if err := os.WriteFile(seedFile, seed, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	credsFile := filepath.Join(t.TempDir(), "user.creds")
+	creds := "-----BEGIN NATS USER JWT-----\neyJ0eXAi.test.jwt\n------END NATS USER JWT------\n\n" +
+		"-----BEGIN USER NKEY SEED-----\n" + string(seed) + "\n------END USER NKEY SEED------\n"
+	if err := os.WriteFile(credsFile, []byte(creds), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opt, err := authOption(config.BusConfig{CredsFile: credsFile})
+	if err != nil {
+		t.Fatalf("creds option: %v", err)
+	}
+	o := applyOption(t, opt)
+	if o.UserJWT == nil || o.SignatureCB == nil || o.Nkey != "" {
+		t.Fatalf("expected user JWT callbacks for creds file, got %+v", o)
+	}
+	if jwt, err := o.UserJWT(); err != nil || jwt != "eyJ0eXAi.test.jwt" {
+		t.Fatalf("expected JWT from creds file, got %q, %v", jwt, err)
+	}
+
+	opt, err = authOption(config.BusConfig{NKeySeedFile: seedFile})
+	if err != nil {
+		t.Fatalf("nkey option: %v", err)
+	}
+	if o := applyOption(t, opt); o.Nkey != pub || o.SignatureCB == nil || o.UserJWT != nil {
+		t.Fatalf("expected nkey %s, got %+v", pub, o)
+	}
+
+	opt, _ = authOption(config.BusConfig{Token: "secret"})
+	if o := applyOption(t, opt); o.Token != "secret" {
+		t.Fatalf("expected token auth, got %+v", o)
+	}
+	opt, _ = authOption(config.BusConfig{Username: "loqa", Password: "pw"})
+	if o := applyOption(t, opt); o.User != "loqa" || o.Password != "pw" {
+		t.Fatalf("expected user/password auth, got %+v", o)
+	}
+	if opt, err := authOption(config.BusConfig{}); opt != nil || err != nil {
+		t.Fatalf("expected no auth option, got %v, %v", opt, err)
+	}
+	if _, err := authOption(config.BusConfig{NKeySeedFile: filepath.Join(t.TempDir(), "missing.nk")}); err == nil {
+		t.Fatal("expected missing seed file to fail")
+	}
+}
+
+func TestClientName(t *testing.T) {
+	cfg := config.Config{RuntimeName: "loqa-runtime", Environment: "production"}
+	cfg.Node.ID = "kitchen"
+	if got := ClientName(cfg); got != "loqa-kitchen (loqa-runtime, production)" {
+		t.Fatalf("unexpected default name %q", got)
+	}
+	cfg.RuntimeName, cfg.Environment = "", ""
+	if got := ClientName(cfg); got != "loqa-kitchen" {
+		t.Fatalf("unexpected untagged name %q", got)
+	}
+	cfg.Bus.ClientName = "custom"
+	if got := ClientName(cfg); got != "custom" {
+		t.Fatalf("expected bus.client_name override, got %q", got)
+	}
+}
+
+func TestPublishRejectsOversizedPayload(t *testing.T) {
+	ns, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true, MaxPayload: 1024})
+	if err != nil {
+		t.Fatalf("create nats server: %v", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(ns.Shutdown)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client, err := Connect(context.Background(), config.BusConfig{Servers: []string{ns.ClientURL()}, ConnectTimeout: 2000}, log)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	if client.MaxPayload() != 1024 {
+		t.Fatalf("expected max payload 1024, got %d", client.MaxPayload())
+	}
+	if err := client.Publish("test.small", make([]byte, 512)); err != nil {
+		t.Fatalf("publish within limit: %v", err)
+	}
+	if err := client.Publish("test.big", make([]byte, 2048)); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("expected ErrPayloadTooLarge, got %v", err)
+	}
+	msg := nats.NewMsg("test.headers")
+	msg.Header.Set("Loqa-Padding", strings.Repeat("x", 600))
+	msg.Data = make([]byte, 600)
+	if err := client.PublishMsg(msg); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("expected headers to count toward the limit, got %v", err)
+	}
+}
+
+type captureHandler struct {
+	slog.Handler
+	records chan slog.Record
+}
+
+func (h captureHandler) Handle(_ context.Context, r slog.Record) error {
+	select {
+	case h.records <- r:
+	default:
+	}
+	return nil
+}
+
+func TestSubscribeAppliesPendingLimitsAndLogsSlowConsumer(t *testing.T) {
+	ns, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatalf("create nats server: %v", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(ns.Shutdown)
+	records := make(chan slog.Record, 16)
+	log := slog.New(captureHandler{Handler: slog.NewTextHandler(io.Discard, nil), records: records})
+	client, err := Connect(context.Background(), config.BusConfig{Servers: []string{ns.ClientURL()}, ConnectTimeout: 2000, PendingMsgs: 2}, log)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	release := make(chan struct{})
+	sub, err := client.Subscribe("test.frames", func(*nats.Msg) { <-release })
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	t.Cleanup(func() { close(release); _ = sub.Unsubscribe() })
+	msgs, bytes, err := sub.PendingLimits()
+	if err != nil || msgs != 2 || bytes != nats.DefaultSubPendingBytesLimit {
+		t.Fatalf("expected limits 2/%d, got %d/%d (%v)", nats.DefaultSubPendingBytesLimit, msgs, bytes, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := client.Publish("test.frames", []byte("pcm")); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	if err := client.Conn().Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case r := <-records:
+			if !strings.Contains(r.Message, "slow consumer") {
+				continue
+			}
+			var subject string
+			r.Attrs(func(a slog.Attr) bool {
+				if a.Key == "subject" {
+					subject = a.Value.String()
+				}
+				return true
+			})
+			if subject != "test.frames" {
+				t.Fatalf("expected slow consumer on test.frames, got %q", subject)
+			}
+			return
+		case <-timeout:
+			t.Fatal("slow consumer was not logged")
+		}
+	}
+}
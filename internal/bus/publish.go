@@ -0,0 +1,62 @@
+package bus
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ErrPayloadTooLarge reports a message bigger than the server's max_payload.
+// NATS rejects such messages (by default anything over 1MB), so they are
+// caught before publishing instead of surfacing as a generic publish error.
+var ErrPayloadTooLarge = errors.New("payload exceeds server max_payload")
+
+// MaxPayload returns the largest message, headers included, the connected
+// server accepts.
+func (c *Client) MaxPayload() int64 {
+	return c.conn.MaxPayload()
+}
+
+// Publish sends data on subject, failing with ErrPayloadTooLarge when it
+// cannot fit in a single message.
+func (c *Client) Publish(subject string, data []byte) error {
+	return c.PublishMsg(&nats.Msg{Subject: subject, Data: data})
+}
+
+// PublishMsg is Publish for messages with headers.
+func (c *Client) PublishMsg(msg *nats.Msg) error {
+	size := int64(len(msg.Data) + headerSize(msg.Header))
+	if max := c.conn.MaxPayload(); max > 0 && size > max {
+		return c.tooLarge(msg.Subject, size, max)
+	}
+	err := c.conn.PublishMsg(msg)
+	if errors.Is(err, nats.ErrMaxPayload) {
+		return c.tooLarge(msg.Subject, size, c.conn.MaxPayload())
+	}
+	return err
+}
+
+func (c *Client) tooLarge(subject string, size, max int64) error {
+	c.log.Error("bus message exceeds server max_payload",
+		slog.String("subject", subject),
+		slog.Int64("bytes", size),
+		slog.Int64("max_payload", max))
+	return fmt.Errorf("%w: %s is %d bytes (max %d)", ErrPayloadTooLarge, subject, size, max)
+}
+
+// headerSize approximates the wire size of h: the NATS/1.0 status line plus
+// one "key: value" line per value and the terminating blank line.
+func headerSize(h nats.Header) int {
+	if len(h) == 0 {
+		return 0
+	}
+	size := len("NATS/1.0\r\n") + len("\r\n")
+	for key, values := range h {
+		for _, v := range values {
+			size += len(key) + len(": ") + len(v) + len("\r\n")
+		}
+	}
+	return size
+}
@@ -0,0 +1,76 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultRequestTimeout bounds Request when ctx carries no deadline, so a
+// request nobody answers cannot block forever.
+const DefaultRequestTimeout = 5 * time.Second
+
+// HeaderError carries a responder's error message on an otherwise empty
+// reply; Request turns it back into an error.
+const HeaderError = "Loqa-Error"
+
+var (
+	// ErrNoResponders reports a request sent to a subject nobody serves.
+	ErrNoResponders = errors.New("no responders")
+	// ErrRemote wraps an error returned by the handler that answered a
+	// request.
+	ErrRemote = errors.New("responder failed")
+)
+
+// ReplyHandler answers one request. A returned error is sent back to the
+// requester in place of a reply.
+type ReplyHandler func(data []byte) ([]byte, error)
+
+// Request sends data on subject and waits for a single reply, until ctx is
+// done or, without a deadline, DefaultRequestTimeout.
+func (c *Client) Request(ctx context.Context, subject string, data []byte) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRequestTimeout)
+		defer cancel()
+	}
+	size := int64(len(data))
+	if max := c.conn.MaxPayload(); max > 0 && size > max {
+		return nil, c.tooLarge(subject, size, max)
+	}
+	msg, err := c.conn.RequestWithContext(ctx, subject, data)
+	switch {
+	case errors.Is(err, nats.ErrNoResponders):
+		return nil, fmt.Errorf("request %s: %w", subject, ErrNoResponders)
+	case err != nil:
+		return nil, fmt.Errorf("request %s: %w", subject, err)
+	}
+	if remote := msg.Header.Get(HeaderError); remote != "" {
+		return nil, fmt.Errorf("request %s: %w: %s", subject, ErrRemote, remote)
+	}
+	return msg.Data, nil
+}
+
+// RespondTo serves requests on subject with handler until the returned
+// subscription is drained. Messages without a reply subject are ignored.
+func (c *Client) RespondTo(subject string, handler ReplyHandler) (*nats.Subscription, error) {
+	return c.Subscribe(subject, func(msg *nats.Msg) {
+		if msg.Reply == "" {
+			return
+		}
+		reply := nats.NewMsg(msg.Reply)
+		data, err := handler(msg.Data)
+		if err != nil {
+			reply.Header.Set(HeaderError, err.Error())
+		} else {
+			reply.Data = data
+		}
+		if err := c.PublishMsg(reply); err != nil {
+			c.log.Warn("failed to send reply", slog.String("subject", subject), slog.String("error", err.Error()))
+		}
+	})
+}
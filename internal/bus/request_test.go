@@ -0,0 +1,58 @@
+package bus_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/bus"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+)
+
+func TestRequestReplyRoundTrip(t *testing.T) {
+	client := testutil.StartBus(t)
+
+	sub, err := client.RespondTo("test.upper", func(data []byte) ([]byte, error) {
+		if len(data) == 0 {
+			return nil, errors.New("empty request")
+		}
+		return []byte(strings.ToUpper(string(data))), nil
+	})
+	if err != nil {
+		t.Fatalf("respond to: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	reply, err := client.Request(ctx, "test.upper", []byte("hello"))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if string(reply) != "HELLO" {
+		t.Fatalf("expected HELLO, got %q", reply)
+	}
+
+	if _, err := client.Request(ctx, "test.upper", nil); !errors.Is(err, bus.ErrRemote) || !strings.Contains(err.Error(), "empty request") {
+		t.Fatalf("expected remote error, got %v", err)
+	}
+	if _, err := client.Request(ctx, "test.nobody", []byte("x")); !errors.Is(err, bus.ErrNoResponders) {
+		t.Fatalf("expected ErrNoResponders, got %v", err)
+	}
+
+	slow, err := client.RespondTo("test.slow", func([]byte) ([]byte, error) {
+		time.Sleep(500 * time.Millisecond)
+		return []byte("late"), nil
+	})
+	if err != nil {
+		t.Fatalf("respond to: %v", err)
+	}
+	t.Cleanup(func() { _ = slow.Unsubscribe() })
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	if _, err := client.Request(short, "test.slow", []byte("x")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package bus
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Subscribe is Conn().Subscribe with the configured bus.pending_msgs and
+// bus.pending_bytes applied, so bursts of audio frames queue instead of
+// being dropped at the library's default limits.
+func (c *Client) Subscribe(subject string, handler nats.MsgHandler) (*nats.Subscription, error) {
+	sub, err := c.conn.Subscribe(subject, handler)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.applyPendingLimits(sub); err != nil {
+		_ = sub.Unsubscribe()
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (c *Client) applyPendingLimits(sub *nats.Subscription) error {
+	if c.pendingMsgs == 0 && c.pendingBytes == 0 {
+		return nil
+	}
+	msgs, bytes, err := sub.PendingLimits()
+	if err != nil {
+		return err
+	}
+	if c.pendingMsgs != 0 {
+		msgs = c.pendingMsgs
+	}
+	if c.pendingBytes != 0 {
+		bytes = c.pendingBytes
+	}
+	return sub.SetPendingLimits(msgs, bytes)
+}
+
+// asyncErrorHandler logs errors NATS reports outside any call, most
+// importantly slow consumers: a subscription whose pending buffer overflowed
+// and is now dropping messages. NATS reports each slow-consumer episode once.
+func asyncErrorHandler(log *slog.Logger) nats.ErrHandler {
+	return func(_ *nats.Conn, sub *nats.Subscription, err error) {
+		if sub == nil {
+			log.Warn("nats async error", slog.String("error", err.Error()))
+			return
+		}
+		attrs := []any{slog.String("subject", sub.Subject), slog.String("error", err.Error())}
+		if dropped, derr := sub.Dropped(); derr == nil {
+			attrs = append(attrs, slog.Int("dropped", dropped))
+		}
+		if msgs, bytes, perr := sub.Pending(); perr == nil {
+			attrs = append(attrs, slog.Int("pending_msgs", msgs), slog.Int("pending_bytes", bytes))
+		}
+		if errors.Is(err, nats.ErrSlowConsumer) {
+			log.Warn("slow consumer dropping messages; raise bus.pending_msgs/pending_bytes or add capacity", attrs...)
+			return
+		}
+		log.Warn("nats subscription error", attrs...)
+	}
+}
@@ -0,0 +1,147 @@
+package capability
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// QueryWindow is how long RemoteQuery collects replies when ctx allows
+// longer. Every registry answers for its own node only, so a query has to
+// gather replies rather than trust one registry's view of its peers.
+var QueryWindow = 250 * time.Millisecond
+
+// QueryRequest is the payload of a capability query. Empty fields match
+// every node, so an empty request lists the whole registry.
+type QueryRequest struct {
+	Capability  string            `json:"capability,omitempty"`
+	Tier        string            `json:"tier,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	HealthyOnly bool              `json:"healthy_only,omitempty"`
+}
+
+// QueryResponse is one registry's reply to a QueryRequest: its own node
+// when it matches, otherwise no nodes.
+type QueryResponse struct {
+	Nodes []NodeInfo `json:"nodes"`
+	Error string     `json:"error,omitempty"`
+}
+
+// Filter returns the predicate equivalent to q. Attributes apply to the
+// requested capability, or to any capability when none is named.
+func (q QueryRequest) Filter() func(NodeInfo) bool {
+	filters := []func(NodeInfo) bool{}
+	if q.HealthyOnly {
+		filters = append(filters, func(node NodeInfo) bool { return node.Healthy })
+	}
+	if q.Capability != "" {
+		filters = append(filters, WithCapabilityFilter(q.Capability))
+	}
+	if q.Tier != "" {
+		filters = append(filters, WithTierFilter(q.Tier))
+	}
+	for key, value := range q.Attributes {
+		key, value := key, value
+		if q.Capability != "" {
+			filters = append(filters, WithAttributeFilter(q.Capability, key, value))
+			continue
+		}
+		filters = append(filters, func(node NodeInfo) bool {
+			for _, cap := range node.Capabilities {
+				if v, ok := cap.Attributes[key]; ok && v == value {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	return And(filters...)
+}
+
+// handleQuery answers a capability query with the local node when it
+// matches, and an empty list otherwise, so the asker can tell a registry is
+// listening.
+func (r *Registry) handleQuery(msg *nats.Msg) {
+	if msg.Reply == "" {
+		return
+	}
+	var resp QueryResponse
+	var req QueryRequest
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			resp.Error = fmt.Sprintf("invalid query: %v", err)
+		}
+	}
+	if resp.Error == "" {
+		filter := req.Filter()
+		resp.Nodes = r.Query(func(node NodeInfo) bool { return node.ID == r.cfg.ID && filter(node) })
+	}
+	if resp.Nodes == nil {
+		resp.Nodes = []NodeInfo{}
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		r.log.Warn("failed to encode capability query response", slog.String("error", err.Error()))
+		return
+	}
+	if err := msg.Respond(data); err != nil {
+		r.log.Warn("failed to respond to capability query", slog.String("error", err.Error()))
+	}
+}
+
+// RemoteQuery asks every registry on the bus for the nodes matching req and
+// merges the answers, sorted by ID. Replies are collected for QueryWindow or
+// until ctx ends; an error is returned when no registry answers. subject is
+// the runtime's capability query subject (protocol.Subjects.CapabilityQuery).
+func RemoteQuery(ctx context.Context, conn *nats.Conn, subject string, req QueryRequest) ([]NodeInfo, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encode query: %w", err)
+	}
+	inbox := conn.NewRespInbox()
+	sub, err := conn.SubscribeSync(inbox)
+	if err != nil {
+		return nil, fmt.Errorf("capability query: %w", err)
+	}
+	defer sub.Unsubscribe()
+	if err := conn.PublishRequest(subject, inbox, data); err != nil {
+		return nil, fmt.Errorf("capability query: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryWindow)
+	defer cancel()
+	replies := 0
+	byID := make(map[string]NodeInfo)
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			break
+		}
+		var resp QueryResponse
+		if err := json.Unmarshal(msg.Data, &resp); err != nil {
+			return nil, fmt.Errorf("decode query response: %w", err)
+		}
+		if resp.Error != "" {
+			return nil, errors.New(resp.Error)
+		}
+		replies++
+		for _, node := range resp.Nodes {
+			byID[node.ID] = node
+		}
+	}
+	if replies == 0 {
+		return nil, fmt.Errorf("capability query: %w", nats.ErrNoResponders)
+	}
+	nodes := make([]NodeInfo, 0, len(byID))
+	for _, node := range byID {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
@@ -5,11 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"reflect"
+	goruntime "runtime"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/loqalabs/loqa-core/internal/bus"
 	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/protocol"
 	"github.com/nats-io/nats.go"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -22,49 +27,114 @@ type Capability struct {
 	Attributes map[string]string `json:"attributes,omitempty"`
 }
 
+// NodeStats is the load snapshot a node attaches to each heartbeat. Fields
+// missing from older peers decode as zero.
+type NodeStats struct {
+	InFlight    map[string]int `json:"in_flight,omitempty"`
+	MemoryBytes uint64         `json:"memory_bytes,omitempty"`
+	Goroutines  int            `json:"goroutines,omitempty"`
+	Draining    bool           `json:"draining,omitempty"`
+}
+
+// Load returns the total in-flight work across services.
+func (s NodeStats) Load() int {
+	total := 0
+	for _, n := range s.InFlight {
+		total += n
+	}
+	return total
+}
+
 type NodeInfo struct {
 	ID           string       `json:"id"`
+	InstanceID   string       `json:"instance_id,omitempty"`
 	Role         string       `json:"role"`
 	Capabilities []Capability `json:"capabilities"`
+	Stats        NodeStats    `json:"stats"`
 	LastSeen     time.Time    `json:"last_seen"`
 	Healthy      bool         `json:"healthy"`
+	// MissedHeartbeats counts consecutive heartbeat intervals the node has
+	// been silent past the heartbeat timeout. Any message resets it.
+	MissedHeartbeats int `json:"missed_heartbeats,omitempty"`
+
+	lastMiss time.Time
 }
 
+// InstanceID in announce and heartbeat messages is a per-process token
+// that distinguishes two runtimes misconfigured with the same node.id.
 type announceMessage struct {
 	NodeID       string       `json:"node_id"`
+	InstanceID   string       `json:"instance_id,omitempty"`
 	Role         string       `json:"role"`
 	Capabilities []Capability `json:"capabilities"`
-	Timestamp    time.Time    `json:"timestamp"`
+	Draining     bool         `json:"draining,omitempty"`
+	// Resync asks every peer to re-announce, so the sender learns their
+	// capabilities without waiting for the next change.
+	Resync    bool      `json:"resync,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 type heartbeatMessage struct {
-	NodeID    string    `json:"node_id"`
-	Timestamp time.Time `json:"timestamp"`
+	NodeID     string    `json:"node_id"`
+	InstanceID string    `json:"instance_id,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+	Stats      NodeStats `json:"stats"`
 }
 
 type Registry struct {
-	cfg       config.NodeConfig
-	log       *slog.Logger
-	bus       *bus.Client
-	mu        sync.RWMutex
-	nodes     map[string]*NodeInfo
-	heartbeat *time.Ticker
-	cancel    context.CancelFunc
-	subs      []*nats.Subscription
-	meter     metric.Meter
-	nodeGauge metric.Int64ObservableGauge
-	attrGauge metric.Int64ObservableGauge
-}
-
-func NewRegistry(ctx context.Context, cfg config.NodeConfig, busClient *bus.Client, log *slog.Logger) (*Registry, error) {
+	cfg        config.NodeConfig
+	clock      func() time.Time
+	instanceID string
+	collisions map[string]struct{}
+	log        *slog.Logger
+	bus        *bus.Client
+	subjects   protocol.Subjects
+	mu         sync.RWMutex
+	nodes      map[string]*NodeInfo
+	local      []Capability
+	loads      map[string]func() int
+	draining   bool
+	announceMu sync.Mutex
+	heartbeat  *time.Ticker
+	converged  chan struct{}
+	cancel     context.CancelFunc
+	subs       []*nats.Subscription
+	watchers   watchers
+	meter      metric.Meter
+	nodeGauge  metric.Int64ObservableGauge
+	attrGauge  metric.Int64ObservableGauge
+}
+
+// Option customizes a Registry created by NewRegistry.
+type Option func(*Registry)
+
+// WithClock replaces time.Now as the registry's source of the current time,
+// used for message timestamps and health evaluation.
+func WithClock(clock func() time.Time) Option {
+	return func(r *Registry) {
+		r.clock = clock
+	}
+}
+
+func NewRegistry(ctx context.Context, cfg config.NodeConfig, busClient *bus.Client, subjects protocol.Subjects, log *slog.Logger, opts ...Option) (*Registry, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	r := &Registry{
-		cfg:    cfg,
-		log:    log.With(slog.String("component", "capability-registry")),
-		bus:    busClient,
-		nodes:  make(map[string]*NodeInfo),
-		meter:  otel.Meter("github.com/loqalabs/loqa-core/runtime"),
-		cancel: cancel,
+		cfg:        cfg,
+		clock:      time.Now,
+		instanceID: uuid.NewString(),
+		collisions: make(map[string]struct{}),
+		log:        log.With(slog.String("component", "capability-registry")),
+		bus:        busClient,
+		subjects:   subjects,
+		nodes:      make(map[string]*NodeInfo),
+		local:      convertCapabilities(cfg.Capabilities),
+		loads:      make(map[string]func() int),
+		converged:  make(chan struct{}),
+		meter:      otel.Meter("github.com/loqalabs/loqa-core/runtime"),
+		cancel:     cancel,
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
 
 	if err := r.initMetrics(ctx); err != nil {
@@ -80,13 +150,22 @@ func NewRegistry(ctx context.Context, cfg config.NodeConfig, busClient *bus.Clie
 	go r.runHeartbeat(ctx)
 	go r.monitorHealth(ctx)
 
-	if err := r.announce(); err != nil {
+	if err := r.announceResync(true); err != nil {
 		r.log.Warn("failed to announce node", slog.String("error", err.Error()))
 	}
+	time.AfterFunc(time.Duration(cfg.HeartbeatInterval)*time.Millisecond, func() { close(r.converged) })
 
 	return r, nil
 }
 
+// Converged returns a channel that is closed one heartbeat interval after
+// the registry started. By then every live peer has answered the startup
+// resync or sent a heartbeat, so a capability Query does not find is absent
+// rather than not yet heard of.
+func (r *Registry) Converged() <-chan struct{} {
+	return r.converged
+}
+
 func (r *Registry) Close() {
 	if r.cancel != nil {
 		r.cancel()
@@ -97,22 +176,29 @@ func (r *Registry) Close() {
 	for _, sub := range r.subs {
 		_ = sub.Drain()
 	}
+	r.watchers.closeAll()
 }
 
 func (r *Registry) subscribe(ctx context.Context) error {
 	conn := r.bus.Conn()
-	announceSub, err := conn.Subscribe("ctrl.node.announce", r.handleAnnounce)
+	announceSub, err := conn.Subscribe(r.subjects.NodeAnnounce, r.handleAnnounce)
 	if err != nil {
 		return fmt.Errorf("subscribe announce: %w", err)
 	}
 	r.subs = append(r.subs, announceSub)
 
-	heartbeatSub, err := conn.Subscribe("ctrl.node.heartbeat.*", r.handleHeartbeat)
+	heartbeatSub, err := conn.Subscribe(r.subjects.NodeHeartbeatPrefix+".*", r.handleHeartbeat)
 	if err != nil {
 		return fmt.Errorf("subscribe heartbeat: %w", err)
 	}
 	r.subs = append(r.subs, heartbeatSub)
 
+	querySub, err := conn.Subscribe(r.subjects.CapabilityQuery, r.handleQuery)
+	if err != nil {
+		return fmt.Errorf("subscribe capability query: %w", err)
+	}
+	r.subs = append(r.subs, querySub)
+
 	return nil
 }
 
@@ -143,35 +229,138 @@ func (r *Registry) monitorHealth(ctx context.Context) {
 	}
 }
 
+// AddLocalCapability adds c to the capabilities this node advertises and
+// re-announces the node when the set changes. Capabilities are identified by
+// name and tier; adding one that is already present is a no-op.
+func (r *Registry) AddLocalCapability(c Capability) error {
+	r.mu.Lock()
+	for _, existing := range r.local {
+		if existing.Name == c.Name && existing.Tier == c.Tier {
+			r.mu.Unlock()
+			return nil
+		}
+	}
+	r.local = append(r.local, c)
+	r.mu.Unlock()
+	return r.announce()
+}
+
+// UpdateCapabilities replaces the capabilities this node advertises and
+// broadcasts the new set on ctrl.node.announce.
+func (r *Registry) UpdateCapabilities(capabilities []Capability) error {
+	r.mu.Lock()
+	r.local = append([]Capability{}, capabilities...)
+	r.mu.Unlock()
+	return r.announce()
+}
+
+// Announce re-broadcasts the node's current capabilities, for example after
+// a peer restarts or an operator changes what the node serves.
+func (r *Registry) Announce() error {
+	return r.announce()
+}
+
+// announce broadcasts the local capability set.
 func (r *Registry) announce() error {
+	return r.announceResync(false)
+}
+
+// announceResync broadcasts the local capability set, asking peers to
+// re-announce theirs when resync is set. Announcements are serialized so the
+// local view and the broadcast order cannot diverge.
+func (r *Registry) announceResync(resync bool) error {
+	r.announceMu.Lock()
+	defer r.announceMu.Unlock()
+
+	r.mu.RLock()
+	capabilities := make([]Capability, len(r.local))
+	copy(capabilities, r.local)
+	draining := r.draining
+	r.mu.RUnlock()
 	msg := announceMessage{
 		NodeID:       r.cfg.ID,
+		InstanceID:   r.instanceID,
 		Role:         r.cfg.Role,
-		Capabilities: convertCapabilities(r.cfg.Capabilities),
-		Timestamp:    time.Now().UTC(),
+		Capabilities: capabilities,
+		Draining:     draining,
+		Resync:       resync,
+		Timestamp:    r.clock().UTC(),
 	}
 	payload, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
-	if err := r.bus.Conn().Publish("ctrl.node.announce", payload); err != nil {
+	if err := r.bus.Publish(r.subjects.NodeAnnounce, payload); err != nil {
 		return err
 	}
-	r.updateNode(msg.NodeID, msg.Role, msg.Capabilities, msg.Timestamp, true)
+	r.updateNode(msg.NodeID, msg.InstanceID, msg.Role, msg.Capabilities, nil, msg.Timestamp, true)
+	r.setNodeDraining(msg.NodeID, msg.Draining)
 	return nil
 }
 
+// SetDraining marks the local node as draining (or not) and broadcasts the
+// change immediately. Draining nodes stay alive in the registry but are
+// skipped by SelectBest so in-flight work can finish before shutdown.
+func (r *Registry) SetDraining(draining bool) error {
+	r.mu.Lock()
+	r.draining = draining
+	r.mu.Unlock()
+	if err := r.announce(); err != nil {
+		return err
+	}
+	return r.publishHeartbeat()
+}
+
+// Draining reports whether the local node is draining.
+func (r *Registry) Draining() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.draining
+}
+
+// ReportLoad registers fn as the in-flight counter for service; its value is
+// sampled into every heartbeat.
+func (r *Registry) ReportLoad(service string, fn func() int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loads[service] = fn
+}
+
+func (r *Registry) localStats() NodeStats {
+	r.mu.RLock()
+	loads := make(map[string]func() int, len(r.loads))
+	for name, fn := range r.loads {
+		loads[name] = fn
+	}
+	stats := NodeStats{Draining: r.draining}
+	r.mu.RUnlock()
+
+	if len(loads) > 0 {
+		stats.InFlight = make(map[string]int, len(loads))
+		for name, fn := range loads {
+			stats.InFlight[name] = fn()
+		}
+	}
+	var mem goruntime.MemStats
+	goruntime.ReadMemStats(&mem)
+	stats.MemoryBytes = mem.HeapAlloc
+	stats.Goroutines = goruntime.NumGoroutine()
+	return stats
+}
+
 func (r *Registry) publishHeartbeat() error {
 	msg := heartbeatMessage{
-		NodeID:    r.cfg.ID,
-		Timestamp: time.Now().UTC(),
+		NodeID:     r.cfg.ID,
+		InstanceID: r.instanceID,
+		Timestamp:  r.clock().UTC(),
+		Stats:      r.localStats(),
 	}
 	payload, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
-	subject := fmt.Sprintf("ctrl.node.heartbeat.%s", r.cfg.ID)
-	return r.bus.Conn().Publish(subject, payload)
+	subject := fmt.Sprintf("%s.%s", r.subjects.NodeHeartbeatPrefix, r.cfg.ID)
+	return r.bus.Publish(subject, payload)
 }
 
 func (r *Registry) handleAnnounce(msg *nats.Msg) {
@@ -180,10 +369,17 @@ func (r *Registry) handleAnnounce(msg *nats.Msg) {
 		r.log.Warn("invalid announce message", slog.String("error", err.Error()))
 		return
 	}
+	if r.isCollision(announcement.NodeID, announcement.InstanceID) {
+		return
+	}
 	if announcement.Timestamp.IsZero() {
-		announcement.Timestamp = time.Now().UTC()
+		announcement.Timestamp = r.clock().UTC()
+	}
+	r.updateNode(announcement.NodeID, announcement.InstanceID, announcement.Role, announcement.Capabilities, nil, announcement.Timestamp, true)
+	r.setNodeDraining(announcement.NodeID, announcement.Draining)
+	if announcement.Resync && announcement.NodeID != r.cfg.ID {
+		go r.reannounce()
 	}
-	r.updateNode(announcement.NodeID, announcement.Role, announcement.Capabilities, announcement.Timestamp, true)
 }
 
 func (r *Registry) handleHeartbeat(msg *nats.Msg) {
@@ -192,13 +388,57 @@ func (r *Registry) handleHeartbeat(msg *nats.Msg) {
 		r.log.Warn("invalid heartbeat message", slog.String("error", err.Error()))
 		return
 	}
+	if r.isCollision(hb.NodeID, hb.InstanceID) {
+		return
+	}
 	if hb.Timestamp.IsZero() {
-		hb.Timestamp = time.Now().UTC()
+		hb.Timestamp = r.clock().UTC()
+	}
+	// A heartbeat from a node not in the registry (one that started before
+	// us, or came back after expiring) carries no capabilities, so ask every
+	// peer to re-announce rather than keep an empty entry.
+	if added := r.updateNode(hb.NodeID, hb.InstanceID, "", nil, &hb.Stats, hb.Timestamp, true); added && hb.NodeID != r.cfg.ID {
+		go func() {
+			if err := r.announceResync(true); err != nil {
+				r.log.Warn("failed to request capability resync", slog.String("error", err.Error()))
+			}
+		}()
+	}
+}
+
+func (r *Registry) reannounce() {
+	if err := r.announce(); err != nil {
+		r.log.Warn("failed to re-announce node", slog.String("error", err.Error()))
 	}
-	r.updateNode(hb.NodeID, "", nil, hb.Timestamp, true)
 }
 
-func (r *Registry) updateNode(nodeID, role string, capabilities []Capability, timestamp time.Time, healthy bool) {
+// isCollision reports whether a message claiming nodeID came from another
+// process using this node's ID. The local entry is kept as-is and the first
+// message from each foreign instance is logged at error level. Messages
+// from peers that predate instance IDs are never treated as collisions.
+func (r *Registry) isCollision(nodeID, instanceID string) bool {
+	if nodeID != r.cfg.ID || instanceID == "" || instanceID == r.instanceID {
+		return false
+	}
+	r.mu.Lock()
+	_, seen := r.collisions[instanceID]
+	r.collisions[instanceID] = struct{}{}
+	r.mu.Unlock()
+	if !seen {
+		r.log.Error("node ID collision: another runtime is using this node.id; give each runtime a unique node.id",
+			slog.String("node_id", nodeID),
+			slog.String("instance_id", r.instanceID),
+			slog.String("other_instance_id", instanceID),
+		)
+	}
+	return true
+}
+
+// updateNode records a message from nodeID and reports whether the node
+// was not in the registry before. Watchers hear about joins, health
+// transitions and changed identity, role or capabilities; a heartbeat that
+// only refreshes LastSeen or load stats is not a change.
+func (r *Registry) updateNode(nodeID, instanceID, role string, capabilities []Capability, stats *NodeStats, timestamp time.Time, healthy bool) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -207,25 +447,92 @@ func (r *Registry) updateNode(nodeID, role string, capabilities []Capability, ti
 		node = &NodeInfo{ID: nodeID}
 		r.nodes[nodeID] = node
 	}
-	if role != "" {
+	kind := ChangeUpdated
+	switch {
+	case !ok:
+		kind = ChangeAdded
+	case node.Healthy != healthy:
+		kind = ChangeHealth
+	}
+	changed := kind != ChangeUpdated
+	if instanceID != "" && instanceID != node.InstanceID {
+		node.InstanceID = instanceID
+		changed = true
+	}
+	if role != "" && role != node.Role {
 		node.Role = role
+		changed = true
 	}
-	if len(capabilities) > 0 {
+	// Heartbeats pass nil and keep the last announced set; announcements
+	// always replace it, even when empty.
+	if capabilities != nil {
+		if !reflect.DeepEqual(node.Capabilities, capabilities) {
+			changed = true
+		}
 		node.Capabilities = capabilities
 	}
+	if stats != nil {
+		if stats.Draining != node.Stats.Draining {
+			changed = true
+		}
+		node.Stats = *stats
+	}
 	node.LastSeen = timestamp
 	node.Healthy = healthy
+	node.MissedHeartbeats = 0
+	node.lastMiss = time.Time{}
+	if changed {
+		r.emitLocked(kind, node)
+	}
+	return !ok
+}
+
+func (r *Registry) setNodeDraining(nodeID string, draining bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if node, ok := r.nodes[nodeID]; ok && node.Stats.Draining != draining {
+		node.Stats.Draining = draining
+		r.emitLocked(ChangeUpdated, node)
+	}
 }
 
+// evaluateHealth counts a missed heartbeat for every node that has been
+// silent longer than the heartbeat timeout, at most once per heartbeat
+// interval, and marks it unhealthy once missed_heartbeats_threshold
+// consecutive misses accumulate. Unhealthy peers silent for longer than
+// node_expiry_ms are removed.
 func (r *Registry) evaluateHealth() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	now := r.clock()
+	expiry := time.Duration(r.cfg.NodeExpiry) * time.Millisecond
+
 	timeout := time.Duration(r.cfg.HeartbeatTimeout) * time.Millisecond
-	now := time.Now()
+	interval := time.Duration(r.cfg.HeartbeatInterval) * time.Millisecond
+	threshold := r.cfg.MissedHeartbeatsThreshold
+	if threshold < 1 {
+		threshold = 1
+	}
 	for _, node := range r.nodes {
-		if now.Sub(node.LastSeen) > timeout {
+		if now.Sub(node.LastSeen) <= timeout {
+			continue
+		}
+		if node.lastMiss.IsZero() || now.Sub(node.lastMiss) >= interval {
+			node.MissedHeartbeats++
+			node.lastMiss = now
+		}
+		if node.MissedHeartbeats >= threshold && node.Healthy {
 			node.Healthy = false
+			r.emitLocked(ChangeHealth, node)
+		}
+		if expiry > 0 && !node.Healthy && node.ID != r.cfg.ID && now.Sub(node.LastSeen) > expiry {
+			delete(r.nodes, node.ID)
+			r.emitLocked(ChangeRemoved, node)
+			r.log.Info("removed expired node",
+				slog.String("node_id", node.ID),
+				slog.Time("last_seen", node.LastSeen),
+			)
 		}
 	}
 }
@@ -255,6 +562,25 @@ func (r *Registry) Query(filter func(NodeInfo) bool) []NodeInfo {
 	return results
 }
 
+// SelectBest returns the healthy, non-draining node matching filter with the
+// least in-flight work, breaking ties by node ID.
+func (r *Registry) SelectBest(filter func(NodeInfo) bool) (NodeInfo, bool) {
+	candidates := r.Query(func(node NodeInfo) bool {
+		return node.Healthy && !node.Stats.Draining && (filter == nil || filter(node))
+	})
+	if len(candidates) == 0 {
+		return NodeInfo{}, false
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		li, lj := candidates[i].Stats.Load(), candidates[j].Stats.Load()
+		if li != lj {
+			return li < lj
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+	return candidates[0], true
+}
+
 func (r *Registry) initMetrics(ctx context.Context) error {
 	if r.meter == nil {
 		return nil
@@ -338,6 +664,48 @@ func WithTierFilter(tier string) func(NodeInfo) bool {
 	}
 }
 
+// WithAttributeFilter matches nodes advertising capName with the attribute
+// attrKey set to attrValue, e.g. WithAttributeFilter("stt", "gpu", "true").
+func WithAttributeFilter(capName, attrKey, attrValue string) func(NodeInfo) bool {
+	return func(node NodeInfo) bool {
+		for _, cap := range node.Capabilities {
+			if cap.Name != capName {
+				continue
+			}
+			if v, ok := cap.Attributes[attrKey]; ok && v == attrValue {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// And matches nodes accepted by every filter. Nil filters are ignored, and
+// And() with no filters matches every node.
+func And(filters ...func(NodeInfo) bool) func(NodeInfo) bool {
+	return func(node NodeInfo) bool {
+		for _, f := range filters {
+			if f != nil && !f(node) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches nodes accepted by at least one filter. Nil filters are ignored,
+// and Or() with no filters matches nothing.
+func Or(filters ...func(NodeInfo) bool) func(NodeInfo) bool {
+	return func(node NodeInfo) bool {
+		for _, f := range filters {
+			if f != nil && f(node) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 func (c Capability) AttributesAsAttrs() []attribute.KeyValue {
 	var attrs []attribute.KeyValue
 	for k, v := range c.Attributes {
@@ -0,0 +1,677 @@
+package capability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func testNodeConfig() config.NodeConfig {
+	return config.NodeConfig{
+		ID:                "node-a",
+		Role:              "runtime",
+		HeartbeatInterval: 1000,
+		HeartbeatTimeout:  3000,
+		Capabilities:      []config.NodeCapability{{Name: "runtime.core", Tier: "balanced"}},
+	}
+}
+
+func TestAddLocalCapabilityReannounces(t *testing.T) {
+	client := testutil.StartBus(t)
+	announcements := make(chan announceMessage, 8)
+	sub, err := client.Conn().Subscribe(protocol.SubjectNodeAnnounce, func(msg *nats.Msg) {
+		var a announceMessage
+		if err := json.Unmarshal(msg.Data, &a); err == nil {
+			announcements <- a
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe announce: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	reg, err := NewRegistry(context.Background(), testNodeConfig(), client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(reg.Close)
+	<-announcements
+
+	if err := reg.AddLocalCapability(Capability{Name: "llm", Tier: "fast"}); err != nil {
+		t.Fatalf("add capability: %v", err)
+	}
+	if err := reg.AddLocalCapability(Capability{Name: "llm", Tier: "fast"}); err != nil {
+		t.Fatalf("add duplicate capability: %v", err)
+	}
+
+	select {
+	case a := <-announcements:
+		if len(a.Capabilities) != 2 || a.Capabilities[1].Name != "llm" {
+			t.Fatalf("unexpected announcement %+v", a.Capabilities)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for re-announce")
+	}
+	select {
+	case a := <-announcements:
+		t.Fatalf("duplicate capability should not re-announce, got %+v", a)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if got := reg.Query(WithCapabilityFilter("llm")); len(got) != 1 {
+		t.Fatalf("expected local node to advertise llm, got %+v", got)
+	}
+}
+
+func TestUpdateCapabilitiesConcurrentWithHeartbeats(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := testNodeConfig()
+	cfg.HeartbeatInterval = 5
+	reg, err := NewRegistry(context.Background(), cfg, client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(reg.Close)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			caps := []Capability{{Name: "tts"}, {Name: fmt.Sprintf("model-%d", i)}}
+			if err := reg.UpdateCapabilities(caps); err != nil {
+				t.Errorf("update capabilities: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := reg.UpdateCapabilities([]Capability{{Name: "stt"}}); err != nil {
+		t.Fatalf("update capabilities: %v", err)
+	}
+	if err := reg.Announce(); err != nil {
+		t.Fatalf("announce: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		local := reg.LocalCapabilities()
+		if len(local) == 1 && local[0].Name == "stt" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected only stt to be advertised, got %+v", local)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !reg.Healthy() {
+		t.Fatal("expected local node to stay healthy")
+	}
+}
+
+func TestHeartbeatStatsPropagate(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfgA := testNodeConfig()
+	cfgA.HeartbeatInterval = 20
+	cfgA.Capabilities = []config.NodeCapability{{Name: "tts"}}
+	cfgB := cfgA
+	cfgB.ID = "node-b"
+
+	a, err := NewRegistry(context.Background(), cfgA, client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry a: %v", err)
+	}
+	t.Cleanup(a.Close)
+	b, err := NewRegistry(context.Background(), cfgB, client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry b: %v", err)
+	}
+	t.Cleanup(b.Close)
+	a.ReportLoad("tts", func() int { return 3 })
+	b.ReportLoad("tts", func() int { return 1 })
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		nodes := b.Query(func(n NodeInfo) bool { return n.ID == "node-a" })
+		if len(nodes) == 1 && nodes[0].Stats.InFlight["tts"] == 3 {
+			if nodes[0].Stats.Goroutines == 0 {
+				t.Fatalf("expected goroutine count in stats, got %+v", nodes[0].Stats)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("node-a stats never reached node-b: %+v", nodes)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for {
+		best, ok := a.SelectBest(WithCapabilityFilter("tts"))
+		if ok && best.ID == "node-b" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected lightly-loaded node-b to be selected, got %+v", best)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHeartbeatWithoutStatsDecodes(t *testing.T) {
+	client := testutil.StartBus(t)
+	reg, err := NewRegistry(context.Background(), testNodeConfig(), client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(reg.Close)
+
+	legacy := []byte(`{"node_id":"old-node","timestamp":"2025-01-01T00:00:00Z"}`)
+	reg.handleHeartbeat(&nats.Msg{Data: legacy})
+	nodes := reg.Query(func(n NodeInfo) bool { return n.ID == "old-node" })
+	if len(nodes) != 1 || nodes[0].Stats.Load() != 0 || nodes[0].Stats.Draining {
+		t.Fatalf("expected zero stats for legacy heartbeat, got %+v", nodes)
+	}
+}
+
+func TestSetDrainingExcludesNodeFromSelection(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfgA := testNodeConfig()
+	cfgA.Capabilities = []config.NodeCapability{{Name: "tts"}}
+	cfgB := cfgA
+	cfgB.ID = "node-b"
+
+	a, err := NewRegistry(context.Background(), cfgA, client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry a: %v", err)
+	}
+	t.Cleanup(a.Close)
+	b, err := NewRegistry(context.Background(), cfgB, client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry b: %v", err)
+	}
+	t.Cleanup(b.Close)
+
+	if err := a.SetDraining(true); err != nil {
+		t.Fatalf("set draining: %v", err)
+	}
+	if !a.Draining() {
+		t.Fatal("expected local node to report draining")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		nodes := b.Query(func(n NodeInfo) bool { return n.ID == "node-a" })
+		if len(nodes) == 1 && nodes[0].Stats.Draining {
+			if !nodes[0].Healthy {
+				t.Fatal("draining node should still be reported alive")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("drain state never reached node-b: %+v", nodes)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		if best, ok := b.SelectBest(WithCapabilityFilter("tts")); !ok || best.ID != "node-b" {
+			t.Fatalf("expected draining node-a to be skipped, got %+v", best)
+		}
+	}
+
+	if err := a.SetDraining(false); err != nil {
+		t.Fatalf("clear draining: %v", err)
+	}
+	for {
+		nodes := b.Query(func(n NodeInfo) bool { return n.ID == "node-a" })
+		if len(nodes) == 1 && !nodes[0].Stats.Draining {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("undrain never reached node-b: %+v", nodes)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAttributeFilterAndCombinators(t *testing.T) {
+	gpuSTT := NodeInfo{ID: "gpu", Capabilities: []Capability{
+		{Name: "stt", Attributes: map[string]string{"gpu": "true"}},
+		{Name: "tts"},
+	}}
+	cpuSTT := NodeInfo{ID: "cpu", Capabilities: []Capability{
+		{Name: "stt", Attributes: map[string]string{"gpu": "false"}},
+	}}
+	// The gpu attribute belongs to llm here, not stt.
+	mixed := NodeInfo{ID: "mixed", Capabilities: []Capability{
+		{Name: "stt"},
+		{Name: "llm", Tier: "fast", Attributes: map[string]string{"gpu": "true"}},
+	}}
+	nodes := []NodeInfo{gpuSTT, cpuSTT, mixed}
+
+	match := func(filter func(NodeInfo) bool) string {
+		var ids []string
+		for _, n := range nodes {
+			if filter(n) {
+				ids = append(ids, n.ID)
+			}
+		}
+		return fmt.Sprint(ids)
+	}
+
+	cases := []struct {
+		name   string
+		filter func(NodeInfo) bool
+		want   string
+	}{
+		{"attribute", WithAttributeFilter("stt", "gpu", "true"), "[gpu]"},
+		{"attribute other capability", WithAttributeFilter("llm", "gpu", "true"), "[mixed]"},
+		{"attribute missing key", WithAttributeFilter("tts", "gpu", "true"), "[]"},
+		{"and", And(WithCapabilityFilter("stt"), WithAttributeFilter("stt", "gpu", "false")), "[cpu]"},
+		{"and empty", And(), "[gpu cpu mixed]"},
+		{"or", Or(WithCapabilityFilter("tts"), WithTierFilter("fast")), "[gpu mixed]"},
+		{"or empty", Or(), "[]"},
+		{"nested", And(WithCapabilityFilter("stt"), Or(WithAttributeFilter("stt", "gpu", "true"), WithCapabilityFilter("llm"))), "[gpu mixed]"},
+		{"nil ignored", And(nil, WithCapabilityFilter("tts")), "[gpu]"},
+	}
+	for _, tc := range cases {
+		if got := match(tc.filter); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCapabilityQueryOverBus(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfgA := testNodeConfig()
+	cfgA.Capabilities = []config.NodeCapability{{Name: "stt", Attributes: map[string]string{"gpu": "true"}}, {Name: "tts", Tier: "fast"}}
+	cfgB := cfgA
+	cfgB.ID = "node-b"
+	cfgB.Capabilities = []config.NodeCapability{{Name: "stt", Attributes: map[string]string{"gpu": "false"}}}
+
+	a, err := NewRegistry(context.Background(), cfgA, client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry a: %v", err)
+	}
+	t.Cleanup(a.Close)
+	b, err := NewRegistry(context.Background(), cfgB, client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry b: %v", err)
+	}
+	t.Cleanup(b.Close)
+	query := func(req QueryRequest) []NodeInfo {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		nodes, err := RemoteQuery(ctx, client.Conn(), protocol.SubjectCapabilityQuery, req)
+		if err != nil {
+			t.Fatalf("remote query %+v: %v", req, err)
+		}
+		return nodes
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(query(QueryRequest{Capability: "stt"})) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("registries never learned about each other")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if nodes := query(QueryRequest{Capability: "tts"}); len(nodes) != 1 || nodes[0].ID != "node-a" {
+		t.Fatalf("expected node-a for tts, got %+v", nodes)
+	}
+	if nodes := query(QueryRequest{Capability: "stt", Attributes: map[string]string{"gpu": "false"}, HealthyOnly: true}); len(nodes) != 1 || nodes[0].ID != "node-b" {
+		t.Fatalf("expected node-b for stt gpu=false, got %+v", nodes)
+	}
+	if nodes := query(QueryRequest{Tier: "balanced"}); len(nodes) != 0 {
+		t.Fatalf("expected no balanced nodes, got %+v", nodes)
+	}
+
+	msg, err := client.Conn().Request(protocol.SubjectCapabilityQuery, []byte("{"), 2*time.Second)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	var resp QueryResponse
+	if err := json.Unmarshal(msg.Data, &resp); err != nil || resp.Error == "" {
+		t.Fatalf("expected error response for malformed query, got %s", msg.Data)
+	}
+}
+
+// syncBuffer guards a bytes.Buffer used as a log sink from concurrent writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestNodeIDCollisionIsDetected(t *testing.T) {
+	client := testutil.StartBus(t)
+	var logs syncBuffer
+	log := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelError}))
+	reg, err := NewRegistry(context.Background(), testNodeConfig(), client, protocol.DefaultSubjects(), log)
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(reg.Close)
+
+	impostor, _ := json.Marshal(announceMessage{NodeID: "node-a", InstanceID: "other-process", Role: "runtime", Capabilities: []Capability{{Name: "tts"}}})
+	heartbeat, _ := json.Marshal(heartbeatMessage{NodeID: "node-a", InstanceID: "other-process"})
+	reg.handleAnnounce(&nats.Msg{Data: impostor})
+	reg.handleHeartbeat(&nats.Msg{Data: heartbeat})
+
+	if got := strings.Count(logs.String(), "node ID collision"); got != 1 {
+		t.Fatalf("expected one collision warning, got %d in %q", got, logs.String())
+	}
+	local := reg.LocalCapabilities()
+	if len(local) != 1 || local[0].Name != "runtime.core" {
+		t.Fatalf("collision must not overwrite the local node, got %+v", local)
+	}
+	nodes := reg.Query(func(n NodeInfo) bool { return n.ID == "node-a" })
+	if len(nodes) != 1 || nodes[0].InstanceID != reg.instanceID {
+		t.Fatalf("expected local instance ID to be kept, got %+v", nodes)
+	}
+
+	// Messages from the registry's own process and from legacy peers
+	// without an instance ID are not collisions.
+	own, _ := json.Marshal(heartbeatMessage{NodeID: "node-a", InstanceID: reg.instanceID})
+	legacy, _ := json.Marshal(heartbeatMessage{NodeID: "node-a"})
+	reg.handleHeartbeat(&nats.Msg{Data: own})
+	reg.handleHeartbeat(&nats.Msg{Data: legacy})
+	if got := strings.Count(logs.String(), "node ID collision"); got != 1 {
+		t.Fatalf("expected no further collision warnings, got %d", got)
+	}
+}
+
+func TestMissedHeartbeatsThreshold(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := testNodeConfig()
+	cfg.MissedHeartbeatsThreshold = 3
+	seen := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: seen}
+	reg, err := NewRegistry(context.Background(), cfg, client, protocol.DefaultSubjects(), testutil.Logger(), WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(reg.Close)
+
+	reg.updateNode("peer", "", "runtime", nil, nil, seen, true)
+	healthy := func() (bool, int) {
+		nodes := reg.Query(func(n NodeInfo) bool { return n.ID == "peer" })
+		return nodes[0].Healthy, nodes[0].MissedHeartbeats
+	}
+	timeout := time.Duration(cfg.HeartbeatTimeout) * time.Millisecond
+	interval := time.Duration(cfg.HeartbeatInterval) * time.Millisecond
+
+	now := seen.Add(timeout + time.Millisecond)
+	evaluateAt(reg, clock, now)
+	if ok, misses := healthy(); !ok || misses != 1 {
+		t.Fatalf("one missed beat must not flip health, got healthy=%v misses=%d", ok, misses)
+	}
+	// Ticks within the same interval do not count again.
+	evaluateAt(reg, clock, now.Add(interval/2))
+	if _, misses := healthy(); misses != 1 {
+		t.Fatalf("expected misses to be counted per interval, got %d", misses)
+	}
+	evaluateAt(reg, clock, now.Add(interval))
+	if ok, misses := healthy(); !ok || misses != 2 {
+		t.Fatalf("two missed beats must not flip health, got healthy=%v misses=%d", ok, misses)
+	}
+
+	// Any message resets the counter.
+	reg.updateNode("peer", "", "", nil, &NodeStats{}, seen, true)
+	evaluateAt(reg, clock, now)
+	evaluateAt(reg, clock, now.Add(interval))
+	if ok, misses := healthy(); !ok || misses != 2 {
+		t.Fatalf("expected counter to restart after a message, got healthy=%v misses=%d", ok, misses)
+	}
+	evaluateAt(reg, clock, now.Add(2*interval))
+	if ok, misses := healthy(); ok || misses != 3 {
+		t.Fatalf("expected three consecutive misses to mark peer unhealthy, got healthy=%v misses=%d", ok, misses)
+	}
+}
+
+// fakeClock is a settable registry clock.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// evaluateAt runs a health pass with the registry clock set to now.
+func evaluateAt(reg *Registry, clock *fakeClock, now time.Time) {
+	clock.Set(now)
+	reg.evaluateHealth()
+}
+
+func TestExpiredNodesAreRemoved(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := testNodeConfig()
+	cfg.NodeExpiry = 60000
+	seen := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: seen}
+	reg, err := NewRegistry(context.Background(), cfg, client, protocol.DefaultSubjects(), testutil.Logger(), WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(reg.Close)
+
+	reg.updateNode("peer", "", "runtime", []Capability{{Name: "tts"}}, nil, seen, true)
+
+	evaluateAt(reg, clock, seen.Add(10*time.Second))
+	if nodes, _ := reg.snapshotCounts(); nodes != 2 {
+		t.Fatalf("expected unhealthy peer to be kept before expiry, got %d nodes", nodes)
+	}
+	evaluateAt(reg, clock, seen.Add(61*time.Second))
+	if got := reg.Query(func(n NodeInfo) bool { return n.ID == "peer" }); len(got) != 0 {
+		t.Fatalf("expected expired peer to be removed, got %+v", got)
+	}
+	if got := reg.Query(func(n NodeInfo) bool { return n.ID == cfg.ID }); len(got) != 1 {
+		t.Fatal("the local node must never expire")
+	}
+	if nodes, caps := reg.snapshotCounts(); nodes != 1 || caps != 1 {
+		t.Fatalf("expected gauges to count only the local node, got nodes=%d caps=%d", nodes, caps)
+	}
+}
+
+func TestExpiredPeerRegainsCapabilitiesOnReturn(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfgA := testNodeConfig()
+	cfgA.HeartbeatInterval = 20
+	cfgB := cfgA
+	cfgB.ID = "node-b"
+	cfgB.Capabilities = []config.NodeCapability{{Name: "tts"}}
+
+	a, err := NewRegistry(context.Background(), cfgA, client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry a: %v", err)
+	}
+	t.Cleanup(a.Close)
+	b, err := NewRegistry(context.Background(), cfgB, client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry b: %v", err)
+	}
+	t.Cleanup(b.Close)
+
+	waitForTTS := func(stage string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for len(a.Query(And(WithCapabilityFilter("tts"), func(n NodeInfo) bool { return n.ID == "node-b" }))) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: node-a never learned node-b's capabilities: %+v", stage, a.Query(nil))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForTTS("startup")
+
+	// Expire node-b from node-a's view; node-b keeps heartbeating and never
+	// learns it was dropped.
+	a.mu.Lock()
+	delete(a.nodes, "node-b")
+	a.mu.Unlock()
+	waitForTTS("after expiry")
+}
+
+func TestRegistryUsesInjectedClock(t *testing.T) {
+	client := testutil.StartBus(t)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	reg, err := NewRegistry(context.Background(), testNodeConfig(), client, protocol.DefaultSubjects(), testutil.Logger(), WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(reg.Close)
+
+	local := reg.Query(func(n NodeInfo) bool { return n.ID == "node-a" })
+	if len(local) != 1 || !local[0].LastSeen.Equal(start) {
+		t.Fatalf("expected announce timestamp from the injected clock, got %+v", local)
+	}
+	// A peer heartbeat without a timestamp is stamped with the clock too.
+	reg.handleHeartbeat(&nats.Msg{Data: []byte(`{"node_id":"peer"}`)})
+
+	evaluateAt(reg, clock, start.Add(3*time.Second))
+	if !reg.Healthy() {
+		t.Fatal("expected node to stay healthy at the timeout boundary")
+	}
+	evaluateAt(reg, clock, start.Add(3*time.Second+time.Millisecond))
+	if reg.Healthy() {
+		t.Fatal("expected node to be unhealthy just past the timeout")
+	}
+	if peer := reg.Query(func(n NodeInfo) bool { return n.ID == "peer" }); len(peer) != 1 || peer[0].Healthy {
+		t.Fatalf("expected clock-stamped peer to time out with the local node, got %+v", peer)
+	}
+}
+
+func TestWatchReportsJoinHealthAndRemoval(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := testNodeConfig()
+	cfg.NodeExpiry = 60000
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	reg, err := NewRegistry(context.Background(), cfg, client, protocol.DefaultSubjects(), testutil.Logger(), WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(reg.Close)
+
+	changes, cancel := reg.Watch(16)
+	defer cancel()
+	next := func(id string) Change {
+		t.Helper()
+		deadline := time.After(2 * time.Second)
+		for {
+			select {
+			case c := <-changes:
+				if c.Node.ID == id {
+					return c
+				}
+			case <-deadline:
+				t.Fatalf("timed out waiting for a change to %s", id)
+			}
+		}
+	}
+
+	joined, _ := json.Marshal(announceMessage{NodeID: "peer", Role: "runtime", Capabilities: []Capability{{Name: "tts"}}, Timestamp: start})
+	reg.handleAnnounce(&nats.Msg{Data: joined})
+	if c := next("peer"); c.Kind != ChangeAdded || len(c.Node.Capabilities) != 1 {
+		t.Fatalf("expected join event, got %+v", c)
+	}
+	evaluateAt(reg, clock, start.Add(4*time.Second))
+	if c := next("peer"); c.Kind != ChangeHealth || c.Node.Healthy {
+		t.Fatalf("expected unhealthy transition, got %+v", c)
+	}
+	evaluateAt(reg, clock, start.Add(2*time.Minute))
+	if c := next("peer"); c.Kind != ChangeRemoved {
+		t.Fatalf("expected removal, got %+v", c)
+	}
+}
+
+func TestWatchIgnoresHeartbeatsWithoutChanges(t *testing.T) {
+	client := testutil.StartBus(t)
+	reg, err := NewRegistry(context.Background(), testNodeConfig(), client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(reg.Close)
+
+	changes, cancel := reg.Watch(16)
+	defer cancel()
+	caps := []Capability{{Name: "tts"}}
+	now := time.Now()
+	reg.updateNode("peer", "", "runtime", caps, nil, now, true)
+	for i := 1; i <= 3; i++ {
+		stats := NodeStats{InFlight: map[string]int{"tts": i}}
+		reg.updateNode("peer", "", "", nil, &stats, now.Add(time.Duration(i)*time.Second), true)
+	}
+	reg.updateNode("peer", "", "runtime", []Capability{{Name: "tts"}, {Name: "stt"}}, nil, now.Add(5*time.Second), true)
+
+	var kinds []ChangeKind
+	for len(kinds) < 2 {
+		select {
+		case c := <-changes:
+			if c.Node.ID == "peer" {
+				kinds = append(kinds, c.Kind)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for changes, got %v", kinds)
+		}
+	}
+	if kinds[0] != ChangeAdded || kinds[1] != ChangeUpdated {
+		t.Fatalf("expected add then a capability update with no heartbeat events between, got %v", kinds)
+	}
+}
+
+func TestSlowWatcherIsDropped(t *testing.T) {
+	client := testutil.StartBus(t)
+	reg, err := NewRegistry(context.Background(), testNodeConfig(), client, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(reg.Close)
+
+	slow, cancelSlow := reg.Watch(1)
+	defer cancelSlow()
+	for i := 0; i < 3; i++ {
+		reg.updateNode(fmt.Sprintf("peer-%d", i), "", "runtime", nil, nil, time.Now(), true)
+	}
+	// The buffered change is still delivered, then the channel closes.
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-slow:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("expected slow watcher to be dropped")
+		}
+	}
+}
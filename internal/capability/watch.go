@@ -0,0 +1,102 @@
+package capability
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ChangeKind describes how a node changed in the registry.
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "add"
+	ChangeUpdated ChangeKind = "update"
+	ChangeRemoved ChangeKind = "remove"
+	ChangeHealth  ChangeKind = "health"
+)
+
+// Change is a single registry change delivered to watchers. Node is a copy
+// of the node after the change (before it, for removals).
+type Change struct {
+	Kind      ChangeKind `json:"kind"`
+	Node      NodeInfo   `json:"node"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+// watchers fans registry changes out to observers. Sends never block: a
+// watcher whose buffer is full is dropped and its channel closed.
+type watchers struct {
+	mu   sync.Mutex
+	next int
+	subs map[int]chan Change
+}
+
+func (w *watchers) add(buffer int) (int, chan Change) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.subs == nil {
+		w.subs = make(map[int]chan Change)
+	}
+	id := w.next
+	w.next++
+	ch := make(chan Change, buffer)
+	w.subs[id] = ch
+	return id, ch
+}
+
+func (w *watchers) remove(id int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if ch, ok := w.subs[id]; ok {
+		delete(w.subs, id)
+		close(ch)
+	}
+}
+
+// notify delivers change to every watcher and reports how many were
+// dropped for falling behind.
+func (w *watchers) notify(change Change) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	dropped := 0
+	for id, ch := range w.subs {
+		select {
+		case ch <- change:
+		default:
+			delete(w.subs, id)
+			close(ch)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+func (w *watchers) closeAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id, ch := range w.subs {
+		delete(w.subs, id)
+		close(ch)
+	}
+}
+
+// Watch registers an observer for node joins, updates, removals and health
+// transitions. The returned channel is closed when cancel is called, when
+// the registry closes, or when the observer falls more than buffer changes
+// behind; callers that need a full view should re-read Query after a close.
+func (r *Registry) Watch(buffer int) (<-chan Change, func()) {
+	if buffer < 1 {
+		buffer = 1
+	}
+	id, ch := r.watchers.add(buffer)
+	return ch, func() { r.watchers.remove(id) }
+}
+
+// emitLocked notifies watchers about node. Callers hold r.mu; notify never
+// blocks, so holding the lock is safe.
+func (r *Registry) emitLocked(kind ChangeKind, node *NodeInfo) {
+	if dropped := r.watchers.notify(Change{Kind: kind, Node: *node, Timestamp: r.clock().UTC()}); dropped > 0 {
+		r.log.Warn("dropped slow registry watchers", slog.Int("count", dropped))
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/loqalabs/loqa-core/internal/protocol"
 	"gopkg.in/yaml.v3"
 )
 
@@ -21,6 +22,14 @@ type TelemetryConfig struct {
 type HTTPConfig struct {
 	Bind string `yaml:"bind"`
 	Port int    `yaml:"port"`
+	// TLSCert and TLSKey are PEM files; setting both serves HTTPS.
+	TLSCert string `yaml:"tls_cert"`
+	TLSKey  string `yaml:"tls_key"`
+	// AuthToken, when set, is required as a bearer token on every endpoint
+	// except the health and readiness probes.
+	AuthToken string `yaml:"auth_token"`
+	// EnablePprof mounts /debug/pprof/ and /debug/vars on the API server.
+	EnablePprof bool `yaml:"enable_pprof"`
 }
 
 type Config struct {
@@ -36,25 +45,55 @@ type Config struct {
 	LLM         LLMConfig        `yaml:"llm"`
 	TTS         TTSConfig        `yaml:"tts"`
 	Router      RouterConfig     `yaml:"router"`
+
+	// ShutdownTimeoutMS bounds runtime teardown; each shutdown phase gets a
+	// fixed share of it.
+	ShutdownTimeoutMS int `yaml:"shutdown_timeout_ms"`
 }
 
 type BusConfig struct {
-	Embedded       bool     `yaml:"embedded"`
-	Port           int      `yaml:"port"`
-	Servers        []string `yaml:"servers"`
-	Username       string   `yaml:"username"`
-	Password       string   `yaml:"password"`
-	Token          string   `yaml:"token"`
-	TLSInsecure    bool     `yaml:"tls_insecure"`
-	ConnectTimeout int      `yaml:"connect_timeout_ms"`
+	Embedded bool     `yaml:"embedded"`
+	Port     int      `yaml:"port"`
+	StoreDir string   `yaml:"store_dir"` // embedded server JetStream directory
+	Servers  []string `yaml:"servers"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	Token    string   `yaml:"token"`
+	// CredsFile is a NATS 2.x .creds file (user JWT plus NKey seed).
+	CredsFile string `yaml:"creds_file"`
+	// NKeySeedFile is a file holding a bare NKey user seed.
+	NKeySeedFile   string `yaml:"nkey_seed_file"`
+	TLSInsecure    bool   `yaml:"tls_insecure"`
+	ConnectTimeout int    `yaml:"connect_timeout_ms"`
+	// ClientName is the NATS connection name shown in server monitoring;
+	// empty derives it from node.id, runtime_name and environment.
+	ClientName string `yaml:"client_name"`
+	// SubjectPrefix namespaces every subject the runtime uses, e.g. "tenant-a.".
+	SubjectPrefix string `yaml:"subject_prefix"`
+	// Subjects remaps individual subjects by key (e.g. tts_request) before
+	// the prefix is applied.
+	Subjects map[string]string `yaml:"subjects"`
+	Codec    string            `yaml:"codec"` // json, msgpack
+	// RawAudio publishes PCM as the raw message body with metadata in headers.
+	RawAudio bool `yaml:"raw_audio"`
+	// PendingMsgs and PendingBytes cap the messages buffered per
+	// subscription before NATS drops them as a slow consumer. 0 keeps the
+	// client library default, -1 removes the limit.
+	PendingMsgs  int `yaml:"pending_msgs"`
+	PendingBytes int `yaml:"pending_bytes"`
 }
 
 type NodeConfig struct {
-	ID                string           `yaml:"id"`
-	Role              string           `yaml:"role"`
-	HeartbeatInterval int              `yaml:"heartbeat_interval_ms"`
-	HeartbeatTimeout  int              `yaml:"heartbeat_timeout_ms"`
-	Capabilities      []NodeCapability `yaml:"capabilities"`
+	ID                string `yaml:"id"`
+	Role              string `yaml:"role"`
+	HeartbeatInterval int    `yaml:"heartbeat_interval_ms"`
+	HeartbeatTimeout  int    `yaml:"heartbeat_timeout_ms"`
+	// MissedHeartbeatsThreshold is how many consecutive heartbeat intervals
+	// a peer may miss past heartbeat_timeout_ms before it is marked unhealthy.
+	MissedHeartbeatsThreshold int `yaml:"missed_heartbeats_threshold"`
+	// NodeExpiry removes unhealthy peers silent for this long; 0 keeps them.
+	NodeExpiry   int              `yaml:"node_expiry_ms"`
+	Capabilities []NodeCapability `yaml:"capabilities"`
 }
 
 type NodeCapability struct {
@@ -69,11 +108,28 @@ type EventStoreConfig struct {
 	RetentionDays int    `yaml:"retention_days"`
 	MaxSessions   int    `yaml:"max_sessions"`
 	VacuumOnStart bool   `yaml:"vacuum_on_start"`
+	// BatchSize > 1 buffers events and writes them in one transaction per
+	// batch, at least every FlushIntervalMS.
+	BatchSize       int `yaml:"batch_size"`
+	FlushIntervalMS int `yaml:"flush_interval_ms"`
+	// SQLite tuning, applied to every pooled connection. Zero values keep
+	// the SQLite and database/sql defaults.
+	BusyTimeoutMS int    `yaml:"busy_timeout_ms"`
+	Synchronous   string `yaml:"synchronous"` // OFF, NORMAL, FULL or EXTRA
+	CacheSize     int    `yaml:"cache_size"`  // pages, or KiB when negative
+	MaxOpenConns  int    `yaml:"max_open_conns"`
+	MaxIdleConns  int    `yaml:"max_idle_conns"`
+	// Redaction maps a privacy scope to how payloads in it are stored: keep
+	// (default), hash, truncate (to RedactionTruncateBytes), drop (metadata
+	// only) or discard (the event is not stored). Keys must be known
+	// privacy scopes.
+	Redaction              map[string]string `yaml:"redaction"`
+	RedactionTruncateBytes int               `yaml:"redaction_truncate_bytes"`
 }
 
 type STTConfig struct {
 	Enabled         bool   `yaml:"enabled"`
-	Mode            string `yaml:"mode"`
+	Mode            string `yaml:"mode"` // mock, exec, scripted
 	Command         string `yaml:"command"`
 	ModelPath       string `yaml:"model_path"`
 	Language        string `yaml:"language"`
@@ -82,6 +138,24 @@ type STTConfig struct {
 	FrameDurationMS int    `yaml:"frame_duration_ms"`
 	PartialEveryMS  int    `yaml:"partial_every_ms"`
 	PublishInterim  bool   `yaml:"publish_interim"`
+	StdinPCM        bool   `yaml:"stdin_pcm"`
+	// CommandArgs replaces the built-in exec flags with a template whose
+	// {audio}, {sample_rate}, {channels}, {model}, {language} and {partial}
+	// placeholders are filled per request.
+	CommandArgs []string `yaml:"command_args"`
+	// ExecEnv and ExecEnvPassthrough define the exec command's environment:
+	// the parent environment (or, when ExecEnvPassthrough is set, only the
+	// variables it names; [] for none) plus fixed values.
+	ExecEnv            map[string]string `yaml:"exec_env"`
+	ExecEnvPassthrough []string          `yaml:"exec_env_passthrough"`
+	// ReorderWindow is how many out-of-order frames are held while waiting
+	// for a missing sequence number; 0 appends frames in arrival order.
+	ReorderWindow int `yaml:"reorder_window"`
+	// Scripted mode replays these transcripts (then the lines of ScriptFile)
+	// in order, holding on the last one unless ScriptCycle is set.
+	ScriptTranscripts []string `yaml:"script_transcripts"`
+	ScriptFile        string   `yaml:"script_file"`
+	ScriptCycle       bool     `yaml:"script_cycle"`
 }
 
 type LLMConfig struct {
@@ -94,16 +168,94 @@ type LLMConfig struct {
 	DefaultTier   string  `yaml:"default_tier"`
 	MaxTokens     int     `yaml:"max_tokens"`
 	Temperature   float64 `yaml:"temperature"`
+	ExecStreaming bool    `yaml:"exec_streaming"` // exec mode: read NDJSON chunks instead of one object
+	// CommandArgs is appended to Command in exec mode, with {prompt},
+	// {system}, {tier}, {max_tokens} and {temperature} placeholders filled
+	// per request.
+	CommandArgs  []string `yaml:"command_args"`
+	MockBehavior string   `yaml:"mock_behavior"` // mock mode: default, echo, reverse, fixed:<text>, template:<path>
+	// ExecEnv and ExecEnvPassthrough work as in STTConfig.
+	ExecEnv            map[string]string `yaml:"exec_env"`
+	ExecEnvPassthrough []string          `yaml:"exec_env_passthrough"`
+	// Stream publishes each token on nlu.response.partial. When false the
+	// service buffers the backend stream and publishes only the final.
+	Stream bool `yaml:"stream"`
+	// Stop lists sequences that end a completion. They are passed to the
+	// backend and also enforced on the streamed output.
+	Stop []string `yaml:"stop"`
+	// Warmup loads ollama models at startup so the first request does not
+	// wait for them. Failures are logged and otherwise ignored.
+	Warmup bool `yaml:"warmup"`
+	// HealthIntervalMS is how often backend servers (ollama) are probed for
+	// readiness; the service is ready while any backend is reachable. 0
+	// disables probing.
+	HealthIntervalMS int `yaml:"health_interval_ms"`
+	// MaxContextTokens bounds the estimated prompt + system + max_tokens size;
+	// the oldest prompt lines are dropped to fit. 0 disables truncation.
+	MaxContextTokens int `yaml:"max_context_tokens"`
+	// Backends lists additional named generators selectable per request via
+	// nlu.request's backend field. The top-level mode/endpoint settings form
+	// the backend named "default".
+	Backends       []LLMBackendConfig `yaml:"backends"`
+	DefaultBackend string             `yaml:"default_backend"`
+	// Fallback is tried when the selected backend fails before streaming any
+	// content. Leave mode empty to disable.
+	Fallback LLMBackendConfig `yaml:"fallback"`
+}
+
+// LLMBackendConfig describes one named LLM generator.
+type LLMBackendConfig struct {
+	Name          string   `yaml:"name"`
+	Mode          string   `yaml:"mode"` // mock, ollama, exec
+	Endpoint      string   `yaml:"endpoint"`
+	Command       string   `yaml:"command"`
+	ModelFast     string   `yaml:"model_fast"`
+	ModelBalanced string   `yaml:"model_balanced"`
+	ExecStreaming bool     `yaml:"exec_streaming"`
+	CommandArgs   []string `yaml:"command_args"`
+	MockBehavior  string   `yaml:"mock_behavior"`
+	// ExecEnv and ExecEnvPassthrough work as in STTConfig.
+	ExecEnv            map[string]string `yaml:"exec_env"`
+	ExecEnvPassthrough []string          `yaml:"exec_env_passthrough"`
 }
 
 type TTSConfig struct {
-	Enabled         bool   `yaml:"enabled"`
-	Mode            string `yaml:"mode"`
-	Command         string `yaml:"command"`
-	Voice           string `yaml:"voice"`
-	SampleRate      int    `yaml:"sample_rate"`
-	Channels        int    `yaml:"channels"`
-	ChunkDurationMS int    `yaml:"chunk_duration_ms"`
+	Enabled bool   `yaml:"enabled"`
+	Mode    string `yaml:"mode"`
+	Command string `yaml:"command"`
+	// CommandArgs is appended to Command in exec mode, with {text},
+	// {voice}, {sample_rate} and {channels} placeholders filled per request.
+	CommandArgs     []string `yaml:"command_args"`
+	Voice           string   `yaml:"voice"`
+	SampleRate      int      `yaml:"sample_rate"`
+	Channels        int      `yaml:"channels"`
+	ChunkDurationMS int      `yaml:"chunk_duration_ms"`
+	// ExecEnv and ExecEnvPassthrough work as in STTConfig and also apply
+	// to VoicesCommand.
+	ExecEnv            map[string]string `yaml:"exec_env"`
+	ExecEnvPassthrough []string          `yaml:"exec_env_passthrough"`
+	// MaxConcurrentSynth caps in-flight synthesis; further requests wait.
+	MaxConcurrentSynth int `yaml:"max_concurrent_synth"`
+	// Mock mode renders a sine tone of MockToneHz for MockDurationMS.
+	MockToneHz     float64 `yaml:"mock_tone_hz"`
+	MockDurationMS int     `yaml:"mock_duration_ms"`
+	// SSML marks the engine as SSML-capable: exec requests then carry the
+	// markup in "ssml"; otherwise tags are stripped to plain text.
+	SSML bool `yaml:"ssml"`
+	// VoicesCommand, when set, is run at startup to list the engine's
+	// voices (one per line); readiness fails if Voice is not among them.
+	VoicesCommand string `yaml:"voices_command"`
+	// CacheBytes bounds an LRU of synthesized utterances keyed by text,
+	// voice and sample rate; repeats replay from memory. 0 disables.
+	CacheBytes int `yaml:"cache_bytes"`
+	// GainDB scales synthesized 16-bit PCM by a fixed amount. With
+	// Normalize set, each chunk is first scaled so the utterance's running
+	// peak reaches NormalizePeakDBFS (0 is full scale), boosting quiet
+	// audio by at most NormalizeMaxGainDB.
+	GainDB             float64 `yaml:"gain_db"`
+	Normalize          bool    `yaml:"normalize"`
+	NormalizePeakDBFS  float64 `yaml:"normalize_peak_dbfs"`
+	NormalizeMaxGainDB float64 `yaml:"normalize_max_gain_db"`
 }
 
 type RouterConfig struct {
@@ -111,19 +263,88 @@ type RouterConfig struct {
 	DefaultTier  string `yaml:"default_tier"`
 	DefaultVoice string `yaml:"default_voice"`
 	Target       string `yaml:"target"`
+	// Mode is normal (transcript -> LLM -> TTS) or echo, which speaks each
+	// transcript back without an LLM.
+	Mode string `yaml:"mode"`
+	// SystemPrompt, MaxTokens and Temperature are injected into every LLM
+	// request; zero values leave the LLM service's defaults in place.
+	SystemPrompt string  `yaml:"system_prompt"`
+	MaxTokens    int     `yaml:"max_tokens"`
+	Temperature  float64 `yaml:"temperature"`
+	// Tiers overrides the generation settings for requests routed to a
+	// tier, field by field.
+	Tiers map[string]RouterTierConfig `yaml:"tiers"`
+	// DedupWindowMS drops a final transcript identical to the session's
+	// previous one when it arrives within this window. 0 disables.
+	DedupWindowMS int `yaml:"dedup_window_ms"`
+	// NormalizeInput cleans transcripts (bracketed annotations, fillers,
+	// whitespace) before they reach the LLM; CapitalizeInput also
+	// upper-cases the first letter.
+	NormalizeInput  bool `yaml:"normalize_input"`
+	CapitalizeInput bool `yaml:"capitalize_input"`
+	// ExtraTargets are output devices that play every spoken response in
+	// addition to the session's own target (multi-room audio).
+	ExtraTargets []string `yaml:"extra_targets"`
+	// MinConfidence answers final transcripts scored below it with
+	// ClarifyText instead of sending them to the LLM. 0 disables; unscored
+	// transcripts (confidence 0) always pass.
+	MinConfidence float64 `yaml:"min_confidence"`
+	ClarifyText   string  `yaml:"clarify_text"`
+	// IntentMode dispatches LLM replies that parse as a protocol.Intent to
+	// skill.<skill>.<action> instead of speaking them. Only the subjects
+	// listed in IntentSubjects are dispatched, and the router describes them
+	// to the LLM in the system prompt.
+	IntentMode     bool     `yaml:"intent_mode"`
+	IntentSubjects []string `yaml:"intent_subjects"`
+	// LogConversations appends each transcript and reply to the event store
+	// as conversation.user/conversation.assistant events of the session.
+	LogConversations bool `yaml:"log_conversations"`
+}
+
+// RouterTierConfig holds per-tier generation overrides for the router.
+type RouterTierConfig struct {
+	SystemPrompt string  `yaml:"system_prompt"`
+	MaxTokens    int     `yaml:"max_tokens"`
+	Temperature  float64 `yaml:"temperature"`
 }
 
 type SkillsConfig struct {
-	Enabled      bool   `yaml:"enabled"`
-	Directory    string `yaml:"directory"`
-	Concurrency  int    `yaml:"max_concurrency"`
-	AuditPrivacy string `yaml:"audit_privacy_scope"`
+	Enabled        bool   `yaml:"enabled"`
+	Directory      string `yaml:"directory"`
+	Concurrency    int    `yaml:"max_concurrency"`
+	AuditPrivacy   string `yaml:"audit_privacy_scope"`
+	ConflictPolicy string `yaml:"skill_conflict"` // error, first-wins, last-wins, version-wins
+	CacheDir       string `yaml:"module_cache_dir"`
+	AuditMode      string `yaml:"audit_mode"` // sync, jetstream
+	MaxEventBytes  int    `yaml:"max_event_payload_bytes"`
+	// Caps on lengths a guest may pass to host_publish and host_log.
+	MaxPublishBytes int `yaml:"max_publish_bytes"`
+	MaxLogBytes     int `yaml:"max_log_bytes"`
+	// host_http limits for skills granted network:http.
+	MaxHTTPBytes  int `yaml:"max_http_bytes"`
+	HTTPTimeoutMS int `yaml:"http_timeout_ms"`
+	// MaxPublishesPerSecond rate-limits host_publish per skill; 0 disables.
+	// Manifests may lower it with capabilities.bus.max_publishes_per_second.
+	MaxPublishesPerSecond float64 `yaml:"max_publishes_per_second"`
+	// InvocationTimeoutMS bounds each invocation attempt; skills see the
+	// time left as LOQA_INVOCATION_DEADLINE_MS and via host_deadline.
+	InvocationTimeoutMS int `yaml:"invocation_timeout_ms"`
+	// DedupWindowMS skips an event delivered to a skill again within this
+	// window, keyed by its Nats-Msg-Id header; 0 disables. DedupByPayload
+	// also treats events without the header as duplicates when their
+	// subject and payload hash match.
+	DedupWindowMS  int  `yaml:"dedup_window_ms"`
+	DedupByPayload bool `yaml:"dedup_by_payload"`
+	// Config holds per-skill values for host_config_get, keyed by skill
+	// name and then by a key the skill's manifest declares.
+	Config map[string]map[string]string `yaml:"config"`
 }
 
 func Default() Config {
 	return Config{
-		RuntimeName: "loqa-runtime",
-		Environment: "development",
+		RuntimeName:       "loqa-runtime",
+		Environment:       "development",
+		ShutdownTimeoutMS: 10000,
 		HTTP: HTTPConfig{
 			Bind: "0.0.0.0",
 			Port: 8080,
@@ -137,29 +358,45 @@ func Default() Config {
 		Bus: BusConfig{
 			Embedded:       true,
 			Port:           4222,
+			StoreDir:       "./data/nats",
 			Servers:        []string{"nats://localhost:4222"},
 			ConnectTimeout: 2000,
+			Codec:          "json",
 		},
 		Node: NodeConfig{
-			ID:                "loqa-node-1",
-			Role:              "runtime",
-			HeartbeatInterval: 2000,
-			HeartbeatTimeout:  6000,
+			ID:                        "loqa-node-1",
+			Role:                      "runtime",
+			HeartbeatInterval:         2000,
+			HeartbeatTimeout:          6000,
+			MissedHeartbeatsThreshold: 1,
+			NodeExpiry:                300000,
 			Capabilities: []NodeCapability{
 				{Name: "runtime.core", Tier: "balanced"},
 			},
 		},
 		Skills: SkillsConfig{
-			Enabled:      true,
-			Directory:    "./skills",
-			Concurrency:  4,
-			AuditPrivacy: "internal",
+			Enabled:               true,
+			Directory:             "./skills",
+			Concurrency:           4,
+			AuditPrivacy:          "internal",
+			ConflictPolicy:        "first-wins",
+			CacheDir:              "./data/skills-cache",
+			AuditMode:             "sync",
+			MaxEventBytes:         256 << 10,
+			MaxPublishBytes:       1 << 20,
+			MaxLogBytes:           64 << 10,
+			MaxPublishesPerSecond: 100,
+			MaxHTTPBytes:          1 << 20,
+			HTTPTimeoutMS:         10000,
+			InvocationTimeoutMS:   30000,
+			DedupWindowMS:         10000,
 		},
 		EventStore: EventStoreConfig{
 			Path:          "./data/loqa-events.db",
 			RetentionMode: "session",
 			RetentionDays: 30,
 			MaxSessions:   10000,
+			BusyTimeoutMS: 5000,
 		},
 		STT: STTConfig{
 			Enabled:         false,
@@ -168,34 +405,52 @@ func Default() Config {
 			Channels:        1,
 			FrameDurationMS: 20,
 			PartialEveryMS:  800,
+			ReorderWindow:   8,
 		},
 		LLM: LLMConfig{
-			Enabled:       false,
-			Mode:          "mock",
-			Endpoint:      "http://localhost:11434",
-			ModelFast:     "llama3.2:latest",
-			ModelBalanced: "llama3.2:latest",
-			DefaultTier:   "balanced",
-			MaxTokens:     256,
-			Temperature:   0.7,
+			Enabled:          false,
+			Mode:             "mock",
+			Endpoint:         "http://localhost:11434",
+			ModelFast:        "llama3.2:latest",
+			ModelBalanced:    "llama3.2:latest",
+			DefaultTier:      "balanced",
+			MaxTokens:        256,
+			Temperature:      0.7,
+			MaxContextTokens: 4096,
+			DefaultBackend:   "default",
+			HealthIntervalMS: 10000,
+			Stream:           true,
 		},
 		TTS: TTSConfig{
-			Enabled:         false,
-			Mode:            "mock",
-			SampleRate:      22050,
-			Channels:        1,
-			ChunkDurationMS: 400,
+			Enabled:            false,
+			Mode:               "mock",
+			SampleRate:         22050,
+			Channels:           1,
+			ChunkDurationMS:    400,
+			MaxConcurrentSynth: 2,
+			MockToneHz:         440,
+			MockDurationMS:     1000,
+			NormalizePeakDBFS:  -3,
+			NormalizeMaxGainDB: 20,
 		},
 		Router: RouterConfig{
-			Enabled:      true,
-			DefaultTier:  "balanced",
-			DefaultVoice: "en-US",
-			Target:       "default",
+			Enabled:       true,
+			DefaultTier:   "balanced",
+			DefaultVoice:  "en-US",
+			Target:        "default",
+			Mode:          "normal",
+			DedupWindowMS: 1500,
+			ClarifyText:   "Sorry, could you repeat that?",
 		},
 	}
 }
 
-func Load(path string) (Config, error) {
+// Load reads the config file at path over the defaults (the defaults alone
+// when path is empty), applies environment overrides and then each of
+// overrides in order, and validates the result. Validating last means an
+// override can replace a setting that would otherwise be rejected, and that
+// its own values are checked like any others.
+func Load(path string, overrides ...func(*Config)) (Config, error) {
 	cfg := Default()
 
 	if path != "" {
@@ -212,6 +467,15 @@ func Load(path string) (Config, error) {
 	}
 
 	applyEnvOverrides(&cfg)
+	for _, override := range overrides {
+		override(&cfg)
+	}
+	// Before privacy scopes were a fixed set, audit_privacy_scope was free
+	// text. Known scopes are matched case-insensitively; other values are
+	// kept as they are and the skills service warns about them.
+	if scope := strings.ToLower(strings.TrimSpace(cfg.Skills.AuditPrivacy)); protocol.PrivacyRank(scope) >= 0 {
+		cfg.Skills.AuditPrivacy = scope
+	}
 	if err := validate(cfg); err != nil {
 		return cfg, err
 	}
@@ -221,29 +485,53 @@ func Load(path string) (Config, error) {
 func applyEnvOverrides(cfg *Config) {
 	overrideString(&cfg.RuntimeName, "LOQA_RUNTIME_NAME")
 	overrideString(&cfg.Environment, "LOQA_RUNTIME_ENVIRONMENT")
+	overrideInt(&cfg.ShutdownTimeoutMS, "LOQA_SHUTDOWN_TIMEOUT_MS")
 	overrideString(&cfg.HTTP.Bind, "LOQA_HTTP_BIND")
 	overrideInt(&cfg.HTTP.Port, "LOQA_HTTP_PORT")
+	overrideString(&cfg.HTTP.TLSCert, "LOQA_HTTP_TLS_CERT")
+	overrideString(&cfg.HTTP.TLSKey, "LOQA_HTTP_TLS_KEY")
+	overrideString(&cfg.HTTP.AuthToken, "LOQA_HTTP_AUTH_TOKEN")
+	overrideBool(&cfg.HTTP.EnablePprof, "LOQA_HTTP_ENABLE_PPROF")
 	overrideString(&cfg.Telemetry.LogLevel, "LOQA_TELEMETRY_LOG_LEVEL")
 	overrideString(&cfg.Telemetry.OTLPEndpoint, "LOQA_TELEMETRY_OTLP_ENDPOINT")
 	overrideBool(&cfg.Telemetry.OTLPInsecure, "LOQA_TELEMETRY_OTLP_INSECURE")
 	overrideString(&cfg.Telemetry.PrometheusBind, "LOQA_TELEMETRY_PROMETHEUS_BIND")
 	overrideBool(&cfg.Bus.Embedded, "LOQA_BUS_EMBEDDED")
 	overrideInt(&cfg.Bus.Port, "LOQA_BUS_PORT")
+	overrideString(&cfg.Bus.StoreDir, "LOQA_BUS_STORE_DIR")
 	overrideStringSlice(&cfg.Bus.Servers, "LOQA_BUS_SERVERS")
 	overrideString(&cfg.Bus.Username, "LOQA_BUS_USERNAME")
 	overrideString(&cfg.Bus.Password, "LOQA_BUS_PASSWORD")
 	overrideString(&cfg.Bus.Token, "LOQA_BUS_TOKEN")
+	overrideString(&cfg.Bus.CredsFile, "LOQA_BUS_CREDS_FILE")
+	overrideString(&cfg.Bus.NKeySeedFile, "LOQA_BUS_NKEY_SEED_FILE")
 	overrideBool(&cfg.Bus.TLSInsecure, "LOQA_BUS_TLS_INSECURE")
 	overrideInt(&cfg.Bus.ConnectTimeout, "LOQA_BUS_CONNECT_TIMEOUT_MS")
+	overrideString(&cfg.Bus.ClientName, "LOQA_BUS_CLIENT_NAME")
+	overrideString(&cfg.Bus.SubjectPrefix, "LOQA_BUS_SUBJECT_PREFIX")
+	overrideString(&cfg.Bus.Codec, "LOQA_BUS_CODEC")
+	overrideBool(&cfg.Bus.RawAudio, "LOQA_BUS_RAW_AUDIO")
+	overrideInt(&cfg.Bus.PendingMsgs, "LOQA_BUS_PENDING_MSGS")
+	overrideInt(&cfg.Bus.PendingBytes, "LOQA_BUS_PENDING_BYTES")
 	overrideString(&cfg.Node.ID, "LOQA_NODE_ID")
 	overrideString(&cfg.Node.Role, "LOQA_NODE_ROLE")
 	overrideInt(&cfg.Node.HeartbeatInterval, "LOQA_NODE_HEARTBEAT_INTERVAL_MS")
 	overrideInt(&cfg.Node.HeartbeatTimeout, "LOQA_NODE_HEARTBEAT_TIMEOUT_MS")
+	overrideInt(&cfg.Node.MissedHeartbeatsThreshold, "LOQA_NODE_MISSED_HEARTBEATS_THRESHOLD")
+	overrideInt(&cfg.Node.NodeExpiry, "LOQA_NODE_EXPIRY_MS")
 	overrideString(&cfg.EventStore.Path, "LOQA_EVENT_STORE_PATH")
 	overrideString(&cfg.EventStore.RetentionMode, "LOQA_EVENT_STORE_RETENTION_MODE")
+	overrideInt(&cfg.EventStore.BatchSize, "LOQA_EVENT_STORE_BATCH_SIZE")
+	overrideInt(&cfg.EventStore.FlushIntervalMS, "LOQA_EVENT_STORE_FLUSH_INTERVAL_MS")
 	overrideInt(&cfg.EventStore.RetentionDays, "LOQA_EVENT_STORE_RETENTION_DAYS")
 	overrideInt(&cfg.EventStore.MaxSessions, "LOQA_EVENT_STORE_MAX_SESSIONS")
 	overrideBool(&cfg.EventStore.VacuumOnStart, "LOQA_EVENT_STORE_VACUUM_ON_START")
+	overrideInt(&cfg.EventStore.BusyTimeoutMS, "LOQA_EVENT_STORE_BUSY_TIMEOUT_MS")
+	overrideString(&cfg.EventStore.Synchronous, "LOQA_EVENT_STORE_SYNCHRONOUS")
+	overrideInt(&cfg.EventStore.CacheSize, "LOQA_EVENT_STORE_CACHE_SIZE")
+	overrideInt(&cfg.EventStore.MaxOpenConns, "LOQA_EVENT_STORE_MAX_OPEN_CONNS")
+	overrideInt(&cfg.EventStore.MaxIdleConns, "LOQA_EVENT_STORE_MAX_IDLE_CONNS")
+	overrideInt(&cfg.EventStore.RedactionTruncateBytes, "LOQA_EVENT_STORE_REDACTION_TRUNCATE_BYTES")
 	overrideBool(&cfg.STT.Enabled, "LOQA_STT_ENABLED")
 	overrideString(&cfg.STT.Mode, "LOQA_STT_MODE")
 	overrideString(&cfg.STT.Command, "LOQA_STT_COMMAND")
@@ -254,6 +542,10 @@ func applyEnvOverrides(cfg *Config) {
 	overrideInt(&cfg.STT.FrameDurationMS, "LOQA_STT_FRAME_DURATION_MS")
 	overrideInt(&cfg.STT.PartialEveryMS, "LOQA_STT_PARTIAL_EVERY_MS")
 	overrideBool(&cfg.STT.PublishInterim, "LOQA_STT_PUBLISH_INTERIM")
+	overrideBool(&cfg.STT.StdinPCM, "LOQA_STT_STDIN_PCM")
+	overrideInt(&cfg.STT.ReorderWindow, "LOQA_STT_REORDER_WINDOW")
+	overrideString(&cfg.STT.ScriptFile, "LOQA_STT_SCRIPT_FILE")
+	overrideBool(&cfg.STT.ScriptCycle, "LOQA_STT_SCRIPT_CYCLE")
 	overrideBool(&cfg.LLM.Enabled, "LOQA_LLM_ENABLED")
 	overrideString(&cfg.LLM.Mode, "LOQA_LLM_MODE")
 	overrideString(&cfg.LLM.Endpoint, "LOQA_LLM_ENDPOINT")
@@ -263,6 +555,13 @@ func applyEnvOverrides(cfg *Config) {
 	overrideString(&cfg.LLM.DefaultTier, "LOQA_LLM_DEFAULT_TIER")
 	overrideInt(&cfg.LLM.MaxTokens, "LOQA_LLM_MAX_TOKENS")
 	overrideFloat(&cfg.LLM.Temperature, "LOQA_LLM_TEMPERATURE")
+	overrideString(&cfg.LLM.DefaultBackend, "LOQA_LLM_DEFAULT_BACKEND")
+	overrideBool(&cfg.LLM.ExecStreaming, "LOQA_LLM_EXEC_STREAMING")
+	overrideString(&cfg.LLM.MockBehavior, "LOQA_LLM_MOCK_BEHAVIOR")
+	overrideInt(&cfg.LLM.MaxContextTokens, "LOQA_LLM_MAX_CONTEXT_TOKENS")
+	overrideBool(&cfg.LLM.Stream, "LOQA_LLM_STREAM")
+	overrideBool(&cfg.LLM.Warmup, "LOQA_LLM_WARMUP")
+	overrideInt(&cfg.LLM.HealthIntervalMS, "LOQA_LLM_HEALTH_INTERVAL_MS")
 	overrideBool(&cfg.TTS.Enabled, "LOQA_TTS_ENABLED")
 	overrideString(&cfg.TTS.Mode, "LOQA_TTS_MODE")
 	overrideString(&cfg.TTS.Command, "LOQA_TTS_COMMAND")
@@ -270,10 +569,32 @@ func applyEnvOverrides(cfg *Config) {
 	overrideInt(&cfg.TTS.SampleRate, "LOQA_TTS_SAMPLE_RATE")
 	overrideInt(&cfg.TTS.Channels, "LOQA_TTS_CHANNELS")
 	overrideInt(&cfg.TTS.ChunkDurationMS, "LOQA_TTS_CHUNK_DURATION_MS")
+	overrideBool(&cfg.TTS.SSML, "LOQA_TTS_SSML")
+	overrideString(&cfg.TTS.VoicesCommand, "LOQA_TTS_VOICES_COMMAND")
+	overrideInt(&cfg.TTS.CacheBytes, "LOQA_TTS_CACHE_BYTES")
+	overrideFloat(&cfg.TTS.GainDB, "LOQA_TTS_GAIN_DB")
+	overrideBool(&cfg.TTS.Normalize, "LOQA_TTS_NORMALIZE")
+	overrideFloat(&cfg.TTS.NormalizePeakDBFS, "LOQA_TTS_NORMALIZE_PEAK_DBFS")
+	overrideFloat(&cfg.TTS.NormalizeMaxGainDB, "LOQA_TTS_NORMALIZE_MAX_GAIN_DB")
+	overrideInt(&cfg.TTS.MaxConcurrentSynth, "LOQA_TTS_MAX_CONCURRENT_SYNTH")
+	overrideFloat(&cfg.TTS.MockToneHz, "LOQA_TTS_MOCK_TONE_HZ")
+	overrideInt(&cfg.TTS.MockDurationMS, "LOQA_TTS_MOCK_DURATION_MS")
 	overrideBool(&cfg.Router.Enabled, "LOQA_ROUTER_ENABLED")
 	overrideString(&cfg.Router.DefaultTier, "LOQA_ROUTER_DEFAULT_TIER")
 	overrideString(&cfg.Router.DefaultVoice, "LOQA_ROUTER_DEFAULT_VOICE")
 	overrideString(&cfg.Router.Target, "LOQA_ROUTER_TARGET")
+	overrideString(&cfg.Router.Mode, "LOQA_ROUTER_MODE")
+	overrideString(&cfg.Router.SystemPrompt, "LOQA_ROUTER_SYSTEM_PROMPT")
+	overrideInt(&cfg.Router.MaxTokens, "LOQA_ROUTER_MAX_TOKENS")
+	overrideFloat(&cfg.Router.Temperature, "LOQA_ROUTER_TEMPERATURE")
+	overrideInt(&cfg.Router.DedupWindowMS, "LOQA_ROUTER_DEDUP_WINDOW_MS")
+	overrideBool(&cfg.Router.NormalizeInput, "LOQA_ROUTER_NORMALIZE_INPUT")
+	overrideBool(&cfg.Router.CapitalizeInput, "LOQA_ROUTER_CAPITALIZE_INPUT")
+	overrideFloat(&cfg.Router.MinConfidence, "LOQA_ROUTER_MIN_CONFIDENCE")
+	overrideString(&cfg.Router.ClarifyText, "LOQA_ROUTER_CLARIFY_TEXT")
+	overrideBool(&cfg.Router.IntentMode, "LOQA_ROUTER_INTENT_MODE")
+	overrideStringSlice(&cfg.Router.IntentSubjects, "LOQA_ROUTER_INTENT_SUBJECTS")
+	overrideBool(&cfg.Router.LogConversations, "LOQA_ROUTER_LOG_CONVERSATIONS")
 }
 
 func overrideString(target *string, envKey string) {
@@ -325,9 +646,21 @@ func validate(cfg Config) error {
 	if cfg.RuntimeName == "" {
 		return errors.New("runtime_name must not be empty")
 	}
+	switch strings.ToLower(cfg.Telemetry.LogLevel) {
+	case "debug", "info", "warn", "warning", "error":
+		// ok
+	default:
+		return errors.New("telemetry.log_level must be one of debug|info|warn|warning|error")
+	}
+	if cfg.ShutdownTimeoutMS <= 0 {
+		return errors.New("shutdown_timeout_ms must be > 0")
+	}
 	if cfg.HTTP.Port <= 0 || cfg.HTTP.Port > 65535 {
 		return errors.New("http.port must be between 1 and 65535")
 	}
+	if (cfg.HTTP.TLSCert == "") != (cfg.HTTP.TLSKey == "") {
+		return errors.New("http.tls_cert and http.tls_key must be set together")
+	}
 	if cfg.Bus.Embedded {
 		if cfg.Bus.Port <= 0 || cfg.Bus.Port > 65535 {
 			return errors.New("bus.port must be between 1 and 65535 when embedded mode is enabled")
@@ -337,6 +670,32 @@ func validate(cfg Config) error {
 			return errors.New("bus.servers must not be empty when embedded mode is disabled")
 		}
 	}
+	authMethods := 0
+	for _, set := range []bool{cfg.Bus.Username != "" || cfg.Bus.Password != "", cfg.Bus.Token != "", cfg.Bus.CredsFile != "", cfg.Bus.NKeySeedFile != ""} {
+		if set {
+			authMethods++
+		}
+	}
+	if authMethods > 1 {
+		return errors.New("bus must configure at most one of username/password, token, creds_file, or nkey_seed_file")
+	}
+	if p := cfg.Bus.SubjectPrefix; p != "" {
+		if strings.ContainsAny(p, " \t*>") || strings.HasPrefix(p, ".") || strings.Contains(p, "..") {
+			return errors.New("bus.subject_prefix must be dot-separated tokens without wildcards or whitespace")
+		}
+	}
+	if _, err := protocol.DefaultSubjects().WithOverrides(cfg.Bus.Subjects); err != nil {
+		return fmt.Errorf("bus.subjects: %w", err)
+	}
+	if _, err := protocol.CodecByName(cfg.Bus.Codec); err != nil {
+		return errors.New("bus.codec must be one of json|msgpack")
+	}
+	if cfg.Bus.PendingMsgs < -1 {
+		return errors.New("bus.pending_msgs must be -1 (unlimited) or >= 0")
+	}
+	if cfg.Bus.PendingBytes < -1 {
+		return errors.New("bus.pending_bytes must be -1 (unlimited) or >= 0")
+	}
 	if cfg.Node.ID == "" {
 		return errors.New("node.id must not be empty")
 	}
@@ -346,6 +705,12 @@ func validate(cfg Config) error {
 	if cfg.Node.HeartbeatTimeout <= cfg.Node.HeartbeatInterval {
 		return errors.New("node.heartbeat_timeout_ms must be greater than heartbeat interval")
 	}
+	if cfg.Node.MissedHeartbeatsThreshold < 1 {
+		return errors.New("node.missed_heartbeats_threshold must be at least 1")
+	}
+	if cfg.Node.NodeExpiry != 0 && cfg.Node.NodeExpiry <= cfg.Node.HeartbeatTimeout {
+		return errors.New("node.node_expiry_ms must be 0 or greater than heartbeat timeout")
+	}
 	if len(cfg.Node.Capabilities) == 0 {
 		return errors.New("node.capabilities must not be empty")
 	}
@@ -353,14 +718,43 @@ func validate(cfg Config) error {
 		return errors.New("event_store.path must not be empty")
 	}
 	switch cfg.EventStore.RetentionMode {
-	case "ephemeral", "session", "persistent":
+	case "ephemeral", "memory", "session", "persistent":
 		// ok
 	default:
-		return errors.New("event_store.retention_mode must be one of ephemeral|session|persistent")
+		return errors.New("event_store.retention_mode must be one of ephemeral|memory|session|persistent")
+	}
+	if cfg.EventStore.BatchSize < 0 || cfg.EventStore.FlushIntervalMS < 0 {
+		return errors.New("event_store.batch_size and flush_interval_ms must be >= 0")
 	}
 	if cfg.EventStore.RetentionDays < 0 {
 		return errors.New("event_store.retention_days must be >= 0")
 	}
+	if cfg.EventStore.BusyTimeoutMS < 0 {
+		return errors.New("event_store.busy_timeout_ms must be >= 0")
+	}
+	switch strings.ToUpper(cfg.EventStore.Synchronous) {
+	case "", "OFF", "NORMAL", "FULL", "EXTRA":
+		// ok
+	default:
+		return errors.New("event_store.synchronous must be one of OFF|NORMAL|FULL|EXTRA")
+	}
+	if cfg.EventStore.MaxOpenConns < 0 || cfg.EventStore.MaxIdleConns < 0 {
+		return errors.New("event_store.max_open_conns and max_idle_conns must be >= 0")
+	}
+	for scope, policy := range cfg.EventStore.Redaction {
+		if protocol.PrivacyRank(scope) < 0 {
+			return fmt.Errorf("event_store.redaction keys must be privacy scopes (%s), got %q", protocol.PrivacyScopesList(), scope)
+		}
+		switch policy {
+		case "keep", "hash", "truncate", "drop", "discard":
+			// ok
+		default:
+			return fmt.Errorf("event_store.redaction.%s must be one of keep|hash|truncate|drop|discard", scope)
+		}
+	}
+	if cfg.EventStore.RedactionTruncateBytes < 0 {
+		return errors.New("event_store.redaction_truncate_bytes must be >= 0")
+	}
 	if cfg.Telemetry.PrometheusBind == "" {
 		return errors.New("telemetry.prometheus_bind must not be empty")
 	}
@@ -371,6 +765,40 @@ func validate(cfg Config) error {
 		if cfg.Skills.Concurrency <= 0 {
 			return errors.New("skills.max_concurrency must be >= 1")
 		}
+		switch cfg.Skills.ConflictPolicy {
+		case "", "error", "first-wins", "last-wins", "version-wins":
+		default:
+			return errors.New("skills.skill_conflict must be one of error|first-wins|last-wins|version-wins")
+		}
+		switch cfg.Skills.AuditMode {
+		case "", "sync", "jetstream":
+		default:
+			return errors.New("skills.audit_mode must be one of sync|jetstream")
+		}
+		if cfg.Skills.MaxEventBytes < 0 {
+			return errors.New("skills.max_event_payload_bytes must be >= 0")
+		}
+		if cfg.Skills.MaxPublishBytes < 0 {
+			return errors.New("skills.max_publish_bytes must be >= 0")
+		}
+		if cfg.Skills.MaxLogBytes < 0 {
+			return errors.New("skills.max_log_bytes must be >= 0")
+		}
+		if cfg.Skills.MaxHTTPBytes < 0 {
+			return errors.New("skills.max_http_bytes must be >= 0")
+		}
+		if cfg.Skills.HTTPTimeoutMS < 0 {
+			return errors.New("skills.http_timeout_ms must be >= 0")
+		}
+		if cfg.Skills.MaxPublishesPerSecond < 0 {
+			return errors.New("skills.max_publishes_per_second must be >= 0")
+		}
+		if cfg.Skills.InvocationTimeoutMS < 0 {
+			return errors.New("skills.invocation_timeout_ms must be >= 0")
+		}
+		if cfg.Skills.DedupWindowMS < 0 {
+			return errors.New("skills.dedup_window_ms must be >= 0")
+		}
 	}
 	if cfg.Skills.AuditPrivacy == "" {
 		return errors.New("skills.audit_privacy_scope must not be empty")
@@ -382,6 +810,12 @@ func validate(cfg Config) error {
 		if cfg.STT.Channels <= 0 {
 			return errors.New("stt.channels must be positive")
 		}
+		if cfg.STT.ReorderWindow < 0 {
+			return errors.New("stt.reorder_window must be >= 0")
+		}
+		if cfg.STT.Mode == "scripted" && len(cfg.STT.ScriptTranscripts) == 0 && cfg.STT.ScriptFile == "" {
+			return errors.New("stt.script_transcripts or stt.script_file must be set when mode=scripted")
+		}
 		if cfg.STT.Mode == "exec" && cfg.STT.Command == "" {
 			return errors.New("stt.command must be set when mode=exec")
 		}
@@ -401,6 +835,47 @@ func validate(cfg Config) error {
 		if cfg.LLM.MaxTokens < 0 {
 			return errors.New("llm.max_tokens must be >= 0")
 		}
+		if err := validateMockBehavior("llm.mock_behavior", cfg.LLM.MockBehavior); err != nil {
+			return err
+		}
+		for _, stop := range cfg.LLM.Stop {
+			if stop == "" {
+				return errors.New("llm.stop entries must not be empty")
+			}
+		}
+		if cfg.LLM.HealthIntervalMS < 0 {
+			return errors.New("llm.health_interval_ms must be >= 0")
+		}
+		if cfg.LLM.MaxContextTokens < 0 {
+			return errors.New("llm.max_context_tokens must be >= 0")
+		}
+		if cfg.LLM.MaxContextTokens > 0 && cfg.LLM.MaxContextTokens <= cfg.LLM.MaxTokens {
+			return errors.New("llm.max_context_tokens must exceed llm.max_tokens")
+		}
+		names := map[string]struct{}{"default": {}, "fallback": {}}
+		for i, b := range cfg.LLM.Backends {
+			prefix := fmt.Sprintf("llm.backends[%d]", i)
+			if b.Name == "" {
+				return fmt.Errorf("%s.name must not be empty", prefix)
+			}
+			if _, dup := names[b.Name]; dup {
+				return fmt.Errorf("%s.name %q is reserved or duplicated", prefix, b.Name)
+			}
+			names[b.Name] = struct{}{}
+			if err := validateLLMBackend(prefix, b); err != nil {
+				return err
+			}
+		}
+		if cfg.LLM.Fallback.Mode != "" {
+			if err := validateLLMBackend("llm.fallback", cfg.LLM.Fallback); err != nil {
+				return err
+			}
+		}
+		if cfg.LLM.DefaultBackend != "" {
+			if _, ok := names[cfg.LLM.DefaultBackend]; !ok {
+				return fmt.Errorf("llm.default_backend %q does not match a configured backend", cfg.LLM.DefaultBackend)
+			}
+		}
 	}
 	if cfg.TTS.Enabled {
 		switch cfg.TTS.Mode {
@@ -417,6 +892,29 @@ func validate(cfg Config) error {
 		if cfg.TTS.Channels <= 0 {
 			return errors.New("tts.channels must be positive")
 		}
+		if cfg.TTS.MaxConcurrentSynth <= 0 {
+			return errors.New("tts.max_concurrent_synth must be >= 1")
+		}
+		if cfg.TTS.CacheBytes < 0 {
+			return errors.New("tts.cache_bytes must be >= 0")
+		}
+		if cfg.TTS.GainDB < -60 || cfg.TTS.GainDB > 40 {
+			return errors.New("tts.gain_db must be between -60 and 40")
+		}
+		if cfg.TTS.NormalizePeakDBFS > 0 || cfg.TTS.NormalizePeakDBFS < -60 {
+			return errors.New("tts.normalize_peak_dbfs must be between -60 and 0")
+		}
+		if cfg.TTS.NormalizeMaxGainDB < 0 || cfg.TTS.NormalizeMaxGainDB > 60 {
+			return errors.New("tts.normalize_max_gain_db must be between 0 and 60")
+		}
+		if cfg.TTS.Mode == "mock" {
+			if cfg.TTS.MockToneHz <= 0 || cfg.TTS.MockToneHz >= float64(cfg.TTS.SampleRate)/2 {
+				return errors.New("tts.mock_tone_hz must be positive and below half the sample rate")
+			}
+			if cfg.TTS.MockDurationMS < 0 {
+				return errors.New("tts.mock_duration_ms must be >= 0")
+			}
+		}
 	}
 	if cfg.Router.Enabled {
 		if cfg.Router.DefaultTier == "" {
@@ -425,6 +923,72 @@ func validate(cfg Config) error {
 		if cfg.Router.DefaultVoice == "" {
 			cfg.Router.DefaultVoice = "en-US"
 		}
+		switch cfg.Router.Mode {
+		case "", "normal", "echo":
+		default:
+			return fmt.Errorf("router.mode must be normal or echo, got %q", cfg.Router.Mode)
+		}
+		if cfg.Router.DedupWindowMS < 0 {
+			return errors.New("router.dedup_window_ms must be >= 0")
+		}
+		if cfg.Router.MinConfidence < 0 || cfg.Router.MinConfidence > 1 {
+			return errors.New("router.min_confidence must be between 0 and 1")
+		}
+		if cfg.Router.MinConfidence > 0 && strings.TrimSpace(cfg.Router.ClarifyText) == "" {
+			return errors.New("router.clarify_text must be set when router.min_confidence is enabled")
+		}
+		if cfg.Router.MaxTokens < 0 {
+			return errors.New("router.max_tokens must be >= 0")
+		}
+		if cfg.Router.Temperature < 0 {
+			return errors.New("router.temperature must be >= 0")
+		}
+		if cfg.Router.IntentMode && len(cfg.Router.IntentSubjects) == 0 {
+			return errors.New("router.intent_subjects must list at least one skill.<skill>.<action> subject when router.intent_mode is enabled")
+		}
+		for _, subject := range cfg.Router.IntentSubjects {
+			if _, err := protocol.ParseIntentSubject(subject); err != nil {
+				return fmt.Errorf("router.intent_subjects: %w", err)
+			}
+		}
+		for tier, t := range cfg.Router.Tiers {
+			if t.MaxTokens < 0 {
+				return fmt.Errorf("router.tiers.%s.max_tokens must be >= 0", tier)
+			}
+			if t.Temperature < 0 {
+				return fmt.Errorf("router.tiers.%s.temperature must be >= 0", tier)
+			}
+		}
 	}
 	return nil
 }
+
+func validateLLMBackend(prefix string, b LLMBackendConfig) error {
+	switch b.Mode {
+	case "mock", "ollama", "exec":
+	default:
+		return fmt.Errorf("%s.mode must be one of mock|ollama|exec", prefix)
+	}
+	if b.Mode == "ollama" && b.Endpoint == "" {
+		return fmt.Errorf("%s.endpoint must be set when mode=ollama", prefix)
+	}
+	if b.Mode == "exec" && b.Command == "" {
+		return fmt.Errorf("%s.command must be set when mode=exec", prefix)
+	}
+	return validateMockBehavior(prefix+".mock_behavior", b.MockBehavior)
+}
+
+func validateMockBehavior(field, behavior string) error {
+	kind, arg, _ := strings.Cut(behavior, ":")
+	switch kind {
+	case "", "default", "echo", "reverse", "fixed":
+		return nil
+	case "template":
+		if arg == "" {
+			return fmt.Errorf("%s template requires a path", field)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s must be one of default|echo|reverse|fixed:<text>|template:<path>", field)
+	}
+}
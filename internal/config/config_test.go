@@ -25,6 +25,9 @@ func TestLoadDefaults(t *testing.T) {
 	if !cfg.Router.Enabled {
 		t.Fatalf("expected router enabled by default")
 	}
+	if !cfg.LLM.Stream {
+		t.Fatalf("expected LLM streaming enabled by default")
+	}
 }
 
 func TestEnvOverrides(t *testing.T) {
@@ -52,6 +55,7 @@ func TestEnvOverrides(t *testing.T) {
 	t.Setenv("LOQA_STT_FRAME_DURATION_MS", "30")
 	t.Setenv("LOQA_STT_PARTIAL_EVERY_MS", "500")
 	t.Setenv("LOQA_STT_PUBLISH_INTERIM", "true")
+	t.Setenv("LOQA_STT_STDIN_PCM", "true")
 	t.Setenv("LOQA_LLM_ENABLED", "true")
 	t.Setenv("LOQA_LLM_MODE", "ollama")
 	t.Setenv("LOQA_LLM_ENDPOINT", "http://localhost:11434")
@@ -60,6 +64,8 @@ func TestEnvOverrides(t *testing.T) {
 	t.Setenv("LOQA_LLM_DEFAULT_TIER", "fast")
 	t.Setenv("LOQA_LLM_MAX_TOKENS", "128")
 	t.Setenv("LOQA_LLM_TEMPERATURE", "0.5")
+	t.Setenv("LOQA_LLM_EXEC_STREAMING", "true")
+	t.Setenv("LOQA_LLM_STREAM", "false")
 	t.Setenv("LOQA_TTS_ENABLED", "true")
 	t.Setenv("LOQA_TTS_MODE", "exec")
 	t.Setenv("LOQA_TTS_COMMAND", "python3 tts/kokoro.py")
@@ -122,6 +128,9 @@ func TestEnvOverrides(t *testing.T) {
 	if cfg.STT.PartialEveryMS != 500 || !cfg.STT.PublishInterim {
 		t.Fatalf("expected STT partial overrides")
 	}
+	if !cfg.STT.StdinPCM {
+		t.Fatalf("expected STT stdin_pcm override")
+	}
 	if !cfg.LLM.Enabled || cfg.LLM.Mode != "ollama" {
 		t.Fatalf("expected LLM overrides")
 	}
@@ -131,6 +140,12 @@ func TestEnvOverrides(t *testing.T) {
 	if cfg.LLM.Temperature != 0.5 {
 		t.Fatalf("expected LLM temperature override, got %f", cfg.LLM.Temperature)
 	}
+	if !cfg.LLM.ExecStreaming {
+		t.Fatalf("expected LLM exec streaming override")
+	}
+	if cfg.LLM.Stream {
+		t.Fatalf("expected LLM stream override")
+	}
 	if !cfg.TTS.Enabled || cfg.TTS.Mode != "exec" {
 		t.Fatalf("expected TTS overrides")
 	}
@@ -144,3 +159,156 @@ func TestEnvOverrides(t *testing.T) {
 		t.Fatalf("expected router overrides")
 	}
 }
+
+func TestValidateLLMBackends(t *testing.T) {
+	cases := []struct {
+		name     string
+		backends []LLMBackendConfig
+		def      string
+		fallback LLMBackendConfig
+		wantErr  bool
+	}{
+		{name: "named backends", backends: []LLMBackendConfig{{Name: "cloud", Mode: "ollama", Endpoint: "https://llm.example.com"}}, def: "cloud"},
+		{name: "missing name", backends: []LLMBackendConfig{{Mode: "mock"}}, wantErr: true},
+		{name: "reserved name", backends: []LLMBackendConfig{{Name: "default", Mode: "mock"}}, wantErr: true},
+		{name: "duplicate name", backends: []LLMBackendConfig{{Name: "a", Mode: "mock"}, {Name: "a", Mode: "mock"}}, wantErr: true},
+		{name: "bad mode", backends: []LLMBackendConfig{{Name: "a", Mode: "gpt"}}, wantErr: true},
+		{name: "exec without command", backends: []LLMBackendConfig{{Name: "a", Mode: "exec"}}, wantErr: true},
+		{name: "unknown default", def: "cloud", wantErr: true},
+		{name: "fallback", fallback: LLMBackendConfig{Mode: "ollama", Endpoint: "http://backup:11434"}},
+		{name: "fallback without endpoint", fallback: LLMBackendConfig{Mode: "ollama"}, wantErr: true},
+		{name: "fallback name reserved", backends: []LLMBackendConfig{{Name: "fallback", Mode: "mock"}}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.LLM.Enabled = true
+			cfg.LLM.Backends = tc.backends
+			cfg.LLM.Fallback = tc.fallback
+			if tc.def != "" {
+				cfg.LLM.DefaultBackend = tc.def
+			}
+			err := validate(cfg)
+			if tc.wantErr && err == nil {
+				t.Fatalf("expected validation error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateSubjectPrefix(t *testing.T) {
+	cases := map[string]bool{
+		"":          false,
+		"tenant-a":  false,
+		"tenant-a.": false,
+		"org.site.": false,
+		"tenant *":  true,
+		"tenant.>":  true,
+		".tenant":   true,
+		"a..b":      true,
+	}
+	for prefix, wantErr := range cases {
+		cfg := Default()
+		cfg.Bus.SubjectPrefix = prefix
+		err := validate(cfg)
+		if wantErr && err == nil {
+			t.Fatalf("prefix %q: expected validation error", prefix)
+		}
+		if !wantErr && err != nil {
+			t.Fatalf("prefix %q: unexpected error: %v", prefix, err)
+		}
+	}
+}
+
+func TestValidateBusAuthMethods(t *testing.T) {
+	cases := []struct {
+		mutate  func(*BusConfig)
+		wantErr bool
+	}{
+		{func(b *BusConfig) {}, false},
+		{func(b *BusConfig) { b.CredsFile = "nats.creds" }, false},
+		{func(b *BusConfig) { b.NKeySeedFile = "user.nk" }, false},
+		{func(b *BusConfig) { b.Username, b.Password = "u", "p" }, false},
+		{func(b *BusConfig) { b.CredsFile, b.Token = "nats.creds", "t" }, true},
+		{func(b *BusConfig) { b.NKeySeedFile, b.Username = "user.nk", "u" }, true},
+		{func(b *BusConfig) { b.CredsFile, b.NKeySeedFile = "nats.creds", "user.nk" }, true},
+	}
+	for i, tc := range cases {
+		cfg := Default()
+		tc.mutate(&cfg.Bus)
+		if err := validate(cfg); (err != nil) != tc.wantErr {
+			t.Errorf("case %d: wantErr=%v, got %v", i, tc.wantErr, err)
+		}
+	}
+}
+
+func TestValidateHTTPTLSPair(t *testing.T) {
+	cfg := Default()
+	cfg.HTTP.TLSCert = "cert.pem"
+	if err := validate(cfg); err == nil {
+		t.Fatal("expected cert without key to be rejected")
+	}
+	cfg.HTTP.TLSKey = "key.pem"
+	if err := validate(cfg); err != nil {
+		t.Fatalf("expected cert and key to validate: %v", err)
+	}
+}
+
+func TestValidateRouterIntentSubjects(t *testing.T) {
+	cases := []struct {
+		mutate  func(*RouterConfig)
+		wantErr bool
+	}{
+		{func(r *RouterConfig) { r.IntentMode = true }, true},
+		{func(r *RouterConfig) { r.IntentMode, r.IntentSubjects = true, []string{"skill.home.command"} }, false},
+		{func(r *RouterConfig) { r.IntentMode, r.IntentSubjects = true, []string{"skill.home"} }, true},
+		{func(r *RouterConfig) { r.IntentMode, r.IntentSubjects = true, []string{"skill.home.*"} }, true},
+		{func(r *RouterConfig) { r.IntentMode, r.IntentSubjects = true, []string{"audio.home.command"} }, true},
+	}
+	for i, tc := range cases {
+		cfg := Default()
+		tc.mutate(&cfg.Router)
+		if err := validate(cfg); (err != nil) != tc.wantErr {
+			t.Errorf("case %d: wantErr=%v, got %v", i, tc.wantErr, err)
+		}
+	}
+}
+
+func TestValidateRedactionScopes(t *testing.T) {
+	cfg := Default()
+	cfg.EventStore.Redaction = map[string]string{"private": "truncate", "sensitive": "hash"}
+	if err := validate(cfg); err != nil {
+		t.Fatalf("expected known scopes to validate: %v", err)
+	}
+	cfg.EventStore.Redaction = map[string]string{"sensitve": "hash"}
+	if err := validate(cfg); err == nil {
+		t.Fatal("expected a misspelled privacy scope to be rejected")
+	}
+}
+
+func TestValidateLogLevelAcceptsWarning(t *testing.T) {
+	cfg := Default()
+	cfg.Telemetry.LogLevel = "warning"
+	if err := validate(cfg); err != nil {
+		t.Fatalf("expected warning to validate: %v", err)
+	}
+	cfg.Telemetry.LogLevel = "verbose"
+	if err := validate(cfg); err == nil {
+		t.Fatal("expected an unknown log level to be rejected")
+	}
+}
+
+func TestLoadKeepsLegacyAuditPrivacyScopes(t *testing.T) {
+	for scope, want := range map[string]string{" Internal ": "internal", "SENSITIVE": "sensitive", "confidential": "confidential"} {
+		cfg, err := Load("", func(cfg *Config) { cfg.Skills.AuditPrivacy = scope })
+		if err != nil {
+			t.Fatalf("%q: expected the config to load, got %v", scope, err)
+		}
+		if cfg.Skills.AuditPrivacy != want {
+			t.Errorf("%q: expected %q, got %q", scope, want, cfg.Skills.AuditPrivacy)
+		}
+	}
+}
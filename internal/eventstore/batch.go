@@ -0,0 +1,206 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// DefaultFlushInterval applies when batching is enabled without
+// event_store.flush_interval_ms.
+const DefaultFlushInterval = 200 * time.Millisecond
+
+// maxPendingEvents caps the buffer while the database cannot be written;
+// the oldest events are dropped beyond it.
+const maxPendingEvents = 10000
+
+// SQLite primary result codes that mean a row can never be written.
+const (
+	sqliteConstraint = 19
+	sqliteMismatch   = 20
+)
+
+// batcher buffers events and writes them in one transaction once
+// batch_size events are pending or flush_interval_ms elapses. Close flushes
+// whatever is left, so a graceful shutdown loses nothing.
+type batcher struct {
+	size    int
+	mu      sync.Mutex
+	pending []Event
+	kick    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func (s *Store) startBatcher() {
+	interval := time.Duration(s.cfg.FlushIntervalMS) * time.Millisecond
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	s.batch = &batcher{
+		size: s.cfg.BatchSize,
+		kick: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go s.runBatcher(interval)
+}
+
+func (s *Store) runBatcher(interval time.Duration) {
+	defer close(s.batch.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.batch.stop:
+			return
+		case <-ticker.C:
+		case <-s.batch.kick:
+		}
+		if err := s.Flush(context.Background()); err != nil {
+			s.log.Warn("event store batch flush failed", slog.String("error", err.Error()))
+		}
+	}
+}
+
+func (b *batcher) add(evt Event) int {
+	b.mu.Lock()
+	b.pending = append(b.pending, evt)
+	dropped := b.trim()
+	full := len(b.pending) >= b.size
+	b.mu.Unlock()
+	if full {
+		select {
+		case b.kick <- struct{}{}:
+		default:
+		}
+	}
+	return dropped
+}
+
+// trim drops the oldest events beyond maxPendingEvents and returns how many
+// were dropped. b.mu must be held.
+func (b *batcher) trim() int {
+	over := len(b.pending) - maxPendingEvents
+	if over <= 0 {
+		return 0
+	}
+	b.pending = append([]Event(nil), b.pending[over:]...)
+	return over
+}
+
+// requeue puts events that failed transiently back in front of the buffer.
+func (b *batcher) requeue(events []Event) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(events, b.pending...)
+	return b.trim()
+}
+
+// Flush writes buffered events in a single transaction. If the batch fails,
+// its events are written one by one: events that can never be written (a
+// constraint violation, such as a session deleted before the flush) are
+// dropped and logged, and events that failed transiently stay buffered for
+// the next flush. Without batching it is a no-op.
+func (s *Store) Flush(ctx context.Context) error {
+	if s.batch == nil || s.db == nil {
+		return nil
+	}
+	b := s.batch
+	b.mu.Lock()
+	events := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+	if len(events) == 0 {
+		return nil
+	}
+	err := s.insertEvents(ctx, events)
+	if err == nil {
+		return nil
+	}
+	if !permanent(err) {
+		s.logDropped(b.requeue(events))
+		return err
+	}
+
+	var retry []Event
+	var lastErr error
+	dropped := 0
+	for _, evt := range events {
+		if err := s.insertEvents(ctx, []Event{evt}); err != nil {
+			if permanent(err) {
+				dropped++
+				continue
+			}
+			retry = append(retry, evt)
+			lastErr = err
+		}
+	}
+	if dropped > 0 {
+		s.log.Warn("event store dropped events that cannot be written",
+			slog.Int("events", dropped), slog.String("error", err.Error()))
+	}
+	if len(retry) > 0 {
+		s.logDropped(b.requeue(retry))
+		return lastErr
+	}
+	return nil
+}
+
+// permanent reports whether err means retrying the same rows cannot help.
+func permanent(err error) bool {
+	var sqlErr *sqlite.Error
+	if !errors.As(err, &sqlErr) {
+		return false
+	}
+	switch sqlErr.Code() & 0xff {
+	case sqliteConstraint, sqliteMismatch:
+		return true
+	}
+	return false
+}
+
+func (s *Store) logDropped(n int) {
+	if n > 0 {
+		s.log.Warn("event store buffer full; dropped oldest events", slog.Int("events", n), slog.Int("max_pending", maxPendingEvents))
+	}
+}
+
+// closeBatcher stops the background flusher and flushes the remainder.
+func (s *Store) closeBatcher() error {
+	if s.batch == nil {
+		return nil
+	}
+	close(s.batch.stop)
+	<-s.batch.done
+	return s.Flush(context.Background())
+}
+
+func (s *Store) insertEvents(ctx context.Context, events []Event) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO events(session_id, trace_id, actor_id, event_type, payload, privacy_scope, created_at)
+		 VALUES(?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, evt := range events {
+		if _, err = stmt.ExecContext(ctx, evt.SessionID, evt.TraceID, evt.ActorID, evt.Type, evt.Payload, evt.Privacy, evt.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
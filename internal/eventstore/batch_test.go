@@ -0,0 +1,152 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+)
+
+func TestBatchedEventsAreDurableAfterClose(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+	// A long interval and large batch keep everything buffered until Close.
+	cfg := config.EventStoreConfig{Path: path, RetentionMode: "session", BatchSize: 1000, FlushIntervalMS: int(time.Hour / time.Millisecond)}
+	es, err := Open(ctx, cfg, testutil.Logger())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := es.AppendSession(ctx, "s1", "actor", "internal"); err != nil {
+		t.Fatalf("append session: %v", err)
+	}
+	for i := 0; i < 250; i++ {
+		if err := es.AppendEvent(ctx, Event{SessionID: "s1", Type: fmt.Sprintf("e%d", i)}); err != nil {
+			t.Fatalf("append event: %v", err)
+		}
+	}
+	if err := es.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	reopened, err := Open(ctx, config.EventStoreConfig{Path: path, RetentionMode: "session"}, testutil.Logger())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	t.Cleanup(func() { _ = reopened.Close() })
+	events, err := reopened.ListSessionEvents(ctx, "s1", 1000)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 250 {
+		t.Fatalf("expected all 250 buffered events after close, got %d", len(events))
+	}
+}
+
+func TestBatcherFlushesOnSizeAndReads(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.EventStoreConfig{Path: filepath.Join(t.TempDir(), "events.db"), RetentionMode: "session", BatchSize: 3, FlushIntervalMS: int(time.Hour / time.Millisecond)}
+	es, err := Open(ctx, cfg, testutil.Logger())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = es.Close() })
+	if err := es.AppendSession(ctx, "s1", "actor", "internal"); err != nil {
+		t.Fatalf("append session: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := es.AppendEvent(ctx, Event{SessionID: "s1", Type: "e"}); err != nil {
+			t.Fatalf("append event: %v", err)
+		}
+	}
+	// A full batch is written by the background flusher without a read.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var n int
+		if err := es.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM events`).Scan(&n); err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		if n == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a full batch to flush, got %d rows", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// A partial batch is visible to reads immediately.
+	if err := es.AppendEvent(ctx, Event{SessionID: "s1", Type: "partial"}); err != nil {
+		t.Fatalf("append event: %v", err)
+	}
+	events, err := es.ListSessionEvents(ctx, "s1", 10)
+	if err != nil || len(events) != 4 {
+		t.Fatalf("expected buffered event to be readable, got %d events, %v", len(events), err)
+	}
+}
+
+func TestBatchedEventForUnknownSessionDoesNotBlockFlushes(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.EventStoreConfig{Path: filepath.Join(t.TempDir(), "events.db"), RetentionMode: "session", BatchSize: 100, FlushIntervalMS: int(time.Hour / time.Millisecond)}
+	es, err := Open(ctx, cfg, testutil.Logger())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = es.Close() })
+
+	if err := es.AppendEvent(ctx, Event{SessionID: "missing", Type: "e"}); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected unknown session to be rejected, got %v", err)
+	}
+
+	for _, id := range []string{"s1", "s2"} {
+		if err := es.AppendSession(ctx, id, "actor", "internal"); err != nil {
+			t.Fatalf("append session: %v", err)
+		}
+		if err := es.AppendEvent(ctx, Event{SessionID: id, Type: "e"}); err != nil {
+			t.Fatalf("append event: %v", err)
+		}
+	}
+	// The session disappears while its event is still buffered.
+	if _, err := es.db.ExecContext(ctx, `DELETE FROM sessions WHERE session_id = 's2'`); err != nil {
+		t.Fatalf("delete session: %v", err)
+	}
+	if err := es.Flush(ctx); err != nil {
+		t.Fatalf("expected the unwritable event to be dropped, got %v", err)
+	}
+	events, err := es.ListSessionEvents(ctx, "s1", 10)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("expected the valid event to be written, got %d events, %v", len(events), err)
+	}
+	if len(es.batch.pending) != 0 {
+		t.Fatalf("expected nothing left buffered, got %d", len(es.batch.pending))
+	}
+}
+
+func BenchmarkAppendEvent(b *testing.B) {
+	for _, batch := range []int{0, 64} {
+		b.Run(fmt.Sprintf("batch=%d", batch), func(b *testing.B) {
+			ctx := context.Background()
+			cfg := config.EventStoreConfig{Path: filepath.Join(b.TempDir(), "events.db"), RetentionMode: "session", BatchSize: batch}
+			es, err := Open(ctx, cfg, testutil.Logger())
+			if err != nil {
+				b.Fatalf("open: %v", err)
+			}
+			if err := es.AppendSession(ctx, "bench", "actor", "internal"); err != nil {
+				b.Fatalf("append session: %v", err)
+			}
+			payload := []byte(`{"chunk":"hello"}`)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := es.AppendEvent(ctx, Event{SessionID: "bench", Type: "bench", Payload: payload}); err != nil {
+					b.Fatalf("append: %v", err)
+				}
+			}
+			if err := es.Close(); err != nil {
+				b.Fatalf("close: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ExportFormat selects the encoding of ExportSession.
+type ExportFormat string
+
+const (
+	// ExportNDJSON writes one JSON event per line.
+	ExportNDJSON ExportFormat = "ndjson"
+	// ExportJSON writes a single JSON array of events.
+	ExportJSON ExportFormat = "json"
+)
+
+// ErrSessionNotFound is returned when exporting a session the store does
+// not hold.
+var ErrSessionNotFound = errors.New("session not found")
+
+// ExportedEvent is the serialized form of an Event. Payloads that are valid
+// JSON are embedded as-is; anything else is base64 encoded in PayloadBase64.
+type ExportedEvent struct {
+	ID            int64           `json:"id"`
+	SessionID     string          `json:"session_id"`
+	TraceID       string          `json:"trace_id,omitempty"`
+	ActorID       string          `json:"actor_id,omitempty"`
+	Type          string          `json:"type"`
+	Privacy       string          `json:"privacy_scope,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	PayloadBase64 []byte          `json:"payload_base64,omitempty"`
+	Redacted      bool            `json:"redacted,omitempty"`
+}
+
+// ExportOption adjusts which events ExportSession writes.
+type ExportOption func(*exportSettings)
+
+type exportSettings struct {
+	scopes map[string]bool
+	redact map[string]bool
+}
+
+// WithScopes limits the export to events whose privacy scope is listed.
+func WithScopes(scopes ...string) ExportOption {
+	return func(s *exportSettings) { s.scopes = scopeSet(scopes) }
+}
+
+// WithRedactedScopes drops the payload of events in the listed scopes while
+// keeping their metadata, marking them as redacted.
+func WithRedactedScopes(scopes ...string) ExportOption {
+	return func(s *exportSettings) { s.redact = scopeSet(scopes) }
+}
+
+func scopeSet(scopes []string) map[string]bool {
+	if len(scopes) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		set[scope] = true
+	}
+	return set
+}
+
+// ExportSession writes every event of sessionID to w in time order. It
+// returns ErrSessionNotFound when the session does not exist.
+func (s *Store) ExportSession(ctx context.Context, sessionID string, w io.Writer, format ExportFormat, opts ...ExportOption) error {
+	if format != ExportNDJSON && format != ExportJSON {
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+	var settings exportSettings
+	for _, opt := range opts {
+		opt(&settings)
+	}
+	events, err := s.allSessionEvents(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	records := make([]ExportedEvent, 0, len(events))
+	for _, evt := range events {
+		if settings.scopes != nil && !settings.scopes[evt.Privacy] {
+			continue
+		}
+		records = append(records, exportEvent(evt, settings.redact[evt.Privacy]))
+	}
+
+	enc := json.NewEncoder(w)
+	if format == ExportJSON {
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportEvent(evt Event, redact bool) ExportedEvent {
+	rec := ExportedEvent{
+		ID:        evt.ID,
+		SessionID: evt.SessionID,
+		TraceID:   evt.TraceID,
+		ActorID:   evt.ActorID,
+		Type:      evt.Type,
+		Privacy:   evt.Privacy,
+		CreatedAt: evt.CreatedAt,
+	}
+	switch {
+	case redact:
+		rec.Redacted = true
+	case len(evt.Payload) == 0:
+	case json.Valid(evt.Payload):
+		rec.Payload = json.RawMessage(evt.Payload)
+	default:
+		rec.PayloadBase64 = evt.Payload
+	}
+	return rec
+}
+
+// allSessionEvents returns the full, unpaginated timeline of sessionID.
+func (s *Store) allSessionEvents(ctx context.Context, sessionID string) ([]Event, error) {
+	if s.mem != nil {
+		if !s.mem.hasSession(sessionID) {
+			return nil, ErrSessionNotFound
+		}
+		return s.mem.listSessionEvents(sessionID, -1), nil
+	}
+	if s.cfg.RetentionMode == "ephemeral" || s.db == nil {
+		return nil, ErrSessionNotFound
+	}
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	var found int
+	err := s.db.QueryRowContext(ctx, `SELECT 1 FROM sessions WHERE session_id = ?`, sessionID).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.queryEvents(ctx,
+		`SELECT id, session_id, trace_id, actor_id, event_type, payload, privacy_scope, created_at
+		 FROM events WHERE session_id = ? ORDER BY created_at ASC`, sessionID)
+}
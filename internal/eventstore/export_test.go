@@ -0,0 +1,82 @@
+package eventstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+)
+
+func TestExportSessionRoundTrip(t *testing.T) {
+	forEachBackend(t, config.EventStoreConfig{RetentionMode: "session"}, func(t *testing.T, es *Store) {
+		ctx := context.Background()
+		base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		if err := es.AppendSession(ctx, "s1", "actor", "internal"); err != nil {
+			t.Fatalf("append session: %v", err)
+		}
+		seeded := []Event{
+			{SessionID: "s1", TraceID: "t1", Type: "stt.final", Payload: []byte(`{"text":"hi"}`), Privacy: "internal"},
+			{SessionID: "s1", Type: "audio", Payload: []byte{0x00, 0xff}, Privacy: "internal"},
+			{SessionID: "s1", Type: "llm.response", Payload: []byte(`{"text":"secret"}`), Privacy: "private"},
+		}
+		for i, evt := range seeded {
+			evt.CreatedAt = base.Add(time.Duration(i) * time.Second)
+			if err := es.AppendEvent(ctx, evt); err != nil {
+				t.Fatalf("append event: %v", err)
+			}
+		}
+
+		var ndjson bytes.Buffer
+		if err := es.ExportSession(ctx, "s1", &ndjson, ExportNDJSON); err != nil {
+			t.Fatalf("export ndjson: %v", err)
+		}
+		var lines []ExportedEvent
+		scanner := bufio.NewScanner(&ndjson)
+		for scanner.Scan() {
+			var rec ExportedEvent
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				t.Fatalf("decode line %q: %v", scanner.Text(), err)
+			}
+			lines = append(lines, rec)
+		}
+		if len(lines) != len(seeded) {
+			t.Fatalf("expected %d lines, got %d", len(seeded), len(lines))
+		}
+		if string(lines[0].Payload) != `{"text":"hi"}` || lines[0].TraceID != "t1" || !lines[0].CreatedAt.Equal(base) {
+			t.Fatalf("unexpected first record: %+v", lines[0])
+		}
+		if !bytes.Equal(lines[1].PayloadBase64, []byte{0x00, 0xff}) {
+			t.Fatalf("expected binary payload to round-trip, got %+v", lines[1])
+		}
+
+		var array bytes.Buffer
+		if err := es.ExportSession(ctx, "s1", &array, ExportJSON, WithRedactedScopes("private")); err != nil {
+			t.Fatalf("export json: %v", err)
+		}
+		var records []ExportedEvent
+		if err := json.Unmarshal(array.Bytes(), &records); err != nil {
+			t.Fatalf("decode json export: %v", err)
+		}
+		if len(records) != 3 || !records[2].Redacted || records[2].Payload != nil || records[2].Type != "llm.response" {
+			t.Fatalf("expected private payload redacted, got %+v", records)
+		}
+
+		var filtered bytes.Buffer
+		if err := es.ExportSession(ctx, "s1", &filtered, ExportJSON, WithScopes("private")); err != nil {
+			t.Fatalf("export filtered: %v", err)
+		}
+		records = nil
+		if err := json.Unmarshal(filtered.Bytes(), &records); err != nil || len(records) != 1 || records[0].Privacy != "private" {
+			t.Fatalf("expected only private events, got %+v (%v)", records, err)
+		}
+
+		if err := es.ExportSession(ctx, "missing", &bytes.Buffer{}, ExportNDJSON); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("expected ErrSessionNotFound, got %v", err)
+		}
+	})
+}
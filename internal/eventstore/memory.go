@@ -0,0 +1,227 @@
+package eventstore
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryPruneInterval spaces out the age-based prunes run on append, so a
+// busy store does not rescan every event on every write.
+const memoryPruneInterval = time.Minute
+
+// memoryStore keeps the timeline in RAM for retention_mode: memory. It
+// mirrors the SQLite schema: events belong to an existing session, deleting
+// a session deletes its events, and queries return events by creation time.
+// Nothing outlives the process, so retention is applied as data is added
+// rather than at open: a new session past maxSessions evicts the oldest,
+// and data older than retention is dropped at most every
+// memoryPruneInterval.
+type memoryStore struct {
+	mu          sync.Mutex
+	nextID      int64
+	sessions    map[string]memorySession
+	events      []Event
+	retention   time.Duration
+	maxSessions int
+	lastPrune   time.Time
+}
+
+type memorySession struct {
+	actorID   string
+	privacy   string
+	createdAt time.Time
+}
+
+// newMemoryStore returns an empty store that keeps data for retention (all
+// of it when zero) and at most maxSessions sessions (any number when zero).
+func newMemoryStore(retention time.Duration, maxSessions int) *memoryStore {
+	return &memoryStore{sessions: make(map[string]memorySession), retention: retention, maxSessions: maxSessions}
+}
+
+func (m *memoryStore) appendSession(sessionID, actorID, privacy string, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sess, ok := m.sessions[sessionID]
+	if !ok {
+		sess.createdAt = now
+	}
+	sess.actorID = actorID
+	sess.privacy = privacy
+	m.sessions[sessionID] = sess
+	if !ok {
+		m.evictSessionsLocked(sessionID)
+	}
+	m.expireLocked(now)
+}
+
+func (m *memoryStore) ensureSession(sessionID, actorID, privacy string, now time.Time) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if sess, ok := m.sessions[sessionID]; ok {
+		return sess.privacy
+	}
+	m.sessions[sessionID] = memorySession{actorID: actorID, privacy: privacy, createdAt: now}
+	m.evictSessionsLocked(sessionID)
+	m.expireLocked(now)
+	return privacy
+}
+
+func (m *memoryStore) appendEvent(evt Event, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expireLocked(now)
+	if _, ok := m.sessions[evt.SessionID]; !ok {
+		return fmt.Errorf("append event: unknown session %q", evt.SessionID)
+	}
+	m.nextID++
+	evt.ID = m.nextID
+	evt.Payload = append([]byte(nil), evt.Payload...)
+	m.events = append(m.events, evt)
+	return nil
+}
+
+// evictSessionsLocked drops the oldest sessions other than keep, with their
+// events, until at most maxSessions remain.
+func (m *memoryStore) evictSessionsLocked(keep string) {
+	if m.maxSessions <= 0 || len(m.sessions) <= m.maxSessions {
+		return
+	}
+	evicted := map[string]bool{}
+	for len(m.sessions) > m.maxSessions {
+		oldest := ""
+		for id, sess := range m.sessions {
+			if id != keep && (oldest == "" || sess.createdAt.Before(m.sessions[oldest].createdAt)) {
+				oldest = id
+			}
+		}
+		if oldest == "" {
+			break
+		}
+		delete(m.sessions, oldest)
+		evicted[oldest] = true
+	}
+	m.dropEvents(func(evt Event) bool { return evicted[evt.SessionID] })
+}
+
+// expireLocked applies the retention window when memoryPruneInterval has
+// passed since the last time it did.
+func (m *memoryStore) expireLocked(now time.Time) {
+	if m.retention <= 0 || now.Sub(m.lastPrune) < memoryPruneInterval {
+		return
+	}
+	m.lastPrune = now
+	m.pruneLocked(now.Add(-m.retention), 0)
+}
+
+func (m *memoryStore) listSessionEvents(sessionID string, limit int) []Event {
+	return m.listEvents(func(evt Event) bool { return evt.SessionID == sessionID }, limit)
+}
+
+func (m *memoryStore) hasSession(sessionID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.sessions[sessionID]
+	return ok
+}
+
+func (m *memoryStore) listTraceEvents(traceID string, limit int) []Event {
+	return m.listEvents(func(evt Event) bool { return evt.TraceID == traceID }, limit)
+}
+
+func (m *memoryStore) listEvents(match func(Event) bool, limit int) []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var events []Event
+	for _, evt := range m.events {
+		if match(evt) {
+			evt.Payload = append([]byte(nil), evt.Payload...)
+			events = append(events, evt)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+	if limit >= 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return events
+}
+
+// deleteSession removes sessionID and its events, reporting the number of
+// events removed and whether the session existed.
+func (m *memoryStore) deleteSession(sessionID string) (int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[sessionID]; !ok {
+		return 0, false
+	}
+	delete(m.sessions, sessionID)
+	return m.dropEvents(func(evt Event) bool { return evt.SessionID == sessionID }), true
+}
+
+// deleteActor removes the actor's sessions and every event it recorded.
+func (m *memoryStore) deleteActor(actorID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	owned := map[string]bool{}
+	for id, sess := range m.sessions {
+		if sess.actorID == actorID {
+			owned[id] = true
+			delete(m.sessions, id)
+		}
+	}
+	return m.dropEvents(func(evt Event) bool { return evt.ActorID == actorID || owned[evt.SessionID] })
+}
+
+func (m *memoryStore) dropEvents(match func(Event) bool) int {
+	kept := m.events[:0]
+	for _, evt := range m.events {
+		if !match(evt) {
+			kept = append(kept, evt)
+		}
+	}
+	dropped := len(m.events) - len(kept)
+	m.events = kept
+	return dropped
+}
+
+// prune drops events and sessions created before cutoff (when non-zero) and
+// then all but the maxSessions most recent sessions (when positive).
+func (m *memoryStore) prune(cutoff time.Time, maxSessions int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(cutoff, maxSessions)
+}
+
+func (m *memoryStore) pruneLocked(cutoff time.Time, maxSessions int) {
+	if !cutoff.IsZero() {
+		for id, sess := range m.sessions {
+			if sess.createdAt.Before(cutoff) {
+				delete(m.sessions, id)
+			}
+		}
+	}
+	if maxSessions > 0 && len(m.sessions) > maxSessions {
+		ids := make([]string, 0, len(m.sessions))
+		for id := range m.sessions {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return m.sessions[ids[i]].createdAt.After(m.sessions[ids[j]].createdAt)
+		})
+		for _, id := range ids[maxSessions:] {
+			delete(m.sessions, id)
+		}
+	}
+	kept := m.events[:0]
+	for _, evt := range m.events {
+		if _, ok := m.sessions[evt.SessionID]; !ok {
+			continue
+		}
+		if !cutoff.IsZero() && evt.CreatedAt.Before(cutoff) {
+			continue
+		}
+		kept = append(kept, evt)
+	}
+	m.events = kept
+}
@@ -0,0 +1,157 @@
+package eventstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+)
+
+// forEachBackend runs fn against a SQLite store and an in-memory store
+// opened with the same retention settings, so both backends are held to
+// the same behavior.
+func forEachBackend(t *testing.T, cfg config.EventStoreConfig, fn func(t *testing.T, es *Store)) {
+	t.Helper()
+	for _, mode := range []string{"sqlite", "memory"} {
+		t.Run(mode, func(t *testing.T) {
+			c := cfg
+			if mode == "memory" {
+				c.RetentionMode = "memory"
+			} else {
+				c.Path = filepath.Join(t.TempDir(), "events.db")
+			}
+			es, err := Open(context.Background(), c, testutil.Logger())
+			if err != nil {
+				t.Fatalf("open event store: %v", err)
+			}
+			t.Cleanup(func() { _ = es.Close() })
+			fn(t, es)
+		})
+	}
+}
+
+func TestMemoryStoreDoesNotTouchDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "events.db")
+	es, err := Open(context.Background(), config.EventStoreConfig{Path: path, RetentionMode: "memory"}, testutil.Logger())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if es.db != nil || es.mem == nil {
+		t.Fatal("expected an in-memory store without SQLite")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*")); len(matches) != 0 {
+		t.Fatalf("memory store created files: %v", matches)
+	}
+}
+
+func TestBackendsAppendAndQuery(t *testing.T) {
+	forEachBackend(t, config.EventStoreConfig{RetentionMode: "session"}, func(t *testing.T, es *Store) {
+		ctx := context.Background()
+		base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		if err := es.AppendSession(ctx, "s1", "actor", "internal"); err != nil {
+			t.Fatalf("append session: %v", err)
+		}
+		// Appended out of order; queries sort by creation time.
+		for i, offset := range []int{2, 0, 1} {
+			evt := Event{SessionID: "s1", Type: "step", Payload: []byte{byte('a' + i)}, CreatedAt: base.Add(time.Duration(offset) * time.Second)}
+			if err := es.AppendEvent(ctx, evt); err != nil {
+				t.Fatalf("append event: %v", err)
+			}
+		}
+		if err := es.AppendEvent(ctx, Event{SessionID: "missing", Type: "orphan"}); err == nil {
+			t.Fatal("expected an event for an unknown session to be rejected")
+		}
+
+		events, err := es.ListSessionEvents(ctx, "s1", 2)
+		if err != nil {
+			t.Fatalf("list events: %v", err)
+		}
+		if len(events) != 2 || string(events[0].Payload) != "b" || string(events[1].Payload) != "c" {
+			t.Fatalf("expected the two earliest events in order, got %+v", events)
+		}
+		if events[0].ID == 0 || events[0].ID == events[1].ID {
+			t.Fatalf("expected distinct event IDs, got %+v", events)
+		}
+		if other, _ := es.ListSessionEvents(ctx, "other", 10); len(other) != 0 {
+			t.Fatalf("expected no events for another session, got %+v", other)
+		}
+	})
+}
+
+func TestBackendsPruneByDaysAndSessions(t *testing.T) {
+	cfg := config.EventStoreConfig{RetentionMode: "persistent", RetentionDays: 1, MaxSessions: 1}
+	forEachBackend(t, cfg, func(t *testing.T, es *Store) {
+		ctx := context.Background()
+		es.clock = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+		if err := es.AppendSession(ctx, "old-session", "actor", "session"); err != nil {
+			t.Fatalf("append session: %v", err)
+		}
+		if err := es.AppendEvent(ctx, Event{SessionID: "old-session", Type: "note"}); err != nil {
+			t.Fatalf("append event: %v", err)
+		}
+
+		es.clock = func() time.Time { return time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC) }
+		for _, id := range []string{"mid-session", "new-session"} {
+			if err := es.AppendSession(ctx, id, "actor", "session"); err != nil {
+				t.Fatalf("append session: %v", err)
+			}
+			if err := es.AppendEvent(ctx, Event{SessionID: id, Type: "note"}); err != nil {
+				t.Fatalf("append event: %v", err)
+			}
+			es.clock = func() time.Time { return time.Date(2025, 1, 3, 1, 0, 0, 0, time.UTC) }
+		}
+		if err := es.Prune(ctx); err != nil {
+			t.Fatalf("prune: %v", err)
+		}
+
+		for id, want := range map[string]int{"old-session": 0, "mid-session": 0, "new-session": 1} {
+			events, err := es.ListSessionEvents(ctx, id, 10)
+			if err != nil {
+				t.Fatalf("list events: %v", err)
+			}
+			if len(events) != want {
+				t.Errorf("%s: expected %d events after prune, got %d", id, want, len(events))
+			}
+		}
+	})
+}
+
+func TestMemoryStoreAppliesRetentionOnAppend(t *testing.T) {
+	ctx := context.Background()
+	es, err := Open(ctx, config.EventStoreConfig{RetentionMode: "memory", RetentionDays: 1, MaxSessions: 2}, testutil.Logger())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	es.clock = func() time.Time { return now }
+	for i, id := range []string{"s1", "s2", "s3"} {
+		now = now.Add(time.Second)
+		if _, err := es.EnsureSession(ctx, id, "actor", "session"); err != nil {
+			t.Fatalf("ensure session: %v", err)
+		}
+		if err := es.AppendEvent(ctx, Event{SessionID: id, Type: "note"}); err != nil {
+			t.Fatalf("append event %d: %v", i, err)
+		}
+	}
+	if es.mem.hasSession("s1") || !es.mem.hasSession("s2") || !es.mem.hasSession("s3") {
+		t.Fatal("expected the oldest session evicted once max_sessions was exceeded")
+	}
+	if events, _ := es.ListSessionEvents(ctx, "s1", 10); len(events) != 0 {
+		t.Fatalf("expected the evicted session's events dropped, got %+v", events)
+	}
+
+	// Two days later the next write expires everything older than a day.
+	now = now.Add(48 * time.Hour)
+	if err := es.AppendSession(ctx, "s4", "actor", "session"); err != nil {
+		t.Fatalf("append session: %v", err)
+	}
+	if es.mem.hasSession("s2") || es.mem.hasSession("s3") || len(es.mem.events) != 0 {
+		t.Fatalf("expected expired sessions and events dropped on append, got %d events", len(es.mem.events))
+	}
+	if !es.mem.hasSession("s4") {
+		t.Fatal("expected the new session kept")
+	}
+}
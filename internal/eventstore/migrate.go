@@ -0,0 +1,168 @@
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrSchemaTooNew is returned when a database was migrated by a newer
+// binary than this one. Opening it anyway could write rows the newer schema
+// does not expect, so the store refuses.
+var ErrSchemaTooNew = errors.New("event store schema is newer than this binary supports")
+
+// Migration is one versioned step of a SQLite schema. Versions start at 1
+// and must be unique; Up runs inside a transaction that also records the
+// version, so a failed step leaves the database at the previous version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations is the event store schema history. Append new steps with the
+// next version; never edit a step that has shipped.
+var migrations = []Migration{
+	{Version: 1, Name: "initial schema", Up: migrateInitialSchema},
+	{Version: 2, Name: "index events by trace", Up: migrateTraceIndex},
+}
+
+// Migrate applies the migrations newer than the version recorded in the
+// schema_version table, in version order, and returns the resulting version.
+// Databases created before versioning existed start at version 0. A
+// database already past the last step fails with ErrSchemaTooNew.
+func Migrate(ctx context.Context, db *sql.DB, steps []Migration) (int, error) {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+    version INTEGER NOT NULL,
+    name TEXT,
+    applied_at TIMESTAMP NOT NULL
+)`); err != nil {
+		return 0, fmt.Errorf("create schema_version: %w", err)
+	}
+	current, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	ordered := append([]Migration(nil), steps...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Version < ordered[j].Version })
+	for i, m := range ordered {
+		if m.Version < 1 || m.Up == nil {
+			return current, fmt.Errorf("migration %d (%s) is invalid", m.Version, m.Name)
+		}
+		if i > 0 && ordered[i-1].Version == m.Version {
+			return current, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+	}
+	if err := checkSchemaVersion(current, ordered); err != nil {
+		return current, err
+	}
+
+	for _, m := range ordered {
+		if m.Version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, db, m); err != nil {
+			return current, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		current = m.Version
+	}
+	return current, nil
+}
+
+// SchemaVersion reports the highest applied migration, or 0 when none has
+// been recorded.
+func SchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// checkSchemaVersion fails with ErrSchemaTooNew when version is past the
+// highest of steps.
+func checkSchemaVersion(version int, steps []Migration) error {
+	latest := 0
+	for _, m := range steps {
+		if m.Version > latest {
+			latest = m.Version
+		}
+	}
+	if version > latest {
+		return fmt.Errorf("%w: database is at version %d, latest known is %d", ErrSchemaTooNew, version, latest)
+	}
+	return nil
+}
+
+// checkReadableSchema is the read-only counterpart of Migrate's version
+// check: it changes nothing, and a database without a schema_version table
+// predates versioning and passes.
+func checkReadableSchema(ctx context.Context, db *sql.DB, steps []Migration) error {
+	var tables int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`).Scan(&tables); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	if tables == 0 {
+		return nil
+	}
+	version, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	return checkSchemaVersion(version, steps)
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m Migration) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if err = m.Up(ctx, tx); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx,
+		`INSERT INTO schema_version(version, name, applied_at) VALUES(?, ?, CURRENT_TIMESTAMP)`,
+		m.Version, m.Name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrateInitialSchema creates the original tables. It uses IF NOT EXISTS so
+// databases created before versioning adopt version 1 unchanged.
+func migrateInitialSchema(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS sessions (
+    session_id TEXT PRIMARY KEY,
+    actor_id TEXT,
+    privacy_scope TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    trace_id TEXT,
+    actor_id TEXT,
+    event_type TEXT,
+    payload BLOB,
+    privacy_scope TEXT,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY(session_id) REFERENCES sessions(session_id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_events_session_created ON events(session_id, created_at);
+`)
+	return err
+}
+
+func migrateTraceIndex(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_events_trace_created ON events(trace_id, created_at)`)
+	return err
+}
@@ -0,0 +1,168 @@
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+)
+
+// legacySchema is the DDL written by releases before schema versioning.
+const legacySchema = `
+CREATE TABLE sessions (
+    session_id TEXT PRIMARY KEY,
+    actor_id TEXT,
+    privacy_scope TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE TABLE events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    trace_id TEXT,
+    actor_id TEXT,
+    event_type TEXT,
+    payload BLOB,
+    privacy_scope TEXT,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY(session_id) REFERENCES sessions(session_id) ON DELETE CASCADE
+);
+`
+
+func TestOpenMigratesLegacyDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatalf("open legacy db: %v", err)
+	}
+	now := time.Now().UTC()
+	if _, err := db.ExecContext(ctx, legacySchema); err != nil {
+		t.Fatalf("create legacy schema: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO sessions(session_id, actor_id, privacy_scope, created_at) VALUES('s1', 'a', 'internal', ?)`, now); err != nil {
+		t.Fatalf("seed session: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO events(session_id, trace_id, actor_id, event_type, payload, privacy_scope, created_at) VALUES('s1', '', 'a', 'legacy', x'', 'internal', ?)`, now); err != nil {
+		t.Fatalf("seed event: %v", err)
+	}
+	db.Close()
+
+	es, err := Open(ctx, config.EventStoreConfig{Path: path, RetentionMode: "session"}, testutil.Logger())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = es.Close() })
+	version, err := SchemaVersion(ctx, es.db)
+	if err != nil {
+		t.Fatalf("schema version: %v", err)
+	}
+	if want := migrations[len(migrations)-1].Version; version != want {
+		t.Fatalf("expected schema version %d, got %d", want, version)
+	}
+	events, err := es.ListSessionEvents(ctx, "s1", 10)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "legacy" {
+		t.Fatalf("expected legacy event to survive migration, got %+v", events)
+	}
+}
+
+func TestMigrateAppliesPendingStepsInOrder(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if v, err := Migrate(ctx, db, migrations[:1]); err != nil || v != 1 {
+		t.Fatalf("initial migrate: version %d, err %v", v, err)
+	}
+	var applied []int
+	steps := append([]Migration{
+		{Version: 3, Name: "index subject", Up: func(ctx context.Context, tx *sql.Tx) error {
+			applied = append(applied, 3)
+			_, err := tx.ExecContext(ctx, `CREATE INDEX idx_events_subject ON events(subject)`)
+			return err
+		}},
+		{Version: 2, Name: "add subject", Up: func(ctx context.Context, tx *sql.Tx) error {
+			applied = append(applied, 2)
+			_, err := tx.ExecContext(ctx, `ALTER TABLE events ADD COLUMN subject TEXT`)
+			return err
+		}},
+	}, migrations[:1]...)
+	v, err := Migrate(ctx, db, steps)
+	if err != nil || v != 3 {
+		t.Fatalf("forward migrate: version %d, err %v", v, err)
+	}
+	if len(applied) != 2 || applied[0] != 2 || applied[1] != 3 {
+		t.Fatalf("expected migrations 2 then 3, got %v", applied)
+	}
+	if _, err := db.ExecContext(ctx, `SELECT subject FROM events`); err != nil {
+		t.Fatalf("expected subject column: %v", err)
+	}
+
+	// Re-running is a no-op.
+	applied = nil
+	if v, err := Migrate(ctx, db, steps); err != nil || v != 3 || len(applied) != 0 {
+		t.Fatalf("re-run: version %d, applied %v, err %v", v, applied, err)
+	}
+}
+
+func TestMigrateFailureKeepsPreviousVersion(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	boom := errors.New("boom")
+	steps := append(append([]Migration(nil), migrations...), Migration{
+		Version: 99, Name: "broken", Up: func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, `CREATE TABLE half_done (id INTEGER)`); err != nil {
+				return err
+			}
+			return boom
+		},
+	})
+	v, err := Migrate(ctx, db, steps)
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected migration error, got %v", err)
+	}
+	if want := migrations[len(migrations)-1].Version; v != want {
+		t.Fatalf("expected version %d after failure, got %d", want, v)
+	}
+	if _, err := db.ExecContext(ctx, `SELECT id FROM half_done`); err == nil {
+		t.Fatal("expected failed migration to roll back")
+	}
+}
+
+func TestOpenRefusesNewerSchema(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	future := append(append([]Migration(nil), migrations...), Migration{
+		Version: 99, Name: "from a newer release", Up: func(context.Context, *sql.Tx) error { return nil },
+	})
+	if _, err := Migrate(ctx, db, future); err != nil {
+		t.Fatalf("migrate ahead: %v", err)
+	}
+	db.Close()
+
+	cfg := config.EventStoreConfig{Path: path, RetentionMode: "session"}
+	for name, opts := range map[string][]OpenOption{"read-write": nil, "read-only": {ReadOnly()}} {
+		if _, err := Open(ctx, cfg, testutil.Logger(), opts...); !errors.Is(err, ErrSchemaTooNew) {
+			t.Errorf("%s: expected ErrSchemaTooNew, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package eventstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"unicode/utf8"
+)
+
+// Redaction policies for event_store.redaction, keyed by privacy scope.
+const (
+	RedactKeep     = "keep"
+	RedactHash     = "hash"
+	RedactTruncate = "truncate"
+	RedactDrop     = "drop"
+	RedactDiscard  = "discard"
+)
+
+// DefaultRedactionTruncateBytes is the payload prefix kept by the truncate
+// policy when event_store.redaction_truncate_bytes is unset.
+const DefaultRedactionTruncateBytes = 64
+
+// redact applies the redaction policy for evt's privacy scope before the
+// event is stored. It reports false when the event must not be stored.
+func (s *Store) redact(evt Event) (Event, bool) {
+	switch s.cfg.Redaction[evt.Privacy] {
+	case RedactDiscard:
+		return evt, false
+	case RedactDrop:
+		evt.Payload = nil
+	case RedactHash:
+		if len(evt.Payload) > 0 {
+			sum := sha256.Sum256(evt.Payload)
+			evt.Payload, _ = json.Marshal(map[string]any{
+				"redacted": RedactHash,
+				"sha256":   hex.EncodeToString(sum[:]),
+				"bytes":    len(evt.Payload),
+			})
+		}
+	case RedactTruncate:
+		limit := s.cfg.RedactionTruncateBytes
+		if limit <= 0 {
+			limit = DefaultRedactionTruncateBytes
+		}
+		if len(evt.Payload) > limit {
+			evt.Payload, _ = json.Marshal(map[string]any{
+				"redacted": RedactTruncate,
+				"prefix":   string(truncateUTF8(evt.Payload, limit)),
+				"bytes":    len(evt.Payload),
+			})
+		}
+	}
+	return evt, true
+}
+
+// truncateUTF8 cuts b to at most limit bytes without splitting a UTF-8
+// sequence, so the prefix survives being stored as a JSON string.
+func truncateUTF8(b []byte, limit int) []byte {
+	for limit > 0 && !utf8.RuneStart(b[limit]) {
+		limit--
+	}
+	return b[:limit]
+}
@@ -0,0 +1,87 @@
+package eventstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+)
+
+func TestRedactionPolicyPerScope(t *testing.T) {
+	cfg := config.EventStoreConfig{
+		RetentionMode: "session",
+		Redaction: map[string]string{
+			"public":    "discard",
+			"internal":  "keep",
+			"session":   "drop",
+			"private":   "truncate",
+			"sensitive": "hash",
+		},
+		RedactionTruncateBytes: 4,
+	}
+	forEachBackend(t, cfg, func(t *testing.T, es *Store) {
+		ctx := context.Background()
+		if err := es.AppendSession(ctx, "s1", "actor", "sensitive"); err != nil {
+			t.Fatalf("append session: %v", err)
+		}
+		payload := []byte(`{"text":"call mom at 555-0100"}`)
+		for _, scope := range []string{"public", "internal", "session", "private", "sensitive"} {
+			if err := es.AppendEvent(ctx, Event{SessionID: "s1", Type: scope, Payload: payload, Privacy: scope}); err != nil {
+				t.Fatalf("append %s event: %v", scope, err)
+			}
+		}
+		events, err := es.ListSessionEvents(ctx, "s1", 10)
+		if err != nil {
+			t.Fatalf("list events: %v", err)
+		}
+		got := map[string]Event{}
+		for _, evt := range events {
+			got[evt.Type] = evt
+		}
+
+		if _, ok := got["public"]; ok || len(events) != 4 {
+			t.Fatalf("expected the discard scope to be dropped, got %d events", len(events))
+		}
+		if string(got["internal"].Payload) != string(payload) {
+			t.Fatalf("expected internal payload kept, got %s", got["internal"].Payload)
+		}
+		var truncated struct {
+			Redacted string `json:"redacted"`
+			Prefix   string `json:"prefix"`
+			Bytes    int    `json:"bytes"`
+		}
+		if err := json.Unmarshal(got["private"].Payload, &truncated); err != nil {
+			t.Fatalf("decode truncated payload %q: %v", got["private"].Payload, err)
+		}
+		if truncated.Redacted != "truncate" || truncated.Prefix != `{"te` || truncated.Bytes != len(payload) {
+			t.Fatalf("unexpected truncated payload %+v", truncated)
+		}
+		if len(got["session"].Payload) != 0 {
+			t.Fatalf("expected session payload dropped, got %q", got["session"].Payload)
+		}
+		var hashed struct {
+			Redacted string `json:"redacted"`
+			SHA256   string `json:"sha256"`
+			Bytes    int    `json:"bytes"`
+		}
+		if err := json.Unmarshal(got["sensitive"].Payload, &hashed); err != nil {
+			t.Fatalf("decode hashed payload: %v", err)
+		}
+		sum := sha256.Sum256(payload)
+		if hashed.Redacted != "hash" || hashed.SHA256 != hex.EncodeToString(sum[:]) || hashed.Bytes != len(payload) {
+			t.Fatalf("unexpected hashed payload %+v", hashed)
+		}
+	})
+}
+
+func TestTruncateUTF8KeepsWholeRunes(t *testing.T) {
+	if got := string(truncateUTF8([]byte("héllo"), 2)); got != "h" {
+		t.Fatalf("expected the split rune dropped, got %q", got)
+	}
+	if got := string(truncateUTF8([]byte("héllo"), 3)); got != "hé" {
+		t.Fatalf("expected the whole rune kept, got %q", got)
+	}
+}
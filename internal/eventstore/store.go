@@ -8,6 +8,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/loqalabs/loqa-core/internal/config"
@@ -26,83 +27,142 @@ type Event struct {
 	CreatedAt time.Time
 }
 
-// Store wraps a SQLite-backed event timeline store.
+// Store wraps a SQLite-backed event timeline store. With retention_mode
+// memory the timeline lives in mem instead and db is nil.
 type Store struct {
 	db    *sql.DB
+	mem   *memoryStore
+	batch *batcher
 	cfg   config.EventStoreConfig
 	log   *slog.Logger
 	clock func() time.Time
 }
 
+// OpenOption adjusts how Open prepares the store.
+type OpenOption func(*openSettings)
+
+type openSettings struct {
+	maintenance bool
+	readOnly    bool
+}
+
+// WithoutMaintenance skips the vacuum and retention prune Open otherwise
+// runs, for offline commands that must not change what they inspect.
+func WithoutMaintenance() OpenOption {
+	return func(s *openSettings) { s.maintenance = false }
+}
+
+// ReadOnly opens an existing database for reading only. It implies
+// WithoutMaintenance, leaves the schema as it is and starts no batcher.
+func ReadOnly() OpenOption {
+	return func(s *openSettings) { s.maintenance, s.readOnly = false, true }
+}
+
 // Open initializes the event store according to config.
-func Open(ctx context.Context, cfg config.EventStoreConfig, log *slog.Logger) (*Store, error) {
+func Open(ctx context.Context, cfg config.EventStoreConfig, log *slog.Logger, opts ...OpenOption) (*Store, error) {
+	settings := openSettings{maintenance: true}
+	for _, opt := range opts {
+		opt(&settings)
+	}
 	if cfg.RetentionMode == "ephemeral" {
 		return &Store{cfg: cfg, log: log, clock: time.Now}, nil
 	}
+	if cfg.RetentionMode == "memory" {
+		mem := newMemoryStore(retentionPeriod(cfg), cfg.MaxSessions)
+		return &Store{mem: mem, cfg: cfg, log: log, clock: time.Now}, nil
+	}
 
 	dir := filepath.Dir(cfg.Path)
-	if dir != "." && dir != "" {
+	if dir != "." && dir != "" && !settings.readOnly {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
 			return nil, fmt.Errorf("create data dir: %w", err)
 		}
 	}
 
-	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=foreign_keys(ON)", cfg.Path)
-	db, err := sql.Open("sqlite", dsn)
+	db, err := sql.Open("sqlite", sqliteDSN(cfg, settings.readOnly))
 	if err != nil {
 		return nil, fmt.Errorf("open sqlite: %w", err)
 	}
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
 	if err := db.PingContext(ctx); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("ping sqlite: %w", err)
 	}
 
 	s := &Store{db: db, cfg: cfg, log: log, clock: time.Now}
+	if settings.readOnly {
+		if err := checkReadableSchema(ctx, db, migrations); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return s, nil
+	}
 
 	if err := s.initSchema(ctx); err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	if cfg.VacuumOnStart {
-		if err := s.vacuum(ctx); err != nil {
-			log.Warn("event store vacuum failed", slog.String("error", err.Error()))
+	if settings.maintenance {
+		if cfg.VacuumOnStart {
+			if err := s.vacuum(ctx); err != nil {
+				log.Warn("event store vacuum failed", slog.String("error", err.Error()))
+			}
+		}
+		if err := s.Prune(ctx); err != nil {
+			log.Warn("event store prune on start failed", slog.String("error", err.Error()))
 		}
 	}
 
-	if err := s.Prune(ctx); err != nil {
-		log.Warn("event store prune on start failed", slog.String("error", err.Error()))
+	if cfg.BatchSize > 1 {
+		s.startBatcher()
 	}
 
 	return s, nil
 }
 
+// sqliteDSN builds the connection string. Pragmas in the DSN run on every
+// new connection, so pooled connections share the same settings;
+// busy_timeout comes first so the remaining pragmas also wait on locks. A
+// read-only connection leaves the journal mode as the writer set it.
+func sqliteDSN(cfg config.EventStoreConfig, readOnly bool) string {
+	pragmas := []string{}
+	if cfg.BusyTimeoutMS > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("busy_timeout(%d)", cfg.BusyTimeoutMS))
+	}
+	if !readOnly {
+		pragmas = append(pragmas, "journal_mode(WAL)")
+	}
+	pragmas = append(pragmas, "foreign_keys(ON)")
+	if cfg.Synchronous != "" {
+		pragmas = append(pragmas, fmt.Sprintf("synchronous(%s)", strings.ToUpper(cfg.Synchronous)))
+	}
+	if cfg.CacheSize != 0 {
+		pragmas = append(pragmas, fmt.Sprintf("cache_size(%d)", cfg.CacheSize))
+	}
+	dsn := fmt.Sprintf("file:%s?_pragma=%s", cfg.Path, strings.Join(pragmas, "&_pragma="))
+	if readOnly {
+		dsn += "&mode=ro"
+	}
+	return dsn
+}
+
+// initSchema brings the database up to the latest schema version.
 func (s *Store) initSchema(ctx context.Context) error {
 	if s.db == nil {
 		return nil
 	}
-	ddl := `
-CREATE TABLE IF NOT EXISTS sessions (
-    session_id TEXT PRIMARY KEY,
-    actor_id TEXT,
-    privacy_scope TEXT,
-    created_at TIMESTAMP NOT NULL
-);
-CREATE TABLE IF NOT EXISTS events (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    session_id TEXT NOT NULL,
-    trace_id TEXT,
-    actor_id TEXT,
-    event_type TEXT,
-    payload BLOB,
-    privacy_scope TEXT,
-    created_at TIMESTAMP NOT NULL,
-    FOREIGN KEY(session_id) REFERENCES sessions(session_id) ON DELETE CASCADE
-);
-CREATE INDEX IF NOT EXISTS idx_events_session_created ON events(session_id, created_at);
-`
-	_, err := s.db.ExecContext(ctx, ddl)
-	return err
+	version, err := Migrate(ctx, s.db, migrations)
+	if err != nil {
+		return err
+	}
+	s.log.Debug("event store schema ready", slog.Int("version", version))
+	return nil
 }
 
 func (s *Store) vacuum(ctx context.Context) error {
@@ -113,16 +173,23 @@ func (s *Store) vacuum(ctx context.Context) error {
 	return err
 }
 
-// Close releases underlying resources.
+// Close flushes buffered events and releases underlying resources.
 func (s *Store) Close() error {
 	if s.db == nil {
 		return nil
 	}
+	if err := s.closeBatcher(); err != nil {
+		s.log.Error("event store lost buffered events on close", slog.Int("events", len(s.batch.pending)), slog.String("error", err.Error()))
+	}
 	return s.db.Close()
 }
 
 // AppendSession ensures a session row exists.
 func (s *Store) AppendSession(ctx context.Context, sessionID, actorID, privacy string) error {
+	if s.mem != nil {
+		s.mem.appendSession(sessionID, actorID, privacy, s.clock().UTC())
+		return nil
+	}
 	if s.cfg.RetentionMode == "ephemeral" || s.db == nil {
 		return nil
 	}
@@ -134,14 +201,57 @@ func (s *Store) AppendSession(ctx context.Context, sessionID, actorID, privacy s
 	return err
 }
 
-// AppendEvent writes an event into the store.
-func (s *Store) AppendEvent(ctx context.Context, evt Event) error {
+// EnsureSession creates the session if it does not exist yet, leaving an
+// existing session's actor and privacy scope untouched, and returns the
+// session's privacy scope.
+func (s *Store) EnsureSession(ctx context.Context, sessionID, actorID, privacy string) (string, error) {
+	if s.mem != nil {
+		return s.mem.ensureSession(sessionID, actorID, privacy, s.clock().UTC()), nil
+	}
 	if s.cfg.RetentionMode == "ephemeral" || s.db == nil {
+		return privacy, nil
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO sessions(session_id, actor_id, privacy_scope, created_at)
+		 VALUES(?, ?, ?, ?)
+		 ON CONFLICT(session_id) DO NOTHING`,
+		sessionID, actorID, privacy, s.clock().UTC())
+	if err != nil {
+		return "", err
+	}
+	var scope string
+	err = s.db.QueryRowContext(ctx, `SELECT privacy_scope FROM sessions WHERE session_id = ?`, sessionID).Scan(&scope)
+	return scope, err
+}
+
+// AppendEvent writes an event into the store. The payload is first redacted
+// according to event_store.redaction for the event's privacy scope. With
+// event_store.batch_size above 1 the event is buffered and written by the
+// next batch flush; an event for a session that does not exist is rejected
+// up front with ErrSessionNotFound.
+func (s *Store) AppendEvent(ctx context.Context, evt Event) error {
+	if s.mem == nil && (s.cfg.RetentionMode == "ephemeral" || s.db == nil) {
+		return nil
+	}
+	evt, keep := s.redact(evt)
+	if !keep {
 		return nil
 	}
 	if evt.CreatedAt.IsZero() {
 		evt.CreatedAt = s.clock().UTC()
 	}
+	if s.mem != nil {
+		return s.mem.appendEvent(evt, s.clock().UTC())
+	}
+	if s.batch != nil {
+		// A buffered event is written later, so check its session now
+		// rather than failing the whole batch on the foreign key.
+		if err := s.sessionExists(ctx, evt.SessionID); err != nil {
+			return err
+		}
+		s.logDropped(s.batch.add(evt))
+		return nil
+	}
 	_, err := s.db.ExecContext(ctx,
 		`INSERT INTO events(session_id, trace_id, actor_id, event_type, payload, privacy_scope, created_at)
 		 VALUES(?, ?, ?, ?, ?, ?, ?)`,
@@ -149,17 +259,57 @@ func (s *Store) AppendEvent(ctx context.Context, evt Event) error {
 	return err
 }
 
+func (s *Store) sessionExists(ctx context.Context, sessionID string) error {
+	var one int
+	err := s.db.QueryRowContext(ctx, `SELECT 1 FROM sessions WHERE session_id = ?`, sessionID).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	return err
+}
+
 // ListSessionEvents retrieves up to limit events for a session ordered ascending by time.
 func (s *Store) ListSessionEvents(ctx context.Context, sessionID string, limit int) ([]Event, error) {
-	if s.cfg.RetentionMode == "ephemeral" || s.db == nil {
+	if s.mem == nil && (s.cfg.RetentionMode == "ephemeral" || s.db == nil) {
 		return nil, nil
 	}
 	if limit <= 0 {
 		limit = 100
 	}
-	rows, err := s.db.QueryContext(ctx,
+	if s.mem != nil {
+		return s.mem.listSessionEvents(sessionID, limit), nil
+	}
+	// Read your writes: buffered events are flushed before querying.
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return s.queryEvents(ctx,
 		`SELECT id, session_id, trace_id, actor_id, event_type, payload, privacy_scope, created_at
 		 FROM events WHERE session_id = ? ORDER BY created_at ASC LIMIT ?`, sessionID, limit)
+}
+
+// ListEventsByTrace retrieves up to limit events sharing traceID, across
+// sessions, ordered ascending by time.
+func (s *Store) ListEventsByTrace(ctx context.Context, traceID string, limit int) ([]Event, error) {
+	if s.mem == nil && (s.cfg.RetentionMode == "ephemeral" || s.db == nil) {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	if s.mem != nil {
+		return s.mem.listTraceEvents(traceID, limit), nil
+	}
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return s.queryEvents(ctx,
+		`SELECT id, session_id, trace_id, actor_id, event_type, payload, privacy_scope, created_at
+		 FROM events WHERE trace_id = ? ORDER BY created_at ASC LIMIT ?`, traceID, limit)
+}
+
+func (s *Store) queryEvents(ctx context.Context, query string, args ...any) ([]Event, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -180,11 +330,23 @@ func (s *Store) ListSessionEvents(ctx context.Context, sessionID string, limit i
 	return events, rows.Err()
 }
 
-// Prune applies configured retention (called on startup and can be scheduled).
+// Prune applies configured retention (called on startup and can be
+// scheduled). The memory backend also applies it as data is appended.
 func (s *Store) Prune(ctx context.Context) error {
+	if s.mem != nil {
+		var cutoff time.Time
+		if period := retentionPeriod(s.cfg); period > 0 {
+			cutoff = s.clock().Add(-period).UTC()
+		}
+		s.mem.prune(cutoff, s.cfg.MaxSessions)
+		return nil
+	}
 	if s.cfg.RetentionMode == "ephemeral" || s.db == nil {
 		return nil
 	}
+	if err := s.Flush(ctx); err != nil {
+		return err
+	}
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -199,8 +361,8 @@ func (s *Store) Prune(ctx context.Context) error {
 		// nothing to prune
 		return tx.Commit()
 	}
-	if s.cfg.RetentionDays > 0 {
-		cutoff := s.clock().Add(-time.Duration(s.cfg.RetentionDays) * 24 * time.Hour)
+	if period := retentionPeriod(s.cfg); period > 0 {
+		cutoff := s.clock().Add(-period)
 		if _, err = tx.ExecContext(ctx, `DELETE FROM events WHERE created_at < ?`, cutoff.UTC()); err != nil {
 			return err
 		}
@@ -220,6 +382,91 @@ func (s *Store) Prune(ctx context.Context) error {
 	return err
 }
 
+// retentionPeriod is event_store.retention_days as a duration, zero when
+// data is kept indefinitely.
+func retentionPeriod(cfg config.EventStoreConfig) time.Duration {
+	return time.Duration(cfg.RetentionDays) * 24 * time.Hour
+}
+
+// DeleteSession erases a session and its events, returning how many events
+// were removed. It returns ErrSessionNotFound when the session is unknown.
+func (s *Store) DeleteSession(ctx context.Context, sessionID string) (int, error) {
+	if s.mem != nil {
+		n, ok := s.mem.deleteSession(sessionID)
+		if !ok {
+			return 0, ErrSessionNotFound
+		}
+		return n, nil
+	}
+	if s.cfg.RetentionMode == "ephemeral" || s.db == nil {
+		return 0, ErrSessionNotFound
+	}
+	// Flush first so buffered events cannot land after the delete.
+	if err := s.Flush(ctx); err != nil {
+		return 0, err
+	}
+	var deleted int
+	err := s.eraseTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE session_id = ?`, sessionID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrSessionNotFound
+		}
+		return nil
+	}, &deleted, `SELECT COUNT(*) FROM events WHERE session_id = ?`, sessionID)
+	return deleted, err
+}
+
+// DeleteActor erases every session owned by actorID along with all events
+// the actor recorded, including events in other sessions. It returns how
+// many events were removed.
+func (s *Store) DeleteActor(ctx context.Context, actorID string) (int, error) {
+	if s.mem != nil {
+		return s.mem.deleteActor(actorID), nil
+	}
+	if s.cfg.RetentionMode == "ephemeral" || s.db == nil {
+		return 0, nil
+	}
+	if err := s.Flush(ctx); err != nil {
+		return 0, err
+	}
+	var deleted int
+	err := s.eraseTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM events WHERE actor_id = ?`, actorID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE actor_id = ?`, actorID)
+		return err
+	}, &deleted, `SELECT COUNT(*) FROM events
+		 WHERE actor_id = ? OR session_id IN (SELECT session_id FROM sessions WHERE actor_id = ?)`, actorID, actorID)
+	return deleted, err
+}
+
+// eraseTx counts the events matched by countQuery and runs del in the same
+// transaction, so the count matches what was deleted. Session deletes
+// cascade to events through the foreign key.
+func (s *Store) eraseTx(ctx context.Context, del func(*sql.Tx) error, count *int, countQuery string, args ...any) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if err = tx.QueryRowContext(ctx, countQuery, args...).Scan(count); err != nil {
+		return err
+	}
+	if err = del(tx); err != nil {
+		*count = 0
+		return err
+	}
+	return tx.Commit()
+}
+
 // Ensure supplies a no-op store when persistence disabled.
 func (s *Store) Ensure() error {
 	if s.cfg.RetentionMode == "ephemeral" && s.db != nil {
@@ -2,23 +2,20 @@ package eventstore
 
 import (
 	"context"
-	"io"
-	"log/slog"
+	"errors"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/testutil"
 )
 
-func newLogger() *slog.Logger {
-	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
-}
-
 func TestOpenEphemeral(t *testing.T) {
 	ctx := context.Background()
 	cfg := config.EventStoreConfig{RetentionMode: "ephemeral"}
-	es, err := Open(ctx, cfg, newLogger())
+	es, err := Open(ctx, cfg, testutil.Logger())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -31,7 +28,7 @@ func TestOpenEphemeral(t *testing.T) {
 func TestAppendAndQuery(t *testing.T) {
 	tmp := t.TempDir()
 	cfg := config.EventStoreConfig{Path: filepath.Join(tmp, "events.db"), RetentionMode: "session"}
-	es, err := Open(context.Background(), cfg, newLogger())
+	es, err := Open(context.Background(), cfg, testutil.Logger())
 	if err != nil {
 		t.Fatalf("open event store: %v", err)
 	}
@@ -59,7 +56,7 @@ func TestAppendAndQuery(t *testing.T) {
 func TestPruneByDaysAndSessions(t *testing.T) {
 	tmp := t.TempDir()
 	cfg := config.EventStoreConfig{Path: filepath.Join(tmp, "events.db"), RetentionMode: "persistent", RetentionDays: 1, MaxSessions: 1}
-	es, err := Open(context.Background(), cfg, newLogger())
+	es, err := Open(context.Background(), cfg, testutil.Logger())
 	if err != nil {
 		t.Fatalf("open event store: %v", err)
 	}
@@ -89,3 +86,212 @@ func TestPruneByDaysAndSessions(t *testing.T) {
 		t.Fatalf("expected old session pruned")
 	}
 }
+
+func TestOpenWithoutMaintenanceKeepsEvents(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.EventStoreConfig{Path: filepath.Join(t.TempDir(), "events.db"), RetentionMode: "persistent", MaxSessions: 1}
+	es, err := Open(ctx, cfg, testutil.Logger(), WithoutMaintenance())
+	if err != nil {
+		t.Fatalf("open event store: %v", err)
+	}
+	for _, id := range []string{"s1", "s2"} {
+		if err := es.AppendSession(ctx, id, "actor", "session"); err != nil {
+			t.Fatalf("append session: %v", err)
+		}
+		if err := es.AppendEvent(ctx, Event{SessionID: id, Type: "note"}); err != nil {
+			t.Fatalf("append event: %v", err)
+		}
+	}
+	if err := es.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	ro, err := Open(ctx, cfg, testutil.Logger(), ReadOnly())
+	if err != nil {
+		t.Fatalf("open read-only: %v", err)
+	}
+	t.Cleanup(func() { _ = ro.Close() })
+	for _, id := range []string{"s1", "s2"} {
+		events, err := ro.ListSessionEvents(ctx, id, 10)
+		if err != nil {
+			t.Fatalf("list events: %v", err)
+		}
+		if len(events) != 1 {
+			t.Fatalf("expected session %s kept by a read-only open, got %d events", id, len(events))
+		}
+	}
+	if err := ro.AppendEvent(ctx, Event{SessionID: "s1", Type: "note"}); err == nil {
+		t.Fatal("expected a write through a read-only store to fail")
+	}
+}
+
+func TestListEventsByTrace(t *testing.T) {
+	forEachBackend(t, config.EventStoreConfig{RetentionMode: "session"}, func(t *testing.T, es *Store) {
+		ctx := context.Background()
+		base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, id := range []string{"s1", "s2"} {
+			if err := es.AppendSession(ctx, id, "actor", "internal"); err != nil {
+				t.Fatalf("append session: %v", err)
+			}
+		}
+		for i, evt := range []Event{
+			{SessionID: "s2", TraceID: "trace-1", Type: "tts"},
+			{SessionID: "s1", TraceID: "trace-1", Type: "stt"},
+			{SessionID: "s1", TraceID: "trace-2", Type: "other"},
+			{SessionID: "s2", TraceID: "trace-1", Type: "llm"},
+		} {
+			evt.CreatedAt = base.Add(time.Duration([]int{3, 1, 2, 2}[i]) * time.Second)
+			if err := es.AppendEvent(ctx, evt); err != nil {
+				t.Fatalf("append event: %v", err)
+			}
+		}
+
+		events, err := es.ListEventsByTrace(ctx, "trace-1", 10)
+		if err != nil {
+			t.Fatalf("list by trace: %v", err)
+		}
+		var types []string
+		for _, evt := range events {
+			types = append(types, evt.Type)
+		}
+		if len(types) != 3 || types[0] != "stt" || types[1] != "llm" || types[2] != "tts" {
+			t.Fatalf("expected trace-1 events across sessions in time order, got %v", types)
+		}
+		if limited, _ := es.ListEventsByTrace(ctx, "trace-1", 1); len(limited) != 1 {
+			t.Fatalf("expected limit to apply, got %d events", len(limited))
+		}
+		if none, _ := es.ListEventsByTrace(ctx, "unknown", 10); len(none) != 0 {
+			t.Fatalf("expected no events for an unknown trace, got %+v", none)
+		}
+	})
+}
+
+func TestConcurrentAppendsDoNotHitLockErrors(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.EventStoreConfig{
+		Path:          filepath.Join(t.TempDir(), "events.db"),
+		RetentionMode: "session",
+		BusyTimeoutMS: 5000,
+		Synchronous:   "normal",
+		CacheSize:     -2000,
+		MaxIdleConns:  8,
+	}
+	es, err := Open(ctx, cfg, testutil.Logger())
+	if err != nil {
+		t.Fatalf("open event store: %v", err)
+	}
+	t.Cleanup(func() { _ = es.Close() })
+	if err := es.AppendSession(ctx, "s1", "actor", "internal"); err != nil {
+		t.Fatalf("append session: %v", err)
+	}
+
+	// Single-row appends race multi-row transactions (as batch flushes
+	// issue) on separate pooled connections; without busy_timeout the
+	// losers fail with SQLITE_BUSY.
+	const writers, perWriter, bulkSize = 16, 10, 50
+	bulk := make([]Event, bulkSize)
+	for i := range bulk {
+		bulk[i] = Event{SessionID: "s1", Type: "bulk", CreatedAt: time.Now().UTC()}
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if err := es.AppendEvent(ctx, Event{SessionID: "s1", Type: "single"}); err != nil {
+					errs <- err
+					return
+				}
+				if err := es.insertEvents(ctx, bulk); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent append failed: %v", err)
+	}
+	var count int
+	if err := es.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM events`).Scan(&count); err != nil {
+		t.Fatalf("count events: %v", err)
+	}
+	if want := writers * perWriter * (1 + bulkSize); count != want {
+		t.Fatalf("expected %d events, got %d", want, count)
+	}
+
+	var mode string
+	if err := es.db.QueryRowContext(ctx, `PRAGMA synchronous`).Scan(&mode); err != nil || mode != "1" {
+		t.Fatalf("expected synchronous=NORMAL (1), got %q (%v)", mode, err)
+	}
+}
+
+func TestEnsureSessionKeepsExistingSession(t *testing.T) {
+	forEachBackend(t, config.EventStoreConfig{RetentionMode: "session"}, func(t *testing.T, es *Store) {
+		ctx := context.Background()
+		if err := es.AppendSession(ctx, "s1", "alice", "private"); err != nil {
+			t.Fatalf("append session: %v", err)
+		}
+		if scope, err := es.EnsureSession(ctx, "s1", "router", "session"); err != nil || scope != "private" {
+			t.Fatalf("expected existing scope private, got %q (%v)", scope, err)
+		}
+		if scope, err := es.EnsureSession(ctx, "s2", "router", "session"); err != nil || scope != "session" {
+			t.Fatalf("expected new session in scope session, got %q (%v)", scope, err)
+		}
+		if err := es.AppendEvent(ctx, Event{SessionID: "s1", ActorID: "alice", Type: "a"}); err != nil {
+			t.Fatalf("append event: %v", err)
+		}
+		if n, err := es.DeleteActor(ctx, "alice"); err != nil || n != 1 {
+			t.Fatalf("expected s1 to stay owned by alice, deleted %d (%v)", n, err)
+		}
+	})
+}
+
+func TestDeleteSessionAndActor(t *testing.T) {
+	forEachBackend(t, config.EventStoreConfig{RetentionMode: "session"}, func(t *testing.T, es *Store) {
+		ctx := context.Background()
+		for _, sess := range []struct{ id, actor string }{{"s1", "alice"}, {"s2", "alice"}, {"s3", "bob"}} {
+			if err := es.AppendSession(ctx, sess.id, sess.actor, "private"); err != nil {
+				t.Fatalf("append session: %v", err)
+			}
+		}
+		for _, evt := range []Event{
+			{SessionID: "s1", ActorID: "alice", Type: "a"},
+			{SessionID: "s1", ActorID: "alice", Type: "b"},
+			{SessionID: "s2", ActorID: "alice", Type: "c"},
+			{SessionID: "s3", ActorID: "bob", Type: "d"},
+			{SessionID: "s3", ActorID: "alice", Type: "e"},
+		} {
+			if err := es.AppendEvent(ctx, evt); err != nil {
+				t.Fatalf("append event: %v", err)
+			}
+		}
+
+		n, err := es.DeleteSession(ctx, "s1")
+		if err != nil || n != 2 {
+			t.Fatalf("delete session: deleted %d, err %v", n, err)
+		}
+		if events, _ := es.ListSessionEvents(ctx, "s1", 10); len(events) != 0 {
+			t.Fatalf("expected s1 events gone, got %+v", events)
+		}
+		if _, err := es.DeleteSession(ctx, "s1"); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("expected ErrSessionNotFound on second delete, got %v", err)
+		}
+
+		n, err = es.DeleteActor(ctx, "alice")
+		if err != nil || n != 2 {
+			t.Fatalf("delete actor: deleted %d, err %v", n, err)
+		}
+		if events, _ := es.ListSessionEvents(ctx, "s2", 10); len(events) != 0 {
+			t.Fatalf("expected alice's session gone, got %+v", events)
+		}
+		events, _ := es.ListSessionEvents(ctx, "s3", 10)
+		if len(events) != 1 || events[0].ActorID != "bob" {
+			t.Fatalf("expected only bob's event to remain in s3, got %+v", events)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package exec
+
+import (
+	"context"
+	osexec "os/exec"
+	"time"
+)
+
+// KillGrace is how long a cancelled command's process group has to exit
+// after SIGTERM before it is killed.
+var KillGrace = 2 * time.Second
+
+// Command is exec.CommandContext for backend commands. The command runs in
+// its own process group, and when ctx is done the whole group is sent
+// SIGTERM, then SIGKILL after KillGrace, so children the command forked (a
+// Python wrapper's worker, say) are not orphaned. Wait also stops waiting
+// for output pipes held open by such children after KillGrace.
+func Command(ctx context.Context, name string, args ...string) *osexec.Cmd {
+	cmd := osexec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return terminate(cmd) }
+	cmd.WaitDelay = KillGrace
+	return cmd
+}
+
+// Kill immediately kills a started command's process group, for callers
+// that abandon a command before it exits.
+func Kill(cmd *osexec.Cmd) error {
+	return kill(cmd)
+}
@@ -0,0 +1,76 @@
+//go:build linux
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandKillsProcessGroupOnTimeout(t *testing.T) {
+	grace := KillGrace
+	KillGrace = 200 * time.Millisecond
+	t.Cleanup(func() { KillGrace = grace })
+
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "pids")
+	script := filepath.Join(dir, "slow.sh")
+	// One child exits on SIGTERM; the other ignores it and needs SIGKILL.
+	body := `#!/bin/sh
+sleep 30 &
+echo $! >> '` + pidFile + `'
+sh -c 'trap "" TERM; exec sleep 30' &
+echo $! >> '` + pidFile + `'
+wait
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := Command(ctx, "sh", script).Run(); err == nil {
+		t.Fatal("expected the timed-out command to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("command took %v to stop", elapsed)
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pids := strings.Fields(string(data))
+	if len(pids) != 2 {
+		t.Fatalf("expected two child pids, got %q", pids)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for _, pid := range pids {
+		for alive(t, pid) {
+			if time.Now().After(deadline) {
+				t.Fatalf("child %s still running after timeout", pid)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+}
+
+// alive reports whether pid is a running (not zombie) process.
+func alive(t *testing.T, pid string) bool {
+	t.Helper()
+	if _, err := strconv.Atoi(pid); err != nil {
+		t.Fatalf("bad pid %q", pid)
+	}
+	stat, err := os.ReadFile("/proc/" + pid + "/stat")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
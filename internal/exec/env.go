@@ -0,0 +1,41 @@
+package exec
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Environ builds a subprocess environment from the parent's followed by
+// the fixed values in set, which win over inherited ones. A nil
+// passthrough inherits the whole parent environment, as exec commands
+// always have; a non-nil one (even empty) inherits only the variables it
+// names, skipping those missing from the parent.
+func Environ(passthrough []string, set map[string]string) []string {
+	var env []string
+	if passthrough == nil {
+		for _, kv := range os.Environ() {
+			name, _, _ := strings.Cut(kv, "=")
+			if _, fixed := set[name]; !fixed {
+				env = append(env, kv)
+			}
+		}
+	}
+	for _, name := range passthrough {
+		if _, fixed := set[name]; fixed {
+			continue
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+set[name])
+	}
+	return env
+}
@@ -0,0 +1,42 @@
+package exec
+
+import (
+	osexec "os/exec"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestEnvironLimitsSubprocessToAllowlist(t *testing.T) {
+	t.Setenv("LOQA_TEST_ALLOWED", "yes")
+	t.Setenv("LOQA_TEST_SECRET", "hunter2")
+	t.Setenv("LOQA_TEST_OVERRIDDEN", "parent")
+
+	cmd := osexec.Command("/usr/bin/env")
+	cmd.Env = Environ([]string{"LOQA_TEST_ALLOWED", "LOQA_TEST_OVERRIDDEN", "LOQA_TEST_MISSING"}, map[string]string{
+		"LOQA_TEST_OVERRIDDEN": "fixed",
+		"LOQA_TEST_EXTRA":      "1",
+	})
+	out, err := cmd.Output()
+	if err != nil {
+		t.Skipf("env not available: %v", err)
+	}
+	got := strings.Fields(string(out))
+	sort.Strings(got)
+	want := []string{"LOQA_TEST_ALLOWED=yes", "LOQA_TEST_EXTRA=1", "LOQA_TEST_OVERRIDDEN=fixed"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("expected subprocess environment %q, got %q", want, got)
+	}
+}
+
+func TestEnvironDefaultsAndEmptyPassthrough(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("LOQA_TEST_INHERITED", "parent")
+	env := strings.Join(Environ(nil, map[string]string{"LOQA_TEST_INHERITED": "fixed"}), "\n")
+	if !strings.Contains(env, "PATH=/usr/bin") || strings.Count(env, "LOQA_TEST_INHERITED=") != 1 || !strings.Contains(env, "LOQA_TEST_INHERITED=fixed") {
+		t.Fatalf("expected unset passthrough to inherit the parent environment with overrides, got %q", env)
+	}
+	if env := Environ([]string{}, nil); len(env) != 0 {
+		t.Fatalf("expected empty passthrough to pass nothing, got %q", env)
+	}
+}
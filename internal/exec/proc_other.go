@@ -0,0 +1,16 @@
+//go:build !unix
+
+package exec
+
+import osexec "os/exec"
+
+func setProcessGroup(*osexec.Cmd) {}
+
+// terminate kills the command itself; process groups are unix-only.
+func terminate(cmd *osexec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func kill(cmd *osexec.Cmd) error {
+	return cmd.Process.Kill()
+}
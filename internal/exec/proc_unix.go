@@ -0,0 +1,37 @@
+//go:build unix
+
+package exec
+
+import (
+	"errors"
+	"os"
+	osexec "os/exec"
+	"syscall"
+	"time"
+)
+
+func setProcessGroup(cmd *osexec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminate signals the command's process group, escalating to SIGKILL for
+// anything still running after KillGrace.
+func terminate(cmd *osexec.Cmd) error {
+	group := -cmd.Process.Pid
+	if err := syscall.Kill(group, syscall.SIGTERM); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	time.AfterFunc(KillGrace, func() { _ = syscall.Kill(group, syscall.SIGKILL) })
+	return nil
+}
+
+func kill(cmd *osexec.Cmd) error {
+	err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
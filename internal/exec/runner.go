@@ -0,0 +1,145 @@
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	osexec "os/exec"
+	"strings"
+
+	"github.com/mattn/go-shellwords"
+)
+
+// MaxLineBytes bounds one line of streamed command output, which may carry
+// a base64 audio chunk.
+const MaxLineBytes = 8 << 20
+
+// ErrStop may be returned by a Stream handler to stop reading. The rest of
+// the output is discarded and the command is waited for as usual.
+var ErrStop = errors.New("stop reading command output")
+
+// Spec describes a backend command.
+type Spec struct {
+	// Command is a shell-style command line, split with shellwords.
+	Command string
+	// Args is an optional argument template appended to Command; it may
+	// only reference ArgNames.
+	Args     []string
+	ArgNames []string
+	// Env is the command's complete environment (see Environ); nil means
+	// Environ(nil, nil).
+	Env []string
+}
+
+// Runner starts a configured command once per request. Each run gets the
+// Spec's environment and its own process group (see Command), and failures
+// are reported with the command's stderr.
+type Runner struct {
+	name string
+	cmd  []string
+	args Template
+	env  []string
+}
+
+// NewRunner parses spec for the backend called name ("stt", "tts", ...),
+// which prefixes every error.
+func NewRunner(name string, spec Spec) (*Runner, error) {
+	cmd, err := shellwords.NewParser().Parse(spec.Command)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s command: %w", name, err)
+	}
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("%s command empty", name)
+	}
+	args, err := ParseTemplate(spec.Args, spec.ArgNames...)
+	if err != nil {
+		return nil, fmt.Errorf("%s command_args: %w", name, err)
+	}
+	env := spec.Env
+	if env == nil {
+		env = Environ(nil, nil)
+	}
+	return &Runner{name: name, cmd: cmd, args: args, env: env}, nil
+}
+
+// Templated reports whether the Spec had an argument template.
+func (r *Runner) Templated() bool { return len(r.args) > 0 }
+
+// Render fills the argument template with values.
+func (r *Runner) Render(values map[string]string) []string {
+	return r.args.Render(values)
+}
+
+// Run runs the command with args appended, feeding it stdin (which may be
+// nil), and returns its stdout.
+func (r *Runner) Run(ctx context.Context, args []string, stdin io.Reader) ([]byte, error) {
+	cmd := r.command(ctx, args, stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, r.failed(err, &stderr)
+	}
+	return stdout.Bytes(), nil
+}
+
+// Stream runs the command with args appended and calls handle with each
+// non-blank line of its stdout, such as one JSON object per line. An error
+// from handle other than ErrStop kills the command and is returned as is.
+func (r *Runner) Stream(ctx context.Context, args []string, stdin io.Reader, handle func(line []byte) error) error {
+	cmd := r.command(ctx, args, stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return r.failed(err, &stderr)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxLineBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := handle(line); err != nil {
+			if errors.Is(err, ErrStop) {
+				break
+			}
+			_ = Kill(cmd)
+			_ = cmd.Wait()
+			return err
+		}
+	}
+	scanErr := scanner.Err()
+	// Drain anything printed after the last line read so Wait cannot block.
+	_, _ = io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return r.failed(err, &stderr)
+	}
+	if scanErr != nil {
+		return fmt.Errorf("read %s output: %w", r.name, scanErr)
+	}
+	return nil
+}
+
+func (r *Runner) command(ctx context.Context, args []string, stdin io.Reader) *osexec.Cmd {
+	all := append(append([]string{}, r.cmd[1:]...), args...)
+	cmd := Command(ctx, r.cmd[0], all...)
+	cmd.Env = r.env
+	cmd.Stdin = stdin
+	return cmd
+}
+
+func (r *Runner) failed(err error, stderr *bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%s command failed: %w: %s", r.name, err, msg)
+	}
+	return fmt.Errorf("%s command failed: %w", r.name, err)
+}
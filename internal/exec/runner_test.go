@@ -0,0 +1,71 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunnerRunPassesArgsAndStdin(t *testing.T) {
+	r, err := NewRunner("test", Spec{
+		Command:  `sh -c 'echo "$0 $1 $(cat)"'`,
+		Args:     []string{"{first}", "--x={second}"},
+		ArgNames: []string{"first", "second"},
+	})
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	args := r.Render(map[string]string{"first": "a", "second": "b"})
+	out, err := r.Run(context.Background(), args, strings.NewReader("input"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "a --x=b input" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestRunnerRunReportsStderr(t *testing.T) {
+	r, err := NewRunner("test", Spec{Command: `sh -c 'echo boom >&2; exit 3'`})
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	_, err = r.Run(context.Background(), nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "test command failed") || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected failure with stderr, got %v", err)
+	}
+}
+
+func TestRunnerStreamLines(t *testing.T) {
+	r, err := NewRunner("test", Spec{Command: `sh -c 'echo one; echo; echo two; echo three; echo four'`})
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	var lines []string
+	err = r.Stream(context.Background(), nil, nil, func(line []byte) error {
+		lines = append(lines, string(line))
+		if string(line) == "three" {
+			return ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if strings.Join(lines, ",") != "one,two,three" {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+
+	failure := errors.New("bad line")
+	err = r.Stream(context.Background(), nil, nil, func([]byte) error { return failure })
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+}
+
+func TestNewRunnerRejectsEmptyCommand(t *testing.T) {
+	if _, err := NewRunner("test", Spec{Command: "  "}); err == nil {
+		t.Fatal("expected empty command to be rejected")
+	}
+}
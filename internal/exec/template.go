@@ -0,0 +1,51 @@
+// Package exec is the process runner behind the exec-mode STT, TTS and LLM
+// backends, which run an external command per request: command parsing,
+// argument templates, environment, process-group cleanup and line-delimited
+// output.
+package exec
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// placeholder matches a {name} reference in an argument template.
+var placeholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// Template is a command argument list with {name} placeholders, such as
+// ["-f", "{audio}", "--lang", "{language}"].
+type Template []string
+
+// ParseTemplate checks that args only reference the given placeholder
+// names.
+func ParseTemplate(args []string, names ...string) (Template, error) {
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+	}
+	for _, arg := range args {
+		for _, m := range placeholder.FindAllStringSubmatch(arg, -1) {
+			if !known[m[1]] {
+				return nil, fmt.Errorf("unknown placeholder {%s} in argument %q (supported: %s)", m[1], arg, strings.Join(names, ", "))
+			}
+		}
+	}
+	return Template(args), nil
+}
+
+// Render substitutes values into the template. An argument that is a single
+// placeholder with an empty value is dropped, so optional values such as a
+// model path can be left unset.
+func (t Template) Render(values map[string]string) []string {
+	out := make([]string, 0, len(t))
+	for _, arg := range t {
+		if m := placeholder.FindStringSubmatch(arg); m != nil && m[0] == arg && values[m[1]] == "" {
+			continue
+		}
+		out = append(out, placeholder.ReplaceAllStringFunc(arg, func(ref string) string {
+			return values[ref[1:len(ref)-1]]
+		}))
+	}
+	return out
+}
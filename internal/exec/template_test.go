@@ -0,0 +1,24 @@
+package exec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplateRender(t *testing.T) {
+	tmpl, err := ParseTemplate([]string{"-f", "{audio}", "--lang={language}", "{model}", "--rate", "{sample_rate}"}, "audio", "language", "model", "sample_rate")
+	if err != nil {
+		t.Fatalf("parse template: %v", err)
+	}
+	got := tmpl.Render(map[string]string{"audio": "/tmp/a.wav", "language": "de", "sample_rate": "16000"})
+	want := []string{"-f", "/tmp/a.wav", "--lang=de", "--rate", "16000"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestParseTemplateRejectsUnknownPlaceholder(t *testing.T) {
+	if _, err := ParseTemplate([]string{"--voice", "{voise}"}, "voice"); err == nil {
+		t.Fatal("expected unknown placeholder to be rejected")
+	}
+}
@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"fmt"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	loqaexec "github.com/loqalabs/loqa-core/internal/exec"
+)
+
+const (
+	// DefaultBackend names the generator built from the top-level llm settings.
+	DefaultBackend = "default"
+	// FallbackBackend names the generator built from llm.fallback.
+	FallbackBackend = "fallback"
+)
+
+// NewGenerator builds the generator described by a backend config.
+func NewGenerator(cfg config.LLMBackendConfig) (Generator, error) {
+	switch cfg.Mode {
+	case "ollama":
+		return NewOllamaGenerator(cfg.Endpoint, cfg.ModelFast, cfg.ModelBalanced), nil
+	case "exec":
+		env := loqaexec.Environ(cfg.ExecEnvPassthrough, cfg.ExecEnv)
+		if cfg.ExecStreaming {
+			return NewStreamingExecGenerator(cfg.Command, cfg.CommandArgs, env)
+		}
+		return NewExecGenerator(cfg.Command, cfg.CommandArgs, env)
+	case "mock", "":
+		return NewMockGeneratorWithBehavior(cfg.MockBehavior)
+	default:
+		return nil, fmt.Errorf("unsupported LLM mode %q", cfg.Mode)
+	}
+}
+
+// NewGenerators builds every backend in cfg keyed by name, including the
+// top-level settings under DefaultBackend and, when configured, llm.fallback
+// under FallbackBackend.
+func NewGenerators(cfg config.LLMConfig) (map[string]Generator, error) {
+	backends := append([]config.LLMBackendConfig{{
+		Name:               DefaultBackend,
+		Mode:               cfg.Mode,
+		Endpoint:           cfg.Endpoint,
+		Command:            cfg.Command,
+		ModelFast:          cfg.ModelFast,
+		ModelBalanced:      cfg.ModelBalanced,
+		ExecStreaming:      cfg.ExecStreaming,
+		CommandArgs:        cfg.CommandArgs,
+		MockBehavior:       cfg.MockBehavior,
+		ExecEnv:            cfg.ExecEnv,
+		ExecEnvPassthrough: cfg.ExecEnvPassthrough,
+	}}, cfg.Backends...)
+	if cfg.Fallback.Mode != "" {
+		fallback := cfg.Fallback
+		fallback.Name = FallbackBackend
+		backends = append(backends, fallback)
+	}
+
+	generators := make(map[string]Generator, len(backends))
+	for _, b := range backends {
+		generator, err := NewGenerator(b)
+		if err != nil {
+			return nil, fmt.Errorf("llm backend %q: %w", b.Name, err)
+		}
+		generators[b.Name] = generator
+	}
+	return generators, nil
+}
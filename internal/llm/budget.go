@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// EstimateTokens approximates the token count of text without a tokenizer.
+// It takes the larger of the word count and one token per four characters,
+// which tracks common BPE vocabularies closely enough for budgeting.
+func EstimateTokens(text string) int {
+	words := len(strings.Fields(text))
+	chars := (utf8.RuneCountInString(text) + 3) / 4
+	if words > chars {
+		return words
+	}
+	return chars
+}
+
+// fitContext trims the oldest part of req.Prompt so that system prompt,
+// prompt, and the reserved response budget (req.MaxTokens) fit within
+// maxContext tokens. Whole lines are dropped first, then leading words of
+// the oldest remaining line. The most recent word is always kept. It reports
+// whether anything was removed; maxContext <= 0 disables the check.
+func fitContext(req Request, maxContext int) (Request, bool) {
+	if maxContext <= 0 {
+		return req, false
+	}
+	budget := maxContext - req.MaxTokens - EstimateTokens(req.System)
+	if EstimateTokens(req.Prompt) <= budget {
+		return req, false
+	}
+
+	lines := strings.Split(req.Prompt, "\n")
+	for len(lines) > 1 && EstimateTokens(strings.Join(lines, "\n")) > budget {
+		lines = lines[1:]
+	}
+	prompt := strings.Join(lines, "\n")
+	if EstimateTokens(prompt) > budget {
+		words := strings.Fields(prompt)
+		for len(words) > 1 && EstimateTokens(strings.Join(words, " ")) > budget {
+			words = words[1:]
+		}
+		prompt = strings.Join(words, " ")
+	}
+	req.Prompt = prompt
+	return req, true
+}
@@ -0,0 +1,38 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFitContextDropsOldestLines(t *testing.T) {
+	req := Request{
+		Prompt:    "user: first turn with several words\nassistant: a reply\nuser: latest question",
+		MaxTokens: 4,
+	}
+	fitted, truncated := fitContext(req, 4+EstimateTokens("user: latest question"))
+	if !truncated {
+		t.Fatalf("expected prompt to be truncated")
+	}
+	if fitted.Prompt != "user: latest question" {
+		t.Fatalf("expected only the latest line to remain, got %q", fitted.Prompt)
+	}
+}
+
+func TestFitContextTrimsLeadingWordsOfSingleLine(t *testing.T) {
+	req := Request{Prompt: strings.Repeat("word ", 50) + "end"}
+	fitted, truncated := fitContext(req, 10)
+	if !truncated || EstimateTokens(fitted.Prompt) > 10 || !strings.HasSuffix(fitted.Prompt, "end") {
+		t.Fatalf("expected trailing words within budget, got %q", fitted.Prompt)
+	}
+}
+
+func TestFitContextWithinBudget(t *testing.T) {
+	req := Request{Prompt: "short prompt", System: "be brief", MaxTokens: 16}
+	if fitted, truncated := fitContext(req, 4096); truncated || fitted.Prompt != req.Prompt {
+		t.Fatalf("expected prompt to be left alone, got %q", fitted.Prompt)
+	}
+	if _, truncated := fitContext(Request{Prompt: strings.Repeat("x ", 1000)}, 0); truncated {
+		t.Fatalf("expected zero budget to disable truncation")
+	}
+}
@@ -5,33 +5,56 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
+	"strconv"
 	"sync"
+	"time"
 
-	"github.com/mattn/go-shellwords"
+	loqaexec "github.com/loqalabs/loqa-core/internal/exec"
 )
 
 type execGenerator struct {
-	cmd []string
-	mu  sync.Mutex
+	runner    *loqaexec.Runner
+	streaming bool
+	mu        sync.Mutex
 }
 
+// execArgNames are the placeholders available to llm.command_args.
+var execArgNames = []string{"prompt", "system", "tier", "max_tokens", "temperature"}
+
 type execResponse struct {
 	Content          string `json:"content"`
+	Final            bool   `json:"final,omitempty"`
 	PromptTokens     int    `json:"prompt_tokens,omitempty"`
 	CompletionTokens int    `json:"completion_tokens,omitempty"`
 }
 
-func NewExecGenerator(command string) (Generator, error) {
-	parser := shellwords.NewParser()
-	args, err := parser.Parse(command)
+// NewExecGenerator runs command once per request and reads a single JSON
+// response object from stdout. args, if any, is an argument template
+// appended to command (see execArgNames); env is the command's complete
+// environment.
+func NewExecGenerator(command string, args, env []string) (Generator, error) {
+	return newExecGenerator(command, args, env, false)
+}
+
+// NewStreamingExecGenerator runs command once per request and reads
+// newline-delimited JSON objects from stdout. Each object carries the next
+// piece of content and is published as a partial chunk until one sets
+// "final": true; the final chunk carries the full accumulated text.
+func NewStreamingExecGenerator(command string, args, env []string) (Generator, error) {
+	return newExecGenerator(command, args, env, true)
+}
+
+func newExecGenerator(command string, args, env []string, streaming bool) (Generator, error) {
+	runner, err := loqaexec.NewRunner("llm exec", loqaexec.Spec{
+		Command:  command,
+		Args:     args,
+		ArgNames: execArgNames,
+		Env:      env,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("parse llm command: %w", err)
-	}
-	if len(args) == 0 {
-		return nil, fmt.Errorf("llm command empty")
+		return nil, err
 	}
-	return &execGenerator{cmd: args}, nil
+	return &execGenerator{runner: runner, streaming: streaming}, nil
 }
 
 func (g *execGenerator) Generate(ctx context.Context, req Request, consumer func(Chunk) error) error {
@@ -43,19 +66,25 @@ func (g *execGenerator) Generate(ctx context.Context, req Request, consumer func
 		"system":      req.System,
 		"max_tokens":  req.MaxTokens,
 		"temperature": req.Temperature,
+		"stop":        req.Stop,
 	}
 	input, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
-
-	base := g.cmd[0]
-	args := append([]string{}, g.cmd[1:]...)
-	cmd := exec.CommandContext(ctx, base, args...)
-	cmd.Stdin = bytes.NewReader(input)
-	output, err := cmd.Output()
+	args := g.runner.Render(map[string]string{
+		"prompt":      req.Prompt,
+		"system":      req.System,
+		"tier":        req.Tier,
+		"max_tokens":  strconv.Itoa(req.MaxTokens),
+		"temperature": strconv.FormatFloat(req.Temperature, 'f', -1, 64),
+	})
+	if g.streaming {
+		return g.stream(ctx, args, input, req, consumer)
+	}
+	output, err := g.runner.Run(ctx, args, bytes.NewReader(input))
 	if err != nil {
-		return fmt.Errorf("llm exec command failed: %w", err)
+		return err
 	}
 
 	var resp execResponse
@@ -73,3 +102,49 @@ func (g *execGenerator) Generate(ctx context.Context, req Request, consumer func
 		TraceID:          req.TraceID,
 	})
 }
+
+func (g *execGenerator) stream(ctx context.Context, args []string, input []byte, req Request, consumer func(Chunk) error) error {
+	start := time.Now()
+	var accumulated string
+	var promptTokens, completionTokens int
+	err := g.runner.Stream(ctx, args, bytes.NewReader(input), func(line []byte) error {
+		var resp execResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return fmt.Errorf("decode llm exec chunk: %w", err)
+		}
+		accumulated += resp.Content
+		if resp.PromptTokens > 0 {
+			promptTokens = resp.PromptTokens
+		}
+		if resp.CompletionTokens > 0 {
+			completionTokens = resp.CompletionTokens
+		}
+		if resp.Final {
+			return loqaexec.ErrStop
+		}
+		return consumer(Chunk{
+			SessionID:        req.SessionID,
+			Content:          resp.Content,
+			Partial:          true,
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			Latency:          time.Since(start),
+			TraceID:          req.TraceID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// A command that exits without a final object still completes the
+	// response with whatever it streamed.
+	return consumer(Chunk{
+		SessionID:        req.SessionID,
+		Content:          accumulated,
+		Partial:          false,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		Latency:          time.Since(start),
+		TraceID:          req.TraceID,
+	})
+}
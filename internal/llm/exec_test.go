@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStreamingExecGeneratorEmitsPartials(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "stream.sh")
+	body := `#!/bin/sh
+cat >/dev/null
+echo '{"content":"Hello"}'
+echo '{"content":", "}'
+echo '{"content":"world","final":true,"prompt_tokens":3,"completion_tokens":4}'
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	gen, err := NewStreamingExecGenerator("sh "+script, nil, nil)
+	if err != nil {
+		t.Fatalf("create generator: %v", err)
+	}
+	var chunks []Chunk
+	err = gen.Generate(context.Background(), Request{SessionID: "s-1", Prompt: "hi"}, func(c Chunk) error {
+		chunks = append(chunks, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	if len(chunks) != 3 {
+		t.Fatalf("expected 2 partial chunks and a final chunk, got %d: %+v", len(chunks), chunks)
+	}
+	if !chunks[0].Partial || chunks[0].Content != "Hello" || !chunks[1].Partial || chunks[1].Content != ", " {
+		t.Fatalf("unexpected partial chunks: %+v", chunks[:2])
+	}
+	final := chunks[2]
+	if final.Partial || final.Content != "Hello, world" {
+		t.Fatalf("expected final chunk with full text, got %+v", final)
+	}
+	if final.PromptTokens != 3 || final.CompletionTokens != 4 || final.SessionID != "s-1" {
+		t.Fatalf("unexpected final chunk metadata: %+v", final)
+	}
+}
@@ -1,14 +1,72 @@
 package llm
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"os"
 	"strings"
+	"text/template"
 	"time"
 )
 
-type mockGenerator struct{}
+type mockGenerator struct {
+	render func(Request) (string, error)
+}
+
+// NewMockGenerator returns a mock that answers "[mock completion for <prompt>]".
+func NewMockGenerator() Generator {
+	return &mockGenerator{render: func(req Request) (string, error) {
+		return "[mock completion for " + strings.TrimSpace(req.Prompt) + "]", nil
+	}}
+}
 
-func NewMockGenerator() Generator { return &mockGenerator{} }
+// NewMockGeneratorWithBehavior returns a mock whose completion is derived from
+// behavior:
+//
+//	"" or "default"  [mock completion for <prompt>]
+//	"echo"           the prompt unchanged
+//	"reverse"        the prompt reversed
+//	"fixed:<text>"   <text> for every request
+//	"template:<path>" the text/template at path executed with the request
+//	                 (.Prompt, .System, .Tier, .SessionID)
+func NewMockGeneratorWithBehavior(behavior string) (Generator, error) {
+	kind, arg, _ := strings.Cut(behavior, ":")
+	switch kind {
+	case "", "default":
+		return NewMockGenerator(), nil
+	case "echo":
+		return &mockGenerator{render: func(req Request) (string, error) { return req.Prompt, nil }}, nil
+	case "reverse":
+		return &mockGenerator{render: func(req Request) (string, error) {
+			runes := []rune(req.Prompt)
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			return string(runes), nil
+		}}, nil
+	case "fixed":
+		return &mockGenerator{render: func(Request) (string, error) { return arg, nil }}, nil
+	case "template":
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("read mock template: %w", err)
+		}
+		tmpl, err := template.New("mock").Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("parse mock template: %w", err)
+		}
+		return &mockGenerator{render: func(req Request) (string, error) {
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, req); err != nil {
+				return "", fmt.Errorf("render mock template: %w", err)
+			}
+			return buf.String(), nil
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported mock behavior %q", behavior)
+	}
+}
 
 func (m *mockGenerator) Generate(ctx context.Context, req Request, consumer func(Chunk) error) error {
 	select {
@@ -16,11 +74,15 @@ func (m *mockGenerator) Generate(ctx context.Context, req Request, consumer func
 		return ctx.Err()
 	case <-time.After(20 * time.Millisecond):
 	}
-	content := "[mock completion for " + strings.TrimSpace(req.Prompt) + "]"
+	content, err := m.render(req)
+	if err != nil {
+		return err
+	}
 	return consumer(Chunk{
 		SessionID: req.SessionID,
 		Content:   content,
 		Partial:   false,
 		Latency:   20 * time.Millisecond,
+		TraceID:   req.TraceID,
 	})
 }
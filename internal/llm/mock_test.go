@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMockGeneratorBehaviors(t *testing.T) {
+	tmpl := filepath.Join(t.TempDir(), "reply.tmpl")
+	if err := os.WriteFile(tmpl, []byte("{{.Tier}}: {{.Prompt}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		behavior string
+		want     string
+	}{
+		{behavior: "", want: "[mock completion for lights on]"},
+		{behavior: "echo", want: "lights on"},
+		{behavior: "reverse", want: "no sthgil"},
+		{behavior: "fixed:Okay, done.", want: "Okay, done."},
+		{behavior: "template:" + tmpl, want: "fast: lights on"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.behavior, func(t *testing.T) {
+			gen, err := NewMockGeneratorWithBehavior(tc.behavior)
+			if err != nil {
+				t.Fatalf("create mock: %v", err)
+			}
+			var got string
+			err = gen.Generate(context.Background(), Request{Prompt: "lights on", Tier: "fast"}, func(c Chunk) error {
+				got = c.Content
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := NewMockGeneratorWithBehavior("shout"); err == nil {
+		t.Fatalf("expected unknown behavior to fail")
+	}
+}
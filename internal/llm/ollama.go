@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 )
@@ -49,8 +50,9 @@ type ollamaRequest struct {
 }
 
 type ollamaOptions struct {
-	Temperature float64 `json:"temperature,omitempty"`
-	NumPredict  int     `json:"num_predict,omitempty"`
+	Temperature float64  `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
 }
 
 type ollamaStreamResponse struct {
@@ -70,6 +72,7 @@ func (g *ollamaGenerator) Generate(ctx context.Context, req Request, consumer fu
 		Options: ollamaOptions{
 			Temperature: req.Temperature,
 			NumPredict:  req.MaxTokens,
+			Stop:        req.Stop,
 		},
 	}
 	body, err := json.Marshal(payload)
@@ -121,9 +124,14 @@ func (g *ollamaGenerator) Generate(ctx context.Context, req Request, consumer fu
 			promptTokens = chunk.PromptEvalCount
 		}
 		partial := !chunk.Done
+		content := chunk.Response
+		if chunk.Done {
+			// The done object carries no new tokens; finals hold the full text.
+			content = accumulated
+		}
 		if err := consumer(Chunk{
 			SessionID:        req.SessionID,
-			Content:          chunk.Response,
+			Content:          content,
 			Partial:          partial,
 			PromptTokens:     promptTokens,
 			CompletionTokens: completionTokens,
@@ -135,3 +143,60 @@ func (g *ollamaGenerator) Generate(ctx context.Context, req Request, consumer fu
 	}
 	return scanner.Err()
 }
+
+// Ping checks that the ollama server answers by listing its models.
+func (g *ollamaGenerator) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, g.endpoint+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("ollama returned status %s", resp.Status)
+	}
+	return nil
+}
+
+// Warmup loads the tier models into ollama with an empty-prompt generate
+// call, so the first real request does not pay the model load time.
+func (g *ollamaGenerator) Warmup(ctx context.Context) error {
+	seen := make(map[string]bool, 2)
+	for _, tier := range []string{"fast", "balanced"} {
+		model := g.modelForTier(tier)
+		if seen[model] {
+			continue
+		}
+		seen[model] = true
+		if err := g.load(ctx, model); err != nil {
+			return fmt.Errorf("warm up %s: %w", model, err)
+		}
+	}
+	return nil
+}
+
+func (g *ollamaGenerator) load(ctx context.Context, model string) error {
+	body, err := json.Marshal(ollamaRequest{Model: model})
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("ollama returned status %s", resp.Status)
+	}
+	return nil
+}
@@ -2,39 +2,64 @@ package llm
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/loqalabs/loqa-core/internal/bus"
 	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/logging"
 	"github.com/loqalabs/loqa-core/internal/protocol"
 	"github.com/nats-io/nats.go"
 )
 
 type Service struct {
-	cfg       config.LLMConfig
-	bus       *bus.Client
-	generator Generator
-	sub       *nats.Subscription
-	ctx       context.Context
-	cancel    context.CancelFunc
-	wg        sync.WaitGroup
-	ready     bool
-	logger    *slog.Logger
-}
-
-func NewService(parent context.Context, cfg config.LLMConfig, busClient *bus.Client, generator Generator, logger *slog.Logger) *Service {
+	cfg            config.LLMConfig
+	bus            *bus.Client
+	subjects       protocol.Subjects
+	generators     map[string]Generator
+	fallback       Generator
+	defaultBackend string
+	sub            *nats.Subscription
+	ctx            context.Context
+	cancel         context.CancelFunc
+	wg             sync.WaitGroup
+	inflight       atomic.Int32
+	ready          bool
+	reachable      atomic.Bool
+	logger         *slog.Logger
+}
+
+// NewService creates the LLM service. generators maps backend names to
+// generators; requests naming an unknown or empty backend use
+// cfg.DefaultBackend, or DefaultBackend when that is unset. The generator
+// under FallbackBackend, if any, is only used when another backend fails.
+func NewService(parent context.Context, cfg config.LLMConfig, busClient *bus.Client, subjects protocol.Subjects, generators map[string]Generator, logger *slog.Logger) *Service {
 	ctx, cancel := context.WithCancel(parent)
+	defaultBackend := cfg.DefaultBackend
+	if defaultBackend == "" {
+		defaultBackend = DefaultBackend
+	}
+	selectable := make(map[string]Generator, len(generators))
+	for name, g := range generators {
+		if name != FallbackBackend {
+			selectable[name] = g
+		}
+	}
 	return &Service{
-		cfg:       cfg,
-		bus:       busClient,
-		generator: generator,
-		ctx:       ctx,
-		cancel:    cancel,
-		logger:    logger.With(slog.String("component", "llm-service")),
+		cfg:            cfg,
+		bus:            busClient,
+		subjects:       subjects,
+		generators:     selectable,
+		fallback:       generators[FallbackBackend],
+		defaultBackend: defaultBackend,
+		ctx:            ctx,
+		cancel:         cancel,
+		logger:         logger.With(slog.String("component", "llm-service")),
 	}
 }
 
@@ -42,15 +67,54 @@ func (s *Service) Start() error {
 	if !s.cfg.Enabled {
 		return nil
 	}
-	sub, err := s.bus.Conn().Subscribe(protocol.SubjectLLMRequest, s.handleRequest)
+	sub, err := s.bus.Subscribe(s.subjects.LLMRequest, s.handleRequest)
 	if err != nil {
 		return fmt.Errorf("subscribe LLM requests: %w", err)
 	}
 	s.sub = sub
 	s.ready = true
+	s.reachable.Store(true)
+	if s.cfg.HealthIntervalMS > 0 {
+		s.wg.Add(1)
+		go s.probeLoop(time.Duration(s.cfg.HealthIntervalMS) * time.Millisecond)
+	}
+	if s.cfg.Warmup {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.warmup()
+		}()
+	}
 	return nil
 }
 
+// warmup loads the models of every backend that supports it. Failures are
+// only logged; the first request then pays the load time instead.
+func (s *Service) warmup() {
+	backends := make(map[string]Generator, len(s.generators)+1)
+	for name, g := range s.generators {
+		backends[name] = g
+	}
+	if s.fallback != nil {
+		backends[FallbackBackend] = s.fallback
+	}
+	for name, g := range backends {
+		warmer, ok := g.(Warmer)
+		if !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, 60*time.Second)
+		start := time.Now()
+		err := warmer.Warmup(ctx)
+		cancel()
+		if err != nil {
+			s.logger.Warn("llm warmup failed", slog.String("backend", name), slogError(err))
+			continue
+		}
+		s.logger.Info("llm backend warmed up", slog.String("backend", name), slog.Duration("latency", time.Since(start)))
+	}
+}
+
 func (s *Service) Close() {
 	s.cancel()
 	if s.sub != nil {
@@ -59,26 +123,120 @@ func (s *Service) Close() {
 	s.wg.Wait()
 }
 
+// Healthy reports whether the service is subscribed and, when
+// llm.health_interval_ms is set, whether at least one backend, the fallback
+// included, was reachable at the latest probe. Until the first probe
+// completes the backends are assumed reachable.
 func (s *Service) Healthy() bool {
-	return !s.cfg.Enabled || s.ready
+	return !s.cfg.Enabled || (s.ready && s.reachable.Load())
+}
+
+// healthProbeTimeout bounds each backend reachability probe.
+const healthProbeTimeout = 2 * time.Second
+
+// probeLoop probes the backends right away and then every interval, off the
+// Start path so a slow backend does not delay startup.
+func (s *Service) probeLoop(interval time.Duration) {
+	defer s.wg.Done()
+	down := make(map[string]bool)
+	s.probe(down)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.probe(down)
+		}
+	}
+}
+
+// probe pings every backend, the fallback included, in parallel. Backends
+// that cannot be pinged count as reachable, so the service stays ready while
+// any backend can answer. down tracks which backends failed the previous
+// probe, so that only changes are logged.
+func (s *Service) probe(down map[string]bool) {
+	backends := make(map[string]Generator, len(s.generators)+1)
+	for name, g := range s.generators {
+		backends[name] = g
+	}
+	if s.fallback != nil {
+		backends[FallbackBackend] = s.fallback
+	}
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(map[string]error)
+	)
+	for name, g := range backends {
+		pinger, ok := g.(Pinger)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(s.ctx, healthProbeTimeout)
+			defer cancel()
+			err := pinger.Ping(ctx)
+			mu.Lock()
+			errs[name] = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	reachable := false
+	for name := range backends {
+		err, probed := errs[name]
+		switch {
+		case !probed || err == nil:
+			reachable = true
+			if down[name] {
+				s.logger.Info("llm backend reachable again", slog.String("backend", name))
+				delete(down, name)
+			}
+		case !down[name]:
+			s.logger.Warn("llm backend unreachable", slog.String("backend", name), slogError(err))
+			down[name] = true
+		}
+	}
+	if !reachable && s.reachable.Load() {
+		s.logger.Warn("no llm backend reachable; reporting not ready")
+	}
+	s.reachable.Store(reachable)
 }
 
+// InFlight reports the number of generations currently running.
+func (s *Service) InFlight() int { return int(s.inflight.Load()) }
+
 func (s *Service) handleRequest(msg *nats.Msg) {
 	var req protocol.LLMRequest
-	if err := json.Unmarshal(msg.Data, &req); err != nil {
+	if err := s.bus.Codec().Unmarshal(msg.Data, &req); err != nil {
 		s.logger.Warn("failed to decode llm request", slogError(err))
 		return
 	}
+	if err := protocol.CheckVersion(req.V); err != nil {
+		s.logger.Warn("rejecting llm request", slogError(err))
+		return
+	}
+	log := logging.WithSession(s.logger, req.SessionID, req.TraceID)
 
 	s.wg.Add(1)
+	s.inflight.Add(1)
 	go func() {
 		defer s.wg.Done()
+		defer s.inflight.Add(-1)
 		ctx, cancel := context.WithTimeout(s.ctx, 60*time.Second)
 		defer cancel()
 
 		options, err := OptionsFromConfig(s.cfg, req.Tier)
 		if err != nil {
-			s.logger.Warn("invalid LLM options", slogError(err))
+			log.Warn("invalid LLM options", slogError(err))
 			return
 		}
 		options.SessionID = req.SessionID
@@ -89,24 +247,142 @@ func (s *Service) handleRequest(msg *nats.Msg) {
 			options.Temperature = req.Temperature
 		}
 		options.TraceID = req.TraceID
+		if len(req.Stop) > 0 {
+			options.Stop = req.Stop
+		}
+		if fitted, truncated := fitContext(options, s.cfg.MaxContextTokens); truncated {
+			log.Info("truncated llm prompt to fit context budget",
+				slog.Int("max_context_tokens", s.cfg.MaxContextTokens),
+				slog.Int("prompt_tokens_before", EstimateTokens(options.Prompt)),
+				slog.Int("prompt_tokens_after", EstimateTokens(fitted.Prompt)))
+			options = fitted
+		}
+
+		backend, generator := s.generatorFor(req.Backend)
+		if generator == nil {
+			log.Warn("no llm backend available", slog.String("backend", backend))
+			return
+		}
+		log = log.With(slog.String("backend", backend))
 
 		start := time.Now()
-		err = s.generator.Generate(ctx, options, func(chunk Chunk) error {
-			return s.publishChunk(chunk)
+		var stream streamState
+		stops := newStopFilter(options.Stop)
+		publish := func(chunk Chunk) error {
+			chunk, stopped := stops.apply(chunk)
+			if err := s.emit(stream.observe(chunk)); err != nil {
+				return err
+			}
+			if stopped {
+				return errStopped
+			}
+			return nil
+		}
+		err = generator.Generate(ctx, options, func(chunk Chunk) error {
+			chunk.Backend = backend
+			return publish(chunk)
 		})
+		if errors.Is(err, errStopped) {
+			err = nil
+		}
+		if err != nil && stream.empty() && s.fallback != nil && ctx.Err() == nil {
+			log.Warn("llm backend failed; using fallback", slogError(err))
+			log = log.With(slog.String("fallback", FallbackBackend))
+			// Text the primary streamed but the stop filter held back must
+			// not leak into the fallback's answer.
+			stream = streamState{}
+			stops = newStopFilter(options.Stop)
+			err = s.fallback.Generate(ctx, options, func(chunk Chunk) error {
+				chunk.Backend = FallbackBackend
+				chunk.Fallback = true
+				return publish(chunk)
+			})
+			if errors.Is(err, errStopped) {
+				err = nil
+			}
+		}
+		if err != nil && ctx.Err() != nil {
+			if truncated, ok := stream.truncated(); ok {
+				log.Warn("llm generation cancelled; sending partial answer", slogError(err),
+					slog.Int("content_bytes", len(truncated.Content)))
+				_ = s.publishChunk(truncated)
+				return
+			}
+		}
 		if err != nil {
-			s.logger.Warn("llm generation failed", slogError(err))
+			log.Warn("llm generation failed", slogError(err))
 			return
 		}
-		s.logger.Info("llm generation complete", slog.Duration("latency", time.Since(start)))
+		log.Info("llm generation complete", slog.Duration("latency", time.Since(start)))
 	}()
 }
 
+// streamState accumulates the partial content a generation has published.
+// A final chunk that arrives empty is completed with that text, so
+// consumers of nlu.response.final always get the whole answer, and a
+// cancelled stream can still be finished with what it produced.
+type streamState struct {
+	content strings.Builder
+	last    Chunk
+	final   bool
+}
+
+func (st *streamState) observe(chunk Chunk) Chunk {
+	if chunk.Partial {
+		st.content.WriteString(chunk.Content)
+	} else if chunk.Content == "" {
+		chunk.Content = st.content.String()
+	}
+	if chunk.Content == "" {
+		return chunk
+	}
+	st.last = chunk
+	st.final = st.final || !chunk.Partial
+	return chunk
+}
+
+func (st *streamState) empty() bool { return st.content.Len() == 0 && !st.final }
+
+// truncated returns a final chunk carrying the accumulated partial content,
+// or false when a final was already published or nothing was streamed.
+func (st *streamState) truncated() (Chunk, bool) {
+	if st.final || st.content.Len() == 0 {
+		return Chunk{}, false
+	}
+	chunk := st.last
+	chunk.Content = st.content.String()
+	chunk.Partial = false
+	chunk.Truncated = true
+	return chunk, true
+}
+
+// generatorFor resolves a requested backend name, falling back to the
+// default backend when the name is empty or unknown.
+func (s *Service) generatorFor(name string) (string, Generator) {
+	if name != "" {
+		if g, ok := s.generators[name]; ok {
+			return name, g
+		}
+		s.logger.Warn("unknown llm backend; using default", slog.String("backend", name), slog.String("default", s.defaultBackend))
+	}
+	return s.defaultBackend, s.generators[s.defaultBackend]
+}
+
+// emit publishes a generator chunk, dropping partials when llm.stream is
+// false; streamState still accumulates them for the final.
+func (s *Service) emit(chunk Chunk) error {
+	if chunk.Partial && !s.cfg.Stream {
+		return nil
+	}
+	return s.publishChunk(chunk)
+}
+
 func (s *Service) publishChunk(chunk Chunk) error {
 	if chunk.Content == "" {
 		return nil
 	}
 	msg := protocol.LLMResponse{
+		V:                protocol.SchemaVersion,
 		SessionID:        chunk.SessionID,
 		Content:          chunk.Content,
 		Partial:          chunk.Partial,
@@ -114,18 +390,21 @@ func (s *Service) publishChunk(chunk Chunk) error {
 		PromptTokens:     chunk.PromptTokens,
 		CompletionTokens: chunk.CompletionTokens,
 		LatencyMS:        chunk.Latency.Milliseconds(),
+		Backend:          chunk.Backend,
+		Fallback:         chunk.Fallback,
+		Truncated:        chunk.Truncated,
 		Timestamp:        time.Now().UTC(),
 	}
-	subject := protocol.SubjectLLMResponsePartial
+	subject := s.subjects.LLMResponsePartial
 	if !chunk.Partial {
-		subject = protocol.SubjectLLMResponseFinal
+		subject = s.subjects.LLMResponseFinal
 	}
-	data, err := json.Marshal(msg)
+	data, err := s.bus.Codec().Marshal(msg)
 	if err != nil {
 		return err
 	}
-	if err := s.bus.Conn().Publish(subject, data); err != nil {
-		s.logger.Warn("failed to publish llm chunk", slogError(err))
+	if err := s.bus.Publish(subject, data); err != nil {
+		logging.WithSession(s.logger, chunk.SessionID, chunk.TraceID).Warn("failed to publish llm chunk", slogError(err))
 		return err
 	}
 	return nil
@@ -0,0 +1,402 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+type namedGenerator string
+
+func (namedGenerator) Generate(context.Context, Request, func(Chunk) error) error { return nil }
+
+type failingGenerator struct{}
+
+func (failingGenerator) Generate(context.Context, Request, func(Chunk) error) error {
+	return errors.New("connection refused")
+}
+
+func TestServiceDispatchesByBackend(t *testing.T) {
+	generators := map[string]Generator{
+		DefaultBackend: namedGenerator("local"),
+		"cloud":        namedGenerator("cloud"),
+	}
+	logger := testutil.Logger()
+
+	cases := []struct {
+		name           string
+		defaultBackend string
+		requested      string
+		want           Generator
+	}{
+		{name: "empty uses default", requested: "", want: generators[DefaultBackend]},
+		{name: "named backend", requested: "cloud", want: generators["cloud"]},
+		{name: "unknown falls back", requested: "missing", want: generators[DefaultBackend]},
+		{name: "configured default", defaultBackend: "cloud", requested: "", want: generators["cloud"]},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := NewService(context.Background(), config.LLMConfig{DefaultBackend: tc.defaultBackend}, nil, protocol.DefaultSubjects(), generators, logger)
+			if _, got := svc.generatorFor(tc.requested); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServiceFallsBackWhenPrimaryFails(t *testing.T) {
+	client := testutil.StartBus(t)
+	generators := map[string]Generator{
+		DefaultBackend:  failingGenerator{},
+		FallbackBackend: NewMockGenerator(),
+	}
+	svc := NewService(context.Background(), config.LLMConfig{Enabled: true, MaxTokens: 32, Stream: true}, client, protocol.DefaultSubjects(), generators, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start llm service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	responses := make(chan protocol.LLMResponse, 1)
+	sub, err := client.Conn().Subscribe(protocol.SubjectLLMResponseFinal, func(msg *nats.Msg) {
+		var resp protocol.LLMResponse
+		if err := json.Unmarshal(msg.Data, &resp); err == nil {
+			responses <- resp
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe responses: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	data, _ := json.Marshal(protocol.LLMRequest{SessionID: "s-1", Prompt: "hello"})
+	if err := client.Conn().Publish(protocol.SubjectLLMRequest, data); err != nil {
+		t.Fatalf("publish request: %v", err)
+	}
+
+	select {
+	case resp := <-responses:
+		if !resp.Fallback || resp.Backend != FallbackBackend || resp.Content == "" {
+			t.Fatalf("expected fallback response, got %+v", resp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for fallback response")
+	}
+}
+
+// midStreamFailingGenerator streams text the stop filter holds back as a
+// possible stop sequence, then fails.
+type midStreamFailingGenerator struct{}
+
+func (midStreamFailingGenerator) Generate(_ context.Context, req Request, consumer func(Chunk) error) error {
+	if err := consumer(Chunk{SessionID: req.SessionID, Content: "\nUs", Partial: true}); err != nil {
+		return err
+	}
+	return errors.New("connection reset")
+}
+
+func TestServiceFallbackStartsWithFreshStopFilter(t *testing.T) {
+	client := testutil.StartBus(t)
+	generators := map[string]Generator{
+		DefaultBackend:  midStreamFailingGenerator{},
+		FallbackBackend: deltaGenerator{},
+	}
+	svc := NewService(context.Background(), config.LLMConfig{Enabled: true, MaxTokens: 32, Stream: true}, client, protocol.DefaultSubjects(), generators, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start llm service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	responses := make(chan protocol.LLMResponse, 1)
+	sub, err := client.Conn().Subscribe(protocol.SubjectLLMResponseFinal, func(msg *nats.Msg) {
+		var resp protocol.LLMResponse
+		if err := json.Unmarshal(msg.Data, &resp); err == nil {
+			responses <- resp
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe responses: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	data, _ := json.Marshal(protocol.LLMRequest{SessionID: "s-1", Prompt: "hello", Stop: []string{"\nUser:"}})
+	if err := client.Conn().Publish(protocol.SubjectLLMRequest, data); err != nil {
+		t.Fatalf("publish request: %v", err)
+	}
+
+	select {
+	case resp := <-responses:
+		if !resp.Fallback || resp.Content != "Hello, world" {
+			t.Fatalf("expected the fallback's text without the primary's held-back prefix, got %+v", resp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for fallback response")
+	}
+}
+
+// stallingGenerator streams one partial and then blocks until cancelled.
+type stallingGenerator struct{}
+
+func (stallingGenerator) Generate(ctx context.Context, req Request, consumer func(Chunk) error) error {
+	for _, part := range []string{"The answer ", "is"} {
+		if err := consumer(Chunk{SessionID: req.SessionID, Content: part, Partial: true, TraceID: req.TraceID}); err != nil {
+			return err
+		}
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestServiceFlushesPartialContentOnCancel(t *testing.T) {
+	client := testutil.StartBus(t)
+	generators := map[string]Generator{DefaultBackend: stallingGenerator{}}
+	svc := NewService(context.Background(), config.LLMConfig{Enabled: true, MaxTokens: 32, Stream: true}, client, protocol.DefaultSubjects(), generators, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start llm service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	partials := make(chan struct{}, 2)
+	finals := make(chan protocol.LLMResponse, 1)
+	for subject, handler := range map[string]nats.MsgHandler{
+		protocol.SubjectLLMResponsePartial: func(*nats.Msg) { partials <- struct{}{} },
+		protocol.SubjectLLMResponseFinal: func(msg *nats.Msg) {
+			var resp protocol.LLMResponse
+			if err := json.Unmarshal(msg.Data, &resp); err == nil {
+				finals <- resp
+			}
+		},
+	} {
+		sub, err := client.Conn().Subscribe(subject, handler)
+		if err != nil {
+			t.Fatalf("subscribe %s: %v", subject, err)
+		}
+		t.Cleanup(func() { _ = sub.Unsubscribe() })
+	}
+
+	data, _ := json.Marshal(protocol.LLMRequest{SessionID: "s-1", Prompt: "hello"})
+	if err := client.Conn().Publish(protocol.SubjectLLMRequest, data); err != nil {
+		t.Fatalf("publish request: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-partials:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for partials")
+		}
+	}
+	svc.Close()
+
+	select {
+	case resp := <-finals:
+		if !resp.Truncated || resp.Partial || resp.Content != "The answer is" || resp.SessionID != "s-1" {
+			t.Fatalf("expected truncated final with accumulated content, got %+v", resp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for truncated final")
+	}
+}
+
+// deltaGenerator streams tokens and ends with an empty final, as a backend
+// reporting only token counts on its done message would.
+type deltaGenerator struct{}
+
+func (deltaGenerator) Generate(_ context.Context, req Request, consumer func(Chunk) error) error {
+	for _, part := range []string{"Hello", ", ", "world"} {
+		if err := consumer(Chunk{SessionID: req.SessionID, Content: part, Partial: true}); err != nil {
+			return err
+		}
+	}
+	return consumer(Chunk{SessionID: req.SessionID, CompletionTokens: 3})
+}
+
+func TestServiceFinalResponseCarriesFullText(t *testing.T) {
+	client := testutil.StartBus(t)
+	generators := map[string]Generator{DefaultBackend: deltaGenerator{}}
+	svc := NewService(context.Background(), config.LLMConfig{Enabled: true, MaxTokens: 32, Stream: true}, client, protocol.DefaultSubjects(), generators, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start llm service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	responses := make(chan protocol.LLMResponse, 1)
+	sub, err := client.Conn().Subscribe(protocol.SubjectLLMResponseFinal, func(msg *nats.Msg) {
+		var resp protocol.LLMResponse
+		if err := json.Unmarshal(msg.Data, &resp); err == nil {
+			responses <- resp
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe responses: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	data, _ := json.Marshal(protocol.LLMRequest{SessionID: "s-1", Prompt: "hello"})
+	if err := client.Conn().Publish(protocol.SubjectLLMRequest, data); err != nil {
+		t.Fatalf("publish request: %v", err)
+	}
+
+	select {
+	case resp := <-responses:
+		if resp.Content != "Hello, world" || resp.Truncated || resp.CompletionTokens != 3 {
+			t.Fatalf("expected full text in final response, got %+v", resp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for final response")
+	}
+}
+
+func TestServiceStreamOptionControlsPartials(t *testing.T) {
+	for _, stream := range []bool{true, false} {
+		t.Run(fmt.Sprintf("stream=%t", stream), func(t *testing.T) {
+			client := testutil.StartBus(t)
+			generators := map[string]Generator{DefaultBackend: deltaGenerator{}}
+			svc := NewService(context.Background(), config.LLMConfig{Enabled: true, MaxTokens: 32, Stream: stream}, client, protocol.DefaultSubjects(), generators, testutil.Logger())
+			if err := svc.Start(); err != nil {
+				t.Fatalf("start llm service: %v", err)
+			}
+			t.Cleanup(svc.Close)
+
+			// One subscription keeps partials and the final in publish order.
+			partials := 0
+			finals := make(chan protocol.LLMResponse, 1)
+			sub, err := client.Conn().Subscribe("nlu.response.*", func(msg *nats.Msg) {
+				if msg.Subject == protocol.SubjectLLMResponsePartial {
+					partials++
+					return
+				}
+				var resp protocol.LLMResponse
+				if err := json.Unmarshal(msg.Data, &resp); err == nil {
+					finals <- resp
+				}
+			})
+			if err != nil {
+				t.Fatalf("subscribe responses: %v", err)
+			}
+			t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+			data, _ := json.Marshal(protocol.LLMRequest{SessionID: "s-1", Prompt: "hello"})
+			if err := client.Conn().Publish(protocol.SubjectLLMRequest, data); err != nil {
+				t.Fatalf("publish request: %v", err)
+			}
+			select {
+			case resp := <-finals:
+				if resp.Content != "Hello, world" {
+					t.Fatalf("expected full text in final, got %q", resp.Content)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for final response")
+			}
+			want := 0
+			if stream {
+				want = 3
+			}
+			if partials != want {
+				t.Fatalf("expected %d partials, got %d", want, partials)
+			}
+		})
+	}
+}
+
+func TestServiceWarmupLoadsOllamaModels(t *testing.T) {
+	loaded := make(chan ollamaRequest, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaRequest
+		if r.Method != http.MethodPost || r.URL.Path != "/api/generate" {
+			t.Errorf("unexpected warmup call %s %s", r.Method, r.URL.Path)
+		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode warmup request: %v", err)
+		}
+		loaded <- req
+		_, _ = w.Write([]byte(`{"done":true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := testutil.StartBus(t)
+	generators := map[string]Generator{DefaultBackend: NewOllamaGenerator(server.URL, "small:latest", "large:latest")}
+	svc := NewService(context.Background(), config.LLMConfig{Enabled: true, Warmup: true}, client, protocol.DefaultSubjects(), generators, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start llm service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	models := map[string]bool{}
+	for len(models) < 2 {
+		select {
+		case req := <-loaded:
+			if req.Prompt != "" || req.Stream {
+				t.Fatalf("expected empty non-streaming prompt, got %+v", req)
+			}
+			models[req.Model] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for warmup; loaded %v", models)
+		}
+	}
+	if !models["small:latest"] || !models["large:latest"] {
+		t.Fatalf("expected both tier models to be loaded, got %v", models)
+	}
+}
+
+func TestServiceHealthReflectsOllamaReachability(t *testing.T) {
+	var probes atomic.Int32
+	reachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Errorf("unexpected probe path %s", r.URL.Path)
+		}
+		probes.Add(1)
+		_, _ = w.Write([]byte(`{"models":[]}`))
+	}))
+	t.Cleanup(reachable.Close)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	cases := []struct {
+		name       string
+		generators map[string]Generator
+		want       bool
+	}{
+		{name: "reachable", generators: map[string]Generator{DefaultBackend: NewOllamaGenerator(reachable.URL, "", "")}, want: true},
+		{name: "unreachable", generators: map[string]Generator{DefaultBackend: NewOllamaGenerator(unreachable.URL, "", "")}, want: false},
+		{name: "reachable fallback", generators: map[string]Generator{
+			DefaultBackend:  NewOllamaGenerator(unreachable.URL, "", ""),
+			FallbackBackend: NewOllamaGenerator(reachable.URL, "", ""),
+		}, want: true},
+		{name: "unpingable backend", generators: map[string]Generator{
+			DefaultBackend: NewOllamaGenerator(unreachable.URL, "", ""),
+			"local":        NewMockGenerator(),
+		}, want: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := testutil.StartBus(t)
+			svc := NewService(context.Background(), config.LLMConfig{Enabled: true, HealthIntervalMS: 20}, client, protocol.DefaultSubjects(), tc.generators, testutil.Logger())
+			start := time.Now()
+			if err := svc.Start(); err != nil {
+				t.Fatalf("start llm service: %v", err)
+			}
+			t.Cleanup(svc.Close)
+			if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+				t.Fatalf("expected Start not to wait for the probe, took %s", elapsed)
+			}
+			// Probes run in the background; give a few rounds time to finish.
+			from := probes.Load()
+			deadline := time.Now().Add(2 * time.Second)
+			for time.Now().Before(deadline) && svc.Healthy() && probes.Load() < from+3 {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if got := svc.Healthy(); got != tc.want {
+				t.Fatalf("expected Healthy() = %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"errors"
+	"strings"
+)
+
+// errStopped ends a generation once a stop sequence has been seen; the
+// service treats it as a normal completion.
+var errStopped = errors.New("stop sequence reached")
+
+// stopFilter enforces stop sequences on streamed output for backends that
+// ignore them. Text that could be the start of a stop sequence is held back
+// until the next chunk shows whether it is, so a stop split across tokens
+// is still caught.
+type stopFilter struct {
+	stops   []string
+	text    strings.Builder
+	pending string
+}
+
+func newStopFilter(stops []string) *stopFilter {
+	return &stopFilter{stops: stops}
+}
+
+// apply filters one generator chunk. A partial that reaches a stop sequence
+// is turned into the final chunk, carrying everything before the stop, and
+// stopped is reported so the caller can end the generation.
+func (f *stopFilter) apply(chunk Chunk) (Chunk, bool) {
+	if len(f.stops) == 0 {
+		return chunk, false
+	}
+	if !chunk.Partial {
+		if chunk.Content == "" {
+			// Complete the held-back text; the service fills in the rest.
+			chunk.Content = f.text.String() + f.pending
+		} else {
+			chunk.Content, _ = truncateAtStop(chunk.Content, f.stops)
+		}
+		return chunk, false
+	}
+
+	buf := f.pending + chunk.Content
+	f.pending = ""
+	if head, found := truncateAtStop(buf, f.stops); found {
+		f.text.WriteString(head)
+		chunk.Content = f.text.String()
+		chunk.Partial = false
+		return chunk, true
+	}
+	hold := f.heldBack(buf)
+	f.pending = buf[len(buf)-hold:]
+	chunk.Content = buf[:len(buf)-hold]
+	f.text.WriteString(chunk.Content)
+	return chunk, false
+}
+
+// heldBack returns the length of the longest suffix of buf that is a proper
+// prefix of some stop sequence.
+func (f *stopFilter) heldBack(buf string) int {
+	longest := 0
+	for _, stop := range f.stops {
+		for n := min(len(stop)-1, len(buf)); n > longest; n-- {
+			if strings.HasSuffix(buf, stop[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// truncateAtStop cuts text at the earliest occurrence of any stop sequence.
+func truncateAtStop(text string, stops []string) (string, bool) {
+	cut := -1
+	for _, stop := range stops {
+		if stop == "" {
+			continue
+		}
+		if i := strings.Index(text, stop); i >= 0 && (cut < 0 || i < cut) {
+			cut = i
+		}
+	}
+	if cut < 0 {
+		return text, false
+	}
+	return text[:cut], true
+}
@@ -0,0 +1,41 @@
+package llm
+
+import "testing"
+
+func TestStopFilterTruncatesStreamAtSplitStop(t *testing.T) {
+	f := newStopFilter([]string{"\nUser:"})
+	var published []string
+	for _, token := range []string{"It is ", "sunny.", "\nUs", "er: and", " more"} {
+		chunk, stopped := f.apply(Chunk{Content: token, Partial: true})
+		if stopped {
+			if chunk.Partial || chunk.Content != "It is sunny." {
+				t.Fatalf("expected final truncated at stop, got %+v", chunk)
+			}
+			if len(published) != 2 || published[0] != "It is " || published[1] != "sunny." {
+				t.Fatalf("unexpected partials before stop: %q", published)
+			}
+			return
+		}
+		if chunk.Content != "" {
+			published = append(published, chunk.Content)
+		}
+	}
+	t.Fatal("stop sequence was not detected")
+}
+
+func TestStopFilterReleasesHeldBackTextOnFinal(t *testing.T) {
+	f := newStopFilter([]string{"###"})
+	chunk, _ := f.apply(Chunk{Content: "answer #", Partial: true})
+	if chunk.Content != "answer " {
+		t.Fatalf("expected possible stop prefix to be held back, got %q", chunk.Content)
+	}
+	final, stopped := f.apply(Chunk{})
+	if stopped || final.Content != "answer #" {
+		t.Fatalf("expected held-back text in final, got %+v (stopped=%t)", final, stopped)
+	}
+
+	final, _ = newStopFilter([]string{"###"}).apply(Chunk{Content: "full text### trailing"})
+	if final.Content != "full text" {
+		t.Fatalf("expected non-streamed final truncated at stop, got %q", final.Content)
+	}
+}
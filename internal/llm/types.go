@@ -15,10 +15,13 @@ type Request struct {
 	Tier        string
 	MaxTokens   int
 	Temperature float64
+	Stop        []string
 	TraceID     string
 }
 
-// Chunk represents streamed model output.
+// Chunk represents streamed model output. Partial chunks carry the next
+// piece of text; the final chunk carries the full completion. A final left
+// empty by the generator is filled in by the service from the partials.
 type Chunk struct {
 	SessionID        string
 	Content          string
@@ -27,6 +30,11 @@ type Chunk struct {
 	CompletionTokens int
 	Latency          time.Duration
 	TraceID          string
+	// Backend, Fallback and Truncated are filled in by the service, not
+	// generators.
+	Backend   string
+	Fallback  bool
+	Truncated bool
 }
 
 // Generator defines a pluggable LLM backend.
@@ -34,9 +42,21 @@ type Generator interface {
 	Generate(ctx context.Context, req Request, consumer func(Chunk) error) error
 }
 
+// Warmer is implemented by generators that can load their models before
+// the first request arrives.
+type Warmer interface {
+	Warmup(ctx context.Context) error
+}
+
+// Pinger is implemented by generators backed by a server whose
+// reachability can be checked; the service reflects it in Healthy.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
 // OptionsFromConfig builds defaults from config.
 func OptionsFromConfig(cfg config.LLMConfig, reqTier string) (Request, error) {
-	req := Request{Tier: cfg.DefaultTier, MaxTokens: cfg.MaxTokens, Temperature: cfg.Temperature}
+	req := Request{Tier: cfg.DefaultTier, MaxTokens: cfg.MaxTokens, Temperature: cfg.Temperature, Stop: cfg.Stop}
 	if reqTier != "" {
 		req.Tier = reqTier
 	}
@@ -0,0 +1,25 @@
+// Package logging holds slog helpers shared by the voice pipeline services.
+package logging
+
+import "log/slog"
+
+// WithSession returns logger annotated with the session_id and trace_id
+// attributes used to correlate one utterance across STT, router, LLM, TTS,
+// and skills logs. Empty IDs are omitted, so handlers can pass whatever the
+// incoming message carries.
+func WithSession(logger *slog.Logger, sessionID, traceID string) *slog.Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	attrs := make([]any, 0, 2)
+	if sessionID != "" {
+		attrs = append(attrs, slog.String("session_id", sessionID))
+	}
+	if traceID != "" {
+		attrs = append(attrs, slog.String("trace_id", traceID))
+	}
+	if len(attrs) == 0 {
+		return logger
+	}
+	return logger.With(attrs...)
+}
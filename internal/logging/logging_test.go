@@ -0,0 +1,24 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithSessionOmitsEmptyIDs(t *testing.T) {
+	var buf bytes.Buffer
+	base := slog.New(slog.NewTextHandler(&buf, nil))
+
+	WithSession(base, "s-1", "abc123").Info("hello")
+	if out := buf.String(); !strings.Contains(out, "session_id=s-1") || !strings.Contains(out, "trace_id=abc123") {
+		t.Fatalf("expected correlation attributes, got %q", out)
+	}
+
+	buf.Reset()
+	WithSession(base, "s-2", "").Info("hello")
+	if out := buf.String(); !strings.Contains(out, "session_id=s-2") || strings.Contains(out, "trace_id") {
+		t.Fatalf("expected only session_id, got %q", out)
+	}
+}
@@ -3,6 +3,7 @@ package natsserver
 import (
 	"fmt"
 	"log/slog"
+	"net"
 	"time"
 
 	"github.com/loqalabs/loqa-core/internal/config"
@@ -21,11 +22,15 @@ func Start(cfg config.BusConfig, log *slog.Logger) (*EmbeddedServer, error) {
 		return nil, nil
 	}
 
+	storeDir := cfg.StoreDir
+	if storeDir == "" {
+		storeDir = "./data/nats"
+	}
 	opts := &server.Options{
 		Host:      "0.0.0.0",
 		Port:      cfg.Port,
 		JetStream: true,
-		StoreDir:  "./data/nats",
+		StoreDir:  storeDir,
 		LogFile:   "", // Use stdout/stderr
 		Trace:     false,
 		Debug:     false,
@@ -46,8 +51,8 @@ func Start(cfg config.BusConfig, log *slog.Logger) (*EmbeddedServer, error) {
 	}
 
 	log.Info("embedded NATS server started",
-		slog.Int("port", cfg.Port),
-		slog.String("store_dir", "./data/nats"))
+		slog.String("url", clientURL(ns)),
+		slog.String("store_dir", storeDir))
 
 	return &EmbeddedServer{
 		ns:  ns,
@@ -55,6 +60,20 @@ func Start(cfg config.BusConfig, log *slog.Logger) (*EmbeddedServer, error) {
 	}, nil
 }
 
+// ClientURL returns a loopback URL for connecting to the server. It reports
+// the bound port, so it is accurate when bus.port is -1 (random).
+func (e *EmbeddedServer) ClientURL() string {
+	return clientURL(e.ns)
+}
+
+func clientURL(ns *server.Server) string {
+	port := 0
+	if addr, ok := ns.Addr().(*net.TCPAddr); ok {
+		port = addr.Port
+	}
+	return fmt.Sprintf("nats://127.0.0.1:%d", port)
+}
+
 // Shutdown gracefully shuts down the embedded NATS server.
 func (e *EmbeddedServer) Shutdown() {
 	if e == nil || e.ns == nil {
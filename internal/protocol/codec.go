@@ -0,0 +1,69 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes bus payloads. Services obtain one from the bus client rather
+// than calling encoding/json directly, so the wire format is selected once by
+// bus.codec.
+type Codec interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	// JSON is the default codec and the format skills and edge devices speak.
+	JSON Codec = jsonCodec{}
+	// MessagePack encodes the same fields (keyed by their json tags) in a
+	// compact binary form; []byte PCM is carried without base64.
+	MessagePack Codec = msgpackCodec{}
+)
+
+// CodecByName resolves a bus.codec setting. An empty name selects JSON.
+func CodecByName(name string) (Codec, error) {
+	switch name {
+	case "", "json":
+		return JSON, nil
+	case "msgpack":
+		return MessagePack, nil
+	default:
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                       { return "json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal also accepts JSON objects, so producers that still speak JSON
+// (skills, older nodes) keep working on a msgpack bus.
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		return json.Unmarshal(data, v)
+	}
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
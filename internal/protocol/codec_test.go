@@ -0,0 +1,88 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func sampleChunk() AudioChunk {
+	pcm := make([]byte, 22050*2*400/1000) // 400ms of 16-bit mono at 22.05kHz
+	for i := range pcm {
+		pcm[i] = byte(i * 7)
+	}
+	return AudioChunk{V: SchemaVersion, SessionID: "session-1", Target: "kitchen", Sequence: 3, SampleRate: 22050, Channels: 1, PCM: pcm}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSON, MessagePack} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			in := sampleChunk()
+			data, err := codec.Marshal(in)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			var out AudioChunk
+			if err := codec.Unmarshal(data, &out); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if out.SessionID != in.SessionID || out.Sequence != in.Sequence || out.V != in.V || !bytes.Equal(out.PCM, in.PCM) {
+				t.Fatalf("round trip mismatch: %+v", out)
+			}
+
+			ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+			data, err = codec.Marshal(LLMRequest{SessionID: "s", Prompt: "hi", Timestamp: ts})
+			if err != nil {
+				t.Fatalf("marshal request: %v", err)
+			}
+			var req LLMRequest
+			if err := codec.Unmarshal(data, &req); err != nil {
+				t.Fatalf("unmarshal request: %v", err)
+			}
+			if req.Prompt != "hi" || !req.Timestamp.Equal(ts) {
+				t.Fatalf("unexpected request %+v", req)
+			}
+		})
+	}
+}
+
+func TestMessagePackSmallerForAudioAndReadsJSON(t *testing.T) {
+	chunk := sampleChunk()
+	jsonData, _ := JSON.Marshal(chunk)
+	packed, _ := MessagePack.Marshal(chunk)
+	if len(packed) >= len(jsonData)*3/4 {
+		t.Fatalf("expected msgpack (%d bytes) well below json (%d bytes)", len(packed), len(jsonData))
+	}
+
+	legacy, _ := json.Marshal(TTSRequest{SessionID: "s", Text: "hello"})
+	var req TTSRequest
+	if err := MessagePack.Unmarshal(legacy, &req); err != nil || req.Text != "hello" {
+		t.Fatalf("expected msgpack codec to accept json, got %+v (%v)", req, err)
+	}
+
+	if _, err := CodecByName("protobuf"); err == nil {
+		t.Fatal("expected unknown codec to fail")
+	}
+}
+
+func BenchmarkCodecAudioChunk(b *testing.B) {
+	chunk := sampleChunk()
+	for _, codec := range []Codec{JSON, MessagePack} {
+		b.Run(codec.Name(), func(b *testing.B) {
+			size, _ := codec.Marshal(chunk)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data, err := codec.Marshal(chunk)
+				if err != nil {
+					b.Fatal(err)
+				}
+				var out AudioChunk
+				if err := codec.Unmarshal(data, &out); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(size)), "bytes/msg")
+		})
+	}
+}
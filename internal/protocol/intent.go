@@ -0,0 +1,63 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SubjectIntentPrefix roots the subjects structured intents are dispatched
+// on: an intent for skill "home" with action "command" is published on
+// skill.home.command, the subject the skill subscribes to.
+const SubjectIntentPrefix = "skill"
+
+// Intent is the structured output the router accepts from the LLM in
+// router.intent_mode. The LLM replies with a JSON object such as
+//
+//	{"skill": "home", "action": "command", "params": {"device": "light.kitchen", "action": "turn_on"}}
+//
+// and the router publishes it, with the session and trace filled in, to
+// Subject() instead of speaking the text. Skill and Action must each be a
+// single subject token.
+type Intent struct {
+	V         string         `json:"v,omitempty"`
+	SessionID string         `json:"session_id,omitempty"`
+	Skill     string         `json:"skill"`
+	Action    string         `json:"action"`
+	Params    map[string]any `json:"params,omitempty"`
+	TraceID   string         `json:"trace_id,omitempty"`
+}
+
+// Subject returns the unprefixed subject the intent is dispatched on.
+func (i Intent) Subject() string {
+	return SubjectIntentPrefix + "." + i.Skill + "." + i.Action
+}
+
+// ParseIntentSubject splits an unprefixed skill.<skill>.<action> subject
+// into the intent it dispatches.
+func ParseIntentSubject(subject string) (Intent, error) {
+	rest, ok := strings.CutPrefix(subject, SubjectIntentPrefix+".")
+	skill, action, found := strings.Cut(rest, ".")
+	if !ok || !found {
+		return Intent{}, fmt.Errorf("intent subject must be %s.<skill>.<action>, got %q", SubjectIntentPrefix, subject)
+	}
+	intent := Intent{Skill: skill, Action: action}
+	return intent, intent.Validate()
+}
+
+// Validate checks that Skill and Action form a literal subject.
+func (i Intent) Validate() error {
+	if err := intentToken("skill", i.Skill); err != nil {
+		return err
+	}
+	return intentToken("action", i.Action)
+}
+
+func intentToken(field, value string) error {
+	if value == "" {
+		return fmt.Errorf("intent %s is required", field)
+	}
+	if strings.ContainsAny(value, ". \t\r\n*>") {
+		return fmt.Errorf("intent %s must be a single subject token, got %q", field, value)
+	}
+	return nil
+}
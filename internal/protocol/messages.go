@@ -1,24 +1,41 @@
+// Package protocol defines the bus subjects and payloads shared by loqa
+// services. Core messages carry an optional "v" schema version; see
+// CheckVersion.
 package protocol
 
 import "time"
 
 // AudioFrame represents PCM audio data streamed from edge devices.
 type AudioFrame struct {
+	V          string `json:"v,omitempty"`
 	SessionID  string `json:"session_id"`
 	Sequence   int    `json:"sequence"`
 	SampleRate int    `json:"sample_rate"`
 	Channels   int    `json:"channels"`
 	PCM        []byte `json:"pcm"`
 	Final      bool   `json:"final"`
+	TraceID    string `json:"trace_id,omitempty"`
+	// SpanID is the edge device's span for the utterance. With TraceID it
+	// parents the router's voice.session span.
+	SpanID string `json:"span_id,omitempty"`
+	Target string `json:"target,omitempty"`
+	// Language overrides stt.language for the session; "auto" asks the
+	// engine to detect it.
+	Language string `json:"language,omitempty"`
 }
 
 // Transcript represents STT output broadcast on the bus.
 type Transcript struct {
+	V          string    `json:"v,omitempty"`
 	SessionID  string    `json:"session_id"`
 	Text       string    `json:"text"`
 	Partial    bool      `json:"partial"`
 	Timestamp  time.Time `json:"timestamp"`
 	Confidence float64   `json:"confidence"`
+	TraceID    string    `json:"trace_id,omitempty"`
+	SpanID     string    `json:"span_id,omitempty"` // copied from the session's audio frames
+	Target     string    `json:"target,omitempty"`
+	Language   string    `json:"language,omitempty"` // detected or requested language
 }
 
 const (
@@ -31,22 +48,33 @@ const (
 	SubjectTTSRequest         = "tts.request"
 	SubjectTTSAudio           = "tts.audio"
 	SubjectTTSDone            = "tts.done"
+
+	SubjectNodeAnnounce        = "ctrl.node.announce"
+	SubjectNodeHeartbeatPrefix = "ctrl.node.heartbeat"
+	SubjectCapabilityQuery     = "ctrl.capability.query"
+	SubjectSkillAuditPrefix    = "skills.audit"
 )
 
 // LLMRequest represents a prompt sent to the language model harness.
 type LLMRequest struct {
+	V           string    `json:"v,omitempty"`
 	SessionID   string    `json:"session_id"`
 	Prompt      string    `json:"prompt"`
 	System      string    `json:"system,omitempty"`
 	Tier        string    `json:"tier,omitempty"`
+	Backend     string    `json:"backend,omitempty"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
 	Temperature float64   `json:"temperature,omitempty"`
+	Stop        []string  `json:"stop,omitempty"` // overrides llm.stop when set
 	TraceID     string    `json:"trace_id,omitempty"`
 	Timestamp   time.Time `json:"timestamp"`
 }
 
 // LLMResponse represents streamed or final completions from the harness.
+// A final with Truncated set carries the content streamed before the
+// generation was cancelled or timed out.
 type LLMResponse struct {
+	V                string    `json:"v,omitempty"`
 	SessionID        string    `json:"session_id"`
 	Content          string    `json:"content"`
 	Partial          bool      `json:"partial"`
@@ -54,20 +82,49 @@ type LLMResponse struct {
 	PromptTokens     int       `json:"prompt_tokens,omitempty"`
 	CompletionTokens int       `json:"completion_tokens,omitempty"`
 	LatencyMS        int64     `json:"latency_ms,omitempty"`
+	Backend          string    `json:"backend,omitempty"`
+	Fallback         bool      `json:"fallback,omitempty"`
+	Truncated        bool      `json:"truncated,omitempty"`
 	Timestamp        time.Time `json:"timestamp"`
 }
 
-// TTSRequest asks the TTS service to synthesize a phrase.
+// TTSRequest asks the TTS service to synthesize a phrase. The audio goes to
+// Target and every entry in Targets; Targets lets one announcement reach
+// several devices while Target stays for single-device senders. When SSML
+// is set, engines without SSML support speak its stripped text.
 type TTSRequest struct {
-	SessionID string `json:"session_id"`
-	Text      string `json:"text"`
-	Voice     string `json:"voice,omitempty"`
-	Target    string `json:"target,omitempty"`
-	TraceID   string `json:"trace_id,omitempty"`
+	V         string   `json:"v,omitempty"`
+	SessionID string   `json:"session_id"`
+	Text      string   `json:"text"`
+	SSML      string   `json:"ssml,omitempty"` // optional markup; Text may then be empty
+	Voice     string   `json:"voice,omitempty"`
+	Target    string   `json:"target,omitempty"`
+	Targets   []string `json:"targets,omitempty"`
+	TraceID   string   `json:"trace_id,omitempty"`
+}
+
+// AllTargets returns Target followed by Targets with empty entries and
+// duplicates removed. A request naming no target yields a single "" entry,
+// the untargeted broadcast.
+func (r TTSRequest) AllTargets() []string {
+	seen := make(map[string]bool, len(r.Targets)+1)
+	var targets []string
+	for _, target := range append([]string{r.Target}, r.Targets...) {
+		if target == "" || seen[target] {
+			continue
+		}
+		seen[target] = true
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 {
+		return []string{""}
+	}
+	return targets
 }
 
 // AudioChunk carries synthesized PCM audio destined for output devices.
 type AudioChunk struct {
+	V          string `json:"v,omitempty"`
 	SessionID  string `json:"session_id"`
 	Target     string `json:"target,omitempty"`
 	Sequence   int    `json:"sequence"`
@@ -78,8 +135,10 @@ type AudioChunk struct {
 }
 
 type TTSStatus struct {
+	V         string    `json:"v,omitempty"`
 	SessionID string    `json:"session_id"`
 	Target    string    `json:"target,omitempty"`
 	Completed bool      `json:"completed"`
+	TraceID   string    `json:"trace_id,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
 }
@@ -0,0 +1,32 @@
+package protocol
+
+import "strings"
+
+// Privacy scopes label recorded events, ordered from least to most
+// restricted.
+const (
+	PrivacyPublic    = "public"
+	PrivacyInternal  = "internal"
+	PrivacySession   = "session"
+	PrivacyPrivate   = "private"
+	PrivacySensitive = "sensitive"
+)
+
+var privacyScopes = []string{PrivacyPublic, PrivacyInternal, PrivacySession, PrivacyPrivate, PrivacySensitive}
+
+// PrivacyRank returns the position of scope in the strictness order, or -1
+// when scope is not a known privacy scope.
+func PrivacyRank(scope string) int {
+	for i, s := range privacyScopes {
+		if s == scope {
+			return i
+		}
+	}
+	return -1
+}
+
+// PrivacyScopesList returns the known scopes joined with "|" for error
+// messages.
+func PrivacyScopesList() string {
+	return strings.Join(privacyScopes, "|")
+}
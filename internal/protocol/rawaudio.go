@@ -0,0 +1,158 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Raw audio messages carry PCM as the NATS message body and the remaining
+// AudioFrame/AudioChunk fields as headers, avoiding codec overhead on the
+// largest payloads on the bus.
+const (
+	HeaderSchemaVersion = "Loqa-V"
+	HeaderSessionID     = "Loqa-Session-Id"
+	HeaderSequence      = "Loqa-Sequence"
+	HeaderSampleRate    = "Loqa-Sample-Rate"
+	HeaderChannels      = "Loqa-Channels"
+	HeaderFinal         = "Loqa-Final"
+	HeaderTarget        = "Loqa-Target"
+	HeaderTraceID       = "Loqa-Trace-Id"
+	HeaderLanguage      = "Loqa-Language"
+)
+
+// ErrNotRawAudio is returned when a message lacks raw audio headers and must
+// be decoded with the bus codec instead.
+var ErrNotRawAudio = errors.New("not a raw audio message")
+
+// IsRawAudio reports whether msg uses the raw header encoding.
+func IsRawAudio(msg *nats.Msg) bool {
+	return msg.Header != nil && msg.Header.Get(HeaderSessionID) != ""
+}
+
+// RawAudioChunkMsg encodes chunk for publishing on subject.
+func RawAudioChunkMsg(subject string, chunk AudioChunk) *nats.Msg {
+	msg := nats.NewMsg(subject)
+	setAudioHeaders(msg.Header, chunk.V, chunk.SessionID, chunk.Sequence, chunk.SampleRate, chunk.Channels, chunk.Final, chunk.Target, "")
+	msg.Data = chunk.PCM
+	return msg
+}
+
+// AudioChunkFromRaw decodes a message built by RawAudioChunkMsg.
+func AudioChunkFromRaw(msg *nats.Msg) (AudioChunk, error) {
+	var chunk AudioChunk
+	meta, err := audioHeaders(msg)
+	if err != nil {
+		return chunk, err
+	}
+	chunk = AudioChunk{
+		V:          meta.v,
+		SessionID:  meta.sessionID,
+		Target:     meta.target,
+		Sequence:   meta.sequence,
+		SampleRate: meta.sampleRate,
+		Channels:   meta.channels,
+		PCM:        msg.Data,
+		Final:      meta.final,
+	}
+	return chunk, nil
+}
+
+// RawAudioFrameMsg encodes frame for publishing on subject.
+func RawAudioFrameMsg(subject string, frame AudioFrame) *nats.Msg {
+	msg := nats.NewMsg(subject)
+	setAudioHeaders(msg.Header, frame.V, frame.SessionID, frame.Sequence, frame.SampleRate, frame.Channels, frame.Final, frame.Target, frame.TraceID)
+	if frame.Language != "" {
+		msg.Header.Set(HeaderLanguage, frame.Language)
+	}
+	msg.Data = frame.PCM
+	return msg
+}
+
+// AudioFrameFromRaw decodes a message built by RawAudioFrameMsg.
+func AudioFrameFromRaw(msg *nats.Msg) (AudioFrame, error) {
+	var frame AudioFrame
+	meta, err := audioHeaders(msg)
+	if err != nil {
+		return frame, err
+	}
+	frame = AudioFrame{
+		V:          meta.v,
+		SessionID:  meta.sessionID,
+		Sequence:   meta.sequence,
+		SampleRate: meta.sampleRate,
+		Channels:   meta.channels,
+		PCM:        msg.Data,
+		Final:      meta.final,
+		TraceID:    meta.traceID,
+		Target:     meta.target,
+		Language:   msg.Header.Get(HeaderLanguage),
+	}
+	return frame, nil
+}
+
+type audioMeta struct {
+	v, sessionID, target, traceID  string
+	sequence, sampleRate, channels int
+	final                          bool
+}
+
+func setAudioHeaders(h nats.Header, v, sessionID string, sequence, sampleRate, channels int, final bool, target, traceID string) {
+	if v == "" {
+		v = SchemaVersion
+	}
+	h.Set(HeaderSchemaVersion, v)
+	h.Set(HeaderSessionID, sessionID)
+	h.Set(HeaderSequence, strconv.Itoa(sequence))
+	h.Set(HeaderSampleRate, strconv.Itoa(sampleRate))
+	h.Set(HeaderChannels, strconv.Itoa(channels))
+	h.Set(HeaderFinal, strconv.FormatBool(final))
+	if target != "" {
+		h.Set(HeaderTarget, target)
+	}
+	if traceID != "" {
+		h.Set(HeaderTraceID, traceID)
+	}
+}
+
+func audioHeaders(msg *nats.Msg) (audioMeta, error) {
+	var meta audioMeta
+	if !IsRawAudio(msg) {
+		return meta, ErrNotRawAudio
+	}
+	h := msg.Header
+	meta.v = h.Get(HeaderSchemaVersion)
+	meta.sessionID = h.Get(HeaderSessionID)
+	meta.target = h.Get(HeaderTarget)
+	meta.traceID = h.Get(HeaderTraceID)
+	var err error
+	if meta.sequence, err = headerInt(h, HeaderSequence); err != nil {
+		return meta, err
+	}
+	if meta.sampleRate, err = headerInt(h, HeaderSampleRate); err != nil {
+		return meta, err
+	}
+	if meta.channels, err = headerInt(h, HeaderChannels); err != nil {
+		return meta, err
+	}
+	if raw := h.Get(HeaderFinal); raw != "" {
+		if meta.final, err = strconv.ParseBool(raw); err != nil {
+			return meta, fmt.Errorf("invalid %s header: %w", HeaderFinal, err)
+		}
+	}
+	return meta, nil
+}
+
+func headerInt(h nats.Header, key string) (int, error) {
+	raw := h.Get(key)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s header: %w", key, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,36 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestRawAudioFrameRoundTrip(t *testing.T) {
+	in := AudioFrame{SessionID: "s1", Sequence: 9, SampleRate: 16000, Channels: 2, PCM: []byte{0, 0xff, 0x7f, 0x80}, Final: true, TraceID: "trace", Target: "kitchen"}
+	msg := RawAudioFrameMsg("audio.frame.kitchen", in)
+	if !bytes.Equal(msg.Data, in.PCM) {
+		t.Fatal("expected PCM to be the raw message body")
+	}
+	out, err := AudioFrameFromRaw(msg)
+	if err != nil {
+		t.Fatalf("decode frame: %v", err)
+	}
+	in.V = SchemaVersion
+	if out.SessionID != in.SessionID || out.Sequence != in.Sequence || out.SampleRate != in.SampleRate || out.Channels != in.Channels ||
+		out.Final != in.Final || out.TraceID != in.TraceID || out.Target != in.Target || out.V != in.V || !bytes.Equal(out.PCM, in.PCM) {
+		t.Fatalf("round trip mismatch: %+v", out)
+	}
+}
+
+func TestRawAudioRejectsBadHeaders(t *testing.T) {
+	if _, err := AudioChunkFromRaw(&nats.Msg{Data: []byte("{}")}); err != ErrNotRawAudio {
+		t.Fatalf("expected ErrNotRawAudio, got %v", err)
+	}
+	msg := RawAudioChunkMsg("tts.audio", AudioChunk{SessionID: "s1"})
+	msg.Header.Set(HeaderSequence, "x")
+	if _, err := AudioChunkFromRaw(msg); err == nil {
+		t.Fatal("expected invalid sequence header to fail")
+	}
+}
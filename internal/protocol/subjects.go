@@ -0,0 +1,132 @@
+package protocol
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Subjects holds the concrete NATS subjects a runtime publishes and
+// subscribes to. Services take a Subjects value instead of referring to the
+// Subject* constants, so subjects can be namespaced per deployment or
+// remapped for tests and alternative topologies.
+type Subjects struct {
+	Prefix              string
+	AudioFramePrefix    string
+	TranscriptPartial   string
+	TranscriptFinal     string
+	LLMRequest          string
+	LLMResponsePartial  string
+	LLMResponseFinal    string
+	TTSRequest          string
+	TTSAudio            string
+	TTSDone             string
+	NodeAnnounce        string
+	NodeHeartbeatPrefix string
+	CapabilityQuery     string
+	SkillAuditPrefix    string
+}
+
+// DefaultSubjects returns the canonical, unprefixed subject set.
+func DefaultSubjects() Subjects {
+	return Subjects{
+		AudioFramePrefix:    SubjectAudioFramePrefix,
+		TranscriptPartial:   SubjectTranscriptPartial,
+		TranscriptFinal:     SubjectTranscriptFinal,
+		LLMRequest:          SubjectLLMRequest,
+		LLMResponsePartial:  SubjectLLMResponsePartial,
+		LLMResponseFinal:    SubjectLLMResponseFinal,
+		TTSRequest:          SubjectTTSRequest,
+		TTSAudio:            SubjectTTSAudio,
+		TTSDone:             SubjectTTSDone,
+		NodeAnnounce:        SubjectNodeAnnounce,
+		NodeHeartbeatPrefix: SubjectNodeHeartbeatPrefix,
+		CapabilityQuery:     SubjectCapabilityQuery,
+		SkillAuditPrefix:    SubjectSkillAuditPrefix,
+	}
+}
+
+// NewSubjects returns the default subject set namespaced under prefix.
+func NewSubjects(prefix string) Subjects {
+	return DefaultSubjects().WithPrefix(prefix)
+}
+
+// WithPrefix namespaces every subject under prefix. A missing trailing dot is
+// added, so "tenant-a" and "tenant-a." are equivalent; an empty prefix leaves
+// the set unchanged. Apply it once, after any overrides.
+func (s Subjects) WithPrefix(prefix string) Subjects {
+	if prefix == "" {
+		return s
+	}
+	if !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	s.Prefix = prefix
+	for _, field := range s.fields() {
+		*field.ptr = prefix + *field.ptr
+	}
+	return s
+}
+
+// WithOverrides replaces individual subjects by their config key (for
+// example "tts_request"). Unknown keys and empty subjects are rejected.
+func (s Subjects) WithOverrides(overrides map[string]string) (Subjects, error) {
+	if len(overrides) == 0 {
+		return s, nil
+	}
+	fields := make(map[string]*string)
+	for _, field := range s.fields() {
+		fields[field.key] = field.ptr
+	}
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ptr, ok := fields[key]
+		if !ok {
+			return s, fmt.Errorf("unknown subject %q", key)
+		}
+		subject := overrides[key]
+		if subject == "" || strings.ContainsAny(subject, " \t*>") {
+			return s, fmt.Errorf("subject %q must be a literal subject, got %q", key, subject)
+		}
+		*ptr = subject
+	}
+	return s, nil
+}
+
+// Apply namespaces an arbitrary subject, such as one declared in a skill
+// manifest.
+func (s Subjects) Apply(subject string) string {
+	return s.Prefix + subject
+}
+
+// Strip removes the namespace from a subject received on the bus.
+func (s Subjects) Strip(subject string) string {
+	return strings.TrimPrefix(subject, s.Prefix)
+}
+
+type subjectField struct {
+	key string
+	ptr *string
+}
+
+func (s *Subjects) fields() []subjectField {
+	return []subjectField{
+		{"audio_frame", &s.AudioFramePrefix},
+		{"transcript_partial", &s.TranscriptPartial},
+		{"transcript_final", &s.TranscriptFinal},
+		{"llm_request", &s.LLMRequest},
+		{"llm_response_partial", &s.LLMResponsePartial},
+		{"llm_response_final", &s.LLMResponseFinal},
+		{"tts_request", &s.TTSRequest},
+		{"tts_audio", &s.TTSAudio},
+		{"tts_done", &s.TTSDone},
+		{"node_announce", &s.NodeAnnounce},
+		{"node_heartbeat", &s.NodeHeartbeatPrefix},
+		{"capability_query", &s.CapabilityQuery},
+		{"skill_audit", &s.SkillAuditPrefix},
+	}
+}
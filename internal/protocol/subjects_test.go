@@ -0,0 +1,50 @@
+package protocol
+
+import "testing"
+
+func TestNewSubjectsPrefix(t *testing.T) {
+	bare := DefaultSubjects()
+	if bare.LLMRequest != SubjectLLMRequest || bare.NodeAnnounce != SubjectNodeAnnounce || bare.Prefix != "" {
+		t.Fatalf("expected unprefixed defaults, got %+v", bare)
+	}
+
+	for _, prefix := range []string{"tenant-a", "tenant-a."} {
+		s := NewSubjects(prefix)
+		if s.TTSRequest != "tenant-a.tts.request" {
+			t.Fatalf("prefix %q: unexpected tts subject %q", prefix, s.TTSRequest)
+		}
+		if got := s.Apply("skill.timer.start"); got != "tenant-a.skill.timer.start" {
+			t.Fatalf("prefix %q: unexpected applied subject %q", prefix, got)
+		}
+		if got := s.Strip("tenant-a.skill.timer.start"); got != "skill.timer.start" {
+			t.Fatalf("prefix %q: unexpected stripped subject %q", prefix, got)
+		}
+	}
+}
+
+func TestSubjectOverrides(t *testing.T) {
+	s, err := DefaultSubjects().WithOverrides(map[string]string{"tts_request": "speaker.say"})
+	if err != nil {
+		t.Fatalf("override: %v", err)
+	}
+	s = s.WithPrefix("tenant-a")
+	if s.TTSRequest != "tenant-a.speaker.say" {
+		t.Fatalf("expected remapped, prefixed subject, got %q", s.TTSRequest)
+	}
+	if s.TTSDone != "tenant-a."+SubjectTTSDone {
+		t.Fatalf("expected untouched subjects to keep defaults, got %q", s.TTSDone)
+	}
+	if DefaultSubjects().TTSRequest != SubjectTTSRequest {
+		t.Fatal("overrides must not mutate the defaults")
+	}
+
+	for _, bad := range []map[string]string{
+		{"tts_requests": "x"},
+		{"tts_request": ""},
+		{"tts_request": "speaker.*"},
+	} {
+		if _, err := DefaultSubjects().WithOverrides(bad); err == nil {
+			t.Fatalf("expected %v to be rejected", bad)
+		}
+	}
+}
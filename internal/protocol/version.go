@@ -0,0 +1,40 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SchemaVersion is the "major.minor" version stamped on every core message
+// this build produces. Minor bumps only add optional fields; a major bump
+// changes the meaning or shape of existing ones.
+const SchemaVersion = "1.0"
+
+// ErrUnsupportedVersion reports a message whose major schema version this
+// build does not understand.
+var ErrUnsupportedVersion = errors.New("unsupported schema version")
+
+// CheckVersion accepts messages with no version (producers that predate
+// versioning) and any minor revision of the current major version.
+func CheckVersion(v string) error {
+	if v == "" {
+		return nil
+	}
+	major, _, _ := strings.Cut(v, ".")
+	got, err := strconv.Atoi(major)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrUnsupportedVersion, v)
+	}
+	if got != schemaMajor() {
+		return fmt.Errorf("%w: %s (want %d.x)", ErrUnsupportedVersion, v, schemaMajor())
+	}
+	return nil
+}
+
+func schemaMajor() int {
+	major, _, _ := strings.Cut(SchemaVersion, ".")
+	n, _ := strconv.Atoi(major)
+	return n
+}
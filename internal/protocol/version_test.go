@@ -0,0 +1,40 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCheckVersion(t *testing.T) {
+	for _, v := range []string{"", "1", "1.0", "1.7"} {
+		if err := CheckVersion(v); err != nil {
+			t.Fatalf("version %q: unexpected error %v", v, err)
+		}
+	}
+	for _, v := range []string{"2.0", "0.9", "x.1"} {
+		if err := CheckVersion(v); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Fatalf("version %q: expected ErrUnsupportedVersion, got %v", v, err)
+		}
+	}
+}
+
+func TestVersionFieldIsOptional(t *testing.T) {
+	var req LLMRequest
+	if err := json.Unmarshal([]byte(`{"session_id":"s","prompt":"hi"}`), &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if req.V != "" || CheckVersion(req.V) != nil {
+		t.Fatalf("expected unversioned message to be accepted, got %q", req.V)
+	}
+
+	data, err := json.Marshal(Transcript{SessionID: "s", V: SchemaVersion})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded map[string]any
+	_ = json.Unmarshal(data, &decoded)
+	if decoded["v"] != SchemaVersion {
+		t.Fatalf("expected v=%s on the wire, got %v", SchemaVersion, decoded["v"])
+	}
+}
@@ -0,0 +1,66 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/eventstore"
+	"github.com/loqalabs/loqa-core/internal/logging"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+)
+
+// Event types written with router.log_conversations, so that a session's
+// timeline reads as a chat history.
+const (
+	conversationUser      = "conversation.user"
+	conversationAssistant = "conversation.assistant"
+	conversationActor     = "router"
+)
+
+// conversationWriteTimeout bounds one event store write so a slow store
+// cannot stall transcript handling.
+const conversationWriteTimeout = 2 * time.Second
+
+// logConversation appends one conversation turn to the event store under
+// the session, creating the session in the session privacy scope if it
+// does not exist yet. Turns take the privacy scope of the session they
+// belong to, so the matching event_store.redaction entry controls how much
+// of the text is kept. Failures are logged and otherwise ignored.
+func (s *Service) logConversation(sessionID, traceID, eventType string, payload map[string]any) {
+	if !s.cfg.LogConversations || s.store == nil || sessionID == "" {
+		return
+	}
+	log := logging.WithSession(s.logger, sessionID, traceID)
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Warn("router failed to encode conversation event", slogError(err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), conversationWriteTimeout)
+	defer cancel()
+	privacy, err := s.store.EnsureSession(ctx, sessionID, conversationActor, protocol.PrivacySession)
+	if err == nil {
+		err = s.store.AppendEvent(ctx, eventstore.Event{
+			SessionID: sessionID,
+			TraceID:   traceID,
+			ActorID:   conversationActor,
+			Type:      eventType,
+			Payload:   data,
+			Privacy:   privacy,
+		})
+	}
+	if err != nil {
+		log.Warn("router failed to log conversation event", slog.String("type", eventType), slogError(err))
+	}
+}
+
+// conversationTrace returns the trace ID of the voice.session behind resp,
+// so that a reply is logged under the same trace as its transcript.
+func conversationTrace(resp protocol.LLMResponse, state *sessionState) string {
+	if state != nil && state.TraceID != "" {
+		return state.TraceID
+	}
+	return resp.TraceID
+}
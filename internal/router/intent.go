@@ -0,0 +1,56 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/loqalabs/loqa-core/internal/protocol"
+)
+
+// parseIntent interprets an LLM completion as a protocol.Intent. The reply
+// must be a single JSON object, optionally wrapped in a Markdown code fence,
+// with no fields beyond the intent's own and a valid skill and action.
+// Anything else is treated as text to speak.
+func parseIntent(content string) (protocol.Intent, bool) {
+	body := strings.TrimSpace(content)
+	if fenced, ok := strings.CutPrefix(body, "```"); ok {
+		fenced = strings.TrimPrefix(fenced, "json")
+		if inner, ok := strings.CutSuffix(strings.TrimSpace(fenced), "```"); ok {
+			body = strings.TrimSpace(inner)
+		}
+	}
+	if !strings.HasPrefix(body, "{") {
+		return protocol.Intent{}, false
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(body)))
+	dec.DisallowUnknownFields()
+	var intent protocol.Intent
+	if err := dec.Decode(&intent); err != nil || dec.More() {
+		return protocol.Intent{}, false
+	}
+	if intent.Validate() != nil {
+		return protocol.Intent{}, false
+	}
+	return intent, true
+}
+
+// intentPrompt tells the LLM how to reply with an intent and which skill
+// actions it may target. It is appended to the system prompt of every
+// request in router.intent_mode.
+func intentPrompt(subjects []string) string {
+	var b strings.Builder
+	b.WriteString("To act on a request instead of answering it, reply with only a JSON object of the form ")
+	b.WriteString(`{"skill": "<skill>", "action": "<action>", "params": {...}}`)
+	b.WriteString(" and no other text. Available skill actions:")
+	for _, subject := range subjects {
+		intent, err := protocol.ParseIntentSubject(subject)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "\n- skill %q, action %q", intent.Skill, intent.Action)
+	}
+	b.WriteString("\nFor anything else, answer in plain text.")
+	return b.String()
+}
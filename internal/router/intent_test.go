@@ -0,0 +1,32 @@
+package router
+
+import "testing"
+
+func TestParseIntent(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		ok      bool
+		subject string
+	}{
+		{"object", `{"skill":"home","action":"command","params":{"device":"light.kitchen"}}`, true, "skill.home.command"},
+		{"fenced", "```json\n{\"skill\":\"timer\",\"action\":\"start\",\"params\":{\"minutes\":10}}\n```", true, "skill.timer.start"},
+		{"plain text", "The kitchen light is now on.", false, ""},
+		{"missing action", `{"skill":"home"}`, false, ""},
+		{"unknown field", `{"skill":"home","action":"command","text":"ok"}`, false, ""},
+		{"dotted skill", `{"skill":"home.lights","action":"command"}`, false, ""},
+		{"wildcard action", `{"skill":"home","action":">"}`, false, ""},
+		{"trailing text", `{"skill":"home","action":"command"} done`, false, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			intent, ok := parseIntent(tc.content)
+			if ok != tc.ok {
+				t.Fatalf("parseIntent(%q) ok = %v, want %v", tc.content, ok, tc.ok)
+			}
+			if ok && intent.Subject() != tc.subject {
+				t.Fatalf("expected subject %s, got %s", tc.subject, intent.Subject())
+			}
+		})
+	}
+}
@@ -2,14 +2,17 @@ package router
 
 import (
 	"context"
-	"encoding/json"
 	"log/slog"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/loqalabs/loqa-core/internal/bus"
 	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/eventstore"
+	"github.com/loqalabs/loqa-core/internal/logging"
 	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/loqalabs/loqa-core/internal/text"
 	"github.com/nats-io/nats.go"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -20,6 +23,8 @@ import (
 type Service struct {
 	cfg            config.RouterConfig
 	bus            *bus.Client
+	subjects       protocol.Subjects
+	store          *eventstore.Store
 	logger         *slog.Logger
 	subTranscripts *nats.Subscription
 	subLLM         *nats.Subscription
@@ -31,20 +36,44 @@ type Service struct {
 	tracer         trace.Tracer
 	latency        metric.Float64Histogram
 	latencyEnabled bool
+	stageLatency   metric.Float64Histogram
+	stageEnabled   bool
 
 	mu       sync.Mutex
 	sessions map[string]*sessionState
+	// tier and voice start from cfg and may be swapped by SetDefaults; both
+	// are guarded by mu.
+	tier  string
+	voice string
+
+	// intents is router.intent_subjects as a set; intentSystem describes
+	// them to the LLM.
+	intents      map[string]bool
+	intentSystem string
 }
 
 type sessionState struct {
-	LastPrompt string
-	Voice      string
-	Tier       string
-	Started    time.Time
-	Span       trace.Span
+	LastPrompt   string
+	Voice        string
+	Tier         string
+	Target       string
+	Started      time.Time
+	LLMRequested time.Time
+	LLMResponded time.Time
+	Span         trace.Span
+	TraceID      string // carried on the session's downstream messages
 }
 
-func NewService(parent context.Context, cfg config.RouterConfig, busClient *bus.Client, logger *slog.Logger) *Service {
+// Pipeline stages recorded on the loqa.voice_stage_latency_ms histogram.
+const (
+	stageRouting = "routing" // transcript received -> llm request published
+	stageLLM     = "llm"     // llm request published -> final llm response
+	stageTTS     = "tts"     // final llm response -> tts done
+)
+
+// NewService creates a router. store receives conversation events when
+// router.log_conversations is set and may be nil otherwise.
+func NewService(parent context.Context, cfg config.RouterConfig, busClient *bus.Client, subjects protocol.Subjects, store *eventstore.Store, logger *slog.Logger) *Service {
 	ctx, cancel := context.WithCancel(parent)
 	tracer := otel.Tracer("github.com/loqalabs/loqa-core/router")
 	meter := otel.Meter("github.com/loqalabs/loqa-core/router")
@@ -59,38 +88,91 @@ func NewService(parent context.Context, cfg config.RouterConfig, busClient *bus.
 		logger.Warn("failed to initialize latency histogram", slog.String("error", err.Error()))
 	}
 
+	stageHist, err := meter.Float64Histogram(
+		"loqa.voice_stage_latency_ms",
+		metric.WithDescription("Voice session latency per pipeline stage"),
+		metric.WithUnit("ms"),
+	)
+	stageEnabled := err == nil
+	if err != nil {
+		logger.Warn("failed to initialize stage latency histogram", slog.String("error", err.Error()))
+	}
+
+	intents := make(map[string]bool, len(cfg.IntentSubjects))
+	for _, subject := range cfg.IntentSubjects {
+		intents[subject] = true
+	}
+	intentSystem := ""
+	if cfg.IntentMode {
+		intentSystem = intentPrompt(cfg.IntentSubjects)
+	}
+
 	return &Service{
 		cfg:            cfg,
 		bus:            busClient,
+		subjects:       subjects,
+		store:          store,
 		logger:         logger.With(slog.String("component", "router")),
 		ctx:            ctx,
 		cancel:         cancel,
 		tracer:         tracer,
 		latency:        hist,
 		latencyEnabled: enabled,
+		stageLatency:   stageHist,
+		stageEnabled:   stageEnabled,
 		sessions:       make(map[string]*sessionState),
+		tier:           cfg.DefaultTier,
+		voice:          cfg.DefaultVoice,
+		intents:        intents,
+		intentSystem:   intentSystem,
 	}
 }
 
+// SetDefaults replaces the tier and voice applied to new sessions; sessions
+// already in flight keep the values they started with.
+func (s *Service) SetDefaults(tier, voice string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tier = tier
+	s.voice = voice
+}
+
+func (s *Service) defaults() (tier, voice string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tier, s.voice
+}
+
 func (s *Service) Start() error {
 	if !s.cfg.Enabled {
 		return nil
 	}
 
-	sub, err := s.bus.Conn().Subscribe(protocol.SubjectTranscriptFinal, s.handleTranscript)
+	sub, err := s.bus.Subscribe(s.subjects.TranscriptFinal, s.handleTranscript)
 	if err != nil {
 		return err
 	}
 	s.subTranscripts = sub
+	if s.echo() {
+		// Echo mode never requests a completion, so it only waits for
+		// transcripts and TTS completions.
+		subDone, err := s.bus.Subscribe(s.subjects.TTSDone, s.handleTTSDone)
+		if err != nil {
+			s.subTranscripts.Drain()
+			return err
+		}
+		s.subTTSDone = subDone
+		return nil
+	}
 
-	subLLM, err := s.bus.Conn().Subscribe(protocol.SubjectLLMResponseFinal, s.handleLLMResponse)
+	subLLM, err := s.bus.Subscribe(s.subjects.LLMResponseFinal, s.handleLLMResponse)
 	if err != nil {
 		s.subTranscripts.Drain()
 		return err
 	}
 	s.subLLM = subLLM
 
-	subDone, err := s.bus.Conn().Subscribe(protocol.SubjectTTSDone, s.handleTTSDone)
+	subDone, err := s.bus.Subscribe(s.subjects.TTSDone, s.handleTTSDone)
 	if err != nil {
 		s.subTranscripts.Drain()
 		s.subLLM.Drain()
@@ -118,114 +200,352 @@ func (s *Service) Healthy() bool {
 	if !s.cfg.Enabled {
 		return true
 	}
-	return s.subTranscripts != nil && s.subLLM != nil && s.subTTSDone != nil
+	return s.subTranscripts != nil && (s.subLLM != nil || s.echo()) && s.subTTSDone != nil
+}
+
+func (s *Service) echo() bool {
+	return s.cfg.Mode == "echo"
 }
 
 func (s *Service) handleTranscript(msg *nats.Msg) {
 	var transcript protocol.Transcript
-	if err := json.Unmarshal(msg.Data, &transcript); err != nil {
+	if err := s.bus.Codec().Unmarshal(msg.Data, &transcript); err != nil {
 		s.logger.Warn("router failed to decode transcript", slogError(err))
 		return
 	}
+	if err := protocol.CheckVersion(transcript.V); err != nil {
+		s.logger.Warn("router rejected transcript", slogError(err))
+		return
+	}
+	if s.cfg.NormalizeInput {
+		transcript.Text = text.Normalize(transcript.Text, text.Options{Capitalize: s.cfg.CapitalizeInput})
+	}
 	if transcript.Text == "" {
 		return
 	}
 
+	target := transcript.Target
+	if target == "" {
+		target = s.cfg.Target
+	}
+
+	// A confidence of 0 means the backend did not score the transcript.
+	if s.cfg.MinConfidence > 0 && transcript.Confidence > 0 && transcript.Confidence < s.cfg.MinConfidence {
+		s.reask(transcript, target)
+		return
+	}
+
 	started := time.Now()
-	_, span := s.tracer.Start(context.Background(), "voice.session",
-		trace.WithAttributes(
-			attribute.String("session_id", transcript.SessionID),
-			attribute.String("router.voice", s.cfg.DefaultVoice),
-			attribute.String("router.tier", s.cfg.DefaultTier),
-		),
-	)
+	if s.duplicate(transcript.SessionID, transcript.Text, started) {
+		s.logger.Debug("router dropped duplicate final transcript", slog.String("session_id", transcript.SessionID))
+		return
+	}
 
-	s.mu.Lock()
-	s.sessions[transcript.SessionID] = &sessionState{
+	tier, voice := s.defaults()
+	parent := parentContext(transcript.TraceID, transcript.SpanID)
+	attrs := []attribute.KeyValue{
+		attribute.String("session_id", transcript.SessionID),
+		attribute.String("router.voice", voice),
+		attribute.String("router.tier", tier),
+		attribute.String("router.target", target),
+	}
+	if transcript.TraceID != "" && !trace.SpanContextFromContext(parent).IsValid() {
+		// Without the device's span there is no parent to join, so the
+		// session starts its own trace and records the device's.
+		attrs = append(attrs, attribute.String("edge.trace_id", transcript.TraceID))
+	}
+	_, span := s.tracer.Start(parent, "voice.session", trace.WithAttributes(attrs...))
+	traceID := span.SpanContext().TraceID().String()
+	if !span.SpanContext().IsValid() && transcript.TraceID != "" {
+		// Tracing is off; keep the device's ID for log correlation.
+		traceID = transcript.TraceID
+	}
+	s.logConversation(transcript.SessionID, traceID, conversationUser, map[string]any{
+		"text":       transcript.Text,
+		"confidence": transcript.Confidence,
+		"language":   transcript.Language,
+	})
+
+	state := &sessionState{
 		LastPrompt: transcript.Text,
-		Voice:      s.cfg.DefaultVoice,
-		Tier:       s.cfg.DefaultTier,
+		Voice:      voice,
+		Tier:       tier,
+		Target:     target,
 		Started:    started,
 		Span:       span,
+		TraceID:    traceID,
+	}
+	if s.echo() {
+		// The TTS stage is measured from the LLM response; in echo mode
+		// the transcript stands in for it.
+		state.LLMResponded = started
 	}
+	s.mu.Lock()
+	s.sessions[transcript.SessionID] = state
 	s.mu.Unlock()
 
+	if s.echo() {
+		log := logging.WithSession(s.logger, transcript.SessionID, traceID)
+		log.Info("router echoing transcript", slog.String("text", transcript.Text))
+		s.logConversation(transcript.SessionID, traceID, conversationAssistant, map[string]any{
+			"text": transcript.Text,
+			"mode": "echo",
+		})
+		req := protocol.TTSRequest{
+			SessionID: transcript.SessionID,
+			Text:      transcript.Text,
+			Voice:     voice,
+			Target:    target,
+			Targets:   s.cfg.ExtraTargets,
+			TraceID:   traceID,
+		}
+		if err := s.publishTTSRequest(req); err != nil {
+			log.Warn("router failed to publish tts request", slogError(err))
+		}
+		return
+	}
+
 	req := protocol.LLMRequest{
 		SessionID: transcript.SessionID,
 		Prompt:    transcript.Text,
-		Tier:      s.cfg.DefaultTier,
+		Tier:      tier,
+		TraceID:   traceID,
 		Timestamp: time.Now().UTC(),
 	}
 	if err := s.publishLLMRequest(req); err != nil {
-		s.logger.Warn("router failed to publish llm request", slogError(err))
+		logging.WithSession(s.logger, req.SessionID, req.TraceID).Warn("router failed to publish llm request", slogError(err))
+		return
+	}
+
+	s.mu.Lock()
+	if state := s.sessions[transcript.SessionID]; state != nil {
+		state.LLMRequested = time.Now()
+	}
+	s.mu.Unlock()
+}
+
+// reask answers a transcript scored below router.min_confidence with the
+// clarification prompt rather than risk acting on a misheard request. No
+// session state is kept, so the next transcript starts afresh.
+func (s *Service) reask(transcript protocol.Transcript, target string) {
+	_, voice := s.defaults()
+	log := logging.WithSession(s.logger, transcript.SessionID, transcript.TraceID)
+	log.Info("router asking to repeat low-confidence transcript",
+		slog.String("text", transcript.Text),
+		slog.Float64("confidence", transcript.Confidence),
+	)
+	req := protocol.TTSRequest{
+		SessionID: transcript.SessionID,
+		Text:      s.cfg.ClarifyText,
+		Voice:     voice,
+		Target:    target,
+		Targets:   s.cfg.ExtraTargets,
+		TraceID:   transcript.TraceID,
+	}
+	if err := s.publishTTSRequest(req); err != nil {
+		log.Warn("router failed to publish tts request", slogError(err))
+	}
+}
+
+// duplicate reports whether prompt repeats the session's previous final
+// transcript within router.dedup_window_ms of it. Some STT backends emit the
+// same final twice, which would otherwise trigger two LLM requests.
+func (s *Service) duplicate(sessionID, prompt string, now time.Time) bool {
+	if s.cfg.DedupWindowMS <= 0 {
+		return false
 	}
+	window := time.Duration(s.cfg.DedupWindowMS) * time.Millisecond
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.sessions[sessionID]
+	return state != nil && state.LastPrompt == prompt && now.Sub(state.Started) < window
 }
 
+// publishLLMRequest fills in the configured system prompt and generation
+// parameters for req's tier, keeping any the caller already set. In
+// router.intent_mode the intent instructions are appended to the system
+// prompt.
 func (s *Service) publishLLMRequest(req protocol.LLMRequest) error {
-	data, err := json.Marshal(req)
+	req.V = protocol.SchemaVersion
+	system, maxTokens, temperature := s.generation(req.Tier)
+	if req.System == "" {
+		req.System = system
+	}
+	if s.intentSystem != "" {
+		req.System = strings.TrimSpace(req.System + "\n\n" + s.intentSystem)
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = maxTokens
+	}
+	if req.Temperature == 0 {
+		req.Temperature = temperature
+	}
+	data, err := s.bus.Codec().Marshal(req)
 	if err != nil {
 		return err
 	}
-	return s.bus.Conn().Publish(protocol.SubjectLLMRequest, data)
+	return s.bus.Publish(s.subjects.LLMRequest, data)
+}
+
+// generation returns the router's generation settings for tier: the
+// top-level values with any non-zero per-tier overrides applied.
+func (s *Service) generation(tier string) (system string, maxTokens int, temperature float64) {
+	system, maxTokens, temperature = s.cfg.SystemPrompt, s.cfg.MaxTokens, s.cfg.Temperature
+	t, ok := s.cfg.Tiers[tier]
+	if !ok {
+		return system, maxTokens, temperature
+	}
+	if t.SystemPrompt != "" {
+		system = t.SystemPrompt
+	}
+	if t.MaxTokens != 0 {
+		maxTokens = t.MaxTokens
+	}
+	if t.Temperature != 0 {
+		temperature = t.Temperature
+	}
+	return system, maxTokens, temperature
 }
 
 func (s *Service) handleLLMResponse(msg *nats.Msg) {
 	var resp protocol.LLMResponse
-	if err := json.Unmarshal(msg.Data, &resp); err != nil {
+	if err := s.bus.Codec().Unmarshal(msg.Data, &resp); err != nil {
 		s.logger.Warn("router failed to decode llm response", slogError(err))
 		return
 	}
+	if err := protocol.CheckVersion(resp.V); err != nil {
+		s.logger.Warn("router rejected llm response", slogError(err))
+		return
+	}
 	if resp.Content == "" {
 		return
 	}
 
 	s.mu.Lock()
 	state := s.sessions[resp.SessionID]
+	var snapshot sessionState
+	if state != nil {
+		state.LLMResponded = time.Now()
+		snapshot = *state
+	}
 	s.mu.Unlock()
 
-	voice := s.cfg.DefaultVoice
+	if state != nil {
+		s.recordStage(snapshot, stageRouting, snapshot.Started, snapshot.LLMRequested)
+		s.recordStage(snapshot, stageLLM, snapshot.LLMRequested, snapshot.LLMResponded)
+	}
+
+	_, voice := s.defaults()
 	if state != nil && state.Voice != "" {
 		voice = state.Voice
 	}
+	target := s.cfg.Target
+	if state != nil && state.Target != "" {
+		target = state.Target
+	}
+	if s.cfg.IntentMode {
+		if intent, ok := parseIntent(resp.Content); ok {
+			if s.intents[intent.Subject()] {
+				s.dispatchIntent(intent, resp, state)
+				return
+			}
+			logging.WithSession(s.logger, resp.SessionID, resp.TraceID).Warn("router ignoring intent for subject not in router.intent_subjects",
+				slog.String("subject", intent.Subject()))
+		}
+	}
+
 	if state != nil && state.Span != nil {
 		state.Span.AddEvent("llm.response.final",
 			trace.WithAttributes(
 				attribute.Int("prompt_tokens", resp.PromptTokens),
 				attribute.Int("completion_tokens", resp.CompletionTokens),
+				attribute.String("llm.backend", resp.Backend),
+				attribute.Bool("llm.fallback", resp.Fallback),
 			),
 		)
+		if resp.Fallback {
+			state.Span.SetAttributes(attribute.Bool("llm.fallback", true))
+		}
 	}
 
+	s.logConversation(resp.SessionID, conversationTrace(resp, state), conversationAssistant, map[string]any{
+		"text":      resp.Content,
+		"backend":   resp.Backend,
+		"truncated": resp.Truncated,
+	})
+
 	req := protocol.TTSRequest{
 		SessionID: resp.SessionID,
 		Text:      resp.Content,
 		Voice:     voice,
-		Target:    s.cfg.Target,
+		Target:    target,
+		Targets:   s.cfg.ExtraTargets,
 		TraceID:   resp.TraceID,
 	}
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
 		if err := s.publishTTSRequest(req); err != nil {
-			s.logger.Warn("router failed to publish tts request", slogError(err))
+			logging.WithSession(s.logger, req.SessionID, req.TraceID).Warn("router failed to publish tts request", slogError(err))
 		}
 	}()
 }
 
+// dispatchIntent publishes a structured LLM reply to the skill that handles
+// it. Nothing is spoken, so the session ends here rather than on tts.done.
+func (s *Service) dispatchIntent(intent protocol.Intent, resp protocol.LLMResponse, state *sessionState) {
+	intent.V = protocol.SchemaVersion
+	intent.SessionID = resp.SessionID
+	intent.TraceID = resp.TraceID
+	log := logging.WithSession(s.logger, resp.SessionID, resp.TraceID)
+	subject := intent.Subject()
+	s.logConversation(resp.SessionID, conversationTrace(resp, state), conversationAssistant, map[string]any{
+		"text":   resp.Content,
+		"intent": subject,
+	})
+	data, err := s.bus.Codec().Marshal(intent)
+	if err == nil {
+		err = s.bus.Publish(s.subjects.Apply(subject), data)
+	}
+	if err != nil {
+		log.Warn("router failed to publish intent", slog.String("subject", subject), slogError(err))
+	} else {
+		log.Info("router dispatched intent", slog.String("subject", subject))
+	}
+
+	if state == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.sessions[resp.SessionID] == state {
+		delete(s.sessions, resp.SessionID)
+	}
+	s.mu.Unlock()
+	if state.Span != nil {
+		state.Span.AddEvent("intent.dispatched", trace.WithAttributes(attribute.String("intent.subject", subject)))
+		state.Span.End()
+	}
+}
+
 func (s *Service) publishTTSRequest(req protocol.TTSRequest) error {
-	data, err := json.Marshal(req)
+	req.V = protocol.SchemaVersion
+	data, err := s.bus.Codec().Marshal(req)
 	if err != nil {
 		return err
 	}
-	return s.bus.Conn().Publish(protocol.SubjectTTSRequest, data)
+	return s.bus.Publish(s.subjects.TTSRequest, data)
 }
 
 func (s *Service) handleTTSDone(msg *nats.Msg) {
 	var status protocol.TTSStatus
-	if err := json.Unmarshal(msg.Data, &status); err != nil {
+	if err := s.bus.Codec().Unmarshal(msg.Data, &status); err != nil {
 		s.logger.Warn("router failed to decode tts status", slogError(err))
 		return
 	}
+	if err := protocol.CheckVersion(status.V); err != nil {
+		s.logger.Warn("router rejected tts status", slogError(err))
+		return
+	}
 	if !status.Completed {
 		return
 	}
@@ -241,6 +561,8 @@ func (s *Service) handleTTSDone(msg *nats.Msg) {
 		return
 	}
 
+	s.recordStage(*state, stageTTS, state.LLMResponded, time.Now())
+
 	if state.Span != nil {
 		state.Span.AddEvent("tts.done")
 		state.Span.End()
@@ -257,6 +579,54 @@ func (s *Service) handleTTSDone(msg *nats.Msg) {
 	}
 }
 
+// recordStage records the duration between two pipeline transitions on the
+// stage histogram and the session span. Stages with a missing endpoint are
+// skipped.
+func (s *Service) recordStage(state sessionState, stage string, from, to time.Time) {
+	if from.IsZero() || to.IsZero() {
+		return
+	}
+	ms := float64(to.Sub(from)) / float64(time.Millisecond)
+	if state.Span != nil {
+		state.Span.SetAttributes(attribute.Float64("router.stage."+stage+"_ms", ms))
+	}
+	if s.stageEnabled {
+		s.stageLatency.Record(context.Background(), ms,
+			metric.WithAttributes(
+				attribute.String("stage", stage),
+				attribute.String("router.tier", state.Tier),
+			),
+		)
+	}
+}
+
+// parentContext returns a context carrying the edge device's span as a
+// remote parent, so the session span joins the device's trace as a child of
+// the span that captured the audio. Both IDs are needed: a parent span ID
+// that was never recorded would leave the trace with a dangling reference.
+// Missing or malformed IDs yield a background context.
+func parentContext(traceID, spanID string) context.Context {
+	ctx := context.Background()
+	if traceID == "" || spanID == "" {
+		return ctx
+	}
+	tid, err := trace.TraceIDFromHex(traceID)
+	if err != nil {
+		return ctx
+	}
+	sid, err := trace.SpanIDFromHex(spanID)
+	if err != nil {
+		return ctx
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
 func slogError(err error) slog.Attr {
 	return slog.String("error", err.Error())
 }
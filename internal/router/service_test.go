@@ -0,0 +1,657 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/bus"
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/eventstore"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+	"github.com/nats-io/nats.go"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func publishJSON(t *testing.T, client *bus.Client, subject string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal %s: %v", subject, err)
+	}
+	if err := client.Conn().Publish(subject, data); err != nil {
+		t.Fatalf("publish %s: %v", subject, err)
+	}
+	if err := client.Conn().Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+}
+
+func TestRouterTargetsOriginatingDevice(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := config.RouterConfig{Enabled: true, DefaultTier: "balanced", DefaultVoice: "en-US", Target: "default"}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), nil, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start router: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	ttsRequests := make(chan protocol.TTSRequest, 4)
+	sub, err := client.Conn().Subscribe(protocol.SubjectTTSRequest, func(msg *nats.Msg) {
+		var req protocol.TTSRequest
+		if err := json.Unmarshal(msg.Data, &req); err == nil {
+			ttsRequests <- req
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe tts: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	llmRequests := make(chan struct{}, 4)
+	llmSub, err := client.Conn().Subscribe(protocol.SubjectLLMRequest, func(*nats.Msg) { llmRequests <- struct{}{} })
+	if err != nil {
+		t.Fatalf("subscribe llm: %v", err)
+	}
+	t.Cleanup(func() { _ = llmSub.Unsubscribe() })
+
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s-kitchen", Text: "lights on", Target: "kitchen"})
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s-bedroom", Text: "lights off", Target: "bedroom"})
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s-unknown", Text: "hello"})
+	for i := 0; i < 3; i++ {
+		select {
+		case <-llmRequests:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for llm requests")
+		}
+	}
+	for _, id := range []string{"s-kitchen", "s-bedroom", "s-unknown"} {
+		publishJSON(t, client, protocol.SubjectLLMResponseFinal, protocol.LLMResponse{SessionID: id, Content: "ok"})
+	}
+
+	want := map[string]string{"s-kitchen": "kitchen", "s-bedroom": "bedroom", "s-unknown": "default"}
+	got := make(map[string]string)
+	timeout := time.After(5 * time.Second)
+	for len(got) < len(want) {
+		select {
+		case req := <-ttsRequests:
+			got[req.SessionID] = req.Target
+		case <-timeout:
+			t.Fatalf("timed out waiting for tts requests, got %v", got)
+		}
+	}
+	for session, target := range want {
+		if got[session] != target {
+			t.Fatalf("session %s: expected target %q, got %q", session, target, got[session])
+		}
+	}
+}
+
+func TestRouterUsesInjectedSubjects(t *testing.T) {
+	client := testutil.StartBus(t)
+	subjects := protocol.NewSubjects("tenant-a")
+	cfg := config.RouterConfig{Enabled: true, DefaultTier: "balanced", DefaultVoice: "en-US", Target: "default"}
+	svc := NewService(context.Background(), cfg, client, subjects, nil, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start router: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	requests := make(chan string, 4)
+	sub, err := client.Conn().Subscribe(">", func(msg *nats.Msg) {
+		if msg.Subject == protocol.SubjectLLMRequest || msg.Subject == subjects.LLMRequest {
+			requests <- msg.Subject
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "other-tenant", Text: "hello"})
+	publishJSON(t, client, subjects.TranscriptFinal, protocol.Transcript{SessionID: "s-1", Text: "hello"})
+
+	select {
+	case subject := <-requests:
+		if subject != "tenant-a.nlu.request" {
+			t.Fatalf("expected namespaced llm request, got %s", subject)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for llm request")
+	}
+	select {
+	case subject := <-requests:
+		t.Fatalf("unexpected extra llm request on %s", subject)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestRouterSetDefaultsAppliesToNewSessions(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := config.RouterConfig{Enabled: true, DefaultTier: "balanced", DefaultVoice: "en-US", Target: "default"}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), nil, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start router: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	llmRequests := make(chan protocol.LLMRequest, 4)
+	sub, err := client.Conn().Subscribe(protocol.SubjectLLMRequest, func(msg *nats.Msg) {
+		var req protocol.LLMRequest
+		if err := json.Unmarshal(msg.Data, &req); err == nil {
+			llmRequests <- req
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe llm: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	for i, want := range []string{"balanced", "fast"} {
+		if i == 1 {
+			svc.SetDefaults("fast", "en-GB")
+		}
+		publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: want, Text: "hi"})
+		select {
+		case req := <-llmRequests:
+			if req.Tier != want {
+				t.Fatalf("expected tier %q, got %q", want, req.Tier)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for llm request")
+		}
+	}
+}
+
+func TestRouterEchoModeSpeaksTranscriptWithoutLLM(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := config.RouterConfig{Enabled: true, DefaultTier: "balanced", DefaultVoice: "en-US", Target: "default", Mode: "echo"}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), nil, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start router: %v", err)
+	}
+	t.Cleanup(svc.Close)
+	if !svc.Healthy() {
+		t.Fatal("echo router should report healthy")
+	}
+
+	ttsRequests := make(chan protocol.TTSRequest, 4)
+	sub, err := client.Conn().Subscribe(protocol.SubjectTTSRequest, func(msg *nats.Msg) {
+		var req protocol.TTSRequest
+		if err := json.Unmarshal(msg.Data, &req); err == nil {
+			ttsRequests <- req
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe tts: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+	llmRequests := make(chan struct{}, 4)
+	llmSub, err := client.Conn().Subscribe(protocol.SubjectLLMRequest, func(*nats.Msg) { llmRequests <- struct{}{} })
+	if err != nil {
+		t.Fatalf("subscribe llm: %v", err)
+	}
+	t.Cleanup(func() { _ = llmSub.Unsubscribe() })
+
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s1", Text: "turn on the lights"})
+
+	select {
+	case req := <-ttsRequests:
+		if req.SessionID != "s1" || req.Text != "turn on the lights" || req.Voice != "en-US" || req.Target != "default" {
+			t.Fatalf("unexpected tts request %+v", req)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no tts request for echoed transcript")
+	}
+	select {
+	case <-llmRequests:
+		t.Fatal("echo mode must not request a completion")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRouterInjectsGenerationSettingsPerTier(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := config.RouterConfig{
+		Enabled: true, DefaultTier: "balanced", DefaultVoice: "en-US", Target: "default",
+		SystemPrompt: "You are a helpful home assistant.",
+		MaxTokens:    128,
+		Temperature:  0.3,
+		Tiers:        map[string]config.RouterTierConfig{"fast": {SystemPrompt: "Answer in one sentence.", MaxTokens: 32}},
+	}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), nil, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start router: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	requests := make(chan protocol.LLMRequest, 4)
+	sub, err := client.Conn().Subscribe(protocol.SubjectLLMRequest, func(msg *nats.Msg) {
+		var req protocol.LLMRequest
+		if err := json.Unmarshal(msg.Data, &req); err == nil {
+			requests <- req
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe llm: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+	next := func() protocol.LLMRequest {
+		t.Helper()
+		select {
+		case req := <-requests:
+			return req
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for llm request")
+			return protocol.LLMRequest{}
+		}
+	}
+
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s1", Text: "what's the weather"})
+	if req := next(); req.Tier != "balanced" || req.System != cfg.SystemPrompt || req.MaxTokens != 128 || req.Temperature != 0.3 {
+		t.Fatalf("balanced request: unexpected generation settings %+v", req)
+	}
+
+	svc.SetDefaults("fast", "en-US")
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s2", Text: "what's the weather"})
+	if req := next(); req.Tier != "fast" || req.System != "Answer in one sentence." || req.MaxTokens != 32 || req.Temperature != 0.3 {
+		t.Fatalf("fast request: expected tier overrides over the defaults, got %+v", req)
+	}
+}
+
+func TestRouterDropsDuplicateFinalTranscripts(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := config.RouterConfig{Enabled: true, DefaultTier: "balanced", DefaultVoice: "en-US", Target: "default", DedupWindowMS: 1000}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), nil, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start router: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	requests := make(chan protocol.LLMRequest, 8)
+	sub, err := client.Conn().Subscribe(protocol.SubjectLLMRequest, func(msg *nats.Msg) {
+		var req protocol.LLMRequest
+		if err := json.Unmarshal(msg.Data, &req); err == nil {
+			requests <- req
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe llm: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s1", Text: "set a timer"})
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s1", Text: "set a timer"})
+	// Different text, and the same text in another session, still go through.
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s1", Text: "for ten minutes"})
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s2", Text: "set a timer"})
+
+	var got []string
+	timeout := time.After(500 * time.Millisecond)
+	for done := false; !done; {
+		select {
+		case req := <-requests:
+			got = append(got, req.SessionID+":"+req.Prompt)
+		case <-timeout:
+			done = true
+		}
+	}
+	want := []string{"s1:set a timer", "s1:for ten minutes", "s2:set a timer"}
+	if len(got) != len(want) {
+		t.Fatalf("expected llm requests %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected llm requests %v, got %v", want, got)
+		}
+	}
+}
+
+func TestRouterNormalizesTranscripts(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := config.RouterConfig{Enabled: true, DefaultTier: "balanced", DefaultVoice: "en-US", Target: "default", NormalizeInput: true, CapitalizeInput: true}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), nil, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start router: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	prompts := make(chan string, 4)
+	sub, err := client.Conn().Subscribe(protocol.SubjectLLMRequest, func(msg *nats.Msg) {
+		var req protocol.LLMRequest
+		if err := json.Unmarshal(msg.Data, &req); err == nil {
+			prompts <- req.Prompt
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe llm: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s1", Text: "[BLANK_AUDIO] um"})
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s2", Text: " uh  turn on [inaudible] the   lights "})
+	select {
+	case prompt := <-prompts:
+		if prompt != "Turn on the lights" {
+			t.Fatalf("expected cleaned prompt, got %q", prompt)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for llm request")
+	}
+	select {
+	case prompt := <-prompts:
+		t.Fatalf("artifact-only transcript should be dropped, got %q", prompt)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRouterReasksLowConfidenceTranscripts(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := config.RouterConfig{Enabled: true, DefaultTier: "balanced", DefaultVoice: "en-US", Target: "default",
+		MinConfidence: 0.6, ClarifyText: "Sorry, could you repeat that?"}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), nil, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start router: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	ttsRequests := make(chan protocol.TTSRequest, 4)
+	sub, err := client.Conn().Subscribe(protocol.SubjectTTSRequest, func(msg *nats.Msg) {
+		var req protocol.TTSRequest
+		if err := json.Unmarshal(msg.Data, &req); err == nil {
+			ttsRequests <- req
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe tts: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+	llmRequests := make(chan protocol.LLMRequest, 4)
+	llmSub, err := client.Conn().Subscribe(protocol.SubjectLLMRequest, func(msg *nats.Msg) {
+		var req protocol.LLMRequest
+		if err := json.Unmarshal(msg.Data, &req); err == nil {
+			llmRequests <- req
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe llm: %v", err)
+	}
+	t.Cleanup(func() { _ = llmSub.Unsubscribe() })
+
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s1", Text: "turn of the kites", Confidence: 0.3, Target: "kitchen"})
+
+	select {
+	case req := <-ttsRequests:
+		if req.SessionID != "s1" || req.Text != "Sorry, could you repeat that?" || req.Target != "kitchen" {
+			t.Fatalf("unexpected clarification request %+v", req)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no clarification for low-confidence transcript")
+	}
+	select {
+	case req := <-llmRequests:
+		t.Fatalf("low-confidence transcript reached the llm: %+v", req)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The repeated utterance, heard clearly, proceeds normally.
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s1", Text: "turn off the lights", Confidence: 0.9})
+	select {
+	case req := <-llmRequests:
+		if req.Prompt != "turn off the lights" {
+			t.Fatalf("unexpected llm request %+v", req)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no llm request for confident transcript")
+	}
+
+	// Backends that do not score transcripts report 0, which is not low.
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s2", Text: "what time is it"})
+	select {
+	case req := <-llmRequests:
+		if req.Prompt != "what time is it" {
+			t.Fatalf("unexpected llm request %+v", req)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("unscored transcript did not reach the llm")
+	}
+}
+
+func TestRouterIntentModeDispatchesStructuredReplies(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := config.RouterConfig{
+		Enabled: true, DefaultTier: "balanced", DefaultVoice: "en-US", Target: "default", SystemPrompt: "Be brief.",
+		IntentMode: true, IntentSubjects: []string{"skill.home.command"},
+	}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), nil, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start router: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	llmRequests := make(chan protocol.LLMRequest, 1)
+	llmSub, err := client.Conn().Subscribe(protocol.SubjectLLMRequest, func(msg *nats.Msg) {
+		var req protocol.LLMRequest
+		if err := json.Unmarshal(msg.Data, &req); err == nil {
+			llmRequests <- req
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe llm: %v", err)
+	}
+	t.Cleanup(func() { _ = llmSub.Unsubscribe() })
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s0", Text: "turn on the kitchen light"})
+	select {
+	case req := <-llmRequests:
+		if !strings.HasPrefix(req.System, "Be brief.") || !strings.Contains(req.System, `skill "home", action "command"`) {
+			t.Fatalf("system prompt does not describe intents: %q", req.System)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for llm request")
+	}
+
+	intents := make(chan protocol.Intent, 4)
+	intentSub, err := client.Conn().Subscribe("skill.home.command", func(msg *nats.Msg) {
+		var intent protocol.Intent
+		if err := json.Unmarshal(msg.Data, &intent); err == nil {
+			intents <- intent
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe intents: %v", err)
+	}
+	t.Cleanup(func() { _ = intentSub.Unsubscribe() })
+	ttsRequests := make(chan protocol.TTSRequest, 4)
+	ttsSub, err := client.Conn().Subscribe(protocol.SubjectTTSRequest, func(msg *nats.Msg) {
+		var req protocol.TTSRequest
+		if err := json.Unmarshal(msg.Data, &req); err == nil {
+			ttsRequests <- req
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe tts: %v", err)
+	}
+	t.Cleanup(func() { _ = ttsSub.Unsubscribe() })
+
+	structured := `{"skill":"home","action":"command","params":{"device":"light.kitchen","action":"turn_on"}}`
+	publishJSON(t, client, protocol.SubjectLLMResponseFinal, protocol.LLMResponse{SessionID: "s1", Content: structured, TraceID: "abc"})
+	select {
+	case intent := <-intents:
+		if intent.SessionID != "s1" || intent.TraceID != "abc" || intent.Params["device"] != "light.kitchen" {
+			t.Fatalf("unexpected intent %+v", intent)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no intent dispatched for structured reply")
+	}
+	select {
+	case req := <-ttsRequests:
+		t.Fatalf("structured reply must not be spoken: %+v", req)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	publishJSON(t, client, protocol.SubjectLLMResponseFinal, protocol.LLMResponse{SessionID: "s2", Content: "It's sunny today."})
+	select {
+	case req := <-ttsRequests:
+		if req.SessionID != "s2" || req.Text != "It's sunny today." {
+			t.Fatalf("unexpected tts request %+v", req)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("plain reply was not spoken")
+	}
+	select {
+	case intent := <-intents:
+		t.Fatalf("plain reply dispatched as intent: %+v", intent)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlisted := `{"skill":"timer","action":"start","params":{"minutes":10}}`
+	publishJSON(t, client, protocol.SubjectLLMResponseFinal, protocol.LLMResponse{SessionID: "s3", Content: unlisted})
+	select {
+	case req := <-ttsRequests:
+		if req.SessionID != "s3" {
+			t.Fatalf("unexpected tts request %+v", req)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("intent for an unlisted subject was not spoken")
+	}
+}
+
+func TestRouterLogsConversationToEventStore(t *testing.T) {
+	client := testutil.StartBus(t)
+	store, err := eventstore.Open(context.Background(), config.EventStoreConfig{RetentionMode: "memory"}, testutil.Logger())
+	if err != nil {
+		t.Fatalf("open event store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.AppendSession(context.Background(), "s-chat", "device-1", protocol.PrivacyPrivate); err != nil {
+		t.Fatalf("append session: %v", err)
+	}
+	cfg := config.RouterConfig{Enabled: true, DefaultTier: "balanced", DefaultVoice: "en-US", Target: "default", LogConversations: true}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), store, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start router: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	llmRequests := make(chan struct{}, 1)
+	llmSub, err := client.Conn().Subscribe(protocol.SubjectLLMRequest, func(*nats.Msg) { llmRequests <- struct{}{} })
+	if err != nil {
+		t.Fatalf("subscribe llm: %v", err)
+	}
+	t.Cleanup(func() { _ = llmSub.Unsubscribe() })
+	ttsRequests := make(chan struct{}, 1)
+	ttsSub, err := client.Conn().Subscribe(protocol.SubjectTTSRequest, func(*nats.Msg) { ttsRequests <- struct{}{} })
+	if err != nil {
+		t.Fatalf("subscribe tts: %v", err)
+	}
+	t.Cleanup(func() { _ = ttsSub.Unsubscribe() })
+
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s-chat", Text: "what time is it", Confidence: 0.9})
+	select {
+	case <-llmRequests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for llm request")
+	}
+	publishJSON(t, client, protocol.SubjectLLMResponseFinal, protocol.LLMResponse{SessionID: "s-chat", Content: "It is noon.", Backend: "mock"})
+	select {
+	case <-ttsRequests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for tts request")
+	}
+
+	events, err := store.ListSessionEvents(context.Background(), "s-chat", 10)
+	if err != nil {
+		t.Fatalf("list session events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 conversation events, got %d", len(events))
+	}
+	want := []struct{ typ, text string }{
+		{"conversation.user", "what time is it"},
+		{"conversation.assistant", "It is noon."},
+	}
+	for i, w := range want {
+		var payload struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(events[i].Payload, &payload); err != nil {
+			t.Fatalf("decode event %d payload: %v", i, err)
+		}
+		if events[i].Type != w.typ || payload.Text != w.text || events[i].Privacy != protocol.PrivacyPrivate {
+			t.Fatalf("event %d: expected %s %q, got %s %q (%s)", i, w.typ, w.text, events[i].Type, payload.Text, events[i].Privacy)
+		}
+	}
+	if events[0].TraceID == "" || events[0].TraceID != events[1].TraceID {
+		t.Fatalf("expected both turns under the session span's trace, got %q and %q", events[0].TraceID, events[1].TraceID)
+	}
+}
+
+func TestRouterSessionSpanJoinsEdgeSpan(t *testing.T) {
+	client := testutil.StartBus(t)
+	cfg := config.RouterConfig{Enabled: true, DefaultTier: "balanced", DefaultVoice: "en-US", Target: "default"}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), nil, testutil.Logger())
+	recorder := tracetest.NewSpanRecorder()
+	svc.tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start router: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	llmRequests := make(chan protocol.LLMRequest, 2)
+	sub, err := client.Conn().Subscribe(protocol.SubjectLLMRequest, func(msg *nats.Msg) {
+		var req protocol.LLMRequest
+		if err := json.Unmarshal(msg.Data, &req); err == nil {
+			llmRequests <- req
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe llm: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+	nextRequest := func() protocol.LLMRequest {
+		t.Helper()
+		select {
+		case req := <-llmRequests:
+			return req
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for llm request")
+			return protocol.LLMRequest{}
+		}
+	}
+
+	const edgeTrace, edgeSpan = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s-joined", Text: "lights on", TraceID: edgeTrace, SpanID: edgeSpan})
+	if req := nextRequest(); req.TraceID != edgeTrace {
+		t.Fatalf("expected the llm request under the edge trace, got %q", req.TraceID)
+	}
+	// Without the device's span ID there is no parent to join.
+	publishJSON(t, client, protocol.SubjectTranscriptFinal, protocol.Transcript{SessionID: "s-root", Text: "lights off", TraceID: edgeTrace})
+	if req := nextRequest(); req.TraceID == edgeTrace || req.TraceID == "" {
+		t.Fatalf("expected a new trace without an edge span, got %q", req.TraceID)
+	}
+
+	spans := recorder.Started()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 session spans, got %d", len(spans))
+	}
+	joined, root := spans[0], spans[1]
+	if parent := joined.Parent(); !parent.IsRemote() || parent.TraceID().String() != edgeTrace || parent.SpanID().String() != edgeSpan {
+		t.Fatalf("expected the edge span as remote parent, got %v", parent)
+	}
+	if root.Parent().IsValid() {
+		t.Fatalf("expected a root span without an edge span, got parent %v", root.Parent())
+	}
+	var recorded string
+	for _, kv := range root.Attributes() {
+		if kv.Key == "edge.trace_id" {
+			recorded = kv.Value.AsString()
+		}
+	}
+	if recorded != edgeTrace {
+		t.Fatalf("expected edge.trace_id %q on the root span, got %q", edgeTrace, recorded)
+	}
+}
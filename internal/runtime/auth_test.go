@@ -0,0 +1,50 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	mux.HandleFunc("/healthz", ok)
+	mux.HandleFunc("/readyz", ok)
+	mux.HandleFunc("/status", ok)
+	handler := requireToken("s3cret", mux)
+
+	cases := []struct {
+		path   string
+		header string
+		want   int
+	}{
+		{"/healthz", "", http.StatusOK},
+		{"/readyz", "", http.StatusOK},
+		{"/status", "", http.StatusUnauthorized},
+		{"/status", "Bearer wrong", http.StatusUnauthorized},
+		{"/status", "Basic czNjcmV0", http.StatusUnauthorized},
+		{"/status", "Bearer s3cret", http.StatusOK},
+		{"/status", "bearer s3cret", http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s with %q: got %d, want %d", tc.path, tc.header, rec.Code, tc.want)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected WWW-Authenticate challenge", tc.path)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	requireToken("", mux).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected no auth without a token, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,19 @@
+package runtime
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+)
+
+// mountDebug registers the Go profiling and expvar handlers on mux. Both
+// packages also register themselves on http.DefaultServeMux, which loqad
+// never serves, so the API mux is the only place they are reachable.
+func mountDebug(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+}
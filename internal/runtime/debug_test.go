@@ -0,0 +1,29 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+)
+
+func TestPprofOnlyMountedWhenEnabled(t *testing.T) {
+	paths := []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/vars"}
+	for _, enabled := range []bool{false, true} {
+		cfg := config.Default()
+		cfg.HTTP.EnablePprof = enabled
+		mux := (&Runtime{cfg: cfg}).routes()
+		for _, path := range paths {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			want := http.StatusNotFound
+			if enabled {
+				want = http.StatusOK
+			}
+			if rec.Code != want {
+				t.Errorf("enable_pprof=%v %s: got %d, want %d", enabled, path, rec.Code, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/bus"
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/natsserver"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/loqalabs/loqa-core/internal/stt"
+	"github.com/nats-io/nats.go"
+)
+
+// TestVoicePipelineEndToEnd runs the whole runtime with mock backends on an
+// embedded bus and checks that one spoken utterance comes back as speech:
+// audio frame -> STT -> router -> LLM -> router -> TTS -> status. The
+// device's trace context rides along, so the status reports its trace.
+func TestVoicePipelineEndToEnd(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.Default()
+	cfg.Bus.Port = -1
+	cfg.Bus.StoreDir = t.TempDir()
+	ns, err := natsserver.Start(cfg.Bus, log)
+	if err != nil {
+		t.Fatalf("start embedded bus: %v", err)
+	}
+	t.Cleanup(ns.Shutdown)
+	cfg.Bus.Servers = []string{ns.ClientURL()}
+
+	cfg.HTTP = config.HTTPConfig{Bind: "127.0.0.1", Port: 0}
+	cfg.Telemetry.PrometheusBind = ""
+	cfg.EventStore.RetentionMode = "memory"
+	cfg.STT.Enabled = true
+	cfg.STT.Mode = "mock"
+	cfg.LLM.Enabled = true
+	cfg.LLM.Mode = "mock"
+	cfg.TTS.Enabled = true
+	cfg.TTS.Mode = "mock"
+	cfg.Router.Enabled = true
+	cfg.Skills.Enabled = false
+
+	rt := New(cfg, "test", log)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- rt.Start(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(15 * time.Second):
+			t.Error("runtime did not stop")
+		}
+	})
+	deadline := time.Now().Add(10 * time.Second)
+	for !rt.ready.Load() {
+		select {
+		case err := <-done:
+			done <- err
+			t.Fatalf("runtime exited before ready: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("runtime not ready")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	client, err := bus.Connect(ctx, config.BusConfig{Servers: cfg.Bus.Servers, ConnectTimeout: 2000}, log)
+	if err != nil {
+		t.Fatalf("connect bus: %v", err)
+	}
+	t.Cleanup(client.Close)
+	statuses := make(chan protocol.TTSStatus, 8)
+	sub, err := client.Conn().Subscribe(protocol.SubjectTTSDone, func(msg *nats.Msg) {
+		var status protocol.TTSStatus
+		if err := json.Unmarshal(msg.Data, &status); err == nil {
+			statuses <- status
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe tts status: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+	if err := client.Conn().Flush(); err != nil {
+		t.Fatalf("flush subscription: %v", err)
+	}
+
+	frame := protocol.AudioFrame{
+		SessionID:  "e2e-session",
+		SampleRate: 16000,
+		Channels:   1,
+		PCM:        make([]byte, 3200),
+		Final:      true,
+		TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:     "00f067aa0ba902b7",
+	}
+	if err := stt.PublishAudioFrame(client, protocol.SubjectAudioFramePrefix+"."+frame.SessionID, frame); err != nil {
+		t.Fatalf("publish audio frame: %v", err)
+	}
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case status := <-statuses:
+			if status.SessionID == frame.SessionID && status.Completed {
+				if status.TraceID != frame.TraceID {
+					t.Fatalf("expected the status under the device trace %s, got %q", frame.TraceID, status.TraceID)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("no completed TTS status for the session")
+		}
+	}
+}
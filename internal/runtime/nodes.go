@@ -0,0 +1,97 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/loqalabs/loqa-core/internal/capability"
+	"golang.org/x/net/websocket"
+)
+
+// watchBuffer is how many registry changes a /nodes/watch client may lag
+// behind before it is disconnected.
+const watchBuffer = 64
+
+// handleNodes lists every node known to the capability registry.
+func (r *Runtime) handleNodes(w http.ResponseWriter, _ *http.Request) {
+	if r.registry == nil {
+		http.Error(w, "capability registry unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	nodes := r.registry.Query(nil)
+	if nodes == nil {
+		nodes = []capability.NodeInfo{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Nodes []capability.NodeInfo `json:"nodes"`
+	}{Nodes: nodes})
+}
+
+// handleNodesWatch streams registry changes over a WebSocket as JSON
+// capability.Change messages. Known nodes are sent first as "add" changes;
+// the connection is closed if the client falls behind. Browsers may only
+// connect from a page served by this runtime, so another site cannot read
+// the stream with the user's credentials.
+func (r *Runtime) handleNodesWatch(w http.ResponseWriter, req *http.Request) {
+	if r.registry == nil {
+		http.Error(w, "capability registry unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	server := websocket.Server{Handshake: checkWatchOrigin, Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		changes, cancel := r.registry.Watch(watchBuffer)
+		defer cancel()
+
+		for _, node := range r.registry.Query(nil) {
+			if err := websocket.JSON.Send(ws, capability.Change{Kind: capability.ChangeAdded, Node: node, Timestamp: node.LastSeen}); err != nil {
+				return
+			}
+		}
+
+		// Clients only listen; a read error means they went away.
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			var discard []byte
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+		}()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case <-req.Context().Done():
+				return
+			case change, ok := <-changes:
+				if !ok {
+					r.logger.Debug("registry watch closed", slog.String("remote", req.RemoteAddr))
+					return
+				}
+				if err := websocket.JSON.Send(ws, change); err != nil {
+					return
+				}
+			}
+		}
+	}}
+	server.ServeHTTP(w, req)
+}
+
+// checkWatchOrigin rejects cross-origin browser handshakes. Clients that
+// send no Origin header, such as CLIs, are accepted.
+func checkWatchOrigin(_ *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || !strings.EqualFold(u.Host, req.Host) {
+		return errors.New("cross-origin watch request")
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package runtime
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckWatchOrigin(t *testing.T) {
+	cases := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"http://loqa.local:3000", true},
+		{"https://LOQA.local:3000", true},
+		{"https://evil.example", false},
+		{"http://loqa.local:4000", false},
+		{"::not a url", false},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "http://loqa.local:3000/nodes/watch", nil)
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		if err := checkWatchOrigin(nil, req); (err == nil) != tc.ok {
+			t.Errorf("origin %q: expected ok=%v, got %v", tc.origin, tc.ok, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package runtime
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+)
+
+// Option customizes a Runtime.
+type Option func(*Runtime)
+
+// WithLogLevel lets the runtime control the level of the process logger. The
+// level is set from telemetry.log_level immediately and again on Reload.
+func WithLogLevel(level *slog.LevelVar) Option {
+	return func(r *Runtime) { r.logLevel = level }
+}
+
+// parseLogLevel maps telemetry.log_level to a slog level. "warning" is
+// accepted as a spelling of warn.
+func parseLogLevel(name string) (slog.Level, error) {
+	if strings.EqualFold(name, "warning") {
+		return slog.LevelWarn, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", name)
+	}
+	return level, nil
+}
+
+// applyLogLevel sets the process log level to name, reporting false when
+// name is not a valid level and was ignored.
+func (r *Runtime) applyLogLevel(name string) bool {
+	if r.logLevel == nil {
+		return true
+	}
+	level, err := parseLogLevel(name)
+	if err != nil {
+		r.logger.Warn("ignoring telemetry.log_level", slog.String("error", err.Error()))
+		return false
+	}
+	r.logLevel.Set(level)
+	return true
+}
+
+// Reload applies the hot-swappable subset of cfg to the running runtime:
+// telemetry.log_level and the router's default tier and voice. Every other
+// section that differs from the running config is logged as ignored and
+// takes effect on the next restart.
+func (r *Runtime) Reload(cfg config.Config) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	if cfg.Telemetry.LogLevel != r.live.Telemetry.LogLevel && r.applyLogLevel(cfg.Telemetry.LogLevel) {
+		r.logger.Info("log level reloaded", slog.String("from", r.live.Telemetry.LogLevel), slog.String("to", cfg.Telemetry.LogLevel))
+		r.live.Telemetry.LogLevel = cfg.Telemetry.LogLevel
+	}
+	if cfg.Router.DefaultTier != r.live.Router.DefaultTier || cfg.Router.DefaultVoice != r.live.Router.DefaultVoice {
+		if r.routerService != nil {
+			r.routerService.SetDefaults(cfg.Router.DefaultTier, cfg.Router.DefaultVoice)
+		}
+		r.logger.Info("router defaults reloaded", slog.String("tier", cfg.Router.DefaultTier), slog.String("voice", cfg.Router.DefaultVoice))
+		r.live.Router.DefaultTier = cfg.Router.DefaultTier
+		r.live.Router.DefaultVoice = cfg.Router.DefaultVoice
+	}
+
+	// With the live settings copied over, any remaining difference is a
+	// setting that needs a restart.
+	cfg.Telemetry.LogLevel = r.live.Telemetry.LogLevel
+	cfg.Router.DefaultTier = r.live.Router.DefaultTier
+	cfg.Router.DefaultVoice = r.live.Router.DefaultVoice
+	running := reflect.ValueOf(r.live)
+	loaded := reflect.ValueOf(cfg)
+	for i := 0; i < running.NumField(); i++ {
+		if !reflect.DeepEqual(running.Field(i).Interface(), loaded.Field(i).Interface()) {
+			name := running.Type().Field(i).Tag.Get("yaml")
+			r.logger.Warn("config change ignored on reload; restart to apply", slog.String("setting", name))
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/natsserver"
+)
+
+// lockedBuffer is a log sink safe for concurrent handlers.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestReloadTogglesLogLevel(t *testing.T) {
+	var out lockedBuffer
+	level := new(slog.LevelVar)
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: level}))
+
+	cfg := config.Default()
+	cfg.Telemetry.LogLevel = "warn"
+	rt := New(cfg, "test", logger, WithLogLevel(level))
+	if level.Level() != slog.LevelWarn {
+		t.Fatalf("expected initial level warn, got %v", level.Level())
+	}
+	logger.Debug("hidden")
+
+	next := cfg
+	next.Telemetry.LogLevel = "debug"
+	next.HTTP.Port = cfg.HTTP.Port + 1
+	rt.Reload(next)
+	if level.Level() != slog.LevelDebug {
+		t.Fatalf("expected level debug after reload, got %v", level.Level())
+	}
+	logger.Debug("visible")
+
+	logs := out.String()
+	if strings.Contains(logs, "hidden") || !strings.Contains(logs, "visible") {
+		t.Fatalf("expected debug logs only after reload, got:\n%s", logs)
+	}
+	if !strings.Contains(logs, "config change ignored on reload") || !strings.Contains(logs, "setting=http") {
+		t.Fatalf("expected the http change to be reported as ignored, got:\n%s", logs)
+	}
+	if strings.Contains(logs, "setting=telemetry") {
+		t.Fatalf("expected the log level change not to be reported as ignored, got:\n%s", logs)
+	}
+
+	bad := next
+	bad.Telemetry.LogLevel = "loud"
+	rt.Reload(bad)
+	if level.Level() != slog.LevelDebug || rt.live.Telemetry.LogLevel != "debug" {
+		t.Fatalf("expected an invalid level to leave debug in place, got %v (live %q)", level.Level(), rt.live.Telemetry.LogLevel)
+	}
+	if logs := out.String(); strings.Contains(logs, "to=loud") || !strings.Contains(logs, "ignoring telemetry.log_level") {
+		t.Fatalf("expected an invalid level to be ignored, not reported as reloaded, got:\n%s", logs)
+	}
+
+	back := next
+	back.Telemetry.LogLevel = "error"
+	rt.Reload(back)
+	if level.Level() != slog.LevelError {
+		t.Fatalf("expected level error after second reload, got %v", level.Level())
+	}
+}
+
+func TestParseLogLevelAcceptsWarning(t *testing.T) {
+	for _, name := range []string{"warn", "warning", "WARNING"} {
+		level, err := parseLogLevel(name)
+		if err != nil || level != slog.LevelWarn {
+			t.Fatalf("parseLogLevel(%q) = %v, %v; want warn", name, level, err)
+		}
+	}
+}
+
+// TestReloadDuringStart reloads router defaults while the runtime is still
+// starting; run with -race to catch unguarded access to the running config.
+func TestReloadDuringStart(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.Default()
+	cfg.Bus.Port = -1
+	cfg.Bus.StoreDir = t.TempDir()
+	ns, err := natsserver.Start(cfg.Bus, log)
+	if err != nil {
+		t.Fatalf("start embedded bus: %v", err)
+	}
+	t.Cleanup(ns.Shutdown)
+	cfg.Bus.Servers = []string{ns.ClientURL()}
+	cfg.HTTP = config.HTTPConfig{Bind: "127.0.0.1", Port: 0}
+	cfg.Telemetry.PrometheusBind = ""
+	cfg.EventStore.RetentionMode = "memory"
+	cfg.Router.Enabled = true
+	cfg.Skills.Enabled = false
+
+	rt := New(cfg, "test", log)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- rt.Start(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	next := cfg
+	next.Router.DefaultVoice = "reloaded"
+	deadline := time.Now().Add(10 * time.Second)
+	for !rt.ready.Load() {
+		rt.Reload(next)
+		if time.Now().After(deadline) {
+			t.Fatal("runtime not ready")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	rt.reloadMu.Lock()
+	defer rt.reloadMu.Unlock()
+	if rt.routerService == nil || rt.live.Router.DefaultVoice != "reloaded" || rt.cfg.Router.DefaultVoice == "reloaded" {
+		t.Fatalf("expected the reload tracked in live only, got live %q and cfg %q", rt.live.Router.DefaultVoice, rt.cfg.Router.DefaultVoice)
+	}
+}
@@ -2,8 +2,12 @@ package runtime
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"sync"
 	"sync/atomic"
@@ -13,7 +17,9 @@ import (
 	"github.com/loqalabs/loqa-core/internal/capability"
 	"github.com/loqalabs/loqa-core/internal/config"
 	"github.com/loqalabs/loqa-core/internal/eventstore"
+	loqaexec "github.com/loqalabs/loqa-core/internal/exec"
 	"github.com/loqalabs/loqa-core/internal/llm"
+	"github.com/loqalabs/loqa-core/internal/protocol"
 	"github.com/loqalabs/loqa-core/internal/router"
 	skillservice "github.com/loqalabs/loqa-core/internal/skills/service"
 	"github.com/loqalabs/loqa-core/internal/stt"
@@ -22,6 +28,7 @@ import (
 
 type Runtime struct {
 	cfg           config.Config
+	version       string
 	logger        *slog.Logger
 	httpServer    *http.Server
 	tracerClose   func(context.Context) error
@@ -34,15 +41,29 @@ type Runtime struct {
 	skillsService *skillservice.Service
 	routerService *router.Service
 	metricsServer *http.Server
+	metricsFailed atomic.Bool
 	ready         atomic.Bool
 	wg            sync.WaitGroup
+	logLevel      *slog.LevelVar
+	// cfg is fixed once New returns; Reload tracks the settings it has
+	// applied since in live. reloadMu guards live and routerService, which
+	// a reload may touch while Start is still bringing services up.
+	reloadMu sync.Mutex
+	live     config.Config
 }
 
-func New(cfg config.Config, logger *slog.Logger) *Runtime {
-	return &Runtime{
-		cfg:    cfg,
-		logger: logger,
+func New(cfg config.Config, version string, logger *slog.Logger, opts ...Option) *Runtime {
+	r := &Runtime{
+		cfg:     cfg,
+		version: version,
+		logger:  logger,
+		live:    cfg,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.applyLogLevel(cfg.Telemetry.LogLevel)
+	return r
 }
 
 func (r *Runtime) Start(ctx context.Context) error {
@@ -55,12 +76,19 @@ func (r *Runtime) Start(ctx context.Context) error {
 	}
 	r.tracerClose = shutdownTelemetry
 
-	busClient, err := bus.Connect(ctx, r.cfg.Bus, r.logger)
+	busCfg := r.cfg.Bus
+	busCfg.ClientName = bus.ClientName(r.cfg)
+	busClient, err := bus.Connect(ctx, busCfg, r.logger)
 	if err != nil {
 		return fmt.Errorf("failed to connect to message bus: %w", err)
 	}
 	r.busClient = busClient
-	registry, err := capability.NewRegistry(ctx, r.cfg.Node, r.busClient, r.logger)
+	subjects, err := protocol.DefaultSubjects().WithOverrides(r.cfg.Bus.Subjects)
+	if err != nil {
+		return fmt.Errorf("invalid bus.subjects: %w", err)
+	}
+	subjects = subjects.WithPrefix(r.cfg.Bus.SubjectPrefix)
+	registry, err := capability.NewRegistry(ctx, r.cfg.Node, r.busClient, subjects, r.logger)
 	if err != nil {
 		return fmt.Errorf("failed to start capability registry: %w", err)
 	}
@@ -71,14 +99,6 @@ func (r *Runtime) Start(ctx context.Context) error {
 	}
 	r.eventStore = eventStore
 
-	if r.cfg.Skills.Enabled {
-		svc, err := skillservice.New(ctx, r.cfg.Skills, r.busClient, r.eventStore, r.logger)
-		if err != nil {
-			return fmt.Errorf("start skills service: %w", err)
-		}
-		r.skillsService = svc
-	}
-
 	if r.cfg.STT.Enabled {
 		var recognizer stt.Recognizer
 		var err error
@@ -88,39 +108,44 @@ func (r *Runtime) Start(ctx context.Context) error {
 			if err != nil {
 				return fmt.Errorf("failed to configure exec recognizer: %w", err)
 			}
+		case "scripted":
+			script, err := stt.ScriptFromConfig(r.cfg.STT.ScriptTranscripts, r.cfg.STT.ScriptFile)
+			if err != nil {
+				return fmt.Errorf("failed to load stt script: %w", err)
+			}
+			recognizer, err = stt.NewScriptedRecognizer(script, r.cfg.STT.ScriptCycle)
+			if err != nil {
+				return fmt.Errorf("failed to configure scripted recognizer: %w", err)
+			}
 		case "mock", "":
 			recognizer = stt.NewMockRecognizer()
 		default:
 			return fmt.Errorf("unsupported STT mode %q", r.cfg.STT.Mode)
 		}
-		service := stt.NewService(ctx, r.cfg.STT, r.busClient, recognizer)
+		service := stt.NewService(ctx, r.cfg.STT, r.busClient, subjects, recognizer)
 		if err := service.Start(); err != nil {
 			return fmt.Errorf("start STT service: %w", err)
 		}
 		r.sttService = service
+		r.advertise(capability.Capability{Name: "stt", Attributes: nonEmptyAttrs("language", r.cfg.STT.Language)})
+		r.registry.ReportLoad("stt", service.InFlight)
 	}
 
 	if r.cfg.LLM.Enabled {
-		var generator llm.Generator
-		var err error
-		switch r.cfg.LLM.Mode {
-		case "ollama":
-			generator = llm.NewOllamaGenerator(r.cfg.LLM.Endpoint, r.cfg.LLM.ModelFast, r.cfg.LLM.ModelBalanced)
-		case "exec":
-			generator, err = llm.NewExecGenerator(r.cfg.LLM.Command)
-		case "mock", "":
-			generator = llm.NewMockGenerator()
-		default:
-			return fmt.Errorf("unsupported LLM mode %q", r.cfg.LLM.Mode)
-		}
+		generators, err := llm.NewGenerators(r.cfg.LLM)
 		if err != nil {
 			return fmt.Errorf("failed to configure LLM generator: %w", err)
 		}
-		service := llm.NewService(ctx, r.cfg.LLM, r.busClient, generator, r.logger)
+		service := llm.NewService(ctx, r.cfg.LLM, r.busClient, subjects, generators, r.logger)
 		if err := service.Start(); err != nil {
 			return fmt.Errorf("start LLM service: %w", err)
 		}
 		r.llmService = service
+		r.advertise(
+			capability.Capability{Name: "llm", Tier: "fast", Attributes: nonEmptyAttrs("model", r.cfg.LLM.ModelFast)},
+			capability.Capability{Name: "llm", Tier: "balanced", Attributes: nonEmptyAttrs("model", r.cfg.LLM.ModelBalanced)},
+		)
+		r.registry.ReportLoad("llm", service.InFlight)
 	}
 
 	if r.cfg.TTS.Enabled {
@@ -128,116 +153,188 @@ func (r *Runtime) Start(ctx context.Context) error {
 		var err error
 		switch r.cfg.TTS.Mode {
 		case "exec":
-			synth, err = tts.NewExecSynth(r.cfg.TTS.Command, r.cfg.TTS.SampleRate, r.cfg.TTS.Channels)
+			synth, err = tts.NewExecSynth(r.cfg.TTS.Command, r.cfg.TTS.CommandArgs, loqaexec.Environ(r.cfg.TTS.ExecEnvPassthrough, r.cfg.TTS.ExecEnv), r.cfg.TTS.SampleRate, r.cfg.TTS.Channels)
 		case "mock", "":
-			synth = tts.NewMockSynth(r.cfg.TTS.SampleRate, r.cfg.TTS.Channels)
+			synth = tts.NewMockSynth(r.cfg.TTS)
 		default:
 			return fmt.Errorf("unsupported TTS mode %q", r.cfg.TTS.Mode)
 		}
 		if err != nil {
 			return fmt.Errorf("failed to configure TTS synthesizer: %w", err)
 		}
-		service := tts.NewService(ctx, r.cfg.TTS, r.busClient, synth, r.logger)
+		service := tts.NewService(ctx, r.cfg.TTS, r.busClient, subjects, synth, r.logger)
 		if err := service.Start(); err != nil {
 			return fmt.Errorf("start TTS service: %w", err)
 		}
 		r.ttsService = service
+		r.advertise(capability.Capability{Name: "tts", Attributes: nonEmptyAttrs("voice", r.cfg.TTS.Voice)})
+		r.registry.ReportLoad("tts", service.InFlight)
 	}
 
 	if r.cfg.Router.Enabled {
-		service := router.NewService(ctx, r.cfg.Router, r.busClient, r.logger)
+		if missing := routerDownstreamDisabled(r.cfg); len(missing) > 0 {
+			r.logger.Warn("router enabled but downstream services are disabled on this node; transcripts are dropped unless another node serves them",
+				slog.Any("disabled", missing), slog.String("router_mode", r.cfg.Router.Mode))
+		}
+		service := router.NewService(ctx, r.cfg.Router, r.busClient, subjects, r.eventStore, r.logger)
 		if err := service.Start(); err != nil {
 			return fmt.Errorf("start router service: %w", err)
 		}
+		r.reloadMu.Lock()
 		r.routerService = service
+		// Pick up defaults from a reload that landed while the router started.
+		service.SetDefaults(r.live.Router.DefaultTier, r.live.Router.DefaultVoice)
+		r.reloadMu.Unlock()
+		r.advertise(capability.Capability{Name: "router"})
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/healthz", r.handleHealth)
-	mux.HandleFunc("/readyz", r.handleReady)
+	// Skills start last so a skill's requires list is checked against the
+	// capabilities this node has just advertised.
+	if r.cfg.Skills.Enabled {
+		svc, err := skillservice.New(ctx, r.cfg.Skills, r.version, r.busClient, subjects, r.registry, r.eventStore, r.logger)
+		if err != nil {
+			return fmt.Errorf("start skills service: %w", err)
+		}
+		r.skillsService = svc
+		r.advertise(capability.Capability{Name: "skills"})
+		r.registry.ReportLoad("skills", svc.InFlight)
+	}
+
+	mux := r.routes()
 	if metricsHandler != nil && r.cfg.Telemetry.PrometheusBind != "" {
-		metricsMux := http.NewServeMux()
-		metricsMux.Handle("/metrics", metricsHandler)
-		r.metricsServer = &http.Server{
-			Addr:              r.cfg.Telemetry.PrometheusBind,
-			Handler:           metricsMux,
-			ReadHeaderTimeout: 5 * time.Second,
+		listener, err := r.listenMetrics(metricsHandler)
+		if err != nil {
+			return err
 		}
 		r.wg.Add(1)
 		go func() {
 			defer r.wg.Done()
-			if err := r.metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			if err := r.metricsServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+				r.metricsFailed.Store(true)
 				r.logger.Error("metrics server failed", slog.String("error", err.Error()))
 			}
 		}()
-		r.logger.Info("metrics endpoint ready", slog.String("addr", r.cfg.Telemetry.PrometheusBind))
+		r.logger.Info("metrics endpoint ready", slog.String("addr", listener.Addr().String()))
 	}
 
-	addr := fmt.Sprintf("%s:%d", r.cfg.HTTP.Bind, r.cfg.HTTP.Port)
-	r.httpServer = &http.Server{
-		Addr:              addr,
-		Handler:           mux,
-		ReadHeaderTimeout: 5 * time.Second,
+	listener, err := r.listenHTTP(requireToken(r.cfg.HTTP.AuthToken, mux))
+	if err != nil {
+		return err
 	}
+	addr := listener.Addr().String()
 
 	r.wg.Add(1)
 	go func() {
 		defer r.wg.Done()
-		if err := r.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := r.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 			r.logger.Error("http server failed", slog.String("error", err.Error()))
 		}
 	}()
 
 	r.ready.Store(true)
-	r.logger.Info("runtime started", slog.String("addr", addr))
+	r.logger.Info("runtime started", slog.String("addr", addr), slog.Bool("tls", r.cfg.HTTP.TLSCert != ""))
 
 	<-ctx.Done()
-	r.logger.Info("runtime stopping")
-	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancelShutdown()
-	if err := r.httpServer.Shutdown(shutdownCtx); err != nil {
-		r.logger.Error("http shutdown error", slog.String("error", err.Error()))
-	}
-	if r.registry != nil {
-		r.registry.Close()
+	timeout := time.Duration(r.cfg.ShutdownTimeoutMS) * time.Millisecond
+	r.logger.Info("runtime stopping", slog.Duration("timeout", timeout))
+	runShutdown(r.logger, timeout, r.shutdownSteps())
+
+	return nil
+}
+
+// routerDownstreamDisabled lists the services the router publishes to that
+// are disabled in cfg. Echo mode does not need the LLM.
+func routerDownstreamDisabled(cfg config.Config) []string {
+	var missing []string
+	if !cfg.LLM.Enabled && cfg.Router.Mode != "echo" {
+		missing = append(missing, "llm")
 	}
-	if r.sttService != nil {
-		r.sttService.Close()
+	if !cfg.TTS.Enabled {
+		missing = append(missing, "tts")
 	}
-	if r.llmService != nil {
-		r.llmService.Close()
+	return missing
+}
+
+// routes builds the API mux. The profiling endpoints are mounted only when
+// http.enable_pprof is set.
+func (r *Runtime) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", r.handleHealth)
+	mux.HandleFunc("/readyz", r.handleReady)
+	mux.HandleFunc("/admin/drain", r.handleDrain)
+	mux.HandleFunc("/status", r.handleStatus)
+	mux.HandleFunc("/nodes", r.handleNodes)
+	mux.HandleFunc("/nodes/watch", r.handleNodesWatch)
+	if r.cfg.HTTP.EnablePprof {
+		mountDebug(mux)
 	}
-	if r.ttsService != nil {
-		r.ttsService.Close()
+	return mux
+}
+
+// listenHTTP creates the API server for handler and binds its address. When
+// http.tls_cert and http.tls_key are set the listener serves TLS; the key
+// pair is loaded here so a bad certificate fails startup.
+func (r *Runtime) listenHTTP(handler http.Handler) (net.Listener, error) {
+	addr := fmt.Sprintf("%s:%d", r.cfg.HTTP.Bind, r.cfg.HTTP.Port)
+	r.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
 	}
-	if r.routerService != nil {
-		r.routerService.Close()
+	var tlsConfig *tls.Config
+	if r.cfg.HTTP.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(r.cfg.HTTP.TLSCert, r.cfg.HTTP.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("load http tls key pair: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		r.httpServer.TLSConfig = tlsConfig
 	}
-	if r.skillsService != nil {
-		r.skillsService.Close()
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen for http on %s: %w", addr, err)
 	}
-	if r.metricsServer != nil {
-		if err := r.metricsServer.Shutdown(shutdownCtx); err != nil {
-			r.logger.Warn("metrics server shutdown error", slog.String("error", err.Error()))
-		}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
 	}
-	if r.eventStore != nil {
-		if err := r.eventStore.Close(); err != nil {
-			r.logger.Warn("event store close error", slog.String("error", err.Error()))
-		}
+	return listener, nil
+}
+
+// listenMetrics creates the metrics server, which also answers /healthz, and
+// binds telemetry.prometheus_bind. Binding here rather than in the serving
+// goroutine makes a port conflict fail startup instead of leaving a runtime
+// that reports ready without metrics.
+func (r *Runtime) listenMetrics(handler http.Handler) (net.Listener, error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	mux.HandleFunc("/healthz", r.handleHealth)
+	r.metricsServer = &http.Server{
+		Addr:              r.cfg.Telemetry.PrometheusBind,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
 	}
-	if r.busClient != nil {
-		r.busClient.Close()
+	listener, err := net.Listen("tcp", r.cfg.Telemetry.PrometheusBind)
+	if err != nil {
+		return nil, fmt.Errorf("listen for metrics on %s: %w", r.cfg.Telemetry.PrometheusBind, err)
 	}
-	r.wg.Wait()
+	return listener, nil
+}
 
-	if r.tracerClose != nil {
-		if err := r.tracerClose(shutdownCtx); err != nil {
-			r.logger.Error("telemetry shutdown error", slog.String("error", err.Error()))
+// advertise adds capabilities derived from a started service to the node's
+// announcement so the cluster view reflects what is actually running.
+func (r *Runtime) advertise(caps ...capability.Capability) {
+	for _, c := range caps {
+		if err := r.registry.AddLocalCapability(c); err != nil {
+			r.logger.Warn("failed to announce capability", slog.String("capability", c.Name), slog.String("error", err.Error()))
 		}
 	}
+}
 
-	return nil
+func nonEmptyAttrs(key, value string) map[string]string {
+	if value == "" {
+		return nil
+	}
+	return map[string]string{key: value}
 }
 
 func (r *Runtime) handleHealth(w http.ResponseWriter, _ *http.Request) {
@@ -251,7 +348,8 @@ func (r *Runtime) handleReady(w http.ResponseWriter, _ *http.Request) {
 	ttsHealthy := r.ttsService == nil || r.ttsService.Healthy()
 	routerHealthy := r.routerService == nil || r.routerService.Healthy()
 	skillsHealthy := r.skillsService == nil || r.skillsService.Healthy()
-	if r.ready.Load() && r.busClient != nil && r.busClient.Healthy() && (r.registry == nil || r.registry.Healthy()) && sttHealthy && llmHealthy && ttsHealthy && routerHealthy && skillsHealthy {
+	draining := r.registry != nil && r.registry.Draining()
+	if r.ready.Load() && !draining && !r.metricsFailed.Load() && r.busClient != nil && r.busClient.Healthy() && (r.registry == nil || r.registry.Healthy()) && sttHealthy && llmHealthy && ttsHealthy && routerHealthy && skillsHealthy {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ready"))
 		return
@@ -259,3 +357,40 @@ func (r *Runtime) handleReady(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusServiceUnavailable)
 	_, _ = w.Write([]byte("not ready"))
 }
+
+// handleStatus reports per-skill activity counters for this process.
+func (r *Runtime) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	status := struct {
+		Skills map[string]skillservice.SkillSummary `json:"skills"`
+	}{Skills: r.skillsService.Summary()}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// handleDrain toggles the node's drain state. The optional JSON body
+// {"draining": false} undrains; an empty body drains.
+func (r *Runtime) handleDrain(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.registry == nil {
+		http.Error(w, "capability registry unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	body := struct {
+		Draining *bool `json:"draining"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(req.Body, 1<<10)).Decode(&body); err != nil && err != io.EOF {
+		http.Error(w, "invalid drain request", http.StatusBadRequest)
+		return
+	}
+	draining := body.Draining == nil || *body.Draining
+	if err := r.registry.SetDraining(draining); err != nil {
+		r.logger.Warn("failed to broadcast drain state", slog.String("error", err.Error()))
+	}
+	r.logger.Info("node drain state changed", slog.Bool("draining", draining))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]bool{"draining": draining})
+}
@@ -0,0 +1,230 @@
+package runtime
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/capability"
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+)
+
+// writeSelfSignedCert writes a PEM key pair for 127.0.0.1 into dir.
+func writeSelfSignedCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "loqa-test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestListenHTTPServesTLS(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t, t.TempDir())
+	cfg := config.Default()
+	cfg.HTTP = config.HTTPConfig{Bind: "127.0.0.1", Port: 0, TLSCert: certFile, TLSKey: keyFile}
+	r := New(cfg, "test", slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", r.handleHealth)
+	listener, err := r.listenHTTP(mux)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = r.httpServer.Serve(listener) }()
+	t.Cleanup(func() { _ = r.httpServer.Close() })
+
+	pemData, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(pemData)
+	client := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get("https://" + listener.Addr().String() + "/healthz")
+	if err != nil {
+		t.Fatalf("https request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" || resp.TLS == nil {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestListenHTTPRejectsBadKeyPair(t *testing.T) {
+	cfg := config.Default()
+	dir := t.TempDir()
+	cfg.HTTP = config.HTTPConfig{Bind: "127.0.0.1", TLSCert: filepath.Join(dir, "missing.pem"), TLSKey: filepath.Join(dir, "missing.key")}
+	r := New(cfg, "test", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if _, err := r.listenHTTP(http.NewServeMux()); err == nil {
+		t.Fatal("expected missing key pair to fail startup")
+	}
+}
+
+func TestRouterDownstreamDisabled(t *testing.T) {
+	cfg := config.Default()
+	if got := routerDownstreamDisabled(cfg); len(got) != 2 || got[0] != "llm" || got[1] != "tts" {
+		t.Fatalf("defaults: expected llm and tts disabled, got %v", got)
+	}
+	cfg.Router.Mode = "echo"
+	if got := routerDownstreamDisabled(cfg); len(got) != 1 || got[0] != "tts" {
+		t.Fatalf("echo mode: expected only tts, got %v", got)
+	}
+	cfg.TTS.Enabled = true
+	if got := routerDownstreamDisabled(cfg); len(got) != 0 {
+		t.Fatalf("echo mode with tts: expected nothing missing, got %v", got)
+	}
+}
+
+func TestAdminDrainTogglesReadiness(t *testing.T) {
+	cfg := config.Default()
+	r := New(cfg, "test", testutil.Logger())
+	r.busClient = testutil.StartBus(t)
+	registry, err := capability.NewRegistry(context.Background(), cfg.Node, r.busClient, protocol.DefaultSubjects(), testutil.Logger())
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(registry.Close)
+	r.registry = registry
+	r.ready.Store(true)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/readyz", r.handleReady)
+	mux.HandleFunc("/admin/drain", r.handleDrain)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	readyz := func() int {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/readyz")
+		if err != nil {
+			t.Fatalf("readyz: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	drain := func(body string) (int, map[string]bool) {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"/admin/drain", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("drain: %v", err)
+		}
+		defer resp.Body.Close()
+		var state map[string]bool
+		_ = json.NewDecoder(resp.Body).Decode(&state)
+		return resp.StatusCode, state
+	}
+
+	if code := readyz(); code != http.StatusOK {
+		t.Fatalf("expected ready before draining, got %d", code)
+	}
+	if code, state := drain(""); code != http.StatusOK || !state["draining"] || !registry.Draining() {
+		t.Fatalf("expected an empty body to drain, got %d %v", code, state)
+	}
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected not ready while draining, got %d", code)
+	}
+	if code, _ := drain("{not json"); code != http.StatusBadRequest || !registry.Draining() {
+		t.Fatalf("expected a malformed body to be rejected without undraining, got %d", code)
+	}
+	if code, state := drain(`{"draining":false}`); code != http.StatusOK || state["draining"] || registry.Draining() {
+		t.Fatalf("expected undrain, got %d %v", code, state)
+	}
+	if code := readyz(); code != http.StatusOK {
+		t.Fatalf("expected ready after undraining, got %d", code)
+	}
+
+	resp, err := http.Get(srv.URL + "/admin/drain")
+	if err != nil {
+		t.Fatalf("get drain: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != http.MethodPost {
+		t.Fatalf("expected GET to be refused, got %d", resp.StatusCode)
+	}
+}
+
+func TestListenMetricsServesHealthz(t *testing.T) {
+	cfg := config.Default()
+	cfg.Telemetry.PrometheusBind = "127.0.0.1:0"
+	r := New(cfg, "test", testutil.Logger())
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("loqa_up 1\n")) })
+	listener, err := r.listenMetrics(metrics)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = r.metricsServer.Serve(listener) }()
+	t.Cleanup(func() { _ = r.metricsServer.Close() })
+
+	for path, want := range map[string]string{"/healthz": "ok", "/metrics": "loqa_up 1\n"} {
+		resp, err := http.Get("http://" + listener.Addr().String() + path)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != want {
+			t.Fatalf("%s: expected 200 %q, got %d %q", path, want, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestListenMetricsFailsOnPortConflict(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = taken.Close() })
+
+	cfg := config.Default()
+	cfg.Telemetry.PrometheusBind = taken.Addr().String()
+	r := New(cfg, "test", testutil.Logger())
+	if _, err := r.listenMetrics(http.NotFoundHandler()); err == nil {
+		t.Fatal("expected a bind error for a port already in use")
+	}
+}
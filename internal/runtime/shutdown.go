@@ -0,0 +1,126 @@
+package runtime
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// shutdownStep is one phase of runtime teardown. Each step gets share
+// percent of the overall shutdown timeout, so a slow step cannot starve the
+// ones after it. When run outlives its budget, force (if set) is called and
+// shutdown moves on.
+type shutdownStep struct {
+	name  string
+	share int
+	run   func(ctx context.Context) error
+	force func()
+}
+
+// runShutdown executes steps in order, logging how long each took. It
+// reports the names of steps that had to be forced.
+func runShutdown(log *slog.Logger, total time.Duration, steps []shutdownStep) []string {
+	var forced []string
+	for _, step := range steps {
+		budget := total * time.Duration(step.share) / 100
+		ctx, cancel := context.WithTimeout(context.Background(), budget)
+		start := time.Now()
+		done := make(chan error, 1)
+		go func() { done <- step.run(ctx) }()
+
+		select {
+		case err := <-done:
+			attrs := []any{slog.String("step", step.name), slog.Duration("elapsed", time.Since(start))}
+			if err != nil {
+				log.Warn("shutdown step failed", append(attrs, slog.String("error", err.Error()))...)
+			} else {
+				log.Info("shutdown step complete", attrs...)
+			}
+		case <-ctx.Done():
+			if step.force != nil {
+				step.force()
+			}
+			forced = append(forced, step.name)
+			log.Error("shutdown step exceeded its deadline; forced", slog.String("step", step.name), slog.Duration("budget", budget))
+		}
+		cancel()
+	}
+	return forced
+}
+
+// shutdownSteps orders teardown: stop ingress, drain in-flight work, flush
+// stores, close the bus, then stop telemetry.
+func (r *Runtime) shutdownSteps() []shutdownStep {
+	return []shutdownStep{
+		{
+			name:  "ingress",
+			share: 15,
+			run:   func(ctx context.Context) error { return r.httpServer.Shutdown(ctx) },
+			force: func() { _ = r.httpServer.Close() },
+		},
+		{
+			name:  "drain",
+			share: 40,
+			run: func(context.Context) error {
+				if r.routerService != nil {
+					r.routerService.Close()
+				}
+				if r.sttService != nil {
+					r.sttService.Close()
+				}
+				if r.llmService != nil {
+					r.llmService.Close()
+				}
+				if r.ttsService != nil {
+					r.ttsService.Close()
+				}
+				if r.skillsService != nil {
+					r.skillsService.Close()
+				}
+				return nil
+			},
+		},
+		{
+			name:  "flush",
+			share: 20,
+			run: func(context.Context) error {
+				if r.eventStore != nil {
+					return r.eventStore.Close()
+				}
+				return nil
+			},
+		},
+		{
+			name:  "bus",
+			share: 15,
+			run: func(context.Context) error {
+				if r.registry != nil {
+					r.registry.Close()
+				}
+				r.busClient.Close()
+				return nil
+			},
+			force: func() {
+				if r.busClient != nil {
+					r.busClient.Conn().Close()
+				}
+			},
+		},
+		{
+			name:  "telemetry",
+			share: 10,
+			run: func(ctx context.Context) error {
+				if r.metricsServer != nil {
+					if err := r.metricsServer.Shutdown(ctx); err != nil {
+						r.logger.Warn("metrics server shutdown error", slog.String("error", err.Error()))
+					}
+				}
+				r.wg.Wait()
+				if r.tracerClose != nil {
+					return r.tracerClose(ctx)
+				}
+				return nil
+			},
+		},
+	}
+}
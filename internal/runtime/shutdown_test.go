@@ -0,0 +1,61 @@
+package runtime
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunShutdownForcesSlowStepAndContinues(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+	var forcedHung atomic.Bool
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	steps := []shutdownStep{
+		{name: "ingress", share: 20, run: func(context.Context) error {
+			record("ingress")
+			return nil
+		}},
+		{name: "flush", share: 30, run: func(context.Context) error {
+			record("flush")
+			<-release // ignores its context, like a stuck vacuum
+			return nil
+		}, force: func() { forcedHung.Store(true) }},
+		{name: "bus", share: 50, run: func(ctx context.Context) error {
+			deadline, ok := ctx.Deadline()
+			if !ok || time.Until(deadline) < 100*time.Millisecond {
+				t.Errorf("expected bus to get its own budget, deadline in %v", time.Until(deadline))
+			}
+			record("bus")
+			return nil
+		}},
+	}
+
+	start := time.Now()
+	forced := runShutdown(log, 400*time.Millisecond, steps)
+	elapsed := time.Since(start)
+
+	if len(forced) != 1 || forced[0] != "flush" || !forcedHung.Load() {
+		t.Fatalf("expected only flush to be forced, got %v (force called: %v)", forced, forcedHung.Load())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 3 || order[0] != "ingress" || order[1] != "flush" || order[2] != "bus" {
+		t.Fatalf("expected steps in order, got %v", order)
+	}
+	if elapsed > time.Second {
+		t.Fatalf("expected shutdown bounded by the timeout, took %v", elapsed)
+	}
+}
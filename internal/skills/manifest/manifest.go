@@ -1,45 +1,219 @@
 package manifest
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/loqalabs/loqa-core/internal/protocol"
 	"gopkg.in/yaml.v3"
 )
 
+// Manifest schema versions. A manifest without a schema field is read as
+// SchemaV1.
+const (
+	SchemaV1 = "v1"
+	// CurrentSchema is the newest manifest format this host understands.
+	CurrentSchema = SchemaV1
+)
+
 // Manifest describes a Loqa skill package.
 type Manifest struct {
+	// Schema names the manifest format so future formats can be told apart.
+	Schema       string       `yaml:"schema,omitempty"`
 	Metadata     Metadata     `yaml:"metadata"`
 	Runtime      RuntimeSpec  `yaml:"runtime"`
 	Capabilities Capabilities `yaml:"capabilities"`
 	Permissions  []string     `yaml:"permissions"`
 	Surfaces     Surfaces     `yaml:"surfaces,omitempty"`
+	Audit        AuditSpec    `yaml:"audit,omitempty"`
+	Config       []ConfigSpec `yaml:"config,omitempty"`
+	// Requires names node capabilities (e.g. tts, llm) the skill depends
+	// on; the host skips the skill when no healthy node advertises one.
+	Requires []string `yaml:"requires,omitempty"`
+}
+
+// ConfigSpec declares a key the skill reads with host_config_get. The
+// operator supplies values under skills.config.<skill name>; Default applies
+// when none is set. Secret keys require the config:read permission and
+// their values are redacted from audit events.
+type ConfigSpec struct {
+	Key         string `yaml:"key"`
+	Description string `yaml:"description,omitempty"`
+	Default     string `yaml:"default,omitempty"`
+	Secret      bool   `yaml:"secret,omitempty"`
 }
 
 type Metadata struct {
-	Name        string   `yaml:"name"`
-	Version     string   `yaml:"version"`
-	Description string   `yaml:"description"`
-	Author      string   `yaml:"author"`
-	Tags        []string `yaml:"tags,omitempty"`
+	Name           string   `yaml:"name"`
+	Version        string   `yaml:"version"`
+	Description    string   `yaml:"description"`
+	Author         string   `yaml:"author"`
+	Tags           []string `yaml:"tags,omitempty"`
+	MinHostVersion string   `yaml:"min_host_version,omitempty"`
 }
 
 type RuntimeSpec struct {
-	Mode        string `yaml:"mode"`
-	Module      string `yaml:"module"`
-	Entrypoint  string `yaml:"entrypoint"`
-	HostVersion string `yaml:"host_version"`
+	Mode         string    `yaml:"mode"`
+	Module       string    `yaml:"module"`
+	ModuleSHA256 string    `yaml:"module_sha256,omitempty"`
+	Entrypoint   string    `yaml:"entrypoint"`
+	HostVersion  string    `yaml:"host_version"`
+	Retry        RetrySpec `yaml:"retry,omitempty"`
+	// Handlers maps subjects to the exported function invoked for them in
+	// place of Entrypoint. A key is a subscribe entry or a concrete subject
+	// one of them matches; the concrete subject wins over the pattern.
+	Handlers map[string]string `yaml:"handlers,omitempty"`
+}
+
+// Handler returns the function to call for an event on subject, delivered
+// through the subscription pattern: a handler mapped to the subject, then to
+// the pattern, then Entrypoint.
+func (r RuntimeSpec) Handler(subject, pattern string) string {
+	if fn := r.Handlers[subject]; fn != "" {
+		return fn
+	}
+	if fn := r.Handlers[pattern]; fn != "" {
+		return fn
+	}
+	return r.Entrypoint
+}
+
+// Exports lists every function the module must export: Entrypoint followed
+// by the distinct handler functions in name order.
+func (r RuntimeSpec) Exports() []string {
+	exports := []string{r.Entrypoint}
+	fns := make([]string, 0, len(r.Handlers))
+	for _, fn := range r.Handlers {
+		fns = append(fns, fn)
+	}
+	slices.Sort(fns)
+	for _, fn := range slices.Compact(fns) {
+		if fn != r.Entrypoint {
+			exports = append(exports, fn)
+		}
+	}
+	return exports
+}
+
+// RetrySpec opts a skill into re-running failed invocations. Attempt n+1
+// waits BackoffMS * 2^(n-1), capped at MaxBackoffMS. Once MaxRetries is
+// exhausted the event is republished on DeadLetter, when set.
+type RetrySpec struct {
+	MaxRetries   int    `yaml:"max_retries,omitempty"`
+	BackoffMS    int    `yaml:"backoff_ms,omitempty"`
+	MaxBackoffMS int    `yaml:"max_backoff_ms,omitempty"`
+	DeadLetter   string `yaml:"dead_letter,omitempty"`
+}
+
+// Retry defaults applied when RetrySpec leaves the backoff unset.
+const (
+	DefaultRetryBackoffMS    = 200
+	DefaultRetryMaxBackoffMS = 5000
+	maxRetries               = 10
+)
+
+// Backoff returns the delay before retrying after failed attempt (1-based).
+func (r RetrySpec) Backoff(attempt int) time.Duration {
+	base := r.BackoffMS
+	if base <= 0 {
+		base = DefaultRetryBackoffMS
+	}
+	limit := r.MaxBackoffMS
+	if limit <= 0 {
+		limit = DefaultRetryMaxBackoffMS
+	}
+	delay := base
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return time.Duration(delay) * time.Millisecond
 }
 
 type Capabilities struct {
 	Bus     BusSpec     `yaml:"bus"`
 	Storage StorageSpec `yaml:"storage,omitempty"`
 	Timers  bool        `yaml:"timers,omitempty"`
+	Network NetworkSpec `yaml:"network,omitempty"`
 }
 
+// BusSpec declares the subjects a skill uses. Subscribe entries may use NATS
+// wildcards freely; publish entries may only when PublishWildcards is set, so
+// a skill granted "skill.timer.status.*" opted into publishing on every
+// matching subject.
 type BusSpec struct {
-	Publish   []string `yaml:"publish,omitempty"`
-	Subscribe []string `yaml:"subscribe,omitempty"`
+	Publish          []string `yaml:"publish,omitempty"`
+	Subscribe        []string `yaml:"subscribe,omitempty"`
+	PublishWildcards bool     `yaml:"publish_wildcards,omitempty"`
+	// MaxPublishesPerSecond, when positive, lowers
+	// skills.max_publishes_per_second for this skill; it cannot raise it.
+	MaxPublishesPerSecond float64 `yaml:"max_publishes_per_second,omitempty"`
+}
+
+type NetworkSpec struct {
+	HTTP HTTPSpec `yaml:"http,omitempty"`
+}
+
+// HTTPSpec declares the outbound HTTP a skill may perform through host_http.
+// Allow entries are hostnames, optionally with a port ("ha.local:8123") or a
+// leading wildcard label ("*.example.com"). Methods defaults to GET.
+type HTTPSpec struct {
+	Allow   []string `yaml:"allow,omitempty"`
+	Methods []string `yaml:"methods,omitempty"`
+}
+
+// AllowsHost reports whether host (a URL host, with or without port) matches
+// the allowlist. An entry without a port allows any port.
+func (h HTTPSpec) AllowsHost(host string) bool {
+	name, port := splitHostPort(host)
+	for _, entry := range h.Allow {
+		entryName, entryPort := splitHostPort(entry)
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(entryName, "*."); ok {
+			if strings.HasSuffix(name, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if name == entryName {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsMethod reports whether method may be used; GET is the only method
+// allowed when Methods is empty.
+func (h HTTPSpec) AllowsMethod(method string) bool {
+	if len(h.Methods) == 0 {
+		return strings.EqualFold(method, "GET")
+	}
+	for _, m := range h.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitHostPort(host string) (string, string) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		return strings.Trim(host[:i], "[]"), host[i+1:]
+	}
+	return strings.Trim(host, "[]"), ""
 }
 
 type StorageSpec struct {
@@ -52,27 +226,86 @@ type Surfaces struct {
 	Automations bool `yaml:"automations,omitempty"`
 }
 
-// Load reads a manifest from disk.
+// AuditSpec sets the privacy scope of the skill's audit events. PrivacyScope
+// overrides skills.audit_privacy_scope; PublishPrivacyScope applies to
+// skill.publish events, which describe data the skill sent on the bus, and
+// must be at least as strict.
+type AuditSpec struct {
+	PrivacyScope        string `yaml:"privacy_scope,omitempty"`
+	PublishPrivacyScope string `yaml:"publish_privacy_scope,omitempty"`
+}
+
+// Load reads a manifest from disk. Decoding is strict: keys the schema does
+// not define are reported together, so a typo such as "capabilites" fails
+// instead of silently dropping the section.
 func Load(path string) (Manifest, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return Manifest{}, err
 	}
-	var m Manifest
-	if err := yaml.Unmarshal(data, &m); err != nil {
+	// Check the schema first so a newer format is reported as such rather
+	// than as a list of unknown keys.
+	var head struct {
+		Schema string `yaml:"schema"`
+	}
+	if err := yaml.Unmarshal(data, &head); err != nil {
+		return Manifest{}, err
+	}
+	if err := checkSchema(head.Schema); err != nil {
 		return Manifest{}, err
 	}
+	var m Manifest
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil && !errors.Is(err, io.EOF) {
+		return Manifest{}, unknownFields(err)
+	}
 	return m, nil
 }
 
+func checkSchema(schema string) error {
+	if schema != "" && schema != CurrentSchema {
+		return fmt.Errorf("schema %q not supported (this host reads %s)", schema, CurrentSchema)
+	}
+	return nil
+}
+
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (\S+) not found in type`)
+
+// unknownFields rewrites a strict decoding error that only complains about
+// unknown keys into one listing them with their lines.
+func unknownFields(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	fields := make([]string, 0, len(typeErr.Errors))
+	for _, msg := range typeErr.Errors {
+		match := unknownFieldPattern.FindStringSubmatch(msg)
+		if match == nil {
+			return err
+		}
+		fields = append(fields, fmt.Sprintf("%s (line %s)", match[2], match[1]))
+	}
+	return fmt.Errorf("unknown manifest fields: %s", strings.Join(fields, ", "))
+}
+
 // Validate ensures manifest contains required fields.
 func Validate(m Manifest) error {
+	if err := checkSchema(m.Schema); err != nil {
+		return err
+	}
 	if m.Metadata.Name == "" {
 		return fmt.Errorf("metadata.name is required")
 	}
 	if m.Metadata.Version == "" {
 		return fmt.Errorf("metadata.version is required")
 	}
+	if m.Metadata.MinHostVersion != "" {
+		if _, err := parseVersion(m.Metadata.MinHostVersion); err != nil {
+			return fmt.Errorf("metadata.min_host_version: %w", err)
+		}
+	}
 	if m.Runtime.Mode == "" {
 		return fmt.Errorf("runtime.mode is required")
 	}
@@ -84,14 +317,241 @@ func Validate(m Manifest) error {
 		if m.Runtime.Entrypoint == "" {
 			return fmt.Errorf("runtime.entrypoint is required for wasm")
 		}
+		if IsRemoteModule(m.Runtime.Module) && m.Runtime.ModuleSHA256 == "" {
+			return fmt.Errorf("runtime.module_sha256 is required for remote modules")
+		}
 	default:
 		return fmt.Errorf("runtime.mode %q not supported", m.Runtime.Mode)
 	}
 	if len(m.Capabilities.Bus.Publish) == 0 && len(m.Capabilities.Bus.Subscribe) == 0 {
 		return fmt.Errorf("capabilities.bus must declare publish or subscribe subjects")
 	}
+	for _, subject := range m.Capabilities.Bus.Subscribe {
+		if err := validateSubject(subject); err != nil {
+			return fmt.Errorf("capabilities.bus.subscribe: %w", err)
+		}
+	}
+	for _, subject := range m.Capabilities.Bus.Publish {
+		if err := validateSubject(subject); err != nil {
+			return fmt.Errorf("capabilities.bus.publish: %w", err)
+		}
+		if IsWildcardSubject(subject) && !m.Capabilities.Bus.PublishWildcards {
+			return fmt.Errorf("capabilities.bus.publish: %q contains wildcards; set capabilities.bus.publish_wildcards: true to allow publishing on every matching subject", subject)
+		}
+	}
+	if m.Capabilities.Bus.MaxPublishesPerSecond < 0 {
+		return fmt.Errorf("capabilities.bus.max_publishes_per_second must be >= 0")
+	}
+	if err := validateHandlers(m.Runtime.Handlers, m.Capabilities.Bus.Subscribe); err != nil {
+		return err
+	}
+	if err := validateRetry(m.Runtime.Retry); err != nil {
+		return err
+	}
+	if err := validateAudit(m.Audit); err != nil {
+		return err
+	}
 	if len(m.Permissions) == 0 {
 		return fmt.Errorf("permissions must include at least one entry")
 	}
+	if err := validateConfig(m.Config, m.Permissions); err != nil {
+		return err
+	}
+	for _, name := range m.Requires {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("requires: capability names must not be empty")
+		}
+	}
+	for _, entry := range m.Capabilities.Network.HTTP.Allow {
+		if strings.TrimSpace(entry) == "" || strings.Contains(entry, "/") {
+			return fmt.Errorf("capabilities.network.http.allow: invalid host %q", entry)
+		}
+	}
+	for _, method := range m.Capabilities.Network.HTTP.Methods {
+		switch strings.ToUpper(method) {
+		case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE":
+		default:
+			return fmt.Errorf("capabilities.network.http.methods: unsupported method %q", method)
+		}
+	}
+	return nil
+}
+
+func validateConfig(specs []ConfigSpec, permissions []string) error {
+	seen := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if strings.TrimSpace(spec.Key) == "" {
+			return fmt.Errorf("config: key is required")
+		}
+		if seen[spec.Key] {
+			return fmt.Errorf("config: duplicate key %q", spec.Key)
+		}
+		seen[spec.Key] = true
+		if spec.Secret && spec.Default != "" {
+			return fmt.Errorf("config: secret key %q must not have a default", spec.Key)
+		}
+		if spec.Secret && !slices.Contains(permissions, "config:read") {
+			return fmt.Errorf("config: secret key %q requires the config:read permission", spec.Key)
+		}
+	}
+	return nil
+}
+
+// validateHandlers checks that every handler names a function and a subject
+// the skill subscribes to, either as declared or matched by a pattern.
+func validateHandlers(handlers map[string]string, subscribe []string) error {
+	for subject, fn := range handlers {
+		if strings.TrimSpace(fn) == "" {
+			return fmt.Errorf("runtime.handlers: %q maps to an empty function name", subject)
+		}
+		covered := slices.ContainsFunc(subscribe, func(entry string) bool {
+			if entry == subject {
+				return true
+			}
+			_, ok := MatchSubject(entry, subject)
+			return ok && !IsWildcardSubject(subject)
+		})
+		if !covered {
+			return fmt.Errorf("runtime.handlers: %q is not a subscribed subject", subject)
+		}
+	}
+	return nil
+}
+
+func validateRetry(r RetrySpec) error {
+	if r.MaxRetries < 0 || r.MaxRetries > maxRetries {
+		return fmt.Errorf("runtime.retry.max_retries must be between 0 and %d", maxRetries)
+	}
+	if r.BackoffMS < 0 || r.MaxBackoffMS < 0 {
+		return fmt.Errorf("runtime.retry backoff must be >= 0")
+	}
+	if r.DeadLetter != "" {
+		if err := validateSubject(r.DeadLetter); err != nil {
+			return fmt.Errorf("runtime.retry.dead_letter: %w", err)
+		}
+		if IsWildcardSubject(r.DeadLetter) {
+			return fmt.Errorf("runtime.retry.dead_letter must be a concrete subject")
+		}
+	}
+	return nil
+}
+
+// IsRemoteModule reports whether module is an http(s):// or oci:// reference
+// rather than a local path.
+func IsRemoteModule(module string) bool {
+	lower := strings.ToLower(module)
+	for _, scheme := range []string{"http://", "https://", "oci://"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareVersions compares two semantic version strings (an optional leading
+// "v" is accepted). It returns -1, 0, or 1 when a is lower than, equal to, or
+// greater than b. Build metadata is ignored; a pre-release sorts before the
+// corresponding release.
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < 3; i++ {
+		if va.core[i] != vb.core[i] {
+			if va.core[i] < vb.core[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return comparePrerelease(va.pre, vb.pre), nil
+}
+
+type version struct {
+	core [3]int
+	pre  []string
+}
+
+func parseVersion(s string) (version, error) {
+	raw := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(raw, '+'); i >= 0 {
+		raw = raw[:i]
+	}
+	var v version
+	if i := strings.IndexByte(raw, '-'); i >= 0 {
+		if i == len(raw)-1 {
+			return version{}, fmt.Errorf("invalid version %q", s)
+		}
+		v.pre = strings.Split(raw[i+1:], ".")
+		raw = raw[:i]
+	}
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return version{}, fmt.Errorf("invalid version %q: expected MAJOR.MINOR.PATCH", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return version{}, fmt.Errorf("invalid version %q", s)
+		}
+		v.core[i] = n
+	}
+	return v, nil
+}
+
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		na, errA := strconv.Atoi(a[i])
+		nb, errB := strconv.Atoi(b[i])
+		switch {
+		case errA == nil && errB == nil:
+			if na < nb {
+				return -1
+			}
+			return 1
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		case a[i] < b[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+func validateAudit(a AuditSpec) error {
+	for key, scope := range map[string]string{"privacy_scope": a.PrivacyScope, "publish_privacy_scope": a.PublishPrivacyScope} {
+		if scope != "" && protocol.PrivacyRank(scope) < 0 {
+			return fmt.Errorf("audit.%s must be one of %s", key, protocol.PrivacyScopesList())
+		}
+	}
+	if a.PrivacyScope != "" && a.PublishPrivacyScope != "" && protocol.PrivacyRank(a.PublishPrivacyScope) < protocol.PrivacyRank(a.PrivacyScope) {
+		return fmt.Errorf("audit.publish_privacy_scope %q is less strict than audit.privacy_scope %q", a.PublishPrivacyScope, a.PrivacyScope)
+	}
 	return nil
 }
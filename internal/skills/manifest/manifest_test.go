@@ -1,9 +1,12 @@
 package manifest
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 const validYAML = `metadata:
@@ -65,3 +68,320 @@ func TestValidateUnsupportedMode(t *testing.T) {
 		t.Fatalf("expected error for unsupported runtime")
 	}
 }
+
+func TestValidateRemoteModuleRequiresDigest(t *testing.T) {
+	m := Manifest{
+		Metadata:     Metadata{Name: "x", Version: "1.0.0"},
+		Runtime:      RuntimeSpec{Mode: "wasm", Module: "https://example.com/x.wasm", Entrypoint: "run"},
+		Capabilities: Capabilities{Bus: BusSpec{Publish: []string{"foo"}}},
+		Permissions:  []string{"bus:publish"},
+	}
+	if err := Validate(m); err == nil {
+		t.Fatalf("expected error for remote module without module_sha256")
+	}
+	m.Runtime.ModuleSHA256 = "00"
+	if err := Validate(m); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"0.1.0", "0.1.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"0.1.0", "0.2.0", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha.2", "1.0.0-alpha.10", -1},
+		{"1.0.0-beta", "1.0.0-alpha", 1},
+		{"1.0.0+build.5", "1.0.0", 0},
+	}
+	for _, tc := range cases {
+		got, err := CompareVersions(tc.a, tc.b)
+		if err != nil {
+			t.Fatalf("compare %s %s: %v", tc.a, tc.b, err)
+		}
+		if got != tc.want {
+			t.Fatalf("compare %s %s: expected %d, got %d", tc.a, tc.b, tc.want, got)
+		}
+	}
+	if _, err := CompareVersions("1.0", "1.0.0"); err == nil {
+		t.Fatalf("expected error for malformed version")
+	}
+}
+
+func TestHTTPSpecAllowlist(t *testing.T) {
+	spec := HTTPSpec{Allow: []string{"homeassistant.local:8123", "*.example.com", "API.test"}}
+	cases := map[string]bool{
+		"homeassistant.local:8123": true,
+		"homeassistant.local:80":   false,
+		"homeassistant.local":      false,
+		"lights.example.com":       true,
+		"a.b.example.com:443":      true,
+		"example.com":              false,
+		"evil-example.com":         false,
+		"api.test:9000":            true,
+		"api.test.evil.com":        false,
+	}
+	for host, want := range cases {
+		if got := spec.AllowsHost(host); got != want {
+			t.Errorf("AllowsHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if !spec.AllowsMethod("get") || spec.AllowsMethod("POST") {
+		t.Fatal("expected only GET to be allowed by default")
+	}
+	spec.Methods = []string{"POST"}
+	if spec.AllowsMethod("GET") || !spec.AllowsMethod("post") {
+		t.Fatal("expected declared methods to replace the default")
+	}
+}
+
+func TestValidateNetworkHTTP(t *testing.T) {
+	m := Manifest{
+		Metadata:     Metadata{Name: "x", Version: "0.1.0"},
+		Runtime:      RuntimeSpec{Mode: "wasm", Module: "m.wasm", Entrypoint: "run"},
+		Capabilities: Capabilities{Bus: BusSpec{Publish: []string{"a"}}},
+		Permissions:  []string{"network:http"},
+	}
+	m.Capabilities.Network.HTTP = HTTPSpec{Allow: []string{"ha.local"}, Methods: []string{"GET", "post"}}
+	if err := Validate(m); err != nil {
+		t.Fatalf("expected valid manifest: %v", err)
+	}
+	m.Capabilities.Network.HTTP.Methods = []string{"CONNECT"}
+	if err := Validate(m); err == nil {
+		t.Fatal("expected unsupported method to fail")
+	}
+	m.Capabilities.Network.HTTP = HTTPSpec{Allow: []string{"http://ha.local/api"}}
+	if err := Validate(m); err == nil {
+		t.Fatal("expected URL in allowlist to fail")
+	}
+}
+
+func TestValidateConfigKeys(t *testing.T) {
+	m := Manifest{
+		Metadata:     Metadata{Name: "x", Version: "0.1.0"},
+		Runtime:      RuntimeSpec{Mode: "wasm", Module: "m.wasm", Entrypoint: "run"},
+		Capabilities: Capabilities{Bus: BusSpec{Publish: []string{"a"}}},
+		Permissions:  []string{"bus:publish", "config:read"},
+		Config: []ConfigSpec{
+			{Key: "endpoint", Default: "http://localhost:8123"},
+			{Key: "token", Secret: true},
+		},
+	}
+	if err := Validate(m); err != nil {
+		t.Fatalf("expected valid manifest: %v", err)
+	}
+	cases := map[string]func(m *Manifest){
+		"requires config:read": func(m *Manifest) { m.Permissions = []string{"bus:publish"} },
+		"duplicate key":        func(m *Manifest) { m.Config = append(m.Config, ConfigSpec{Key: "endpoint"}) },
+		"empty key":            func(m *Manifest) { m.Config = append(m.Config, ConfigSpec{Key: " "}) },
+		"secret default":       func(m *Manifest) { m.Config[1].Default = "hunter2" },
+	}
+	for name, mutate := range cases {
+		bad := m
+		bad.Config = append([]ConfigSpec(nil), m.Config...)
+		mutate(&bad)
+		if err := Validate(bad); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestMatchSubject(t *testing.T) {
+	cases := []struct {
+		pattern, subject string
+		tokens           []string
+		ok               bool
+	}{
+		{"skill.timer.start", "skill.timer.start", nil, true},
+		{"skill.timer.*", "skill.timer.start", []string{"start"}, true},
+		{"skill.*.status.*", "skill.timer.status.kitchen", []string{"timer", "kitchen"}, true},
+		{"skill.timer.>", "skill.timer.room.kitchen", []string{"room.kitchen"}, true},
+		{"skill.timer.>", "skill.timer", nil, false},
+		{"skill.timer.*", "skill.timer.a.b", nil, false},
+		{"skill.timer.*", "skill.timer", nil, false},
+		{"skill.timer.start", "skill.timer.stop", nil, false},
+	}
+	for _, tc := range cases {
+		tokens, ok := MatchSubject(tc.pattern, tc.subject)
+		if ok != tc.ok || fmt.Sprint(tokens) != fmt.Sprint(tc.tokens) {
+			t.Errorf("MatchSubject(%q, %q) = %v, %v; want %v, %v", tc.pattern, tc.subject, tokens, ok, tc.tokens, tc.ok)
+		}
+	}
+}
+
+func TestValidateWildcardSubjects(t *testing.T) {
+	m := Manifest{
+		Metadata:    Metadata{Name: "x", Version: "0.1.0"},
+		Runtime:     RuntimeSpec{Mode: "wasm", Module: "m.wasm", Entrypoint: "run"},
+		Permissions: []string{"bus:publish"},
+	}
+	m.Capabilities.Bus = BusSpec{Subscribe: []string{"skill.timer.>"}, Publish: []string{"skill.timer.status.*"}}
+	if err := Validate(m); err == nil || !strings.Contains(err.Error(), "publish_wildcards") {
+		t.Fatalf("expected wildcard publish to require opt-in, got %v", err)
+	}
+	m.Capabilities.Bus.PublishWildcards = true
+	if err := Validate(m); err != nil {
+		t.Fatalf("expected opted-in wildcard publish to validate: %v", err)
+	}
+	for _, bad := range []string{">", "*.status", "skill.>.x", "skill.time*", "skill..x"} {
+		m.Capabilities.Bus.Publish = []string{bad}
+		if err := Validate(m); err == nil {
+			t.Errorf("expected publish subject %q to be rejected", bad)
+		}
+	}
+}
+
+func TestRetryBackoffAndValidation(t *testing.T) {
+	r := RetrySpec{MaxRetries: 3, BackoffMS: 100, MaxBackoffMS: 350}
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 350 * time.Millisecond, 9: 350 * time.Millisecond} {
+		if got := r.Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+	if got := (RetrySpec{}).Backoff(1); got != DefaultRetryBackoffMS*time.Millisecond {
+		t.Fatalf("expected default backoff, got %v", got)
+	}
+
+	m := Manifest{
+		Metadata:    Metadata{Name: "x", Version: "0.1.0"},
+		Runtime:     RuntimeSpec{Mode: "wasm", Module: "m.wasm", Entrypoint: "run", Retry: r},
+		Permissions: []string{"bus:subscribe"},
+	}
+	m.Capabilities.Bus = BusSpec{Subscribe: []string{"skill.x.input"}}
+	if err := Validate(m); err != nil {
+		t.Fatalf("expected retry spec to validate: %v", err)
+	}
+	for _, bad := range []RetrySpec{{MaxRetries: -1}, {MaxRetries: 11}, {BackoffMS: -5}, {DeadLetter: "skill.dlq.*"}, {DeadLetter: "skill..dlq"}} {
+		m.Runtime.Retry = bad
+		if err := Validate(m); err == nil {
+			t.Errorf("expected retry spec %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestValidateAuditPrivacyScopes(t *testing.T) {
+	base := Manifest{
+		Metadata:     Metadata{Name: "x", Version: "1.0.0"},
+		Runtime:      RuntimeSpec{Mode: "wasm", Module: "x.wasm", Entrypoint: "run"},
+		Capabilities: Capabilities{Bus: BusSpec{Publish: []string{"tts.request"}}},
+		Permissions:  []string{"bus:publish"},
+	}
+	cases := []struct {
+		audit AuditSpec
+		ok    bool
+	}{
+		{AuditSpec{}, true},
+		{AuditSpec{PrivacyScope: "private"}, true},
+		{AuditSpec{PrivacyScope: "private", PublishPrivacyScope: "sensitive"}, true},
+		{AuditSpec{PublishPrivacyScope: "public"}, true},
+		{AuditSpec{PrivacyScope: "secret"}, false},
+		{AuditSpec{PrivacyScope: "private", PublishPrivacyScope: "internal"}, false},
+	}
+	for _, tc := range cases {
+		m := base
+		m.Audit = tc.audit
+		if err := Validate(m); (err == nil) != tc.ok {
+			t.Fatalf("audit %+v: expected ok=%v, got %v", tc.audit, tc.ok, err)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "skill.yaml")
+	typo := strings.Replace(validYAML, "capabilities:", "capabilites:", 1)
+	typo = strings.Replace(typo, "  entrypoint: handle\n", "  entrypoint: handle\n  entry_point: handle\n", 1)
+	if err := os.WriteFile(path, []byte(typo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected typo'd key to be rejected")
+	}
+	for _, want := range []string{"unknown manifest fields", "entry_point (line 10)", "capabilites (line 12)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to mention %q, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadChecksSchema(t *testing.T) {
+	dir := t.TempDir()
+	for schema, ok := range map[string]bool{"v1": true, "v2": false} {
+		path := filepath.Join(dir, schema+".yaml")
+		// A newer format is reported by schema even if it adds keys.
+		data := "schema: " + schema + "\nhandlers: {}\n" + validYAML
+		if ok {
+			data = "schema: " + schema + "\n" + validYAML
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		m, err := Load(path)
+		if ok {
+			if err != nil || m.Schema != SchemaV1 {
+				t.Fatalf("schema %s: expected manifest to load, got %v", schema, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), `schema "v2" not supported`) {
+			t.Fatalf("schema %s: expected unsupported schema error, got %v", schema, err)
+		}
+	}
+}
+
+func TestValidateHandlers(t *testing.T) {
+	base := Manifest{
+		Metadata:     Metadata{Name: "timer", Version: "0.1.0"},
+		Runtime:      RuntimeSpec{Mode: "wasm", Module: "timer.wasm", Entrypoint: "run"},
+		Capabilities: Capabilities{Bus: BusSpec{Subscribe: []string{"skill.timer.start", "skill.timer.status.*"}}},
+		Permissions:  []string{"bus:subscribe"},
+	}
+	cases := []struct {
+		handlers map[string]string
+		wantErr  string
+	}{
+		{handlers: map[string]string{"skill.timer.start": "onStart", "skill.timer.status.*": "onStatus"}},
+		{handlers: map[string]string{"skill.timer.status.kitchen": "onKitchen"}},
+		{handlers: map[string]string{"skill.timer.cancel": "onCancel"}, wantErr: "not a subscribed subject"},
+		{handlers: map[string]string{"skill.timer.*": "onAny"}, wantErr: "not a subscribed subject"},
+		{handlers: map[string]string{"skill.timer.start": " "}, wantErr: "empty function name"},
+	}
+	for _, tc := range cases {
+		m := base
+		m.Runtime.Handlers = tc.handlers
+		err := Validate(m)
+		if tc.wantErr == "" && err != nil {
+			t.Fatalf("handlers %v: unexpected error %v", tc.handlers, err)
+		}
+		if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Fatalf("handlers %v: expected %q, got %v", tc.handlers, tc.wantErr, err)
+		}
+	}
+
+	spec := RuntimeSpec{Entrypoint: "run", Handlers: map[string]string{
+		"skill.timer.start":          "onStart",
+		"skill.timer.status.*":       "onStatus",
+		"skill.timer.status.kitchen": "onStatus",
+	}}
+	if got := fmt.Sprint(spec.Exports()); got != "[run onStart onStatus]" {
+		t.Fatalf("unexpected exports %s", got)
+	}
+	for subject, want := range map[string]string{
+		"skill.timer.start":       "onStart",
+		"skill.timer.status.hall": "onStatus",
+		"skill.timer.cancel":      "run",
+	} {
+		pattern := subject
+		if strings.HasPrefix(subject, "skill.timer.status.") {
+			pattern = "skill.timer.status.*"
+		}
+		if got := spec.Handler(subject, pattern); got != want {
+			t.Errorf("Handler(%q) = %q, want %q", subject, got, want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IsWildcardSubject reports whether subject contains a NATS wildcard token
+// ("*" for one token, ">" for one or more trailing tokens).
+func IsWildcardSubject(subject string) bool {
+	for _, tok := range strings.Split(subject, ".") {
+		if tok == "*" || tok == ">" {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchSubject reports whether subject matches pattern using NATS wildcard
+// semantics and returns the text each wildcard matched, in order. A ">"
+// match is returned as the remaining tokens joined with ".".
+func MatchSubject(pattern, subject string) ([]string, bool) {
+	pt := strings.Split(pattern, ".")
+	st := strings.Split(subject, ".")
+	var matched []string
+	for i, tok := range pt {
+		switch {
+		case tok == ">":
+			if i >= len(st) {
+				return nil, false
+			}
+			return append(matched, strings.Join(st[i:], ".")), true
+		case i >= len(st):
+			return nil, false
+		case tok == "*":
+			matched = append(matched, st[i])
+		case tok != st[i]:
+			return nil, false
+		}
+	}
+	if len(st) != len(pt) {
+		return nil, false
+	}
+	return matched, true
+}
+
+// validateSubject checks pattern syntax: no empty tokens, wildcards only as
+// whole tokens, ">" only last, and a literal first token so a declaration
+// cannot cover the entire bus.
+func validateSubject(subject string) error {
+	tokens := strings.Split(subject, ".")
+	for i, tok := range tokens {
+		switch {
+		case tok == "":
+			return fmt.Errorf("subject %q has an empty token", subject)
+		case tok == ">" && i != len(tokens)-1:
+			return fmt.Errorf("subject %q: \">\" must be the last token", subject)
+		case tok != "*" && tok != ">" && strings.ContainsAny(tok, "*> \t"):
+			return fmt.Errorf("subject %q: wildcards must be whole tokens", subject)
+		}
+	}
+	if tokens[0] == "*" || tokens[0] == ">" {
+		return fmt.Errorf("subject %q must start with a literal token", subject)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package modcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxModuleBytes bounds downloads so a misbehaving server cannot fill the disk.
+const maxModuleBytes = 64 << 20
+
+// wasmLayerMediaType identifies the module layer in OCI artifacts.
+const wasmLayerMediaType = "application/vnd.wasm.content.layer.v1+wasm"
+
+// Cache downloads remote skill modules and stores them by SHA-256 digest.
+type Cache struct {
+	dir    string
+	client *http.Client
+	// registryScheme is the scheme used to reach OCI registries; tests
+	// override it to talk to plain-HTTP servers.
+	registryScheme string
+}
+
+// New returns a cache rooted at dir. A nil client uses http.DefaultClient.
+func New(dir string, client *http.Client) *Cache {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Cache{dir: dir, client: client, registryScheme: "https"}
+}
+
+// Fetch returns a local path holding the module referenced by ref. The
+// module is downloaded on first use and reused afterwards; its SHA-256 must
+// match sha256Hex.
+func (c *Cache) Fetch(ctx context.Context, ref, sha256Hex string) (string, error) {
+	want := strings.ToLower(strings.TrimSpace(sha256Hex))
+	if len(want) != sha256.Size*2 {
+		return "", fmt.Errorf("module_sha256 must be a %d character hex digest", sha256.Size*2)
+	}
+	if _, err := hex.DecodeString(want); err != nil {
+		return "", fmt.Errorf("module_sha256: %w", err)
+	}
+
+	path := filepath.Join(c.dir, want+".wasm")
+	if data, err := os.ReadFile(path); err == nil {
+		if digest(data) == want {
+			return path, nil
+		}
+		// Corrupted entry; fall through and download again.
+		_ = os.Remove(path)
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if strings.HasPrefix(strings.ToLower(ref), "oci://") {
+		data, err = c.fetchOCI(ctx, ref[len("oci://"):])
+	} else {
+		data, err = c.get(ctx, ref, nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("fetch %s: %w", ref, err)
+	}
+	if got := digest(data); got != want {
+		return "", fmt.Errorf("fetch %s: sha256 mismatch: expected %s, got %s", ref, want, got)
+	}
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return "", fmt.Errorf("create module cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, "module-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("create cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("commit cache entry: %w", err)
+	}
+	return path, nil
+}
+
+func (c *Cache) get(ctx context.Context, rawURL string, header http.Header) ([]byte, error) {
+	resp, err := c.do(ctx, rawURL, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readBody(resp)
+}
+
+func (c *Cache) do(ctx context.Context, rawURL string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return c.client.Do(req)
+}
+
+func readBody(resp *http.Response) ([]byte, error) {
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxModuleBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxModuleBytes {
+		return nil, fmt.Errorf("module exceeds %d bytes", maxModuleBytes)
+	}
+	return data, nil
+}
+
+type ociManifest struct {
+	Layers []ociDescriptor `json:"layers"`
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+// fetchOCI pulls the wasm layer of an artifact referenced as
+// registry/repository[:tag|@digest]. Anonymous bearer tokens are requested
+// when the registry challenges for them.
+func (c *Cache) fetchOCI(ctx context.Context, ref string) ([]byte, error) {
+	registry, repo, reference, err := parseOCIRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	base := fmt.Sprintf("%s://%s/v2/%s", c.registryScheme, registry, repo)
+
+	header := http.Header{}
+	header.Set("Accept", "application/vnd.oci.image.manifest.v1+json")
+	resp, err := c.do(ctx, base+"/manifests/"+reference, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		token, err := c.anonymousToken(ctx, challenge)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Authorization", "Bearer "+token)
+		resp, err = c.do(ctx, base+"/manifests/"+reference, header)
+		if err != nil {
+			return nil, err
+		}
+	}
+	body, err := readBody(resp)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("oci manifest: %w", err)
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("decode oci manifest: %w", err)
+	}
+	layer, err := pickLayer(manifest.Layers)
+	if err != nil {
+		return nil, err
+	}
+	header.Del("Accept")
+	return c.get(ctx, base+"/blobs/"+layer.Digest, header)
+}
+
+func pickLayer(layers []ociDescriptor) (ociDescriptor, error) {
+	for _, l := range layers {
+		if l.MediaType == wasmLayerMediaType {
+			return l, nil
+		}
+	}
+	if len(layers) == 1 {
+		return layers[0], nil
+	}
+	return ociDescriptor{}, errors.New("oci manifest has no wasm layer")
+}
+
+func (c *Cache) anonymousToken(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported registry auth challenge %q", challenge)
+	}
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[k] = strings.Trim(v, `"`)
+		}
+	}
+	realm := params["realm"]
+	if realm == "" {
+		return "", errors.New("registry auth challenge missing realm")
+	}
+	q := url.Values{}
+	if v := params["service"]; v != "" {
+		q.Set("service", v)
+	}
+	if v := params["scope"]; v != "" {
+		q.Set("scope", v)
+	}
+	body, err := c.get(ctx, realm+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("registry token: %w", err)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return "", fmt.Errorf("decode registry token: %w", err)
+	}
+	if tok.Token != "" {
+		return tok.Token, nil
+	}
+	if tok.AccessToken != "" {
+		return tok.AccessToken, nil
+	}
+	return "", errors.New("registry returned empty token")
+}
+
+func parseOCIRef(ref string) (registry, repo, reference string, err error) {
+	slash := strings.IndexByte(ref, '/')
+	if slash <= 0 || slash == len(ref)-1 {
+		return "", "", "", fmt.Errorf("invalid oci reference %q", ref)
+	}
+	registry, rest := ref[:slash], ref[slash+1:]
+	if at := strings.LastIndexByte(rest, '@'); at >= 0 {
+		return registry, rest[:at], rest[at+1:], nil
+	}
+	if colon := strings.LastIndexByte(rest, ':'); colon >= 0 && !strings.Contains(rest[colon:], "/") {
+		return registry, rest[:colon], rest[colon+1:], nil
+	}
+	return registry, rest, "latest", nil
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,99 @@
+package modcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// wasmBlob is a minimal valid module (magic + version).
+var wasmBlob = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+func sum(data []byte) string {
+	s := sha256.Sum256(data)
+	return hex.EncodeToString(s[:])
+}
+
+func TestFetchHTTPCachesModule(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write(wasmBlob)
+	}))
+	t.Cleanup(srv.Close)
+
+	cache := New(t.TempDir(), srv.Client())
+	ref := srv.URL + "/timer.wasm"
+
+	path, err := cache.Fetch(context.Background(), ref, sum(wasmBlob))
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read cached module: %v", err)
+	}
+	if string(data) != string(wasmBlob) {
+		t.Fatalf("cached module does not match served blob")
+	}
+
+	again, err := cache.Fetch(context.Background(), ref, sum(wasmBlob))
+	if err != nil {
+		t.Fatalf("second fetch: %v", err)
+	}
+	if again != path {
+		t.Fatalf("expected cached path %s, got %s", path, again)
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("expected a single download, got %d", hits.Load())
+	}
+}
+
+func TestFetchHTTPRejectsDigestMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(wasmBlob)
+	}))
+	t.Cleanup(srv.Close)
+
+	cache := New(t.TempDir(), srv.Client())
+	_, err := cache.Fetch(context.Background(), srv.URL+"/timer.wasm", strings.Repeat("0", 64))
+	if err == nil || !strings.Contains(err.Error(), "sha256 mismatch") {
+		t.Fatalf("expected digest mismatch, got %v", err)
+	}
+}
+
+func TestFetchOCI(t *testing.T) {
+	layerDigest := "sha256:" + sum(wasmBlob)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/skills/timer/manifests/v1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer anon" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="test",scope="repository:skills/timer:pull"`, r.Host))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"layers":[{"mediaType":%q,"digest":%q}]}`, wasmLayerMediaType, layerDigest)
+	})
+	mux.HandleFunc("/v2/skills/timer/blobs/"+layerDigest, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(wasmBlob)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"token":"anon"}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	cache := New(t.TempDir(), srv.Client())
+	cache.registryScheme = "http"
+	ref := "oci://" + strings.TrimPrefix(srv.URL, "http://") + "/skills/timer:v1"
+
+	if _, err := cache.Fetch(context.Background(), ref, sum(wasmBlob)); err != nil {
+		t.Fatalf("fetch oci: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Result codes returned to the guest by host_config_get. Non-negative
+// results are the value's length; these values are part of the v1 ABI and
+// must not be renumbered.
+const (
+	// ConfigErrNotFound indicates the key is not declared in the manifest's
+	// config section or has no value.
+	ConfigErrNotFound = -1
+	// ConfigErrNoPermission indicates the key is secret and the manifest
+	// lacks the config:read permission.
+	ConfigErrNoPermission = -2
+	// ConfigErrRuntime indicates a host-side failure (memory access, a key
+	// longer than MaxSubjectBytes, or a panic in the binding).
+	ConfigErrRuntime = -3
+)
+
+// ErrConfigNotFound is returned by HostBindings.GetConfig for keys the skill
+// did not declare or that have no value.
+var ErrConfigNotFound = errors.New("config key not found")
+
+// redacted replaces secret values in audit events.
+const redacted = "[redacted]"
+
+// hostConfigGet implements env.host_config_get(keyPtr, keyLen, outPtr,
+// outCap) -> len. The value is written to outPtr only when it fits in
+// outCap; either way its length is returned, so a guest with too small a
+// buffer can retry with a larger one.
+func hostConfigGet(logger *slog.Logger, binding HostBindings) api.GoModuleFunc {
+	return func(_ context.Context, mod api.Module, stack []uint64) {
+		if len(stack) < 4 {
+			return
+		}
+		keyPtr := api.DecodeU32(stack[0])
+		keyLen := api.DecodeU32(stack[1])
+		outPtr := api.DecodeU32(stack[2])
+		outCap := api.DecodeU32(stack[3])
+		result := func(n int) { stack[0] = api.EncodeI32(int32(n)) }
+		// Reported if the binding panics; recoverHost leaves it in place.
+		result(ConfigErrRuntime)
+
+		if keyLen == 0 || keyLen > MaxSubjectBytes {
+			logger.Warn("skill config key length invalid", slog.Int("key_bytes", int(keyLen)))
+			return
+		}
+		mem := mod.Memory()
+		if mem == nil {
+			return
+		}
+		keyBytes, ok := mem.Read(keyPtr, keyLen)
+		if !ok {
+			return
+		}
+		key := string(keyBytes)
+
+		value, secret, err := binding.GetConfig(key)
+		switch {
+		case errors.Is(err, ErrNoPermission):
+			result(ConfigErrNoPermission)
+			logger.Warn("skill config read denied", slog.String("key", key), slog.String("error", err.Error()))
+			return
+		case errors.Is(err, ErrConfigNotFound):
+			result(ConfigErrNotFound)
+			return
+		case err != nil:
+			logger.Warn("skill config read failed", slog.String("key", key), slog.String("error", err.Error()))
+			return
+		}
+		if uint32(len(value)) > outCap {
+			result(len(value))
+			return
+		}
+		if !mem.Write(outPtr, []byte(value)) {
+			return
+		}
+		if binding.RecordAudit != nil {
+			audited := value
+			if secret {
+				audited = redacted
+			}
+			binding.RecordAudit(AuditEvent{Type: "skill.config.get", Data: map[string]any{
+				"key":    key,
+				"value":  audited,
+				"secret": secret,
+			}})
+		}
+		result(len(value))
+	}
+}
@@ -0,0 +1,753 @@
+package runtime
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/skills/manifest"
+	"github.com/tetratelabs/wazero/api"
+)
+
+func TestHostPublishResultCodes(t *testing.T) {
+	allow := func(string) error { return nil }
+	publishOK := func(string, []byte) error { return nil }
+
+	cases := []struct {
+		name    string
+		payload []byte
+		host    HostBindings
+		want    int32
+	}{
+		{
+			name:    "ok",
+			payload: []byte("hi"),
+			host:    HostBindings{AllowPublish: allow, Publish: publishOK},
+			want:    PublishOK,
+		},
+		{
+			name:    "no permission",
+			payload: []byte("hi"),
+			host: HostBindings{
+				AllowPublish: func(string) error { return fmt.Errorf("%w bus:publish", ErrNoPermission) },
+				Publish:      publishOK,
+			},
+			want: PublishErrNoPermission,
+		},
+		{
+			name:    "subject undeclared",
+			payload: []byte("hi"),
+			host: HostBindings{
+				AllowPublish: func(s string) error { return fmt.Errorf("%w: %s", ErrSubjectUndeclared, s) },
+				Publish:      publishOK,
+			},
+			want: PublishErrSubjectUndeclared,
+		},
+		{
+			name:    "payload too large",
+			payload: []byte("0123456789abcdef"),
+			host:    HostBindings{AllowPublish: allow, Publish: publishOK, MaxPayloadBytes: 8},
+			want:    PublishErrPayloadTooLarge,
+		},
+		{
+			name:    "payload too large while throttled",
+			payload: []byte("0123456789abcdef"),
+			host: HostBindings{
+				AllowPublish:    func(s string) error { return fmt.Errorf("%w: %s", ErrPublishThrottled, s) },
+				Publish:         publishOK,
+				MaxPayloadBytes: 8,
+			},
+			want: PublishErrPayloadTooLarge,
+		},
+		{
+			name:    "bus failure",
+			payload: []byte("hi"),
+			host: HostBindings{
+				AllowPublish: allow,
+				Publish:      func(string, []byte) error { return errors.New("bus down") },
+			},
+			want: PublishErrRuntime,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := callGuest(t, tc.host, publishModule("skill.test.out", tc.payload))
+			if got != tc.want {
+				t.Fatalf("expected code %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestHostMetricForwardsToBinding(t *testing.T) {
+	var gotName string
+	var gotValue float64
+	var gotKind MetricKind
+	host := HostBindings{
+		RecordMetric: func(name string, value float64, kind MetricKind) error {
+			gotName, gotValue, gotKind = name, value, kind
+			return nil
+		},
+	}
+	callGuest(t, host, metricModule("timers_completed", 2.5, MetricHistogram))
+	if gotName != "timers_completed" || gotValue != 2.5 || gotKind != MetricHistogram {
+		t.Fatalf("unexpected metric %q=%v (%s)", gotName, gotValue, gotKind)
+	}
+}
+
+func TestHostRejectsOversizedGuestLengths(t *testing.T) {
+	published := 0
+	host := HostBindings{
+		AllowPublish:    func(string) error { return nil },
+		Publish:         func(string, []byte) error { published++; return nil },
+		MaxPayloadBytes: 16,
+	}
+	cases := []struct {
+		name                   string
+		subjectLen, payloadLen int32
+	}{
+		{"payload claims 2GB", 3, math.MaxInt32},
+		{"payload over cap", 3, 17},
+		{"subject claims 2GB", math.MaxInt32, 2},
+		{"subject over cap", MaxSubjectBytes + 1, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			wasm := publishModuleWithLengths("a.b", []byte("hi"), tc.subjectLen, tc.payloadLen)
+			if got := callGuest(t, host, wasm); got != PublishErrPayloadTooLarge {
+				t.Fatalf("expected PublishErrPayloadTooLarge, got %d", got)
+			}
+		})
+	}
+	if published != 0 {
+		t.Fatalf("expected nothing published, got %d", published)
+	}
+
+	var logged []string
+	host = HostBindings{
+		MaxLogBytes: 8,
+		RecordAudit: func(evt AuditEvent) { logged = append(logged, evt.Data["message"].(string)) },
+	}
+	callGuest(t, host, logModule("hello", 5))
+	callGuest(t, host, logModule("hello world", 11))
+	callGuest(t, host, logModule("hello", math.MaxInt32))
+	if len(logged) != 1 || logged[0] != "hello" {
+		t.Fatalf("expected only the short log line to be recorded, got %q", logged)
+	}
+}
+
+func TestHostHTTPResultCodes(t *testing.T) {
+	allowOnly := func(host string) func(string, *url.URL) error {
+		return func(method string, target *url.URL) error {
+			if target.Host != host {
+				return fmt.Errorf("%w: %s", ErrHostNotAllowed, target.Host)
+			}
+			if method != "GET" {
+				return fmt.Errorf("%w: %s", ErrMethodNotAllowed, method)
+			}
+			return nil
+		}
+	}
+	var calls []HTTPRequest
+	do := func(_ context.Context, req HTTPRequest) (HTTPResponse, error) {
+		calls = append(calls, req)
+		return HTTPResponse{Status: 200, Body: "on"}, nil
+	}
+	cases := []struct {
+		name    string
+		request string
+		respCap int32
+		host    HostBindings
+		want    int32
+	}{
+		{"ok", `{"url":"http://ha.local/api"}`, 1024, HostBindings{AllowHTTP: allowOnly("ha.local"), DoHTTP: do}, HTTPOK},
+		{"no permission", `{"url":"http://ha.local/api"}`, 1024, HostBindings{DoHTTP: do}, HTTPErrNoPermission},
+		{"host denied", `{"url":"http://evil.test/x"}`, 1024, HostBindings{AllowHTTP: allowOnly("ha.local"), DoHTTP: do}, HTTPErrHostNotAllowed},
+		{"method denied", `{"method":"DELETE","url":"http://ha.local/api"}`, 1024, HostBindings{AllowHTTP: allowOnly("ha.local"), DoHTTP: do}, HTTPErrMethodNotAllowed},
+		{"bad scheme", `{"url":"file:///etc/passwd"}`, 1024, HostBindings{AllowHTTP: allowOnly("ha.local"), DoHTTP: do}, HTTPErrBadRequest},
+		{"malformed", `{"url":`, 1024, HostBindings{AllowHTTP: allowOnly("ha.local"), DoHTTP: do}, HTTPErrBadRequest},
+		{"body too large", `{"url":"http://ha.local/api","body":"0123456789"}`, 1024, HostBindings{AllowHTTP: allowOnly("ha.local"), DoHTTP: do, MaxHTTPBytes: 4}, HTTPErrTooLarge},
+		{"buffer too small", `{"url":"http://ha.local/api"}`, 4, HostBindings{AllowHTTP: allowOnly("ha.local"), DoHTTP: do}, HTTPErrBufferTooSmall},
+		{"transport failure", `{"url":"http://ha.local/api"}`, 1024, HostBindings{
+			AllowHTTP: allowOnly("ha.local"),
+			DoHTTP:    func(context.Context, HTTPRequest) (HTTPResponse, error) { return HTTPResponse{}, errors.New("refused") },
+		}, HTTPErrRuntime},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls = nil
+			if got := callGuest(t, tc.host, httpModule(tc.request, tc.respCap)); got != tc.want {
+				t.Fatalf("expected code %d, got %d", tc.want, got)
+			}
+			performed := len(calls) > 0
+			if wantCall := tc.want == HTTPOK || tc.want == HTTPErrBufferTooSmall; performed != wantCall {
+				t.Fatalf("request performed=%v for code %d", performed, tc.want)
+			}
+		})
+	}
+}
+
+func TestHostPanicReportedAsRuntimeError(t *testing.T) {
+	var audits []AuditEvent
+	host := HostBindings{
+		AllowPublish: func(string) error { return nil },
+		Publish:      func(string, []byte) error { panic("bus exploded") },
+		RecordAudit:  func(evt AuditEvent) { audits = append(audits, evt) },
+	}
+	for i := 0; i < 2; i++ {
+		if got := callGuest(t, host, publishModule("skill.test.out", []byte("hi"))); got != PublishErrRuntime {
+			t.Fatalf("call %d: expected PublishErrRuntime, got %d", i, got)
+		}
+	}
+	if len(audits) != 2 || audits[0].Type != "skill.host.panic" || audits[0].Data["function"] != "host_publish" {
+		t.Fatalf("expected panics to be audited, got %+v", audits)
+	}
+
+	// A panic while auditing a panic is swallowed too, and the same skill
+	// keeps serving calls afterwards.
+	var recorded []string
+	host.RecordAudit = func(AuditEvent) { panic("audit exploded") }
+	host.RecordMetric = func(name string, _ float64, _ MetricKind) error {
+		if len(recorded) == 0 {
+			recorded = append(recorded, "panicked")
+			panic("metrics exploded")
+		}
+		recorded = append(recorded, name)
+		return nil
+	}
+	skill := loadGuest(t, host, metricModule("boom", 1, MetricCounter))
+	for i := 0; i < 2; i++ {
+		if _, err := skill.entry.Call(context.Background()); err != nil {
+			t.Fatalf("call %d: expected the guest to complete, got %v", i, err)
+		}
+	}
+	if len(recorded) != 2 || recorded[1] != "boom" {
+		t.Fatalf("expected the second call to reach host_metric, got %v", recorded)
+	}
+}
+
+func TestLoadVerifiesModuleDigest(t *testing.T) {
+	ctx := context.Background()
+	rt, err := New(ctx, HostBindings{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err != nil {
+		t.Fatalf("create runtime: %v", err)
+	}
+	t.Cleanup(func() { rt.Close(ctx) })
+
+	wasm := publishModule("skill.test.out", nil)
+	path := filepath.Join(t.TempDir(), "guest.wasm")
+	if err := os.WriteFile(path, wasm, 0o644); err != nil {
+		t.Fatalf("write module: %v", err)
+	}
+	sum := sha256.Sum256(wasm)
+	digest := hex.EncodeToString(sum[:])
+
+	mf := manifest.Manifest{Runtime: manifest.RuntimeSpec{Mode: "wasm", Module: path, Entrypoint: "run", ModuleSHA256: strings.ToUpper(digest)}}
+	skill, err := rt.Load(ctx, mf, nil)
+	if err != nil {
+		t.Fatalf("load with matching digest: %v", err)
+	}
+	if skill.Digest != digest {
+		t.Fatalf("expected digest %s, got %s", digest, skill.Digest)
+	}
+	skill.Close(ctx)
+
+	mf.Runtime.ModuleSHA256 = strings.Repeat("ab", 32)
+	if _, err := rt.Load(ctx, mf, nil); err == nil || !strings.Contains(err.Error(), "sha256 mismatch") {
+		t.Fatalf("expected digest mismatch error, got %v", err)
+	}
+}
+
+func TestValidateModule(t *testing.T) {
+	ctx := context.Background()
+	if err := ValidateModule(ctx, publishModule("skill.test.out", nil), "run"); err != nil {
+		t.Fatalf("expected valid module: %v", err)
+	}
+	if err := ValidateModule(ctx, publishModule("skill.test.out", nil), "start"); err == nil || !strings.Contains(err.Error(), `entrypoint "start" not exported`) {
+		t.Fatalf("expected missing entrypoint error, got %v", err)
+	}
+
+	// host_publish imported as (i32, i32) -> () instead of the v1 ABI.
+	badSig := wasmModule([]byte{0x60, 0x02, 0x7f, 0x7f, 0x00}, "host_publish", i32Const(0))
+	err := ValidateModule(ctx, badSig, "run")
+	if err == nil || !strings.Contains(err.Error(), "env.host_publish: signature (i32, i32) -> () does not match host (i32, i32, i32, i32) -> (i32)") {
+		t.Fatalf("expected signature mismatch, got %v", err)
+	}
+
+	unknown := wasmModule([]byte{0x60, 0x00, 0x00}, "host_sleep", i32Const(0))
+	err = ValidateModule(ctx, unknown, "missing")
+	if err == nil || !strings.Contains(err.Error(), "env.host_sleep: not provided by host") || !strings.Contains(err.Error(), `"missing"`) {
+		t.Fatalf("expected unknown import and missing entrypoint, got %v", err)
+	}
+
+	if err := ValidateModule(ctx, []byte("not wasm"), "run"); err == nil {
+		t.Fatal("expected invalid module to fail")
+	}
+}
+
+func TestGuestStdoutAndStderrAreLogged(t *testing.T) {
+	cases := []struct {
+		fd    int32
+		attr  string
+		level slog.Level
+	}{
+		{1, "skill.stdout", slog.LevelInfo},
+		{2, "skill.stderr", slog.LevelWarn},
+	}
+	for _, tc := range cases {
+		t.Run(tc.attr, func(t *testing.T) {
+			var buf strings.Builder
+			host := HostBindings{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+			if got := callGuest(t, host, fdWriteModule(tc.fd, "first line\nsecond\r\nno newline")); got != 0 {
+				t.Fatalf("fd_write errno %d", got)
+			}
+			var lines []string
+			for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var rec map[string]any
+				if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+					t.Fatalf("log output is not structured: %q", raw)
+				}
+				if rec["level"] != tc.level.String() {
+					t.Fatalf("expected level %s, got %v", tc.level, rec["level"])
+				}
+				lines = append(lines, fmt.Sprint(rec[tc.attr]))
+			}
+			// The unterminated tail is flushed when the invocation ends.
+			want := []string{"first line", "second", "no newline"}
+			if strings.Join(lines, "|") != strings.Join(want, "|") {
+				t.Fatalf("expected lines %q, got %q", want, lines)
+			}
+		})
+	}
+}
+
+func TestHostDeadlineReportsRemainingTime(t *testing.T) {
+	ctx := context.Background()
+	rt, err := New(ctx, HostBindings{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err != nil {
+		t.Fatalf("create runtime: %v", err)
+	}
+	t.Cleanup(func() { rt.Close(ctx) })
+	// run() -> i32 returns host_deadline() wrapped to i32.
+	wasm := wasmModule([]byte{0x60, 0x00, 0x01, 0x7e}, "host_deadline", []byte{0x10, 0x00, 0xa7})
+	path := filepath.Join(t.TempDir(), "guest.wasm")
+	if err := os.WriteFile(path, wasm, 0o644); err != nil {
+		t.Fatalf("write module: %v", err)
+	}
+	skill, err := rt.Load(ctx, manifest.Manifest{Runtime: manifest.RuntimeSpec{Mode: "wasm", Module: path, Entrypoint: "run"}}, nil)
+	if err != nil {
+		t.Fatalf("load module: %v", err)
+	}
+	t.Cleanup(func() { skill.Close(ctx) })
+
+	results, err := skill.entry.Call(ctx)
+	if err != nil {
+		t.Fatalf("call run: %v", err)
+	}
+	if got := api.DecodeI32(results[0]); got != -1 {
+		t.Fatalf("expected -1 without a deadline, got %d", got)
+	}
+	timed, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	results, err = skill.entry.Call(timed)
+	if err != nil {
+		t.Fatalf("call run: %v", err)
+	}
+	if got := api.DecodeI32(results[0]); got <= 1000 || got > 2000 {
+		t.Fatalf("expected about 2000ms remaining, got %d", got)
+	}
+}
+
+func TestHostConfigGet(t *testing.T) {
+	values := map[string]string{"endpoint": "http://ha.local:8123", "token": "s3cret"}
+	var audits []AuditEvent
+	host := HostBindings{
+		GetConfig: func(key string) (string, bool, error) {
+			switch key {
+			case "locked":
+				return "", true, fmt.Errorf("%w config:read", ErrNoPermission)
+			case "boom":
+				panic("store exploded")
+			}
+			value, ok := values[key]
+			if !ok {
+				return "", false, ErrConfigNotFound
+			}
+			return value, key == "token", nil
+		},
+		RecordAudit: func(evt AuditEvent) { audits = append(audits, evt) },
+	}
+	cases := []struct {
+		key    string
+		outCap int32
+		want   int32
+	}{
+		{"endpoint", 64, int32(len(values["endpoint"]))},
+		{"endpoint", 4, int32(len(values["endpoint"]))},
+		{"token", 64, int32(len(values["token"]))},
+		{"missing", 64, ConfigErrNotFound},
+		{"locked", 64, ConfigErrNoPermission},
+		{"boom", 64, ConfigErrRuntime},
+	}
+	for _, tc := range cases {
+		if got := callGuest(t, host, configModule(tc.key, tc.outCap)); got != tc.want {
+			t.Fatalf("host_config_get(%q, cap %d) = %d, want %d", tc.key, tc.outCap, got, tc.want)
+		}
+	}
+
+	var gets []AuditEvent
+	for _, evt := range audits {
+		if evt.Type == "skill.config.get" {
+			gets = append(gets, evt)
+		}
+	}
+	// The short-buffer probe is not audited; the secret value is redacted.
+	if len(gets) != 2 {
+		t.Fatalf("expected 2 config audits, got %+v", gets)
+	}
+	if gets[0].Data["value"] != values["endpoint"] || gets[1].Data["value"] != "[redacted]" || gets[1].Data["secret"] != true {
+		t.Fatalf("unexpected config audits %+v", gets)
+	}
+}
+
+func TestSkillInvokesHandlerMappedToSubject(t *testing.T) {
+	ctx := context.Background()
+	var published []string
+	host := HostBindings{
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		AllowPublish: func(string) error { return nil },
+		Publish: func(subject string, _ []byte) error {
+			published = append(published, subject)
+			return nil
+		},
+	}
+	rt, err := New(ctx, host)
+	if err != nil {
+		t.Fatalf("create runtime: %v", err)
+	}
+	t.Cleanup(func() { rt.Close(ctx) })
+
+	path := filepath.Join(t.TempDir(), "guest.wasm")
+	if err := os.WriteFile(path, handlersModule("run", "onStart", "onCancel"), 0o644); err != nil {
+		t.Fatalf("write module: %v", err)
+	}
+	mf := manifest.Manifest{Runtime: manifest.RuntimeSpec{Mode: "wasm", Module: path, Entrypoint: "run", Handlers: map[string]string{
+		"skill.timer.start":  "onStart",
+		"skill.timer.cancel": "onCancel",
+	}}}
+	skill, err := rt.Load(ctx, mf, nil)
+	if err != nil {
+		t.Fatalf("load module: %v", err)
+	}
+	t.Cleanup(func() { skill.Close(ctx) })
+
+	for _, subject := range []string{"skill.timer.cancel", "skill.timer.start", "skill.timer.status"} {
+		if err := skill.InvokeHandler(ctx, subject, subject); err != nil {
+			t.Fatalf("invoke %s: %v", subject, err)
+		}
+	}
+	if got := strings.Join(published, ","); got != "onCancel,onStart,run" {
+		t.Fatalf("expected each subject to reach its handler and others the entrypoint, got %s", got)
+	}
+
+	mf.Runtime.Handlers["skill.timer.pause"] = "onPause"
+	if _, err := rt.Load(ctx, mf, nil); err == nil || !strings.Contains(err.Error(), `handler "onPause" not found`) {
+		t.Fatalf("expected missing handler export to fail the load, got %v", err)
+	}
+	if err := ValidateModule(ctx, handlersModule("run", "onStart"), mf.Runtime.Exports()...); err == nil || !strings.Contains(err.Error(), `"onCancel"`) || !strings.Contains(err.Error(), `"onPause"`) {
+		t.Fatalf("expected ValidateModule to list missing handlers, got %v", err)
+	}
+}
+
+// callGuest loads wasm into a fresh runtime and returns the i32 result of its
+// exported run function.
+func callGuest(t *testing.T, host HostBindings, wasm []byte) int32 {
+	t.Helper()
+	skill := loadGuest(t, host, wasm)
+	results, err := skill.entry.Call(context.Background())
+	if err != nil {
+		t.Fatalf("call run: %v", err)
+	}
+	skill.flushOutput()
+	return api.DecodeI32(results[0])
+}
+
+// loadGuest loads wasm as a skill with run as its entrypoint in a fresh
+// runtime, closing both when the test ends.
+func loadGuest(t *testing.T, host HostBindings, wasm []byte) *Skill {
+	t.Helper()
+	ctx := context.Background()
+	if host.Logger == nil {
+		host.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	rt, err := New(ctx, host)
+	if err != nil {
+		t.Fatalf("create runtime: %v", err)
+	}
+	t.Cleanup(func() { rt.Close(ctx) })
+
+	path := filepath.Join(t.TempDir(), "guest.wasm")
+	if err := os.WriteFile(path, wasm, 0o644); err != nil {
+		t.Fatalf("write module: %v", err)
+	}
+	mf := manifest.Manifest{Runtime: manifest.RuntimeSpec{Mode: "wasm", Module: path, Entrypoint: "run"}}
+	skill, err := rt.Load(ctx, mf, nil)
+	if err != nil {
+		t.Fatalf("load module: %v", err)
+	}
+	t.Cleanup(func() { skill.Close(ctx) })
+	return skill
+}
+
+// publishModule assembles a guest whose run() -> i32 calls
+// env.host_publish(subject, payload) and returns the result code.
+func publishModule(subject string, payload []byte) []byte {
+	return publishModuleWithLengths(subject, payload, int32(len(subject)), int32(len(payload)))
+}
+
+// publishModuleWithLengths is publishModule with guest-claimed lengths that
+// may disagree with the data actually placed in memory.
+func publishModuleWithLengths(subject string, payload []byte, subjectLen, payloadLen int32) []byte {
+	const subjectPtr, payloadPtr = 0, 256
+	body := concat(
+		i32Const(subjectPtr), i32Const(subjectLen),
+		i32Const(payloadPtr), i32Const(payloadLen),
+		[]byte{0x10, 0x00}, // call 0 (host_publish)
+	)
+	return wasmModule(
+		[]byte{0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f},
+		"host_publish",
+		body,
+		dataSegment(subjectPtr, []byte(subject)),
+		dataSegment(payloadPtr, payload),
+	)
+}
+
+// httpModule assembles a guest whose run() -> i32 calls env.host_http with
+// request placed at 0 and a respCap-byte response buffer, returning the code.
+func httpModule(request string, respCap int32) []byte {
+	const respPtr, respLenPtr = 8192, 4096
+	body := concat(
+		i32Const(0), i32Const(int32(len(request))),
+		i32Const(respPtr), i32Const(respCap), i32Const(respLenPtr),
+		[]byte{0x10, 0x00}, // call 0 (host_http)
+	)
+	return wasmModule(
+		[]byte{0x60, 0x05, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f},
+		"host_http",
+		body,
+		dataSegment(0, []byte(request)),
+	)
+}
+
+// logModule assembles a guest whose run() -> i32 calls env.host_log(ptr,
+// length) over message and returns 0.
+func logModule(message string, length int32) []byte {
+	body := concat(
+		i32Const(0), i32Const(length),
+		[]byte{0x10, 0x00}, // call 0 (host_log)
+		i32Const(0),
+	)
+	return wasmModule(
+		[]byte{0x60, 0x02, 0x7f, 0x7f, 0x00},
+		"host_log",
+		body,
+		dataSegment(0, []byte(message)),
+	)
+}
+
+// configModule assembles a guest whose run() -> i32 calls
+// env.host_config_get(key, out, outCap) and returns the result.
+func configModule(key string, outCap int32) []byte {
+	body := concat(
+		i32Const(0), i32Const(int32(len(key))),
+		i32Const(1024), i32Const(outCap),
+		[]byte{0x10, 0x00}, // call 0 (host_config_get)
+	)
+	return wasmModule(
+		[]byte{0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f},
+		"host_config_get",
+		body,
+		dataSegment(0, []byte(key)),
+	)
+}
+
+// metricModule assembles a guest whose run() -> i32 calls
+// env.host_metric(name, value, kind) and returns 0.
+func metricModule(metric string, value float64, kind MetricKind) []byte {
+	body := concat(
+		i32Const(0), i32Const(int32(len(metric))),
+		f64Const(value), i32Const(int32(kind)),
+		[]byte{0x10, 0x00}, // call 0 (host_metric)
+		i32Const(0),
+	)
+	return wasmModule(
+		[]byte{0x60, 0x04, 0x7f, 0x7f, 0x7c, 0x7f, 0x00},
+		"host_metric",
+		body,
+		dataSegment(0, []byte(metric)),
+	)
+}
+
+// fdWriteModule assembles a guest whose run() -> i32 writes text to the
+// WASI file descriptor fd with fd_write and returns its errno.
+func fdWriteModule(fd int32, text string) []byte {
+	const iovPtr, nwrittenPtr, textPtr = 16, 32, 64
+	iov := make([]byte, 8)
+	binary.LittleEndian.PutUint32(iov[0:], textPtr)
+	binary.LittleEndian.PutUint32(iov[4:], uint32(len(text)))
+	body := concat(
+		i32Const(fd), i32Const(iovPtr), i32Const(1), i32Const(nwrittenPtr),
+		[]byte{0x10, 0x00}, // call 0 (fd_write)
+	)
+	return wasmModuleImport(
+		"wasi_snapshot_preview1",
+		[]byte{0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f},
+		"fd_write",
+		body,
+		dataSegment(iovPtr, iov),
+		dataSegment(textPtr, []byte(text)),
+	)
+}
+
+// handlersModule assembles a guest exporting one () -> i32 function per
+// name, each calling env.host_publish with its own name as the subject.
+func handlersModule(exports ...string) []byte {
+	var funcs, bodies, exportEntries, data [][]byte
+	for i, export := range exports {
+		ptr := int32(i * 64)
+		body := concat(
+			i32Const(ptr), i32Const(int32(len(export))),
+			i32Const(0), i32Const(0),
+			[]byte{0x10, 0x00}, // call 0 (host_publish)
+		)
+		fn := concat([]byte{0x00}, body, []byte{0x0b})
+		funcs = append(funcs, []byte{0x01})
+		bodies = append(bodies, concat(uleb(uint32(len(fn))), fn))
+		exportEntries = append(exportEntries, concat(name(export), []byte{0x00}, uleb(uint32(i+1))))
+		data = append(data, dataSegment(ptr, []byte(export)))
+	}
+	types := vec(2, []byte{0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f}, []byte{0x60, 0x00, 0x01, 0x7f})
+	imports := vec(1, name("env"), name("host_publish"), []byte{0x00, 0x00})
+	memory := vec(1, []byte{0x00, 0x01})
+	exportsVec := vec(len(exports)+1, append([][]byte{concat(name("memory"), []byte{0x02, 0x00})}, exportEntries...)...)
+	return concat(
+		[]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00},
+		section(1, types),
+		section(2, imports),
+		section(3, vec(len(funcs), funcs...)),
+		section(5, memory),
+		section(7, exportsVec),
+		section(10, vec(len(bodies), bodies...)),
+		section(11, vec(len(data), data...)),
+	)
+}
+
+// wasmModule encodes a module with one imported env function (importType)
+// and one exported "run" function of type () -> i32 plus exported memory.
+func wasmModule(importType []byte, importName string, body []byte, data ...[]byte) []byte {
+	return wasmModuleImport("env", importType, importName, body, data...)
+}
+
+// wasmModuleImport is wasmModule with the function imported from
+// importModule instead of env.
+func wasmModuleImport(importModule string, importType []byte, importName string, body []byte, data ...[]byte) []byte {
+	types := vec(2, importType, []byte{0x60, 0x00, 0x01, 0x7f})
+	imports := vec(1, name(importModule), name(importName), []byte{0x00, 0x00})
+	funcs := vec(1, []byte{0x01})
+	memory := vec(1, []byte{0x00, 0x01})
+	exports := vec(2, name("memory"), []byte{0x02, 0x00}, name("run"), []byte{0x00, 0x01})
+	fn := concat([]byte{0x00}, body, []byte{0x0b})
+	code := vec(1, uleb(uint32(len(fn))), fn)
+	datas := vec(len(data), data...)
+
+	return concat(
+		[]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00},
+		section(1, types),
+		section(2, imports),
+		section(3, funcs),
+		section(5, memory),
+		section(7, exports),
+		section(10, code),
+		section(11, datas),
+	)
+}
+
+func dataSegment(offset int32, b []byte) []byte {
+	return concat([]byte{0x00}, i32Const(offset), []byte{0x0b}, uleb(uint32(len(b))), b)
+}
+
+func section(id byte, content []byte) []byte {
+	return concat([]byte{id}, uleb(uint32(len(content))), content)
+}
+
+func vec(n int, items ...[]byte) []byte {
+	return concat(append([][]byte{uleb(uint32(n))}, items...)...)
+}
+
+func name(s string) []byte {
+	return concat(uleb(uint32(len(s))), []byte(s))
+}
+
+func i32Const(v int32) []byte {
+	return concat([]byte{0x41}, sleb(v))
+}
+
+func f64Const(v float64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+	return concat([]byte{0x44}, b)
+}
+
+func uleb(v uint32) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if v == 0 {
+			return out
+		}
+	}
+}
+
+func sleb(v int32) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		done := (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0)
+		if !done {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if done {
+			return out
+		}
+	}
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
@@ -0,0 +1,183 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Result codes returned to the guest by host_http. Values are part of the v1
+// ABI and must not be renumbered; 0-4 mirror the host_publish codes.
+const (
+	// HTTPOK indicates the response was written to the guest buffer.
+	HTTPOK = 0
+	// HTTPErrNoPermission indicates the manifest lacks the network:http permission.
+	HTTPErrNoPermission = 1
+	// HTTPErrRuntime indicates a host-side failure (memory access, transport error).
+	HTTPErrRuntime = 2
+	// HTTPErrHostNotAllowed indicates the URL host (or a redirect target) is not
+	// listed in capabilities.network.http.allow.
+	HTTPErrHostNotAllowed = 3
+	// HTTPErrTooLarge indicates the request or response body exceeds
+	// HostBindings.MaxHTTPBytes.
+	HTTPErrTooLarge = 4
+	// HTTPErrMethodNotAllowed indicates the method is not listed in
+	// capabilities.network.http.methods.
+	HTTPErrMethodNotAllowed = 5
+	// HTTPErrBadRequest indicates the request document or URL is invalid.
+	HTTPErrBadRequest = 6
+	// HTTPErrBufferTooSmall indicates the response did not fit in the guest
+	// buffer; the required length is written to respLenPtr so the guest can
+	// retry with a larger buffer.
+	HTTPErrBufferTooSmall = 7
+)
+
+// DefaultMaxHTTPBytes bounds host_http request and response bodies when
+// HostBindings.MaxHTTPBytes is unset.
+const DefaultMaxHTTPBytes = 1 << 20
+
+// httpEnvelopeBytes is the allowance for method, URL, and headers in a
+// host_http request document, on top of the (JSON-escaped) body.
+const httpEnvelopeBytes = 64 << 10
+
+// Errors returned by HostBindings.AllowHTTP and HostBindings.DoHTTP to select
+// the result code reported to the guest.
+var (
+	ErrHostNotAllowed   = errors.New("host not allowed")
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	ErrResponseTooLarge = errors.New("response too large")
+)
+
+// HTTPRequest is the JSON document a guest passes to host_http.
+type HTTPRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// HTTPResponse is the JSON document host_http writes back to the guest.
+type HTTPResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// hostHTTP implements env.host_http(reqPtr, reqLen, respPtr, respCap,
+// respLenPtr) -> code.
+func hostHTTP(logger *slog.Logger, binding HostBindings) api.GoModuleFunc {
+	return func(ctx context.Context, mod api.Module, stack []uint64) {
+		if len(stack) < 5 {
+			return
+		}
+		reqPtr := api.DecodeU32(stack[0])
+		reqLen := api.DecodeU32(stack[1])
+		respPtr := api.DecodeU32(stack[2])
+		respCap := api.DecodeU32(stack[3])
+		respLenPtr := api.DecodeU32(stack[4])
+		code := func(c int) { stack[0] = api.EncodeI32(int32(c)) }
+
+		// Bound the raw document before copying it; the decoded body is checked
+		// against MaxHTTPBytes below.
+		if uint64(reqLen) > uint64(binding.MaxHTTPBytes)*2+httpEnvelopeBytes {
+			code(HTTPErrTooLarge)
+			logger.Warn("skill http request too large", slog.Int("request_bytes", int(reqLen)))
+			return
+		}
+		mem := mod.Memory()
+		if mem == nil {
+			code(HTTPErrRuntime)
+			return
+		}
+		raw, ok := mem.Read(reqPtr, reqLen)
+		if !ok {
+			code(HTTPErrRuntime)
+			return
+		}
+		var req HTTPRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			code(HTTPErrBadRequest)
+			logger.Warn("skill http request malformed", slog.String("error", err.Error()))
+			return
+		}
+		if req.Method == "" {
+			req.Method = "GET"
+		}
+		target, err := url.Parse(req.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			code(HTTPErrBadRequest)
+			logger.Warn("skill http url invalid", slog.String("url", req.URL))
+			return
+		}
+		if len(req.Body) > binding.MaxHTTPBytes {
+			code(HTTPErrTooLarge)
+			logger.Warn("skill http request body too large",
+				slog.Int("body_bytes", len(req.Body)),
+				slog.Int("max_bytes", binding.MaxHTTPBytes))
+			return
+		}
+		if err := binding.AllowHTTP(req.Method, target); err != nil {
+			code(httpErrorCode(err))
+			logger.Warn("skill http request blocked",
+				slog.String("method", req.Method),
+				slog.String("host", target.Host),
+				slog.String("error", err.Error()))
+			return
+		}
+
+		resp, err := binding.DoHTTP(ctx, req)
+		if err != nil {
+			code(httpErrorCode(err))
+			logger.Warn("skill http request failed",
+				slog.String("method", req.Method),
+				slog.String("host", target.Host),
+				slog.String("error", err.Error()))
+			return
+		}
+		data, err := json.Marshal(resp)
+		if err != nil {
+			code(HTTPErrRuntime)
+			return
+		}
+		if !mem.WriteUint32Le(respLenPtr, uint32(len(data))) {
+			code(HTTPErrRuntime)
+			return
+		}
+		if uint32(len(data)) > respCap {
+			code(HTTPErrBufferTooSmall)
+			return
+		}
+		if !mem.Write(respPtr, data) {
+			code(HTTPErrRuntime)
+			return
+		}
+		if binding.RecordAudit != nil {
+			binding.RecordAudit(AuditEvent{Type: "skill.http", Data: map[string]any{
+				"method":         req.Method,
+				"host":           target.Host,
+				"status":         resp.Status,
+				"response_bytes": len(resp.Body),
+			}})
+		}
+		code(HTTPOK)
+	}
+}
+
+func httpErrorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrNoPermission):
+		return HTTPErrNoPermission
+	case errors.Is(err, ErrHostNotAllowed):
+		return HTTPErrHostNotAllowed
+	case errors.Is(err, ErrMethodNotAllowed):
+		return HTTPErrMethodNotAllowed
+	case errors.Is(err, ErrResponseTooLarge):
+		return HTTPErrTooLarge
+	default:
+		return HTTPErrRuntime
+	}
+}
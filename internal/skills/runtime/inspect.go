@@ -0,0 +1,74 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// ValidateModule compiles wasm without running it and checks it against the
+// host: each entrypoint (the manifest's entrypoint and handlers, see
+// manifest.RuntimeSpec.Exports) must be an exported function, and every
+// import must name a function the host provides (env.host_* or WASI) with a
+// matching signature. All problems are reported together.
+func ValidateModule(ctx context.Context, wasm []byte, entrypoints ...string) error {
+	rt := wazero.NewRuntime(ctx)
+	defer rt.Close(ctx)
+	if err := instantiateHostModule(ctx, rt, HostBindings{}.ensure()); err != nil {
+		return fmt.Errorf("instantiate host module: %w", err)
+	}
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		return fmt.Errorf("instantiate WASI: %w", err)
+	}
+
+	compiled, err := rt.CompileModule(ctx, wasm)
+	if err != nil {
+		return fmt.Errorf("compile module: %w", err)
+	}
+	defer compiled.Close(ctx)
+
+	var problems []error
+	for _, entrypoint := range entrypoints {
+		if _, ok := compiled.ExportedFunctions()[entrypoint]; !ok {
+			problems = append(problems, fmt.Errorf("entrypoint %q not exported by module", entrypoint))
+		}
+	}
+	for _, imp := range compiled.ImportedFunctions() {
+		moduleName, name, _ := imp.Import()
+		host := rt.Module(moduleName)
+		if host == nil {
+			problems = append(problems, fmt.Errorf("import %s.%s: unknown host module %q", moduleName, name, moduleName))
+			continue
+		}
+		want, ok := host.ExportedFunctionDefinitions()[name]
+		if !ok {
+			problems = append(problems, fmt.Errorf("import %s.%s: not provided by host", moduleName, name))
+			continue
+		}
+		if !slices.Equal(imp.ParamTypes(), want.ParamTypes()) || !slices.Equal(imp.ResultTypes(), want.ResultTypes()) {
+			problems = append(problems, fmt.Errorf("import %s.%s: signature %s does not match host %s",
+				moduleName, name, signature(imp), signature(want)))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+func signature(def api.FunctionDefinition) string {
+	return fmt.Sprintf("%s -> %s", valueTypes(def.ParamTypes()), valueTypes(def.ResultTypes()))
+}
+
+func valueTypes(types []api.ValueType) string {
+	out := "("
+	for i, t := range types {
+		if i > 0 {
+			out += ", "
+		}
+		out += api.ValueTypeName(t)
+	}
+	return out + ")"
+}
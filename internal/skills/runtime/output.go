@@ -0,0 +1,63 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// guestOutput is the WASI stdout or stderr of a skill module. Whatever the
+// guest prints (fmt.Println in TinyGo, for example) is split into lines and
+// logged through the skill's logger under attr ("skill.stdout" or
+// "skill.stderr") instead of interleaving with the runtime's JSON logs.
+// Lines longer than maxLine are logged in maxLine-sized pieces.
+type guestOutput struct {
+	mu      sync.Mutex
+	logger  *slog.Logger
+	level   slog.Level
+	attr    string
+	maxLine int
+	buf     []byte
+}
+
+func newGuestOutput(logger *slog.Logger, level slog.Level, attr string, maxLine int) *guestOutput {
+	return &guestOutput{logger: logger, level: level, attr: attr, maxLine: maxLine}
+}
+
+func (w *guestOutput) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	for len(w.buf) >= w.maxLine {
+		w.emit(w.buf[:w.maxLine])
+		w.buf = w.buf[w.maxLine:]
+	}
+	return len(p), nil
+}
+
+// Flush logs a trailing line the guest left unterminated.
+func (w *guestOutput) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *guestOutput) emit(line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) == 0 {
+		return
+	}
+	w.logger.Log(context.Background(), w.level, "skill output", slog.String(w.attr, string(line)))
+}
@@ -2,11 +2,16 @@ package runtime
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
 	"log/slog"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/loqalabs/loqa-core/internal/skills/manifest"
 	"github.com/tetratelabs/wazero"
@@ -44,9 +49,14 @@ func (r *Runtime) Close(ctx context.Context) error {
 // Skill represents a loaded skill module.
 type Skill struct {
 	Manifest manifest.Manifest
+	// Digest is the hex SHA-256 of the loaded wasm module.
+	Digest   string
 	module   api.Module
 	entry    api.Function
+	handlers map[string]api.Function // runtime.handlers functions by name
 	compiled wazero.CompiledModule
+	stdout   *guestOutput
+	stderr   *guestOutput
 }
 
 // Close releases resources for the skill.
@@ -54,6 +64,7 @@ func (s *Skill) Close(ctx context.Context) error {
 	if s == nil {
 		return nil
 	}
+	s.flushOutput()
 	if s.module != nil {
 		if err := s.module.Close(ctx); err != nil {
 			return err
@@ -79,15 +90,34 @@ func (r *Runtime) Load(ctx context.Context, m manifest.Manifest, env map[string]
 	if err != nil {
 		return nil, fmt.Errorf("read wasm module: %w", err)
 	}
+	sum := sha256.Sum256(wasmBytes)
+	digest := hex.EncodeToString(sum[:])
+	if expected := strings.ToLower(strings.TrimSpace(m.Runtime.ModuleSHA256)); expected != "" && expected != digest {
+		if r.host.Logger != nil {
+			r.host.Logger.Error("skill module digest mismatch",
+				slog.String("module", m.Runtime.Module),
+				slog.String("expected_sha256", expected),
+				slog.String("actual_sha256", digest))
+		}
+		return nil, fmt.Errorf("module sha256 mismatch: expected %s, got %s", expected, digest)
+	}
 	compiled, err := r.rt.CompileModule(ctx, wasmBytes)
 	if err != nil {
 		return nil, fmt.Errorf("compile module: %w", err)
 	}
-	moduleConfig := wazero.NewModuleConfig()
+	logger := r.host.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+	stdout := newGuestOutput(logger, slog.LevelInfo, "skill.stdout", r.host.MaxLogBytes)
+	stderr := newGuestOutput(logger, slog.LevelWarn, "skill.stderr", r.host.MaxLogBytes)
+	moduleConfig := wazero.NewModuleConfig().WithStdout(stdout).WithStderr(stderr)
 	for k, v := range env {
 		moduleConfig = moduleConfig.WithEnv(k, v)
 	}
 	module, err := r.rt.InstantiateModule(ctx, compiled, moduleConfig)
+	stdout.Flush()
+	stderr.Flush()
 	if err != nil {
 		compiled.Close(ctx)
 		return nil, fmt.Errorf("instantiate module: %w", err)
@@ -98,23 +128,55 @@ func (r *Runtime) Load(ctx context.Context, m manifest.Manifest, env map[string]
 		compiled.Close(ctx)
 		return nil, fmt.Errorf("entrypoint %q not found", m.Runtime.Entrypoint)
 	}
+	handlers := make(map[string]api.Function, len(m.Runtime.Handlers))
+	for _, name := range m.Runtime.Exports()[1:] {
+		fn := module.ExportedFunction(name)
+		if fn == nil {
+			module.Close(ctx)
+			compiled.Close(ctx)
+			return nil, fmt.Errorf("handler %q not found", name)
+		}
+		handlers[name] = fn
+	}
 	return &Skill{
 		Manifest: m,
+		Digest:   digest,
 		module:   module,
 		entry:    entry,
+		handlers: handlers,
 		compiled: compiled,
+		stdout:   stdout,
+		stderr:   stderr,
 	}, nil
 }
 
-// Invoke executes the skill entrypoint. Currently no parameters are passed.
-func (s *Skill) Invoke(ctx context.Context) error {
+// InvokeHandler executes the function runtime.handlers maps to an event on
+// subject delivered through pattern, falling back to the entrypoint.
+func (s *Skill) InvokeHandler(ctx context.Context, subject, pattern string) error {
 	if s == nil || s.entry == nil {
 		return fmt.Errorf("skill entrypoint not available")
 	}
-	_, err := s.entry.Call(ctx)
+	if fn := s.handlers[s.Manifest.Runtime.Handler(subject, pattern)]; fn != nil {
+		return s.call(ctx, fn)
+	}
+	return s.call(ctx, s.entry)
+}
+
+func (s *Skill) call(ctx context.Context, fn api.Function) error {
+	_, err := fn.Call(ctx)
+	s.flushOutput()
 	return err
 }
 
+func (s *Skill) flushOutput() {
+	if s.stdout != nil {
+		s.stdout.Flush()
+	}
+	if s.stderr != nil {
+		s.stderr.Flush()
+	}
+}
+
 func instantiateHostModule(ctx context.Context, rt wazero.Runtime, host HostBindings) error {
 	logger := host.Logger
 	if logger == nil {
@@ -124,7 +186,7 @@ func instantiateHostModule(ctx context.Context, rt wazero.Runtime, host HostBind
 	binding := host.ensure()
 
 	builder := rt.NewHostModuleBuilder("env")
-	hostLogFn := api.GoModuleFunc(func(_ context.Context, mod api.Module, stack []uint64) {
+	hostLogFn := recoverHost("host_log", logger, binding, false, func(_ context.Context, mod api.Module, stack []uint64) {
 		if len(stack) < 2 {
 			return
 		}
@@ -133,6 +195,12 @@ func instantiateHostModule(ctx context.Context, rt wazero.Runtime, host HostBind
 		if length == 0 {
 			return
 		}
+		if length > uint32(binding.MaxLogBytes) {
+			logger.Warn("skill log line too large, dropping",
+				slog.Int("log_bytes", int(length)),
+				slog.Int("max_bytes", binding.MaxLogBytes))
+			return
+		}
 		mem := mod.Memory()
 		if mem == nil {
 			printfLogger.Printf("host_log: module has no memory (ptr=%d len=%d)", ptr, length)
@@ -154,7 +222,7 @@ func instantiateHostModule(ctx context.Context, rt wazero.Runtime, host HostBind
 		WithName("host_log").
 		Export("host_log")
 
-	hostPublishFn := api.GoModuleFunc(func(_ context.Context, mod api.Module, stack []uint64) {
+	hostPublishFn := recoverHost("host_publish", logger, binding, true, func(_ context.Context, mod api.Module, stack []uint64) {
 		if len(stack) < 4 {
 			return
 		}
@@ -163,6 +231,11 @@ func instantiateHostModule(ctx context.Context, rt wazero.Runtime, host HostBind
 		payloadPtr := api.DecodeU32(stack[2])
 		payloadLen := api.DecodeU32(stack[3])
 
+		if subjectLen > MaxSubjectBytes {
+			stack[0] = api.EncodeI32(int32(PublishErrPayloadTooLarge))
+			logger.Warn("skill publish subject too large", slog.Int("subject_bytes", int(subjectLen)))
+			return
+		}
 		mem := mod.Memory()
 		if mem == nil {
 			stack[0] = api.EncodeI32(int32(PublishErrRuntime))
@@ -174,9 +247,19 @@ func instantiateHostModule(ctx context.Context, rt wazero.Runtime, host HostBind
 			return
 		}
 		subject := string(subjectBytes)
+		// The size check runs before AllowPublish so a rejected payload
+		// does not spend a publish rate-limit token.
+		if payloadLen > uint32(binding.MaxPayloadBytes) {
+			stack[0] = api.EncodeI32(int32(PublishErrPayloadTooLarge))
+			logger.Warn("skill publish payload too large",
+				slog.String("subject", subject),
+				slog.Int("payload_bytes", int(payloadLen)),
+				slog.Int("max_bytes", binding.MaxPayloadBytes))
+			return
+		}
 		if binding.AllowPublish != nil {
 			if err := binding.AllowPublish(subject); err != nil {
-				stack[0] = api.EncodeI32(int32(PublishErrNotAllowed))
+				stack[0] = api.EncodeI32(int32(publishDeniedCode(err)))
 				logger.Warn("skill publish blocked", slog.String("subject", subject), slog.String("error", err.Error()))
 				return
 			}
@@ -213,33 +296,228 @@ func instantiateHostModule(ctx context.Context, rt wazero.Runtime, host HostBind
 		WithResultNames("code").
 		Export("host_publish")
 
+	hostMetricFn := recoverHost("host_metric", logger, binding, false, func(_ context.Context, mod api.Module, stack []uint64) {
+		if len(stack) < 4 {
+			return
+		}
+		namePtr := api.DecodeU32(stack[0])
+		nameLen := api.DecodeU32(stack[1])
+		value := api.DecodeF64(stack[2])
+		kind := MetricKind(api.DecodeI32(stack[3]))
+		if nameLen > MaxSubjectBytes {
+			logger.Warn("skill metric name too large, dropping", slog.Int("name_bytes", int(nameLen)))
+			return
+		}
+
+		mem := mod.Memory()
+		if mem == nil {
+			printfLogger.Printf("host_metric: module has no memory (ptr=%d len=%d)", namePtr, nameLen)
+			return
+		}
+		nameBytes, ok := mem.Read(namePtr, nameLen)
+		if !ok {
+			printfLogger.Printf("host_metric: unable to read memory (ptr=%d len=%d)", namePtr, nameLen)
+			return
+		}
+		name := string(nameBytes)
+		if err := binding.RecordMetric(name, value, kind); err != nil {
+			logger.Warn("skill metric rejected", slog.String("metric", name), slog.String("error", err.Error()))
+		}
+	})
+	builder.NewFunctionBuilder().
+		WithGoModuleFunction(hostMetricFn, []api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeF64, api.ValueTypeI32}, nil).
+		WithName("host_metric").
+		Export("host_metric")
+
+	builder.NewFunctionBuilder().
+		WithGoModuleFunction(recoverHost("host_http", logger, binding, true, hostHTTP(logger, binding)),
+			[]api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32},
+			[]api.ValueType{api.ValueTypeI32}).
+		WithName("host_http").
+		WithResultNames("code").
+		Export("host_http")
+
+	builder.NewFunctionBuilder().
+		WithGoModuleFunction(recoverHost("host_config_get", logger, binding, false, hostConfigGet(logger, binding)),
+			[]api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32},
+			[]api.ValueType{api.ValueTypeI32}).
+		WithName("host_config_get").
+		WithResultNames("len").
+		Export("host_config_get")
+
+	builder.NewFunctionBuilder().
+		WithGoFunction(api.GoFunc(func(ctx context.Context, stack []uint64) {
+			stack[0] = api.EncodeI64(RemainingMS(ctx))
+		}), nil, []api.ValueType{api.ValueTypeI64}).
+		WithName("host_deadline").
+		WithResultNames("ms").
+		Export("host_deadline")
+
 	_, err := builder.Instantiate(ctx)
 	return err
 }
 
+// RemainingMS returns the milliseconds left before ctx's deadline, 0 once
+// it has passed, or -1 when ctx has no deadline.
+func RemainingMS(ctx context.Context) int64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return -1
+	}
+	return max(time.Until(deadline).Milliseconds(), 0)
+}
+
+// recoverHost wraps a host function so a panicking binding is logged,
+// audited as skill.host.panic, and reported to the guest as
+// PublishErrRuntime (when the function returns a code) instead of unwinding
+// through the wasm call.
+func recoverHost(name string, logger *slog.Logger, binding HostBindings, hasResult bool, fn api.GoModuleFunc) api.GoModuleFunc {
+	return func(ctx context.Context, mod api.Module, stack []uint64) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			logger.Error("skill host function panicked", slog.String("function", name), slog.String("panic", fmt.Sprint(r)))
+			if hasResult && len(stack) > 0 {
+				stack[0] = api.EncodeI32(int32(PublishErrRuntime))
+			}
+			if binding.RecordAudit != nil {
+				func() {
+					defer func() { _ = recover() }()
+					binding.RecordAudit(AuditEvent{Type: "skill.host.panic", Data: map[string]any{
+						"function": name,
+						"panic":    fmt.Sprint(r),
+					}})
+				}()
+			}
+		}()
+		fn(ctx, mod, stack)
+	}
+}
+
+// Result codes returned to the guest by host_publish. Values are part of the
+// v1 ABI and must not be renumbered.
 const (
-	PublishOK            = 0
-	PublishErrNotAllowed = 1
-	PublishErrRuntime    = 2
+	// PublishOK indicates the payload was handed to the bus.
+	PublishOK = 0
+	// PublishErrNoPermission indicates the manifest lacks the bus:publish permission.
+	PublishErrNoPermission = 1
+	// PublishErrRuntime indicates a host-side failure (memory access, bus error).
+	PublishErrRuntime = 2
+	// PublishErrSubjectUndeclared indicates the subject is not listed in capabilities.bus.publish.
+	PublishErrSubjectUndeclared = 3
+	// PublishErrPayloadTooLarge indicates the payload exceeds HostBindings.MaxPayloadBytes
+	// or the subject exceeds MaxSubjectBytes.
+	PublishErrPayloadTooLarge = 4
+	// PublishErrThrottled indicates the skill exceeded its publish rate and
+	// the message was dropped.
+	PublishErrThrottled = 5
 )
 
+// DefaultMaxPublishBytes bounds host_publish payloads when HostBindings.MaxPayloadBytes
+// is unset. It matches the default NATS max_payload.
+const DefaultMaxPublishBytes = 1 << 20
+
+// DefaultMaxLogBytes bounds host_log lines when HostBindings.MaxLogBytes is unset.
+const DefaultMaxLogBytes = 64 << 10
+
+// MaxSubjectBytes bounds the subject passed to host_publish and the metric
+// name passed to host_metric, so guest-supplied lengths are checked before
+// any guest memory is copied.
+const MaxSubjectBytes = 1024
+
+// Errors returned by HostBindings.AllowPublish to select the result code
+// reported to the guest. Other errors are reported as PublishErrNoPermission.
+var (
+	ErrNoPermission      = errors.New("missing permission")
+	ErrSubjectUndeclared = errors.New("subject not declared")
+	ErrPublishThrottled  = errors.New("publish rate exceeded")
+)
+
+// MetricKind selects the instrument backing a host_metric call.
+type MetricKind int32
+
+// Metric kinds accepted by host_metric. Values are part of the v1 ABI.
+const (
+	MetricCounter   MetricKind = 0
+	MetricGauge     MetricKind = 1
+	MetricHistogram MetricKind = 2
+)
+
+func (k MetricKind) String() string {
+	switch k {
+	case MetricCounter:
+		return "counter"
+	case MetricGauge:
+		return "gauge"
+	case MetricHistogram:
+		return "histogram"
+	default:
+		return fmt.Sprintf("unknown(%d)", int32(k))
+	}
+}
+
 type HostBindings struct {
-	Logger       *slog.Logger
-	AllowPublish func(subject string) error
-	Publish      func(subject string, payload []byte) error
-	RecordAudit  func(event AuditEvent)
+	Logger          *slog.Logger
+	AllowPublish    func(subject string) error
+	Publish         func(subject string, payload []byte) error
+	RecordAudit     func(event AuditEvent)
+	RecordMetric    func(name string, value float64, kind MetricKind) error
+	MaxPayloadBytes int
+	MaxLogBytes     int
+	// AllowHTTP vets a host_http request before DoHTTP performs it.
+	AllowHTTP    func(method string, target *url.URL) error
+	DoHTTP       func(ctx context.Context, req HTTPRequest) (HTTPResponse, error)
+	MaxHTTPBytes int
+	// GetConfig resolves a host_config_get key, reporting whether the value
+	// is secret so it can be redacted from the audit trail.
+	GetConfig func(key string) (value string, secret bool, err error)
 }
 
 func (h HostBindings) ensure() HostBindings {
 	if h.AllowPublish == nil {
-		h.AllowPublish = func(string) error { return errors.New("publish disallowed") }
+		h.AllowPublish = func(string) error { return fmt.Errorf("%w: publish disallowed", ErrNoPermission) }
+	}
+	if h.RecordMetric == nil {
+		h.RecordMetric = func(string, float64, MetricKind) error { return errors.New("metrics unsupported") }
+	}
+	if h.MaxPayloadBytes <= 0 {
+		h.MaxPayloadBytes = DefaultMaxPublishBytes
+	}
+	if h.MaxLogBytes <= 0 {
+		h.MaxLogBytes = DefaultMaxLogBytes
+	}
+	if h.AllowHTTP == nil {
+		h.AllowHTTP = func(string, *url.URL) error { return fmt.Errorf("%w: http disallowed", ErrNoPermission) }
+	}
+	if h.DoHTTP == nil {
+		h.DoHTTP = func(context.Context, HTTPRequest) (HTTPResponse, error) {
+			return HTTPResponse{}, errors.New("http unsupported")
+		}
+	}
+	if h.MaxHTTPBytes <= 0 {
+		h.MaxHTTPBytes = DefaultMaxHTTPBytes
 	}
 	if h.Publish == nil {
 		h.Publish = func(string, []byte) error { return errors.New("publish unsupported") }
 	}
+	if h.GetConfig == nil {
+		h.GetConfig = func(string) (string, bool, error) { return "", false, ErrConfigNotFound }
+	}
 	return h
 }
 
+func publishDeniedCode(err error) int {
+	switch {
+	case errors.Is(err, ErrSubjectUndeclared):
+		return PublishErrSubjectUndeclared
+	case errors.Is(err, ErrPublishThrottled):
+		return PublishErrThrottled
+	}
+	return PublishErrNoPermission
+}
+
 type AuditEvent struct {
 	Type string
 	Data map[string]any
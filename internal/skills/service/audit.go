@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/eventstore"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	auditStream     = "LOQA_SKILL_AUDIT"
+	auditConsumer   = "skills-audit-writer"
+	auditFetchBatch = 32
+)
+
+// auditRecord is the JetStream envelope for an audit event waiting to be
+// persisted.
+type auditRecord struct {
+	SessionID string          `json:"session_id"`
+	ActorID   string          `json:"actor_id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	Privacy   string          `json:"privacy_scope"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// auditStreamName returns the audit stream for this runtime's subject
+// namespace; stream names are global to the account, so namespaced runtimes
+// each get their own.
+func (s *Service) auditStreamName() string {
+	ns := strings.TrimSuffix(s.subjects.Prefix, ".")
+	if ns == "" {
+		return auditStream
+	}
+	return auditStream + "_" + strings.ToUpper(strings.ReplaceAll(ns, ".", "_"))
+}
+
+// startAuditStream ensures the audit stream and its durable consumer exist and
+// starts the goroutine that drains it into the event store.
+func (s *Service) startAuditStream() error {
+	js := s.bus.JetStream()
+	if js == nil {
+		return errors.New("skills.audit_mode jetstream requires a JetStream context")
+	}
+	stream := s.auditStreamName()
+	if _, err := js.StreamInfo(stream); err != nil {
+		if !errors.Is(err, nats.ErrStreamNotFound) {
+			return fmt.Errorf("lookup audit stream: %w", err)
+		}
+		if _, err := js.AddStream(&nats.StreamConfig{
+			Name:     stream,
+			Subjects: []string{s.subjects.SkillAuditPrefix + ".>"},
+			Storage:  nats.FileStorage,
+		}); err != nil {
+			return fmt.Errorf("create audit stream: %w", err)
+		}
+	}
+	if _, err := js.AddConsumer(stream, &nats.ConsumerConfig{
+		Durable:   auditConsumer,
+		AckPolicy: nats.AckExplicitPolicy,
+	}); err != nil {
+		return fmt.Errorf("create audit consumer: %w", err)
+	}
+	// Bind to the consumer we created so unsubscribing does not delete it and
+	// pending events survive restarts.
+	sub, err := js.PullSubscribe("", auditConsumer, nats.Bind(stream, auditConsumer))
+	if err != nil {
+		return fmt.Errorf("subscribe audit consumer: %w", err)
+	}
+	s.wg.Add(1)
+	go s.drainAudit(sub)
+	return nil
+}
+
+func (s *Service) drainAudit(sub *nats.Subscription) {
+	defer s.wg.Done()
+	defer func() { _ = sub.Unsubscribe() }()
+	for s.ctx.Err() == nil {
+		msgs, err := sub.Fetch(auditFetchBatch, nats.MaxWait(time.Second))
+		if err != nil {
+			if errors.Is(err, nats.ErrTimeout) || s.ctx.Err() != nil {
+				continue
+			}
+			s.log.Warn("audit stream fetch failed", slog.String("error", err.Error()))
+			select {
+			case <-s.ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for _, msg := range msgs {
+			s.persistAudit(msg)
+		}
+	}
+}
+
+func (s *Service) persistAudit(msg *nats.Msg) {
+	var rec auditRecord
+	if err := json.Unmarshal(msg.Data, &rec); err != nil {
+		s.log.Warn("dropping malformed audit record", slog.String("error", err.Error()))
+		_ = msg.Term()
+		return
+	}
+	evt := eventstore.Event{
+		SessionID: rec.SessionID,
+		ActorID:   rec.ActorID,
+		Type:      rec.Type,
+		Payload:   rec.Payload,
+		Privacy:   rec.Privacy,
+		CreatedAt: rec.CreatedAt,
+	}
+	if err := s.writeAudit(evt); err != nil {
+		s.log.Warn("failed to persist audit event", slog.String("error", err.Error()))
+		_ = msg.Nak()
+		return
+	}
+	_ = msg.Ack()
+}
+
+// enqueueAudit publishes evt to the audit stream. It reports false when the
+// publish fails so the caller can fall back to a synchronous write.
+func (s *Service) enqueueAudit(evt eventstore.Event) bool {
+	data, err := json.Marshal(auditRecord{
+		SessionID: evt.SessionID,
+		ActorID:   evt.ActorID,
+		Type:      evt.Type,
+		Payload:   evt.Payload,
+		Privacy:   evt.Privacy,
+		CreatedAt: evt.CreatedAt,
+	})
+	if err != nil {
+		s.log.Warn("failed to marshal audit record", slog.String("error", err.Error()))
+		return false
+	}
+	if _, err := s.bus.JetStream().Publish(s.subjects.SkillAuditPrefix+"."+evt.ActorID, data); err != nil {
+		s.log.Warn("failed to enqueue audit event; writing synchronously", slog.String("error", err.Error()))
+		return false
+	}
+	return true
+}
+
+// writeAudit persists evt and its session row to the event store.
+func (s *Service) writeAudit(evt eventstore.Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_ = s.store.AppendSession(ctx, evt.SessionID, evt.ActorID, evt.Privacy)
+	return s.store.AppendEvent(ctx, evt)
+}
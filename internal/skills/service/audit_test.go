@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	manifestpkg "github.com/loqalabs/loqa-core/internal/skills/manifest"
+	skillrt "github.com/loqalabs/loqa-core/internal/skills/runtime"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+func TestJetStreamAuditPersistsAsynchronously(t *testing.T) {
+	client := testutil.Connect(t, testutil.StartServer(t, &server.Options{JetStream: true, StoreDir: t.TempDir()}), config.BusConfig{})
+	log := newTestService(t, config.SkillsConfig{}).log
+
+	store := openTestStore(t)
+
+	cfg := config.SkillsConfig{Enabled: true, Directory: t.TempDir(), Concurrency: 1, AuditPrivacy: "internal", AuditMode: "jetstream"}
+	svc, err := New(context.Background(), cfg, "0.1.0", client, protocol.DefaultSubjects(), nil, store, log)
+	if err != nil {
+		t.Fatalf("create service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	b := &binding{
+		manifest:  manifestpkg.Manifest{Metadata: manifestpkg.Metadata{Name: "timer"}},
+		sessionID: "skill-timer",
+	}
+	svc.appendAudit(b, "inv-1", skillrt.AuditEvent{Type: "skill.invoke", Data: map[string]any{"subject": "skill.timer.set"}})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		events, err := store.ListSessionEvents(context.Background(), "skill-timer", 10)
+		if err != nil {
+			t.Fatalf("list events: %v", err)
+		}
+		if len(events) == 1 {
+			if events[0].Type != "skill.invoke" || events[0].ActorID != "timer" {
+				t.Fatalf("unexpected event %+v", events[0])
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for audit event to be persisted")
+}
+
+func TestAuditPrivacyFollowsManifestOverrides(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{AuditPrivacy: "internal"})
+	svc.store = openTestStore(t)
+
+	plain := &binding{manifest: manifestpkg.Manifest{Metadata: manifestpkg.Metadata{Name: "plain"}}, sessionID: "skill-plain"}
+	strict := &binding{
+		manifest: manifestpkg.Manifest{
+			Metadata: manifestpkg.Metadata{Name: "diary"},
+			Audit:    manifestpkg.AuditSpec{PrivacyScope: "private", PublishPrivacyScope: "sensitive"},
+		},
+		sessionID: "skill-diary",
+	}
+	for _, b := range []*binding{plain, strict} {
+		svc.appendAudit(b, "inv-1", skillrt.AuditEvent{Type: "skill.invoke.start"})
+		svc.appendAudit(b, "inv-1", skillrt.AuditEvent{Type: "skill.publish", Data: map[string]any{"subject": "tts.request"}})
+	}
+
+	want := map[string][]string{
+		"skill-plain": {"internal", "internal"},
+		"skill-diary": {"private", "sensitive"},
+	}
+	for session, scopes := range want {
+		events, err := svc.store.ListSessionEvents(context.Background(), session, 10)
+		if err != nil {
+			t.Fatalf("list events: %v", err)
+		}
+		if len(events) != len(scopes) {
+			t.Fatalf("%s: expected %d events, got %+v", session, len(scopes), events)
+		}
+		for i, evt := range events {
+			if evt.Privacy != scopes[i] {
+				t.Fatalf("%s: expected %s event scoped %q, got %q", session, evt.Type, scopes[i], evt.Privacy)
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"fmt"
+
+	skillrt "github.com/loqalabs/loqa-core/internal/skills/runtime"
+)
+
+// configLookup resolves host_config_get keys for b: only keys declared in
+// the manifest's config section are visible, operator values from
+// skills.config.<skill> override manifest defaults, and secret keys require
+// the config:read permission.
+func (s *Service) configLookup(b *binding) func(key string) (string, bool, error) {
+	values := s.cfg.Config[b.manifest.Metadata.Name]
+	return func(key string) (string, bool, error) {
+		for _, spec := range b.manifest.Config {
+			if spec.Key != key {
+				continue
+			}
+			if spec.Secret {
+				if _, ok := b.permissions["config:read"]; !ok {
+					return "", true, fmt.Errorf("%w config:read", skillrt.ErrNoPermission)
+				}
+			}
+			if value, ok := values[key]; ok {
+				return value, spec.Secret, nil
+			}
+			if spec.Default != "" {
+				return spec.Default, spec.Secret, nil
+			}
+			break
+		}
+		return "", false, fmt.Errorf("%w: %s", skillrt.ErrConfigNotFound, key)
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// maxDedupKeys bounds the idempotency cache; the oldest keys are evicted
+// first, even inside the window, once it is full.
+const maxDedupKeys = 4096
+
+// idempotencyKey identifies one delivery of msg to a skill by the
+// publisher's Nats-Msg-Id header. Without one, the subject and payload are
+// hashed when byPayload is set, so identical events are treated as the same
+// event; otherwise the key is empty and the event is never deduplicated.
+func idempotencyKey(skill, subject string, msg *nats.Msg, byPayload bool) string {
+	if id := msg.Header.Get(nats.MsgIdHdr); id != "" {
+		return skill + "\x00id\x00" + id
+	}
+	if !byPayload {
+		return ""
+	}
+	sum := sha256.New()
+	sum.Write([]byte(subject))
+	sum.Write([]byte{0})
+	sum.Write(msg.Data)
+	return skill + "\x00sha256\x00" + hex.EncodeToString(sum.Sum(nil))
+}
+
+// dedupCache remembers recently processed idempotency keys for window. A nil
+// cache remembers nothing.
+type dedupCache struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+	order  []dedupEntry
+	clock  func() time.Time
+}
+
+type dedupEntry struct {
+	key string
+	at  time.Time
+}
+
+func newDedupCache(window time.Duration) *dedupCache {
+	if window <= 0 {
+		return nil
+	}
+	return &dedupCache{window: window, seen: make(map[string]time.Time), clock: time.Now}
+}
+
+// claim records key and reports whether it was new. A key claimed within
+// the window is a duplicate; the empty key is always new.
+func (c *dedupCache) claim(key string) bool {
+	if c == nil || key == "" {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock()
+	c.expire(now)
+	if _, ok := c.seen[key]; ok {
+		return false
+	}
+	c.seen[key] = now
+	c.order = append(c.order, dedupEntry{key: key, at: now})
+	return true
+}
+
+// release forgets key so a redelivery of an event that failed is processed
+// again.
+func (c *dedupCache) release(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.seen, key)
+}
+
+// expire drops keys older than the window and, past maxDedupKeys, the
+// oldest remaining ones. Released keys are skipped over as they surface.
+func (c *dedupCache) expire(now time.Time) {
+	drop := 0
+	for _, entry := range c.order {
+		at, ok := c.seen[entry.key]
+		if ok && at.Equal(entry.at) && now.Sub(at) < c.window && len(c.seen) < maxDedupKeys {
+			break
+		}
+		if ok && at.Equal(entry.at) {
+			delete(c.seen, entry.key)
+		}
+		drop++
+	}
+	c.order = c.order[drop:]
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	manifestpkg "github.com/loqalabs/loqa-core/internal/skills/manifest"
+	skillrt "github.com/loqalabs/loqa-core/internal/skills/runtime"
+)
+
+// defaultHTTPTimeout bounds a single host_http call when
+// skills.http_timeout_ms is unset.
+const defaultHTTPTimeout = 10 * time.Second
+
+// maxHTTPRedirects mirrors net/http's default redirect limit.
+const maxHTTPRedirects = 10
+
+// allowHTTP enforces the network:http permission and the manifest's host and
+// method allowlist before any request is made.
+func allowHTTP(b *binding) func(method string, target *url.URL) error {
+	spec := b.manifest.Capabilities.Network.HTTP
+	return func(method string, target *url.URL) error {
+		if _, ok := b.permissions["network:http"]; !ok {
+			return fmt.Errorf("%w network:http", skillrt.ErrNoPermission)
+		}
+		if !spec.AllowsHost(target.Host) {
+			return fmt.Errorf("%w: %s", skillrt.ErrHostNotAllowed, target.Host)
+		}
+		if !spec.AllowsMethod(method) {
+			return fmt.Errorf("%w: %s", skillrt.ErrMethodNotAllowed, method)
+		}
+		return nil
+	}
+}
+
+// newHTTPDoer performs host_http requests. Redirects are re-checked against
+// the allowlist and response bodies are capped at maxBytes.
+func newHTTPDoer(spec manifestpkg.HTTPSpec, timeout time.Duration, maxBytes int) func(context.Context, skillrt.HTTPRequest) (skillrt.HTTPResponse, error) {
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxHTTPRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxHTTPRedirects)
+			}
+			if !spec.AllowsHost(req.URL.Host) {
+				return fmt.Errorf("%w: redirect to %s", skillrt.ErrHostNotAllowed, req.URL.Host)
+			}
+			return nil
+		},
+	}
+	return func(ctx context.Context, r skillrt.HTTPRequest) (skillrt.HTTPResponse, error) {
+		var body io.Reader
+		if r.Body != "" {
+			body = strings.NewReader(r.Body)
+		}
+		req, err := http.NewRequestWithContext(ctx, strings.ToUpper(r.Method), r.URL, body)
+		if err != nil {
+			return skillrt.HTTPResponse{}, err
+		}
+		for k, v := range r.Headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return skillrt.HTTPResponse{}, err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+		if err != nil {
+			return skillrt.HTTPResponse{}, err
+		}
+		if len(data) > maxBytes {
+			return skillrt.HTTPResponse{}, fmt.Errorf("%w: more than %d bytes", skillrt.ErrResponseTooLarge, maxBytes)
+		}
+		headers := make(map[string]string, len(resp.Header))
+		for k := range resp.Header {
+			headers[k] = resp.Header.Get(k)
+		}
+		return skillrt.HTTPResponse{Status: resp.StatusCode, Headers: headers, Body: string(data)}, nil
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	manifestpkg "github.com/loqalabs/loqa-core/internal/skills/manifest"
+	skillrt "github.com/loqalabs/loqa-core/internal/skills/runtime"
+)
+
+func TestAllowHTTPEnforcesPermissionAndAllowlist(t *testing.T) {
+	b := &binding{permissions: map[string]struct{}{}}
+	b.manifest.Capabilities.Network.HTTP = manifestpkg.HTTPSpec{Allow: []string{"ha.local:8123"}, Methods: []string{"GET", "POST"}}
+	allow := allowHTTP(b)
+	target, _ := url.Parse("http://ha.local:8123/api/services/light/turn_on")
+
+	if err := allow("POST", target); !errors.Is(err, skillrt.ErrNoPermission) {
+		t.Fatalf("expected missing network:http to be rejected, got %v", err)
+	}
+	b.permissions["network:http"] = struct{}{}
+	if err := allow("POST", target); err != nil {
+		t.Fatalf("expected allowed request: %v", err)
+	}
+	if err := allow("DELETE", target); !errors.Is(err, skillrt.ErrMethodNotAllowed) {
+		t.Fatalf("expected DELETE to be rejected, got %v", err)
+	}
+	other, _ := url.Parse("http://ha.local:9999/")
+	if err := allow("GET", other); !errors.Is(err, skillrt.ErrHostNotAllowed) {
+		t.Fatalf("expected other port to be rejected, got %v", err)
+	}
+}
+
+func TestHTTPDoerRejectsRedirectOutsideAllowlistAndLargeBodies(t *testing.T) {
+	hits := 0
+	outside := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits++ }))
+	defer outside.Close()
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			// Same IP, different port, so it is outside the allowlist.
+			http.Redirect(w, r, outside.URL, http.StatusFound)
+		case "/big":
+			_, _ = w.Write([]byte(strings.Repeat("x", 64)))
+		default:
+			w.Header().Set("X-State", "on")
+			_, _ = w.Write([]byte(r.Method + " " + r.Header.Get("Authorization")))
+		}
+	}))
+	defer allowed.Close()
+
+	host := strings.TrimPrefix(allowed.URL, "http://")
+	do := newHTTPDoer(manifestpkg.HTTPSpec{Allow: []string{host}}, 0, 32)
+
+	resp, err := do(context.Background(), skillrt.HTTPRequest{Method: "post", URL: allowed.URL + "/ok", Headers: map[string]string{"Authorization": "Bearer t"}})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.Status != http.StatusOK || resp.Body != "POST Bearer t" || resp.Headers["X-State"] != "on" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if _, err := do(context.Background(), skillrt.HTTPRequest{Method: "GET", URL: allowed.URL + "/redirect"}); !errors.Is(err, skillrt.ErrHostNotAllowed) {
+		t.Fatalf("expected redirect outside allowlist to fail, got %v", err)
+	}
+	if hits != 0 {
+		t.Fatal("redirect target must not be contacted")
+	}
+	if _, err := do(context.Background(), skillrt.HTTPRequest{Method: "GET", URL: allowed.URL + "/big"}); !errors.Is(err, skillrt.ErrResponseTooLarge) {
+		t.Fatalf("expected oversized response to fail, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	skillrt "github.com/loqalabs/loqa-core/internal/skills/runtime"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.]{0,63}$`)
+
+// skillMetrics lazily creates OTel instruments for metrics emitted by skills.
+// Instrument names are namespaced as loqa.skills.<skill>.<name>.
+type skillMetrics struct {
+	meter metric.Meter
+
+	mu          sync.Mutex
+	counters    map[string]metric.Float64Counter
+	gauges      map[string]metric.Float64Gauge
+	histograms  map[string]metric.Float64Histogram
+	instruments map[string]skillrt.MetricKind
+}
+
+func newSkillMetrics() *skillMetrics {
+	return &skillMetrics{
+		meter:       otel.Meter("github.com/loqalabs/loqa-core/skills"),
+		counters:    make(map[string]metric.Float64Counter),
+		gauges:      make(map[string]metric.Float64Gauge),
+		histograms:  make(map[string]metric.Float64Histogram),
+		instruments: make(map[string]skillrt.MetricKind),
+	}
+}
+
+func (m *skillMetrics) record(skill, name string, value float64, kind skillrt.MetricKind) error {
+	if !metricNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid metric name %q", name)
+	}
+	full := fmt.Sprintf("loqa.skills.%s.%s", skill, name)
+	attrs := metric.WithAttributes(attribute.String("skill", skill))
+	ctx := context.Background()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.instruments[full]; ok && existing != kind {
+		return fmt.Errorf("metric %s already registered as %s", name, existing)
+	}
+
+	switch kind {
+	case skillrt.MetricCounter:
+		if value < 0 {
+			return fmt.Errorf("counter %s cannot decrease", name)
+		}
+		counter, ok := m.counters[full]
+		if !ok {
+			c, err := m.meter.Float64Counter(full)
+			if err != nil {
+				return err
+			}
+			counter = c
+			m.counters[full] = c
+		}
+		counter.Add(ctx, value, attrs)
+	case skillrt.MetricGauge:
+		gauge, ok := m.gauges[full]
+		if !ok {
+			g, err := m.meter.Float64Gauge(full)
+			if err != nil {
+				return err
+			}
+			gauge = g
+			m.gauges[full] = g
+		}
+		gauge.Record(ctx, value, attrs)
+	case skillrt.MetricHistogram:
+		hist, ok := m.histograms[full]
+		if !ok {
+			h, err := m.meter.Float64Histogram(full)
+			if err != nil {
+				return err
+			}
+			hist = h
+			m.histograms[full] = h
+		}
+		hist.Record(ctx, value, attrs)
+	default:
+		return fmt.Errorf("unsupported metric kind %s", kind)
+	}
+	m.instruments[full] = kind
+	return nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// publishLimiter is a token bucket bounding how fast one skill may publish.
+// It holds up to rate tokens (at least one), so a skill can burst a second's
+// worth of messages before being throttled. A nil limiter allows everything.
+type publishLimiter struct {
+	mu         sync.Mutex
+	rate       float64
+	burst      float64
+	tokens     float64
+	last       time.Time
+	throttling bool
+	clock      func() time.Time
+}
+
+func newPublishLimiter(rate float64) *publishLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &publishLimiter{rate: rate, burst: burst, tokens: burst, clock: time.Now}
+}
+
+// allow takes a token if one is available. first reports whether a denied
+// call starts a new throttling streak, so callers can audit each flood once
+// instead of once per dropped message.
+func (l *publishLimiter) allow() (ok, first bool) {
+	if l == nil {
+		return true, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		l.throttling = false
+		return true, false
+	}
+	first = !l.throttling
+	l.throttling = true
+	return false, first
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,50 +11,87 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/loqalabs/loqa-core/internal/bus"
+	"github.com/loqalabs/loqa-core/internal/capability"
 	"github.com/loqalabs/loqa-core/internal/config"
 	"github.com/loqalabs/loqa-core/internal/eventstore"
+	"github.com/loqalabs/loqa-core/internal/logging"
+	"github.com/loqalabs/loqa-core/internal/protocol"
 	manifestpkg "github.com/loqalabs/loqa-core/internal/skills/manifest"
+	"github.com/loqalabs/loqa-core/internal/skills/modcache"
 	skillrt "github.com/loqalabs/loqa-core/internal/skills/runtime"
 	"github.com/nats-io/nats.go"
 )
 
+// errSkillConflict aborts skill discovery when two manifests share a name and
+// the conflict policy is "error".
+var errSkillConflict = errors.New("duplicate skill name")
+
+// defaultMaxEventBytes caps event payloads handed to skills when
+// skills.max_event_payload_bytes is unset.
+const defaultMaxEventBytes = 256 << 10
+
 // Service manages lifecycle and execution of WASM skills.
 type Service struct {
-	cfg    config.SkillsConfig
-	log    *slog.Logger
-	bus    *bus.Client
-	store  *eventstore.Store
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
-	sema   chan struct{}
+	cfg      config.SkillsConfig
+	log      *slog.Logger
+	bus      *bus.Client
+	subjects protocol.Subjects
+	registry *capability.Registry
+	store    *eventstore.Store
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	sema     chan struct{}
+
+	hostVersion string
+	metrics     *skillMetrics
+	activity    *activity
+	cache       *modcache.Cache
+	// dedup skips events redelivered within skills.dedup_window_ms.
+	dedup *dedupCache
+
+	// runSkill overrides runModule in tests.
+	runSkill func(ctx context.Context, log *slog.Logger, binding *binding, subject string, env map[string]string, invocationID string, attempt int) error
 
 	mu     sync.RWMutex
 	skills map[string]*binding
 	subs   []*nats.Subscription
+	// deferred holds skills whose requires were unmet at load; they are
+	// registered or skipped once the capability registry has converged.
+	deferred []*binding
 
 	healthy bool
 }
 
 type binding struct {
-	manifest      manifestpkg.Manifest
-	manifestPath  string
-	modulePath    string
-	directory     string
-	publishSet    map[string]struct{}
-	subscribeList []string
-	permissions   map[string]struct{}
-	sessionID     string
+	manifest     manifestpkg.Manifest
+	manifestPath string
+	modulePath   string
+	moduleSHA256 string
+	directory    string
+	publishSet   map[string]struct{}
+	// publishPatterns holds wildcard publish declarations (publish_wildcards).
+	publishPatterns []string
+	subscribeList   []string
+	permissions     map[string]struct{}
+	sessionID       string
+	// limiter throttles host_publish across all invocations of the skill.
+	limiter *publishLimiter
 }
 
 // New creates the skills service. When cfg.Enabled is false, nil is returned.
-func New(ctx context.Context, cfg config.SkillsConfig, busClient *bus.Client, store *eventstore.Store, logger *slog.Logger) (*Service, error) {
+// hostVersion is the running loqad version used to gate skills that declare
+// metadata.min_host_version. Manifest subjects are namespaced with subjects;
+// skills only ever see the unprefixed form. Skills declaring requires are
+// checked against registry, when it is not nil.
+func New(ctx context.Context, cfg config.SkillsConfig, hostVersion string, busClient *bus.Client, subjects protocol.Subjects, registry *capability.Registry, store *eventstore.Store, logger *slog.Logger) (*Service, error) {
 	if !cfg.Enabled {
 		return nil, nil
 	}
@@ -65,25 +104,53 @@ func New(ctx context.Context, cfg config.SkillsConfig, busClient *bus.Client, st
 	if cfg.Concurrency <= 0 {
 		cfg.Concurrency = 1
 	}
+	if cfg.CacheDir == "" {
+		cfg.CacheDir = "./data/skills-cache"
+	}
+	if cfg.MaxEventBytes <= 0 {
+		cfg.MaxEventBytes = defaultMaxEventBytes
+	}
+	if protocol.PrivacyRank(cfg.AuditPrivacy) < 0 {
+		logger.Warn("skills.audit_privacy_scope is not a known privacy scope; audit events keep it verbatim and no event_store.redaction policy applies to them",
+			slog.String("scope", cfg.AuditPrivacy),
+			slog.String("known", protocol.PrivacyScopesList()))
+	}
 	cctx, cancel := context.WithCancel(ctx)
 	svc := &Service{
-		cfg:    cfg,
-		log:    logger.With(slog.String("component", "skills.service")),
-		bus:    busClient,
-		store:  store,
-		ctx:    cctx,
-		cancel: cancel,
-		sema:   make(chan struct{}, cfg.Concurrency),
-		skills: make(map[string]*binding),
+		cfg:         cfg,
+		log:         logger.With(slog.String("component", "skills.service")),
+		hostVersion: hostVersion,
+		bus:         busClient,
+		subjects:    subjects,
+		registry:    registry,
+		store:       store,
+		ctx:         cctx,
+		cancel:      cancel,
+		sema:        make(chan struct{}, cfg.Concurrency),
+		skills:      make(map[string]*binding),
+		metrics:     newSkillMetrics(),
+		activity:    newActivity(),
+		cache:       modcache.New(cfg.CacheDir, nil),
+		dedup:       newDedupCache(time.Duration(cfg.DedupWindowMS) * time.Millisecond),
 	}
 	if err := svc.loadSkills(); err != nil {
 		cancel()
 		return nil, err
 	}
+	if cfg.AuditMode == "jetstream" && store != nil {
+		if err := svc.startAuditStream(); err != nil {
+			svc.Close()
+			return nil, err
+		}
+	}
 	if err := svc.registerSubscriptions(); err != nil {
 		svc.Close()
 		return nil, err
 	}
+	if len(svc.deferred) > 0 {
+		svc.wg.Add(1)
+		go svc.awaitRequires()
+	}
 	svc.healthy = true
 	return svc, nil
 }
@@ -100,6 +167,7 @@ func (s *Service) Close() {
 	s.subs = nil
 	s.mu.Unlock()
 	s.wg.Wait()
+	s.logSummary()
 }
 
 // Healthy reports whether the service is running with active subscriptions.
@@ -107,11 +175,15 @@ func (s *Service) Healthy() bool {
 	return s != nil && s.healthy
 }
 
+// InFlight reports the number of skill invocations currently running.
+func (s *Service) InFlight() int { return len(s.sema) }
+
 func (s *Service) loadSkills() error {
 	root := s.cfg.Directory
 	if root == "" {
 		return errors.New("skills directory not configured")
 	}
+	s.log.Info("loading skills", slog.String("directory", root), slog.String("conflict_policy", s.cfg.ConflictPolicy))
 	entries := 0
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -123,6 +195,9 @@ func (s *Service) loadSkills() error {
 		if strings.EqualFold(d.Name(), "skill.yaml") {
 			entries++
 			if err := s.addSkill(path); err != nil {
+				if errors.Is(err, errSkillConflict) {
+					return err
+				}
 				s.log.Error("failed to load skill", slog.String("path", path), slog.String("error", err.Error()))
 			}
 		}
@@ -131,11 +206,20 @@ func (s *Service) loadSkills() error {
 	if err != nil {
 		return err
 	}
-	if len(s.skills) == 0 {
+	s.deferUnmetRequires()
+	if len(s.skills) == 0 && len(s.deferred) == 0 {
 		s.log.Warn("no skills discovered", slog.String("directory", root))
 	} else {
 		s.log.Info("skills discovered", slog.Int("count", len(s.skills)))
 	}
+	// Audit the modules that won registration once, rather than on every
+	// invocation that loads them.
+	for _, binding := range s.skills {
+		s.appendAudit(binding, "", skillrt.AuditEvent{Type: "skill.load", Data: map[string]any{
+			"module":        binding.modulePath,
+			"module_sha256": binding.moduleSHA256,
+		}})
+	}
 	return nil
 }
 
@@ -151,20 +235,40 @@ func (s *Service) addSkill(manifestPath string) error {
 	if name == "" {
 		return errors.New("manifest missing metadata.name")
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if _, exists := s.skills[name]; exists {
-		return fmt.Errorf("duplicate skill name %s", name)
+	if !s.hostSatisfies(mf) {
+		return nil
 	}
 
 	baseDir := filepath.Dir(manifestPath)
 	modulePath := mf.Runtime.Module
-	if !filepath.IsAbs(modulePath) {
+	if manifestpkg.IsRemoteModule(modulePath) {
+		ctx, cancel := context.WithTimeout(s.ctx, 60*time.Second)
+		defer cancel()
+		cached, err := s.cache.Fetch(ctx, modulePath, mf.Runtime.ModuleSHA256)
+		if err != nil {
+			return fmt.Errorf("fetch module: %w", err)
+		}
+		s.log.Info("skill module cached", slog.String("skill", name), slog.String("module", modulePath), slog.String("path", cached))
+		modulePath = cached
+	} else if !filepath.IsAbs(modulePath) {
 		modulePath = filepath.Join(baseDir, modulePath)
 	}
+	digest, err := s.validateModule(modulePath, mf)
+	if err != nil {
+		s.log.Warn("skipping skill: invalid module",
+			slog.String("skill", name),
+			slog.String("module", modulePath),
+			slog.String("error", err.Error()))
+		return nil
+	}
 
 	publishSet := make(map[string]struct{}, len(mf.Capabilities.Bus.Publish))
+	var publishPatterns []string
 	for _, subj := range mf.Capabilities.Bus.Publish {
+		if manifestpkg.IsWildcardSubject(subj) {
+			publishPatterns = append(publishPatterns, subj)
+			continue
+		}
 		publishSet[subj] = struct{}{}
 	}
 	permSet := make(map[string]struct{}, len(mf.Permissions))
@@ -173,38 +277,259 @@ func (s *Service) addSkill(manifestPath string) error {
 	}
 
 	binding := &binding{
-		manifest:      mf,
-		manifestPath:  manifestPath,
-		modulePath:    modulePath,
-		directory:     baseDir,
-		publishSet:    publishSet,
-		subscribeList: append([]string(nil), mf.Capabilities.Bus.Subscribe...),
-		permissions:   permSet,
-		sessionID:     fmt.Sprintf("skill:%s", name),
+		manifest:        mf,
+		manifestPath:    manifestPath,
+		modulePath:      modulePath,
+		moduleSHA256:    digest,
+		directory:       baseDir,
+		publishSet:      publishSet,
+		publishPatterns: publishPatterns,
+		subscribeList:   append([]string(nil), mf.Capabilities.Bus.Subscribe...),
+		permissions:     permSet,
+		sessionID:       fmt.Sprintf("skill:%s", name),
+		limiter:         newPublishLimiter(publishRate(s.cfg, mf)),
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, exists := s.skills[name]; exists {
+		replace, err := s.resolveConflict(existing, binding)
+		if err != nil {
+			return err
+		}
+		if !replace {
+			return nil
+		}
+	}
 	s.skills[name] = binding
 	return nil
 }
 
+// validateModule checks that the module at path exports the manifest's
+// entrypoint and handlers and imports only host functions this runtime
+// provides, so a broken build is reported at load rather than on every
+// event. It returns the module's hex SHA-256 for the skill.load audit.
+func (s *Service) validateModule(path string, mf manifestpkg.Manifest) (string, error) {
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+	if err := skillrt.ValidateModule(ctx, wasm, mf.Runtime.Exports()...); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(wasm)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// hostSatisfies reports whether the running host meets the manifest's
+// metadata.min_host_version. Skills requiring a newer host are skipped with a
+// warning so rolling upgrades do not surface as invocation failures.
+func (s *Service) hostSatisfies(mf manifestpkg.Manifest) bool {
+	required := mf.Metadata.MinHostVersion
+	if required == "" || s.hostVersion == "" {
+		return true
+	}
+	cmp, err := manifestpkg.CompareVersions(s.hostVersion, required)
+	if err != nil {
+		s.log.Warn("cannot compare host version; loading skill anyway",
+			slog.String("skill", mf.Metadata.Name),
+			slog.String("host_version", s.hostVersion),
+			slog.String("min_host_version", required),
+			slog.String("error", err.Error()))
+		return true
+	}
+	if cmp < 0 {
+		s.log.Warn("skipping skill: requires newer host",
+			slog.String("skill", mf.Metadata.Name),
+			slog.String("host_version", s.hostVersion),
+			slog.String("min_host_version", required))
+		return false
+	}
+	return true
+}
+
+// missingCapabilities lists the capabilities the manifest requires that no
+// healthy node the registry currently knows, this one or a peer, advertises.
+func (s *Service) missingCapabilities(mf manifestpkg.Manifest) []string {
+	if len(mf.Requires) == 0 || s.registry == nil {
+		return nil
+	}
+	var missing []string
+	for _, name := range mf.Requires {
+		nodes := s.registry.Query(capability.And(
+			func(node capability.NodeInfo) bool { return node.Healthy },
+			capability.WithCapabilityFilter(name),
+		))
+		if len(nodes) == 0 {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// deferUnmetRequires moves skills whose required capabilities the registry
+// does not list yet out of the registered set, so they neither subscribe nor
+// audit events while peers may still be announcing themselves.
+func (s *Service) deferUnmetRequires() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, binding := range s.skills {
+		missing := s.missingCapabilities(binding.manifest)
+		if len(missing) == 0 {
+			continue
+		}
+		s.log.Info("deferring skill until the capability registry converges",
+			slog.String("skill", name),
+			slog.Any("requires", binding.manifest.Requires),
+			slog.Any("missing", missing))
+		delete(s.skills, name)
+		s.deferred = append(s.deferred, binding)
+	}
+}
+
+// awaitRequires resolves the deferred skills once the capability registry
+// has converged.
+func (s *Service) awaitRequires() {
+	defer s.wg.Done()
+	select {
+	case <-s.registry.Converged():
+		s.resolveDeferred()
+	case <-s.ctx.Done():
+	}
+}
+
+// resolveDeferred registers each deferred skill whose required capabilities
+// are now advertised by a healthy node, this one or a peer, and skips the
+// rest with a warning, since their events could never be served here.
+func (s *Service) resolveDeferred() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deferred := s.deferred
+	s.deferred = nil
+	for _, binding := range deferred {
+		name := binding.manifest.Metadata.Name
+		if missing := s.missingCapabilities(binding.manifest); len(missing) > 0 {
+			s.log.Warn("skipping skill: required capabilities unavailable",
+				slog.String("skill", name),
+				slog.Any("requires", binding.manifest.Requires),
+				slog.Any("missing", missing))
+			continue
+		}
+		// Close drains s.subs under the same lock after cancelling, so a
+		// skill is not subscribed once shutdown has begun.
+		if s.ctx.Err() != nil {
+			return
+		}
+		s.skills[name] = binding
+		s.appendAudit(binding, "", skillrt.AuditEvent{Type: "skill.load", Data: map[string]any{
+			"module":        binding.modulePath,
+			"module_sha256": binding.moduleSHA256,
+		}})
+		if err := s.subscribeSkill(binding); err != nil {
+			s.log.Error("failed to subscribe deferred skill", slog.String("skill", name), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// resolveConflict applies the configured skill_conflict policy to two skills
+// sharing a name. It reports whether candidate should replace existing.
+func (s *Service) resolveConflict(existing, candidate *binding) (bool, error) {
+	name := candidate.manifest.Metadata.Name
+	policy := s.cfg.ConflictPolicy
+	if policy == "" {
+		policy = "first-wins"
+	}
+	attrs := []any{
+		slog.String("skill", name),
+		slog.String("policy", policy),
+		slog.String("existing", existing.manifestPath),
+		slog.String("existing_version", existing.manifest.Metadata.Version),
+		slog.String("candidate", candidate.manifestPath),
+		slog.String("candidate_version", candidate.manifest.Metadata.Version),
+	}
+
+	var replace bool
+	switch policy {
+	case "error":
+		return false, fmt.Errorf("%w: %s defined by %s and %s", errSkillConflict, name, existing.manifestPath, candidate.manifestPath)
+	case "last-wins":
+		replace = true
+	case "version-wins":
+		cmp, err := manifestpkg.CompareVersions(candidate.manifest.Metadata.Version, existing.manifest.Metadata.Version)
+		if err != nil {
+			s.log.Warn("cannot compare duplicate skill versions; keeping first", append(attrs, slog.String("error", err.Error()))...)
+			return false, nil
+		}
+		replace = cmp > 0
+	default:
+		replace = false
+	}
+
+	if replace {
+		s.log.Warn("duplicate skill name; replacing existing skill", attrs...)
+	} else {
+		s.log.Warn("duplicate skill name; skipping candidate skill", attrs...)
+	}
+	return replace, nil
+}
+
+// mayPublish reports whether subject is declared, exactly or through a
+// wildcard publish pattern. Concrete subjects only: a skill cannot publish
+// to a wildcard.
+func (b *binding) mayPublish(subject string) bool {
+	if _, ok := b.publishSet[subject]; ok {
+		return true
+	}
+	if manifestpkg.IsWildcardSubject(subject) {
+		return false
+	}
+	for _, pattern := range b.publishPatterns {
+		if _, ok := manifestpkg.MatchSubject(pattern, subject); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Service) registerSubscriptions() error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	for _, binding := range s.skills {
-		for _, subject := range binding.subscribeList {
-			subject := subject
-			handler := s.makeHandler(binding)
-			sub, err := s.bus.Conn().Subscribe(subject, handler)
-			if err != nil {
-				return fmt.Errorf("subscribe %s: %w", subject, err)
-			}
-			s.subs = append(s.subs, sub)
-			s.log.Info("skill subscribed", slog.String("skill", binding.manifest.Metadata.Name), slog.String("subject", subject))
+		if err := s.subscribeSkill(binding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscribeSkill subscribes binding to its declared subjects. Callers hold
+// s.mu.
+func (s *Service) subscribeSkill(binding *binding) error {
+	for _, subject := range binding.subscribeList {
+		handler := s.makeHandler(binding)
+		sub, err := s.bus.Subscribe(s.subjects.Apply(subject), handler)
+		if err != nil {
+			return fmt.Errorf("subscribe %s: %w", subject, err)
 		}
+		s.subs = append(s.subs, sub)
+		s.log.Info("skill subscribed", slog.String("skill", binding.manifest.Metadata.Name), slog.String("subject", subject))
 	}
 	return nil
 }
 
+// withSlot runs one invocation attempt inside a skills.concurrency slot.
+// Slots are held per attempt, not per event, so a skill backing off between
+// retries does not keep other events waiting.
+func (s *Service) withSlot(attempt func() error) error {
+	if s.sema != nil {
+		s.sema <- struct{}{}
+		defer func() { <-s.sema }()
+	}
+	return attempt()
+}
+
 func (s *Service) makeHandler(binding *binding) nats.MsgHandler {
 	return func(msg *nats.Msg) {
 		select {
@@ -215,23 +540,74 @@ func (s *Service) makeHandler(binding *binding) nats.MsgHandler {
 		s.wg.Add(1)
 		go func() {
 			defer s.wg.Done()
-			s.sema <- struct{}{}
-			defer func() { <-s.sema }()
-			if err := s.invoke(binding, msg); err != nil {
-				s.log.Error("skill invocation failed", slog.String("skill", binding.manifest.Metadata.Name), slog.String("subject", msg.Subject), slog.String("error", err.Error()))
+			log := s.eventLogger(binding, msg)
+			invocationID := uuid.NewString()
+			defer func() {
+				if r := recover(); r != nil {
+					log.Error("skill invocation panicked", slog.String("subject", msg.Subject), slog.String("panic", fmt.Sprint(r)))
+					s.appendAudit(binding, invocationID, skillrt.AuditEvent{Type: "skill.invoke.error", Data: map[string]any{
+						"error": fmt.Sprintf("panic: %v", r),
+						"panic": true,
+					}})
+				}
+			}()
+			if err := s.invoke(log, binding, msg, invocationID); err != nil {
+				log.Error("skill invocation failed", slog.String("subject", msg.Subject), slog.String("error", err.Error()))
 			}
 		}()
 	}
 }
 
-func (s *Service) invoke(binding *binding, msg *nats.Msg) error {
-	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
-	defer cancel()
+// eventLogger returns a logger for one delivery to binding. Pipeline events
+// carry session_id and trace_id in their JSON payload; other events fall back
+// to the skill's audit session.
+func (s *Service) eventLogger(binding *binding, msg *nats.Msg) *slog.Logger {
+	var ids struct {
+		SessionID string `json:"session_id"`
+		TraceID   string `json:"trace_id"`
+	}
+	if limit := s.cfg.MaxEventBytes; limit <= 0 || len(msg.Data) <= limit {
+		_ = s.bus.Codec().Unmarshal(msg.Data, &ids)
+	}
+	if ids.SessionID == "" {
+		ids.SessionID = binding.sessionID
+	}
+	return logging.WithSession(s.log, ids.SessionID, ids.TraceID).With(slog.String("skill", binding.manifest.Metadata.Name))
+}
+
+func (s *Service) invoke(log *slog.Logger, binding *binding, msg *nats.Msg, invocationID string) (err error) {
+	subject := s.subjects.Strip(msg.Subject)
+	if limit := s.cfg.MaxEventBytes; limit > 0 && len(msg.Data) > limit {
+		log.Warn("rejecting oversized skill event",
+			slog.String("subject", subject),
+			slog.Int("payload_bytes", len(msg.Data)),
+			slog.Int("max_bytes", limit),
+		)
+		s.appendAudit(binding, invocationID, skillrt.AuditEvent{Type: "skill.invoke.rejected", Data: map[string]any{
+			"subject":       subject,
+			"reason":        "payload_too_large",
+			"payload_bytes": len(msg.Data),
+			"max_bytes":     limit,
+		}})
+		return nil
+	}
+	key := idempotencyKey(binding.manifest.Metadata.Name, subject, msg, s.cfg.DedupByPayload)
+	if !s.dedup.claim(key) {
+		log.Info("skipping redelivered skill event", slog.String("subject", subject))
+		s.appendAudit(binding, invocationID, skillrt.AuditEvent{Type: "skill.invoke.duplicate", Data: map[string]any{
+			"subject": subject,
+		}})
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			s.dedup.release(key)
+		}
+	}()
 
-	invocationID := uuid.NewString()
 	env := map[string]string{
 		"LOQA_SKILL_NAME":      binding.manifest.Metadata.Name,
-		"LOQA_EVENT_SUBJECT":   msg.Subject,
+		"LOQA_EVENT_SUBJECT":   subject,
 		"LOQA_EVENT_PAYLOAD":   string(msg.Data),
 		"LOQA_INVOCATION_ID":   invocationID,
 		"LOQA_SKILL_DIRECTORY": binding.directory,
@@ -239,29 +615,107 @@ func (s *Service) invoke(binding *binding, msg *nats.Msg) error {
 	if msg.Reply != "" {
 		env["LOQA_EVENT_REPLY"] = msg.Reply
 	}
+	if msg.Sub != nil {
+		env["LOQA_EVENT_PATTERN"] = s.subjects.Strip(msg.Sub.Subject)
+	}
 
-	hostLogger := s.log.With(
-		slog.String("skill", binding.manifest.Metadata.Name),
-		slog.String("invocation_id", invocationID),
-	)
+	retry := binding.manifest.Runtime.Retry
+	run := s.runSkill
+	if run == nil {
+		run = s.runModule
+	}
+	for attempt := 1; ; attempt++ {
+		env["LOQA_INVOCATION_ATTEMPT"] = strconv.Itoa(attempt)
+		err = s.withSlot(func() error {
+			ctx, cancel := context.WithTimeout(s.ctx, s.invocationTimeout())
+			defer cancel()
+			env["LOQA_INVOCATION_DEADLINE_MS"] = strconv.FormatInt(skillrt.RemainingMS(ctx), 10)
+			return run(ctx, log, binding, subject, env, invocationID, attempt)
+		})
+		if err == nil {
+			return nil
+		}
+		if attempt > retry.MaxRetries {
+			break
+		}
+		delay := retry.Backoff(attempt)
+		log.Warn("skill invocation failed, retrying",
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", delay),
+			slog.String("error", err.Error()))
+		s.appendAudit(binding, invocationID, skillrt.AuditEvent{Type: "skill.invoke.retry", Data: map[string]any{
+			"attempt":    attempt,
+			"backoff_ms": delay.Milliseconds(),
+			"error":      err.Error(),
+		}})
+		select {
+		case <-time.After(delay):
+		case <-s.ctx.Done():
+			return err
+		}
+	}
+	if retry.DeadLetter != "" {
+		s.deadLetter(log, binding, msg, subject, invocationID, retry.MaxRetries+1, err)
+	}
+	return err
+}
+
+// deadLetter republishes an event that exhausted its retries on the skill's
+// runtime.retry.dead_letter subject, with the failure described in headers.
+func (s *Service) deadLetter(log *slog.Logger, binding *binding, msg *nats.Msg, subject, invocationID string, attempts int, cause error) {
+	dlq := nats.NewMsg(s.subjects.Apply(binding.manifest.Runtime.Retry.DeadLetter))
+	dlq.Data = msg.Data
+	dlq.Header.Set("Loqa-Skill", binding.manifest.Metadata.Name)
+	dlq.Header.Set("Loqa-Subject", subject)
+	dlq.Header.Set("Loqa-Invocation-Id", invocationID)
+	dlq.Header.Set("Loqa-Attempts", strconv.Itoa(attempts))
+	dlq.Header.Set("Loqa-Error", cause.Error())
+	if err := s.bus.PublishMsg(dlq); err != nil {
+		log.Error("failed to dead-letter skill event", slog.String("error", err.Error()))
+		return
+	}
+	s.appendAudit(binding, invocationID, skillrt.AuditEvent{Type: "skill.invoke.dead_letter", Data: map[string]any{
+		"subject":  binding.manifest.Runtime.Retry.DeadLetter,
+		"attempts": attempts,
+		"error":    cause.Error(),
+	}})
+}
+
+// invocationTimeout is skills.invocation_timeout_ms, defaulting to 30s.
+func (s *Service) invocationTimeout() time.Duration {
+	if s.cfg.InvocationTimeoutMS <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(s.cfg.InvocationTimeoutMS) * time.Millisecond
+}
+
+// runModule performs one attempt within ctx: it instantiates a fresh
+// runtime, loads the module with env, and calls the function
+// runtime.handlers maps to subject, or the entrypoint.
+func (s *Service) runModule(ctx context.Context, log *slog.Logger, binding *binding, subject string, env map[string]string, invocationID string, attempt int) error {
+	hostLogger := log.With(slog.String("invocation_id", invocationID))
 
 	hostBindings := skillrt.HostBindings{
-		Logger: hostLogger,
-		AllowPublish: func(subject string) error {
-			if _, ok := binding.permissions["bus:publish"]; !ok {
-				return fmt.Errorf("missing permission bus:publish")
-			}
-			if _, ok := binding.publishSet[subject]; !ok {
-				return fmt.Errorf("subject %s not declared in manifest", subject)
-			}
-			return nil
-		},
+		Logger:          hostLogger,
+		MaxPayloadBytes: s.cfg.MaxPublishBytes,
+		MaxLogBytes:     s.cfg.MaxLogBytes,
+		AllowPublish:    s.allowPublish(binding, invocationID),
 		Publish: func(subject string, payload []byte) error {
-			return s.bus.Conn().Publish(subject, payload)
+			return s.bus.Publish(s.subjects.Apply(subject), payload)
 		},
 		RecordAudit: func(event skillrt.AuditEvent) {
 			s.appendAudit(binding, invocationID, event)
 		},
+		AllowHTTP:    allowHTTP(binding),
+		DoHTTP:       newHTTPDoer(binding.manifest.Capabilities.Network.HTTP, time.Duration(s.cfg.HTTPTimeoutMS)*time.Millisecond, s.maxHTTPBytes()),
+		MaxHTTPBytes: s.maxHTTPBytes(),
+		GetConfig:    s.configLookup(binding),
+		RecordMetric: func(name string, value float64, kind skillrt.MetricKind) error {
+			if _, ok := binding.permissions["metrics:emit"]; !ok {
+				return fmt.Errorf("%w metrics:emit", skillrt.ErrNoPermission)
+			}
+			return s.metrics.record(binding.manifest.Metadata.Name, name, value, kind)
+		},
 	}
 
 	runtime, err := skillrt.New(ctx, hostBindings)
@@ -280,34 +734,94 @@ func (s *Service) invoke(binding *binding, msg *nats.Msg) error {
 	defer skill.Close(ctx)
 
 	start := time.Now()
+	pattern := env["LOQA_EVENT_PATTERN"]
 	s.appendAudit(binding, invocationID, skillrt.AuditEvent{Type: "skill.invoke.start", Data: map[string]any{
-		"subject": msg.Subject,
+		"subject":  subject,
+		"attempt":  attempt,
+		"function": mf.Runtime.Handler(subject, pattern),
 	}})
 
-	if err := skill.Invoke(ctx); err != nil {
+	err = skill.InvokeHandler(ctx, subject, pattern)
+	s.activity.addDuration(binding.manifest.Metadata.Name, time.Since(start))
+	if err != nil {
 		s.appendAudit(binding, invocationID, skillrt.AuditEvent{Type: "skill.invoke.error", Data: map[string]any{
-			"error": err.Error(),
+			"error":   err.Error(),
+			"attempt": attempt,
 		}})
 		return err
 	}
 
 	s.appendAudit(binding, invocationID, skillrt.AuditEvent{Type: "skill.invoke.complete", Data: map[string]any{
 		"duration_ms": time.Since(start).Milliseconds(),
+		"attempt":     attempt,
 	}})
 	return nil
 }
 
+// allowPublish vets host_publish calls: the skill needs bus:publish, must
+// have declared the subject, and must stay within its publish rate. The
+// first publish dropped in a throttling streak is audited.
+func (s *Service) allowPublish(binding *binding, invocationID string) func(subject string) error {
+	return func(subject string) error {
+		if _, ok := binding.permissions["bus:publish"]; !ok {
+			return fmt.Errorf("%w bus:publish", skillrt.ErrNoPermission)
+		}
+		if !binding.mayPublish(subject) {
+			return fmt.Errorf("%w: %s", skillrt.ErrSubjectUndeclared, subject)
+		}
+		if ok, first := binding.limiter.allow(); !ok {
+			if first {
+				s.appendAudit(binding, invocationID, skillrt.AuditEvent{Type: "skill.publish.throttled", Data: map[string]any{
+					"subject":        subject,
+					"max_per_second": binding.limiter.rate,
+				}})
+			}
+			return fmt.Errorf("%w: %s", skillrt.ErrPublishThrottled, subject)
+		}
+		return nil
+	}
+}
+
+// publishRate is the host_publish rate limit for mf: the lower of the
+// manifest's own limit and skills.max_publishes_per_second, so a manifest
+// can tighten the host limit but never lift it. Zero means unlimited on
+// either side.
+func publishRate(cfg config.SkillsConfig, mf manifestpkg.Manifest) float64 {
+	rate := mf.Capabilities.Bus.MaxPublishesPerSecond
+	if rate <= 0 || (cfg.MaxPublishesPerSecond > 0 && cfg.MaxPublishesPerSecond < rate) {
+		return cfg.MaxPublishesPerSecond
+	}
+	return rate
+}
+
+// auditPrivacy is the privacy scope recorded for an audit event of
+// eventType: the manifest's publish scope for skill.publish, then its
+// general scope, then skills.audit_privacy_scope.
+func auditPrivacy(cfg config.SkillsConfig, mf manifestpkg.Manifest, eventType string) string {
+	if eventType == "skill.publish" && mf.Audit.PublishPrivacyScope != "" {
+		return mf.Audit.PublishPrivacyScope
+	}
+	if mf.Audit.PrivacyScope != "" {
+		return mf.Audit.PrivacyScope
+	}
+	return cfg.AuditPrivacy
+}
+
+func (s *Service) maxHTTPBytes() int {
+	if s.cfg.MaxHTTPBytes > 0 {
+		return s.cfg.MaxHTTPBytes
+	}
+	return skillrt.DefaultMaxHTTPBytes
+}
+
 func (s *Service) appendAudit(binding *binding, invocationID string, event skillrt.AuditEvent) {
+	s.activity.record(binding.manifest.Metadata.Name, event)
 	if s.store == nil {
 		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-
-	_ = s.store.AppendSession(ctx, binding.sessionID, binding.manifest.Metadata.Name, s.cfg.AuditPrivacy)
-	payload := map[string]any{
-		"invocation_id": invocationID,
-		"skill":         binding.manifest.Metadata.Name,
+	payload := map[string]any{"skill": binding.manifest.Metadata.Name}
+	if invocationID != "" {
+		payload["invocation_id"] = invocationID
 	}
 	for k, v := range event.Data {
 		payload[k] = v
@@ -322,9 +836,13 @@ func (s *Service) appendAudit(binding *binding, invocationID string, event skill
 		ActorID:   binding.manifest.Metadata.Name,
 		Type:      event.Type,
 		Payload:   data,
-		Privacy:   s.cfg.AuditPrivacy,
+		Privacy:   auditPrivacy(s.cfg, binding.manifest, event.Type),
+		CreatedAt: time.Now().UTC(),
+	}
+	if s.cfg.AuditMode == "jetstream" && s.enqueueAudit(evt) {
+		return
 	}
-	if err := s.store.AppendEvent(ctx, evt); err != nil {
+	if err := s.writeAudit(evt); err != nil {
 		s.log.Warn("failed to append audit event", slog.String("error", err.Error()))
 	}
 }
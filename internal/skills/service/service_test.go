@@ -0,0 +1,727 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/capability"
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/eventstore"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	manifestpkg "github.com/loqalabs/loqa-core/internal/skills/manifest"
+	skillrt "github.com/loqalabs/loqa-core/internal/skills/runtime"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+const skillTemplate = `metadata:
+  name: %s
+  version: %s
+  description: test skill
+  author: test
+runtime:
+  mode: wasm
+  module: build/skill.wasm
+  entrypoint: run
+  host_version: v1
+capabilities:
+  bus:
+    subscribe:
+      - skill.test.input
+permissions:
+  - bus:subscribe
+`
+
+// runModule is a minimal wasm module exporting a no-op "run", enough for
+// skills written by writeSkill to pass module validation.
+var runModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x04, 0x01, 0x60, 0x00, 0x00, // type 0: () -> ()
+	0x03, 0x02, 0x01, 0x00, // function 0 has type 0
+	0x07, 0x07, 0x01, 0x03, 'r', 'u', 'n', 0x00, 0x00, // export "run"
+	0x0a, 0x04, 0x01, 0x02, 0x00, 0x0b, // empty body
+}
+
+func newTestService(t *testing.T, cfg config.SkillsConfig) *Service {
+	t.Helper()
+	return &Service{
+		ctx:      context.Background(),
+		cfg:      cfg,
+		log:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		skills:   make(map[string]*binding),
+		activity: newActivity(),
+	}
+}
+
+func openTestStore(t *testing.T) *eventstore.Store {
+	t.Helper()
+	store, err := eventstore.Open(context.Background(), config.EventStoreConfig{
+		Path:          filepath.Join(t.TempDir(), "events.db"),
+		RetentionMode: "session",
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("open event store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func writeSkill(t *testing.T, root, dir, name, version string) string {
+	t.Helper()
+	path := filepath.Join(root, dir, "skill.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(skillTemplate, name, version)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	module := filepath.Join(root, dir, "build", "skill.wasm")
+	if err := os.MkdirAll(filepath.Dir(module), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(module, runModule, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSkillsSkipsInvalidModules(t *testing.T) {
+	root := t.TempDir()
+	writeSkill(t, root, "good", "good", "0.1.0")
+	writeSkill(t, root, "broken", "broken", "0.1.0")
+	if err := os.WriteFile(filepath.Join(root, "broken", "build", "skill.wasm"), []byte("not wasm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeSkill(t, root, "missing", "missing", "0.1.0")
+	if err := os.Remove(filepath.Join(root, "missing", "build", "skill.wasm")); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := newTestService(t, config.SkillsConfig{Directory: root})
+	if err := svc.loadSkills(); err != nil {
+		t.Fatalf("load skills: %v", err)
+	}
+	if _, ok := svc.skills["good"]; !ok || len(svc.skills) != 1 {
+		t.Fatalf("expected only the skill with a valid module to load, got %v", svc.skills)
+	}
+}
+
+func TestLoadSkillsAuditsModuleDigestOnce(t *testing.T) {
+	root := t.TempDir()
+	writeSkill(t, root, "good", "good", "0.1.0")
+	svc := newTestService(t, config.SkillsConfig{Directory: root})
+	svc.store = openTestStore(t)
+	if err := svc.loadSkills(); err != nil {
+		t.Fatalf("load skills: %v", err)
+	}
+
+	events, err := svc.store.ListSessionEvents(context.Background(), "skill:good", 10)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "skill.load" {
+		t.Fatalf("expected a single skill.load event, got %+v", events)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(events[0].Payload, &payload); err != nil {
+		t.Fatalf("decode audit payload: %v", err)
+	}
+	sum := sha256.Sum256(runModule)
+	if payload["module_sha256"] != hex.EncodeToString(sum[:]) {
+		t.Fatalf("expected the module digest in the audit event, got %v", payload)
+	}
+	if _, ok := payload["invocation_id"]; ok {
+		t.Fatalf("expected no invocation_id on a load event, got %v", payload)
+	}
+}
+
+func TestSkillConflictPolicies(t *testing.T) {
+	root := t.TempDir()
+	// WalkDir visits directories lexically, so "a" loads before "b".
+	first := writeSkill(t, root, "a", "dup", "0.2.0")
+	writeSkill(t, root, "b", "dup", "0.1.0")
+	third := writeSkill(t, root, "c", "dup", "0.3.0-rc.1")
+
+	cases := []struct {
+		policy string
+		want   string
+	}{
+		{policy: "", want: first},
+		{policy: "first-wins", want: first},
+		{policy: "last-wins", want: third},
+		{policy: "version-wins", want: third},
+	}
+	for _, tc := range cases {
+		t.Run(tc.policy, func(t *testing.T) {
+			svc := newTestService(t, config.SkillsConfig{Directory: root, ConflictPolicy: tc.policy})
+			if err := svc.loadSkills(); err != nil {
+				t.Fatalf("load skills: %v", err)
+			}
+			got := svc.skills["dup"]
+			if got == nil {
+				t.Fatalf("expected skill dup to be registered")
+			}
+			if got.manifestPath != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, got.manifestPath)
+			}
+		})
+	}
+}
+
+func TestSkillConflictVersionWinsKeepsHigher(t *testing.T) {
+	root := t.TempDir()
+	first := writeSkill(t, root, "a", "dup", "1.0.0")
+	writeSkill(t, root, "b", "dup", "0.9.0")
+
+	svc := newTestService(t, config.SkillsConfig{Directory: root, ConflictPolicy: "version-wins"})
+	if err := svc.loadSkills(); err != nil {
+		t.Fatalf("load skills: %v", err)
+	}
+	if got := svc.skills["dup"].manifestPath; got != first {
+		t.Fatalf("expected higher version %s to win, got %s", first, got)
+	}
+}
+
+func TestSkillConflictErrorPolicyFails(t *testing.T) {
+	root := t.TempDir()
+	writeSkill(t, root, "a", "dup", "0.1.0")
+	writeSkill(t, root, "b", "dup", "0.1.0")
+
+	svc := newTestService(t, config.SkillsConfig{Directory: root, ConflictPolicy: "error"})
+	err := svc.loadSkills()
+	if !errors.Is(err, errSkillConflict) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+}
+
+func TestMinHostVersionGate(t *testing.T) {
+	root := t.TempDir()
+	path := writeSkill(t, root, "a", "future", "1.0.0")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = []byte(strings.Replace(string(data), "  author: test\n", "  author: test\n  min_host_version: 0.2.0\n", 1))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	older := newTestService(t, config.SkillsConfig{Directory: root})
+	older.hostVersion = "0.1.0-dev"
+	if err := older.loadSkills(); err != nil {
+		t.Fatalf("load skills: %v", err)
+	}
+	if _, ok := older.skills["future"]; ok {
+		t.Fatalf("expected skill requiring newer host to be skipped")
+	}
+
+	newer := newTestService(t, config.SkillsConfig{Directory: root})
+	newer.hostVersion = "0.2.0"
+	if err := newer.loadSkills(); err != nil {
+		t.Fatalf("load skills: %v", err)
+	}
+	if _, ok := newer.skills["future"]; !ok {
+		t.Fatalf("expected skill to load on compatible host")
+	}
+}
+
+func TestInvokeRejectsOversizedPayload(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{MaxEventBytes: 16, AuditPrivacy: "internal"})
+	svc.ctx = context.Background()
+	svc.store = openTestStore(t)
+
+	// The module path does not exist, so reaching the runtime would fail.
+	b := &binding{
+		manifest:   manifestpkg.Manifest{Metadata: manifestpkg.Metadata{Name: "echo"}},
+		modulePath: filepath.Join(t.TempDir(), "missing.wasm"),
+		sessionID:  "skill-echo",
+	}
+	msg := &nats.Msg{Subject: "skill.test.input", Data: []byte(strings.Repeat("x", 17))}
+	if err := svc.invoke(svc.log, b, msg, "inv-1"); err != nil {
+		t.Fatalf("expected oversized event to be rejected without error, got %v", err)
+	}
+
+	events, err := svc.store.ListSessionEvents(context.Background(), "skill-echo", 10)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "skill.invoke.rejected" {
+		t.Fatalf("expected a single skill.invoke.rejected event, got %+v", events)
+	}
+	if !strings.Contains(string(events[0].Payload), `"payload_bytes":17`) {
+		t.Fatalf("expected payload size in audit event, got %s", events[0].Payload)
+	}
+	if sum := svc.Summary()["echo"]; sum.Rejected != 1 || sum.Invocations != 0 {
+		t.Fatalf("expected rejection in activity summary, got %+v", sum)
+	}
+}
+
+func TestActivitySummaryCountsAuditEvents(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{})
+	b := &binding{manifest: manifestpkg.Manifest{Metadata: manifestpkg.Metadata{Name: "timer"}}}
+	for _, typ := range []string{"skill.invoke.start", "skill.publish", "skill.publish", "skill.invoke.complete", "skill.invoke.start", "skill.invoke.error", "skill.http"} {
+		svc.appendAudit(b, "inv", skillrt.AuditEvent{Type: typ})
+	}
+	svc.activity.addDuration("timer", 1500*time.Millisecond)
+
+	sum := svc.Summary()["timer"]
+	want := SkillSummary{Invocations: 2, Errors: 1, Publishes: 2, HTTPCalls: 1, TotalDurationMS: 1500}
+	if sum != want {
+		t.Fatalf("expected %+v, got %+v", want, sum)
+	}
+}
+
+func TestActivitySummaryKeepsSubMillisecondRuns(t *testing.T) {
+	act := newActivity()
+	for i := 0; i < 4000; i++ {
+		act.addDuration("timer", 250*time.Microsecond)
+	}
+	if got := act.snapshot()["timer"].TotalDurationMS; got != 1000 {
+		t.Fatalf("expected 1000ms across 4000 runs of 250µs, got %d", got)
+	}
+}
+
+func TestBindingMayPublishWildcardDeclarations(t *testing.T) {
+	b := &binding{
+		publishSet:      map[string]struct{}{"tts.request": {}},
+		publishPatterns: []string{"skill.timer.status.*"},
+	}
+	for subject, want := range map[string]bool{
+		"tts.request":                true,
+		"skill.timer.status.kitchen": true,
+		"skill.timer.status.a.b":     false,
+		"skill.timer.status.*":       false,
+		"skill.timer.start":          false,
+		"tts.request.extra":          false,
+	} {
+		if got := b.mayPublish(subject); got != want {
+			t.Errorf("mayPublish(%q) = %v, want %v", subject, got, want)
+		}
+	}
+}
+
+func TestInvokeRetriesWithBackoffUntilSuccess(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{AuditPrivacy: "internal"})
+	svc.ctx = context.Background()
+	svc.store = openTestStore(t)
+
+	var attempts []string
+	svc.runSkill = func(_ context.Context, log *slog.Logger, b *binding, subject string, env map[string]string, invocationID string, attempt int) error {
+		attempts = append(attempts, env["LOQA_INVOCATION_ATTEMPT"])
+		svc.appendAudit(b, invocationID, skillrt.AuditEvent{Type: "skill.invoke.start", Data: map[string]any{"attempt": attempt}})
+		if attempt < 3 {
+			return errors.New("downstream unavailable")
+		}
+		return nil
+	}
+	b := &binding{
+		manifest: manifestpkg.Manifest{
+			Metadata: manifestpkg.Metadata{Name: "flaky"},
+			Runtime:  manifestpkg.RuntimeSpec{Retry: manifestpkg.RetrySpec{MaxRetries: 3, BackoffMS: 1}},
+		},
+		sessionID: "skill-flaky",
+	}
+	msg := &nats.Msg{Subject: "skill.test.input", Data: []byte("{}")}
+	if err := svc.invoke(svc.log, b, msg, "inv-1"); err != nil {
+		t.Fatalf("expected third attempt to succeed, got %v", err)
+	}
+	if got := strings.Join(attempts, ","); got != "1,2,3" {
+		t.Fatalf("expected attempts 1,2,3, got %s", got)
+	}
+	events, err := svc.store.ListSessionEvents(context.Background(), "skill-flaky", 20)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	retries := 0
+	for _, ev := range events {
+		if ev.Type == "skill.invoke.retry" {
+			retries++
+		}
+	}
+	if retries != 2 {
+		t.Fatalf("expected 2 retry audit events, got %d", retries)
+	}
+	if sum := svc.Summary()["flaky"]; sum.Invocations != 1 || sum.Retries != 2 {
+		t.Fatalf("expected one invocation with two retries, got %+v", sum)
+	}
+}
+
+func TestRetryBackoffReleasesConcurrencySlot(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{Concurrency: 1})
+	svc.sema = make(chan struct{}, 1)
+	failed := make(chan struct{})
+	svc.runSkill = func(_ context.Context, _ *slog.Logger, b *binding, _ string, _ map[string]string, _ string, attempt int) error {
+		if b.manifest.Metadata.Name == "flaky" && attempt == 1 {
+			close(failed)
+			return errors.New("downstream unavailable")
+		}
+		return nil
+	}
+	flaky := &binding{manifest: manifestpkg.Manifest{
+		Metadata: manifestpkg.Metadata{Name: "flaky"},
+		Runtime:  manifestpkg.RuntimeSpec{Retry: manifestpkg.RetrySpec{MaxRetries: 1, BackoffMS: 2000}},
+	}}
+	quick := &binding{manifest: manifestpkg.Manifest{Metadata: manifestpkg.Metadata{Name: "quick"}}}
+	flakyDone := make(chan error, 1)
+	go func() {
+		flakyDone <- svc.invoke(svc.log, flaky, &nats.Msg{Subject: "skill.test.input"}, "inv-flaky")
+	}()
+	<-failed
+
+	quickDone := make(chan error, 1)
+	go func() {
+		quickDone <- svc.invoke(svc.log, quick, &nats.Msg{Subject: "skill.test.input"}, "inv-quick")
+	}()
+	select {
+	case err := <-quickDone:
+		if err != nil {
+			t.Fatalf("quick invocation: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("invocation waited for a slot held by a skill backing off")
+	}
+	if err := <-flakyDone; err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+}
+
+func TestHandlerRecoversPanicAndKeepsHandlingEvents(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{})
+	var handled []string
+	svc.runSkill = func(_ context.Context, _ *slog.Logger, _ *binding, _ string, env map[string]string, _ string, _ int) error {
+		if env["LOQA_EVENT_PAYLOAD"] == "boom" {
+			panic("skill host exploded")
+		}
+		handled = append(handled, env["LOQA_EVENT_PAYLOAD"])
+		return nil
+	}
+	b := &binding{manifest: manifestpkg.Manifest{Metadata: manifestpkg.Metadata{Name: "fragile"}}}
+	handler := svc.makeHandler(b)
+	for _, payload := range []string{"boom", "first", "boom", "second"} {
+		// Waiting after each delivery keeps runSkill single-threaded.
+		handler(&nats.Msg{Subject: "skill.test.input", Data: []byte(payload)})
+		svc.wg.Wait()
+	}
+
+	if len(handled) != 2 || handled[0] != "first" || handled[1] != "second" {
+		t.Fatalf("expected events after a panic to be handled, got %v", handled)
+	}
+	if sum := svc.Summary()["fragile"]; sum.Errors != 2 {
+		t.Fatalf("expected both panics recorded as errors, got %+v", sum)
+	}
+}
+
+func TestInvokeWithoutRetryFailsOnce(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{})
+	svc.ctx = context.Background()
+	calls := 0
+	svc.runSkill = func(context.Context, *slog.Logger, *binding, string, map[string]string, string, int) error {
+		calls++
+		return errors.New("boom")
+	}
+	b := &binding{manifest: manifestpkg.Manifest{Metadata: manifestpkg.Metadata{Name: "once"}}}
+	if err := svc.invoke(svc.log, b, &nats.Msg{Subject: "skill.test.input"}, "inv-1"); err == nil {
+		t.Fatal("expected error without retries")
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single attempt, got %d", calls)
+	}
+}
+
+func TestInvokeExposesConfiguredDeadline(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{InvocationTimeoutMS: 1500})
+	svc.ctx = context.Background()
+	var env string
+	var remaining time.Duration
+	svc.runSkill = func(ctx context.Context, _ *slog.Logger, _ *binding, _ string, e map[string]string, _ string, _ int) error {
+		env = e["LOQA_INVOCATION_DEADLINE_MS"]
+		if deadline, ok := ctx.Deadline(); ok {
+			remaining = time.Until(deadline)
+		}
+		return nil
+	}
+	b := &binding{manifest: manifestpkg.Manifest{Metadata: manifestpkg.Metadata{Name: "timer"}}}
+	if err := svc.invoke(svc.log, b, &nats.Msg{Subject: "skill.timer.start"}, "inv-1"); err != nil {
+		t.Fatalf("invoke: %v", err)
+	}
+	ms, err := strconv.Atoi(env)
+	if err != nil || ms <= 1000 || ms > 1500 {
+		t.Fatalf("expected LOQA_INVOCATION_DEADLINE_MS close to 1500, got %q", env)
+	}
+	if remaining <= time.Second || remaining > 1500*time.Millisecond {
+		t.Fatalf("expected the attempt context to carry the timeout, got %v", remaining)
+	}
+}
+
+func TestConfigLookupGatesSecretsAndUndeclaredKeys(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{Config: map[string]map[string]string{
+		"home": {"endpoint": "http://ha.local:8123", "token": "s3cret", "undeclared": "x"},
+	}})
+	mf := manifestpkg.Manifest{
+		Metadata: manifestpkg.Metadata{Name: "home"},
+		Config: []manifestpkg.ConfigSpec{
+			{Key: "endpoint", Default: "http://localhost:8123"},
+			{Key: "timeout", Default: "5s"},
+			{Key: "token", Secret: true},
+		},
+	}
+	b := &binding{manifest: mf, permissions: map[string]struct{}{}}
+	get := svc.configLookup(b)
+
+	if v, secret, err := get("endpoint"); err != nil || secret || v != "http://ha.local:8123" {
+		t.Fatalf("expected operator value, got %q %v %v", v, secret, err)
+	}
+	if v, _, err := get("timeout"); err != nil || v != "5s" {
+		t.Fatalf("expected manifest default, got %q %v", v, err)
+	}
+	if _, _, err := get("undeclared"); !errors.Is(err, skillrt.ErrConfigNotFound) {
+		t.Fatalf("expected undeclared key to be hidden, got %v", err)
+	}
+	if _, _, err := get("token"); !errors.Is(err, skillrt.ErrNoPermission) {
+		t.Fatalf("expected secret to require config:read, got %v", err)
+	}
+	b.permissions["config:read"] = struct{}{}
+	if v, secret, err := get("token"); err != nil || !secret || v != "s3cret" {
+		t.Fatalf("expected secret value with config:read, got %q %v %v", v, secret, err)
+	}
+}
+
+func TestAllowPublishThrottlesFloodingSkill(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{AuditPrivacy: "internal", MaxPublishesPerSecond: 1000})
+	svc.store = openTestStore(t)
+	mf := manifestpkg.Manifest{Metadata: manifestpkg.Metadata{Name: "chatty"}}
+	mf.Capabilities.Bus.MaxPublishesPerSecond = 3
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newPublishLimiter(publishRate(svc.cfg, mf))
+	limiter.clock = func() time.Time { return now }
+	b := &binding{
+		manifest:    mf,
+		publishSet:  map[string]struct{}{"tts.request": {}},
+		permissions: map[string]struct{}{"bus:publish": {}},
+		sessionID:   "skill-chatty",
+		limiter:     limiter,
+	}
+	allow := svc.allowPublish(b, "inv-1")
+
+	for i := 0; i < 3; i++ {
+		if err := allow("tts.request"); err != nil {
+			t.Fatalf("publish %d within burst: %v", i, err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if err := allow("tts.request"); !errors.Is(err, skillrt.ErrPublishThrottled) {
+			t.Fatalf("expected throttling past the rate, got %v", err)
+		}
+	}
+	// Half a second refills 1.5 tokens at 3/s.
+	now = now.Add(time.Second / 2)
+	if err := allow("tts.request"); err != nil {
+		t.Fatalf("expected a refilled token, got %v", err)
+	}
+	if err := allow("tts.request"); !errors.Is(err, skillrt.ErrPublishThrottled) {
+		t.Fatalf("expected a new throttling streak, got %v", err)
+	}
+
+	events, err := svc.store.ListSessionEvents(context.Background(), "skill-chatty", 20)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != "skill.publish.throttled" {
+		t.Fatalf("expected one throttled audit per streak, got %+v", events)
+	}
+	if sum := svc.Summary()["chatty"]; sum.Throttled != 2 {
+		t.Fatalf("expected two throttling episodes, got %+v", sum)
+	}
+	if newPublishLimiter(publishRate(config.SkillsConfig{}, manifestpkg.Manifest{})) != nil {
+		t.Fatal("expected a zero rate to disable the limiter")
+	}
+}
+
+func TestPublishRateTakesTheLowerLimit(t *testing.T) {
+	cases := []struct {
+		host, manifest, want float64
+	}{
+		{host: 100, manifest: 0, want: 100},
+		{host: 100, manifest: 3, want: 3},
+		{host: 100, manifest: 500, want: 100},
+		{host: 0, manifest: 5, want: 5},
+		{host: 0, manifest: 0, want: 0},
+	}
+	for _, tc := range cases {
+		var mf manifestpkg.Manifest
+		mf.Capabilities.Bus.MaxPublishesPerSecond = tc.manifest
+		if got := publishRate(config.SkillsConfig{MaxPublishesPerSecond: tc.host}, mf); got != tc.want {
+			t.Errorf("host %v, manifest %v: got %v, want %v", tc.host, tc.manifest, got, tc.want)
+		}
+	}
+}
+
+func TestInvokeSkipsRedeliveredEvent(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{AuditPrivacy: "internal", DedupByPayload: true})
+	svc.ctx = context.Background()
+	svc.store = openTestStore(t)
+	svc.dedup = newDedupCache(time.Minute)
+
+	calls := 0
+	svc.runSkill = func(context.Context, *slog.Logger, *binding, string, map[string]string, string, int) error {
+		calls++
+		return nil
+	}
+	b := &binding{manifest: manifestpkg.Manifest{Metadata: manifestpkg.Metadata{Name: "timer"}}, sessionID: "skill-timer"}
+	msg := &nats.Msg{Subject: "skill.timer.start", Data: []byte(`{"seconds":60}`)}
+	for i, id := range []string{"inv-1", "inv-2"} {
+		if err := svc.invoke(svc.log, b, msg, id); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected one invocation for a redelivered event, got %d", calls)
+	}
+
+	other := &nats.Msg{Subject: "skill.timer.start", Data: []byte(`{"seconds":60}`), Header: nats.Header{}}
+	other.Header.Set(nats.MsgIdHdr, "timer-2")
+	if err := svc.invoke(svc.log, b, other, "inv-3"); err != nil {
+		t.Fatalf("delivery with msg id: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected an event with its own Nats-Msg-Id to run, got %d calls", calls)
+	}
+
+	events, err := svc.store.ListSessionEvents(context.Background(), "skill-timer", 10)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "skill.invoke.duplicate" {
+		t.Fatalf("expected a single skill.invoke.duplicate event, got %+v", events)
+	}
+	if sum := svc.Summary()["timer"]; sum.Duplicates != 1 {
+		t.Fatalf("expected duplicate in activity summary, got %+v", sum)
+	}
+}
+
+func TestInvokeDedupsByMsgIDByDefault(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{})
+	svc.ctx = context.Background()
+	svc.dedup = newDedupCache(time.Minute)
+	calls := 0
+	svc.runSkill = func(context.Context, *slog.Logger, *binding, string, map[string]string, string, int) error {
+		calls++
+		return nil
+	}
+	b := &binding{manifest: manifestpkg.Manifest{Metadata: manifestpkg.Metadata{Name: "timer"}}}
+	repeated := &nats.Msg{Subject: "skill.timer.start", Data: []byte(`{"minutes":5}`)}
+	for i, id := range []string{"inv-1", "inv-2"} {
+		if err := svc.invoke(svc.log, b, repeated, id); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("expected identical events without Nats-Msg-Id to both run, got %d calls", calls)
+	}
+
+	redelivered := &nats.Msg{Subject: "skill.timer.start", Data: []byte(`{"minutes":5}`), Header: nats.Header{}}
+	redelivered.Header.Set(nats.MsgIdHdr, "timer-1")
+	for i, id := range []string{"inv-3", "inv-4"} {
+		if err := svc.invoke(svc.log, b, redelivered, id); err != nil {
+			t.Fatalf("delivery %d: %v", i+3, err)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("expected a redelivered Nats-Msg-Id to run once, got %d calls", calls)
+	}
+}
+
+func TestInvokeRunsRedeliveryOfFailedEvent(t *testing.T) {
+	svc := newTestService(t, config.SkillsConfig{DedupByPayload: true})
+	svc.ctx = context.Background()
+	svc.dedup = newDedupCache(time.Minute)
+	calls := 0
+	svc.runSkill = func(context.Context, *slog.Logger, *binding, string, map[string]string, string, int) error {
+		calls++
+		if calls == 1 {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	b := &binding{manifest: manifestpkg.Manifest{Metadata: manifestpkg.Metadata{Name: "timer"}}}
+	msg := &nats.Msg{Subject: "skill.timer.start", Data: []byte("{}")}
+	if err := svc.invoke(svc.log, b, msg, "inv-1"); err == nil {
+		t.Fatal("expected first delivery to fail")
+	}
+	if err := svc.invoke(svc.log, b, msg, "inv-2"); err != nil || calls != 2 {
+		t.Fatalf("expected redelivery of a failed event to run, got calls=%d err=%v", calls, err)
+	}
+}
+
+func TestRequiredCapabilitiesGateRegistration(t *testing.T) {
+	client := testutil.StartBus(t)
+	svc := newTestService(t, config.SkillsConfig{Directory: t.TempDir()})
+	nodeCfg := config.Default().Node
+	nodeCfg.Capabilities = []config.NodeCapability{{Name: "tts"}}
+	registry, err := capability.NewRegistry(context.Background(), nodeCfg, client, protocol.DefaultSubjects(), svc.log)
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(registry.Close)
+	svc.registry = registry
+	svc.bus = client
+
+	for name, requires := range map[string]string{"speaker": "[tts]", "assistant": "[tts, llm]", "navigator": "[gps]"} {
+		path := writeSkill(t, svc.cfg.Directory, name, name, "0.1.0")
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fmt.Fprintf(f, "requires: %s\n", requires); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	if err := svc.loadSkills(); err != nil {
+		t.Fatalf("load skills: %v", err)
+	}
+	if _, ok := svc.skills["speaker"]; !ok {
+		t.Fatal("expected skill whose requirements are advertised to load")
+	}
+	if _, ok := svc.skills["assistant"]; ok || len(svc.deferred) != 2 {
+		t.Fatalf("expected skills with unmet requires to be deferred, got %d deferred", len(svc.deferred))
+	}
+
+	// A peer advertising llm joins before the registry converges.
+	peerCfg := config.Default().Node
+	peerCfg.ID = "llm-node"
+	peerCfg.Capabilities = []config.NodeCapability{{Name: "llm"}}
+	peer, err := capability.NewRegistry(context.Background(), peerCfg, client, protocol.DefaultSubjects(), svc.log)
+	if err != nil {
+		t.Fatalf("new peer registry: %v", err)
+	}
+	t.Cleanup(peer.Close)
+	select {
+	case <-registry.Converged():
+	case <-time.After(10 * time.Second):
+		t.Fatal("registry did not converge")
+	}
+	svc.resolveDeferred()
+
+	if _, ok := svc.skills["assistant"]; !ok {
+		t.Fatal("expected skill to register once a peer advertising llm joined")
+	}
+	if _, ok := svc.skills["navigator"]; ok {
+		t.Fatal("expected skill requiring an absent gps capability to be skipped")
+	}
+	if len(svc.deferred) != 0 || len(svc.subs) != 1 {
+		t.Fatalf("expected only the assistant to subscribe, got %d deferred and %d subscriptions", len(svc.deferred), len(svc.subs))
+	}
+}
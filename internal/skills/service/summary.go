@@ -0,0 +1,131 @@
+package service
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	skillrt "github.com/loqalabs/loqa-core/internal/skills/runtime"
+)
+
+// SkillSummary aggregates one skill's activity over the process lifetime.
+type SkillSummary struct {
+	Invocations     int64 `json:"invocations"`
+	Errors          int64 `json:"errors"`
+	Rejected        int64 `json:"rejected"`
+	Duplicates      int64 `json:"duplicates"`
+	Retries         int64 `json:"retries"`
+	DeadLettered    int64 `json:"dead_lettered"`
+	Publishes       int64 `json:"publishes"`
+	Throttled       int64 `json:"throttled"` // throttling episodes, not dropped messages
+	HTTPCalls       int64 `json:"http_calls"`
+	HostPanics      int64 `json:"host_panics"`
+	TotalDurationMS int64 `json:"total_duration_ms"`
+}
+
+// activity counts audit events per skill in memory so operators get a
+// picture of skill activity without querying the event store.
+type activity struct {
+	mu     sync.Mutex
+	skills map[string]*SkillSummary
+	// durations accumulates run time at full precision; converting each
+	// run to milliseconds would drop sub-millisecond runs entirely.
+	durations map[string]time.Duration
+}
+
+func newActivity() *activity {
+	return &activity{skills: make(map[string]*SkillSummary), durations: make(map[string]time.Duration)}
+}
+
+func (a *activity) entry(skill string) *SkillSummary {
+	sum := a.skills[skill]
+	if sum == nil {
+		sum = &SkillSummary{}
+		a.skills[skill] = sum
+	}
+	return sum
+}
+
+func (a *activity) record(skill string, event skillrt.AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sum := a.entry(skill)
+	switch event.Type {
+	case "skill.invoke.start":
+		// Retried attempts count once, as the invocation they belong to.
+		if attempt, ok := event.Data["attempt"].(int); !ok || attempt <= 1 {
+			sum.Invocations++
+		}
+	case "skill.invoke.retry":
+		sum.Retries++
+	case "skill.invoke.dead_letter":
+		sum.DeadLettered++
+	case "skill.invoke.error":
+		sum.Errors++
+	case "skill.invoke.rejected":
+		sum.Rejected++
+	case "skill.invoke.duplicate":
+		sum.Duplicates++
+	case "skill.publish":
+		sum.Publishes++
+	case "skill.publish.throttled":
+		sum.Throttled++
+	case "skill.http":
+		sum.HTTPCalls++
+	case "skill.host.panic":
+		sum.HostPanics++
+	}
+}
+
+func (a *activity) addDuration(skill string, d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entry(skill)
+	a.durations[skill] += d
+}
+
+func (a *activity) snapshot() map[string]SkillSummary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make(map[string]SkillSummary, len(a.skills))
+	for name, sum := range a.skills {
+		entry := *sum
+		entry.TotalDurationMS = a.durations[name].Milliseconds()
+		out[name] = entry
+	}
+	return out
+}
+
+// Summary returns per-skill activity counters since the service started.
+func (s *Service) Summary() map[string]SkillSummary {
+	if s == nil {
+		return nil
+	}
+	return s.activity.snapshot()
+}
+
+func (s *Service) logSummary() {
+	summary := s.activity.snapshot()
+	names := make([]string, 0, len(summary))
+	for name := range summary {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sum := summary[name]
+		s.log.Info("skill activity summary",
+			slog.String("skill", name),
+			slog.Int64("invocations", sum.Invocations),
+			slog.Int64("errors", sum.Errors),
+			slog.Int64("rejected", sum.Rejected),
+			slog.Int64("retries", sum.Retries),
+			slog.Int64("dead_lettered", sum.DeadLettered),
+			slog.Int64("publishes", sum.Publishes),
+			slog.Int64("throttled", sum.Throttled),
+			slog.Int64("http_calls", sum.HTTPCalls),
+			slog.Int64("host_panics", sum.HostPanics),
+			slog.Int64("total_duration_ms", sum.TotalDurationMS),
+		)
+	}
+}
@@ -0,0 +1,36 @@
+package stt
+
+import (
+	"fmt"
+
+	"github.com/loqalabs/loqa-core/internal/bus"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/nats-io/nats.go"
+)
+
+// PublishAudioFrame sends frame on subject, as raw PCM with header metadata
+// when bus.raw_audio is enabled and through the bus codec otherwise. Ingest
+// producers use it so the STT service sees the configured wire format.
+func PublishAudioFrame(client *bus.Client, subject string, frame protocol.AudioFrame) error {
+	if client.RawAudio() {
+		return client.PublishMsg(protocol.RawAudioFrameMsg(subject, frame))
+	}
+	data, err := client.Codec().Marshal(frame)
+	if err != nil {
+		return fmt.Errorf("marshal audio frame: %w", err)
+	}
+	return client.Publish(subject, data)
+}
+
+// DecodeAudioFrame reads an audio frame in either wire format, so raw and
+// codec-encoded producers can share a subject.
+func DecodeAudioFrame(client *bus.Client, msg *nats.Msg) (protocol.AudioFrame, error) {
+	if protocol.IsRawAudio(msg) {
+		return protocol.AudioFrameFromRaw(msg)
+	}
+	var frame protocol.AudioFrame
+	if err := client.Codec().Unmarshal(msg.Data, &frame); err != nil {
+		return frame, err
+	}
+	return frame, nil
+}
@@ -6,87 +6,113 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"strconv"
 	"sync"
 
 	"github.com/go-audio/audio"
 	"github.com/go-audio/wav"
 	"github.com/loqalabs/loqa-core/internal/config"
-	"github.com/mattn/go-shellwords"
+	loqaexec "github.com/loqalabs/loqa-core/internal/exec"
 )
 
 type execRecognizer struct {
-	cmd []string
-	cfg config.STTConfig
-	mu  sync.Mutex
+	runner *loqaexec.Runner
+	cfg    config.STTConfig
+	mu     sync.Mutex
 }
 
+// execArgNames are the placeholders available to stt.command_args.
+var execArgNames = []string{"audio", "sample_rate", "channels", "model", "language", "partial"}
+
 type execResult struct {
 	Text       string  `json:"text"`
 	Confidence float64 `json:"confidence"`
+	Language   string  `json:"language"`
 }
 
 func NewExecRecognizer(cfg config.STTConfig) (Recognizer, error) {
-	parser := shellwords.NewParser()
-	args, err := parser.Parse(cfg.Command)
+	runner, err := loqaexec.NewRunner("stt", loqaexec.Spec{
+		Command:  cfg.Command,
+		Args:     cfg.CommandArgs,
+		ArgNames: execArgNames,
+		Env:      loqaexec.Environ(cfg.ExecEnvPassthrough, cfg.ExecEnv),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("parse stt command: %w", err)
-	}
-	if len(args) == 0 {
-		return nil, fmt.Errorf("stt command is empty")
+		return nil, err
 	}
-	return &execRecognizer{cmd: args, cfg: cfg}, nil
+	return &execRecognizer{runner: runner, cfg: cfg}, nil
 }
 
-func (r *execRecognizer) Transcribe(ctx context.Context, pcm []byte, sampleRate int, channels int, final bool) (TranscriptResult, error) {
+func (r *execRecognizer) Transcribe(ctx context.Context, pcm []byte, sampleRate int, channels int, language string, final bool) (TranscriptResult, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	tmpDir := os.TempDir()
-	file, err := os.CreateTemp(tmpDir, "loqa_stt_*.wav")
-	if err != nil {
-		return TranscriptResult{}, fmt.Errorf("temp file: %w", err)
+	if language == "" {
+		language = r.cfg.Language
 	}
-	defer os.Remove(file.Name())
-	defer file.Close()
+	partial := r.cfg.Mode == "exec" && r.cfg.PublishInterim && !final
 
-	if err := writePCMToWav(file, pcm, sampleRate, channels); err != nil {
-		return TranscriptResult{}, err
-	}
+	var stdin io.Reader
+	var audioPath string
+	if r.cfg.StdinPCM {
+		stdin = bytes.NewReader(pcm)
+	} else {
+		file, err := os.CreateTemp(os.TempDir(), "loqa_stt_*.wav")
+		if err != nil {
+			return TranscriptResult{}, fmt.Errorf("temp file: %w", err)
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
 
-	args := append([]string{}, r.cmd...)
-	if len(args) == 0 {
-		return TranscriptResult{}, fmt.Errorf("stt command empty")
-	}
-	base := args[0]
-	cmdArgs := args[1:]
-	cmdArgs = append(cmdArgs, "--audio", file.Name())
-	if r.cfg.ModelPath != "" {
-		cmdArgs = append(cmdArgs, "--model", r.cfg.ModelPath)
+		if err := writePCMToWav(file, pcm, sampleRate, channels); err != nil {
+			return TranscriptResult{}, err
+		}
+		audioPath = file.Name()
 	}
-	if r.cfg.Language != "" {
-		cmdArgs = append(cmdArgs, "--language", r.cfg.Language)
-	}
-	if r.cfg.Mode == "exec" && r.cfg.PublishInterim && !final {
-		cmdArgs = append(cmdArgs, "--partial")
-	}
-
-	command := exec.CommandContext(ctx, base, cmdArgs...)
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	command.Stdout = &stdout
-	command.Stderr = &stderr
 
-	if err := command.Run(); err != nil {
-		return TranscriptResult{}, fmt.Errorf("stt command failed: %w: %s", err, stderr.String())
+	var args []string
+	if r.runner.Templated() {
+		args = r.runner.Render(map[string]string{
+			"audio":       audioPath,
+			"sample_rate": strconv.Itoa(sampleRate),
+			"channels":    strconv.Itoa(channels),
+			"model":       r.cfg.ModelPath,
+			"language":    language,
+			"partial":     strconv.FormatBool(partial),
+		})
+	} else {
+		if r.cfg.StdinPCM {
+			// Raw little-endian s16 PCM is piped directly; the format travels as flags.
+			args = append(args,
+				"--stdin-pcm",
+				"--sample-rate", strconv.Itoa(sampleRate),
+				"--channels", strconv.Itoa(channels),
+			)
+		} else {
+			args = append(args, "--audio", audioPath)
+		}
+		if r.cfg.ModelPath != "" {
+			args = append(args, "--model", r.cfg.ModelPath)
+		}
+		if language != "" {
+			args = append(args, "--language", language)
+		}
+		if partial {
+			args = append(args, "--partial")
+		}
 	}
 
+	output, err := r.runner.Run(ctx, args, stdin)
+	if err != nil {
+		return TranscriptResult{}, err
+	}
 	var resp execResult
-	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+	if err := json.Unmarshal(output, &resp); err != nil {
 		return TranscriptResult{}, fmt.Errorf("decode stt response: %w", err)
 	}
-	return TranscriptResult{Text: resp.Text, Confidence: resp.Confidence}, nil
+	return TranscriptResult{Text: resp.Text, Confidence: resp.Confidence, Language: resp.Language}, nil
 }
 
 func writePCMToWav(file *os.File, pcm []byte, sampleRate int, channels int) error {
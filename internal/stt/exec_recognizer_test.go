@@ -0,0 +1,105 @@
+package stt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+)
+
+func TestExecRecognizerSessionLanguageOverridesConfig(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := filepath.Join(dir, "stt.sh")
+	body := `#!/bin/sh
+echo "$@" > '` + argsFile + `'
+echo '{"text":"hallo","confidence":0.9,"language":"de"}'
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := NewExecRecognizer(config.STTConfig{Mode: "exec", Command: "sh " + script, Language: "en"})
+	if err != nil {
+		t.Fatalf("create recognizer: %v", err)
+	}
+
+	cases := []struct {
+		session string
+		want    string
+	}{
+		{session: "de", want: "--language de"},
+		{session: "auto", want: "--language auto"},
+		{session: "", want: "--language en"},
+	}
+	for _, tc := range cases {
+		result, err := rec.Transcribe(context.Background(), make([]byte, 320), 16000, 1, tc.session, true)
+		if err != nil {
+			t.Fatalf("transcribe (%q): %v", tc.session, err)
+		}
+		if result.Language != "de" {
+			t.Fatalf("expected detected language de, got %q", result.Language)
+		}
+		args, err := os.ReadFile(argsFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(string(args)); !strings.HasSuffix(got, tc.want) || strings.Count(got, "--language") != 1 {
+			t.Fatalf("session language %q: expected args ending in %q, got %q", tc.session, tc.want, got)
+		}
+	}
+}
+
+func TestTranscriptLanguage(t *testing.T) {
+	cases := []struct{ detected, session, configured, want string }{
+		{"fr", "auto", "en", "fr"},
+		{"", "de", "en", "de"},
+		{"", "", "en", "en"},
+		{"", "auto", "en", ""},
+		{"", "", "auto", ""},
+	}
+	for _, tc := range cases {
+		if got := transcriptLanguage(tc.detected, tc.session, tc.configured); got != tc.want {
+			t.Fatalf("transcriptLanguage(%q, %q, %q) = %q, want %q", tc.detected, tc.session, tc.configured, got, tc.want)
+		}
+	}
+}
+
+func TestExecRecognizerRendersCommandArgs(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := filepath.Join(dir, "stt.sh")
+	body := `#!/bin/sh
+echo "$@" > '` + argsFile + `'
+echo '{"text":"hello","confidence":0.9}'
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := NewExecRecognizer(config.STTConfig{
+		Mode:        "exec",
+		Command:     "sh " + script,
+		Language:    "en",
+		CommandArgs: []string{"-f", "{audio}", "--lang", "{language}", "{model}", "-ar={sample_rate}"},
+	})
+	if err != nil {
+		t.Fatalf("create recognizer: %v", err)
+	}
+	if _, err := rec.Transcribe(context.Background(), make([]byte, 320), 16000, 1, "", true); err != nil {
+		t.Fatalf("transcribe: %v", err)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Fields(string(args))
+	if len(fields) != 5 || fields[0] != "-f" || !strings.HasSuffix(fields[1], ".wav") || fields[2] != "--lang" || fields[3] != "en" || fields[4] != "-ar=16000" {
+		t.Fatalf("unexpected rendered args %q", fields)
+	}
+
+	if _, err := NewExecRecognizer(config.STTConfig{Mode: "exec", Command: "sh " + script, CommandArgs: []string{"{voice}"}}); err == nil {
+		t.Fatal("expected unknown placeholder to be rejected")
+	}
+}
@@ -11,7 +11,7 @@ func NewMockRecognizer() Recognizer {
 	return &mockRecognizer{}
 }
 
-func (m *mockRecognizer) Transcribe(_ context.Context, pcm []byte, _ int, _ int, final bool) (TranscriptResult, error) {
+func (m *mockRecognizer) Transcribe(_ context.Context, pcm []byte, _ int, _ int, _ string, final bool) (TranscriptResult, error) {
 	mode := "partial"
 	if final {
 		mode = "final"
@@ -8,9 +8,12 @@ import (
 type TranscriptResult struct {
 	Text       string
 	Confidence float64
+	// Language is the language the engine recognized, when it reports one.
+	Language string
 }
 
-// Recognizer abstracts STT backends.
+// Recognizer abstracts STT backends. language is the session's requested
+// language ("auto" to detect); empty means the configured default.
 type Recognizer interface {
-	Transcribe(ctx context.Context, pcm []byte, sampleRate int, channels int, final bool) (TranscriptResult, error)
+	Transcribe(ctx context.Context, pcm []byte, sampleRate int, channels int, language string, final bool) (TranscriptResult, error)
 }
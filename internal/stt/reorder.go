@@ -0,0 +1,125 @@
+package stt
+
+import "sort"
+
+// reorderBuffer restores sequence order for audio frames that NATS delivered
+// out of order. Frames are held until their predecessors arrive; once more
+// than window frames are waiting, the missing sequence numbers are given up
+// as a gap. A window of zero disables reordering and passes frames through
+// in arrival order. Publishers may count from 0 or 1, so until frame 0
+// arrives the opening frames are held and the stream starts at the lowest of
+// the first window frames received, whichever order they came in.
+type reorderBuffer struct {
+	window   int
+	started  bool
+	next     int
+	pending  map[int][]byte
+	finalSeq int
+}
+
+// reorderResult reports what a pushed frame released.
+type reorderResult struct {
+	PCM       []byte // contiguous audio now ready to append
+	Duplicate bool   // frame was already seen, stale, or beyond the final frame
+	Skipped   int    // sequence numbers abandoned as a gap
+}
+
+func newReorderBuffer(window int) *reorderBuffer {
+	return &reorderBuffer{window: window, pending: make(map[int][]byte), finalSeq: -1}
+}
+
+func (r *reorderBuffer) push(seq int, pcm []byte, final bool) reorderResult {
+	if r.window <= 0 {
+		r.started = true
+		r.next = seq + 1
+		if final {
+			r.finalSeq = seq
+		}
+		return reorderResult{PCM: pcm}
+	}
+	if _, seen := r.pending[seq]; seen || (r.started && seq < r.next) || (r.finalSeq >= 0 && seq > r.finalSeq) {
+		return reorderResult{Duplicate: true}
+	}
+	r.pending[seq] = pcm
+	if final {
+		r.finalSeq = seq
+	}
+	if !r.started {
+		if seq != 0 && len(r.pending) < r.window {
+			return reorderResult{}
+		}
+		r.start()
+	}
+	res := reorderResult{PCM: r.release(nil)}
+	for len(r.pending) > r.window {
+		first := r.lowestPending()
+		res.Skipped += first - r.next
+		r.next = first
+		res.PCM = r.release(res.PCM)
+	}
+	return res
+}
+
+// flush releases every pending frame in sequence order, skipping gaps. It is
+// used once the final frame has waited long enough for stragglers.
+func (r *reorderBuffer) flush() reorderResult {
+	var res reorderResult
+	if !r.started && len(r.pending) > 0 {
+		r.start()
+	}
+	keys := make([]int, 0, len(r.pending))
+	for seq := range r.pending {
+		keys = append(keys, seq)
+	}
+	sort.Ints(keys)
+	for _, seq := range keys {
+		res.Skipped += seq - r.next
+		res.PCM = append(res.PCM, r.pending[seq]...)
+		delete(r.pending, seq)
+		r.next = seq + 1
+	}
+	if r.finalSeq >= r.next {
+		res.Skipped += r.finalSeq + 1 - r.next
+		r.next = r.finalSeq + 1
+	}
+	return res
+}
+
+// finalSeen reports whether the final frame has arrived.
+func (r *reorderBuffer) finalSeen() bool {
+	return r.finalSeq >= 0
+}
+
+// complete reports whether the final frame and everything before it has
+// been released.
+func (r *reorderBuffer) complete() bool {
+	return r.finalSeen() && r.next > r.finalSeq
+}
+
+// start anchors the stream at the lowest frame held so far.
+func (r *reorderBuffer) start() {
+	r.started = true
+	r.next = r.lowestPending()
+}
+
+func (r *reorderBuffer) release(out []byte) []byte {
+	for {
+		pcm, ok := r.pending[r.next]
+		if !ok {
+			return out
+		}
+		out = append(out, pcm...)
+		delete(r.pending, r.next)
+		r.next++
+	}
+}
+
+func (r *reorderBuffer) lowestPending() int {
+	first := -1
+	for seq := range r.pending {
+		if first < 0 || seq < first {
+			first = seq
+		}
+	}
+	return first
+}
@@ -0,0 +1,128 @@
+package stt
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestReorderBufferAssemblesShuffledFrames(t *testing.T) {
+	const frames = 20
+	var want []byte
+	order := make([]int, frames)
+	for i := range order {
+		order[i] = i
+		want = append(want, byte(i), byte(i))
+	}
+	rng := rand.New(rand.NewSource(1))
+	// Shuffle within small neighbourhoods so every frame lands inside the window.
+	for i := 0; i+3 < frames; i += 4 {
+		rng.Shuffle(4, func(a, b int) { order[i+a], order[i+b] = order[i+b], order[i+a] })
+	}
+
+	buf := newReorderBuffer(4)
+	var got []byte
+	for _, seq := range order {
+		res := buf.push(seq, []byte{byte(seq), byte(seq)}, seq == frames-1)
+		if res.Duplicate || res.Skipped != 0 {
+			t.Fatalf("unexpected result for frame %d: %+v", seq, res)
+		}
+		got = append(got, res.PCM...)
+		if dup := buf.push(seq, []byte{0xff}, false); !dup.Duplicate {
+			t.Fatalf("expected replayed frame %d to be a duplicate", seq)
+		}
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("buffer assembled out of order: %v", got)
+	}
+	if !buf.complete() {
+		t.Fatal("expected buffer to be complete after final frame")
+	}
+}
+
+func TestReorderBufferSkipsGapBeyondWindow(t *testing.T) {
+	buf := newReorderBuffer(2)
+	buf.push(0, []byte{0}, false)
+	buf.push(2, []byte{2}, false)
+	buf.push(3, []byte{3}, false)
+	res := buf.push(4, []byte{4}, false)
+	if res.Skipped != 1 || !bytes.Equal(res.PCM, []byte{2, 3, 4}) {
+		t.Fatalf("expected frame 1 skipped and 2-4 released, got %+v", res)
+	}
+	if late := buf.push(1, []byte{1}, false); !late.Duplicate {
+		t.Fatal("expected frame arriving after its gap was skipped to be dropped")
+	}
+}
+
+func TestReorderBufferFinalBeforeStragglers(t *testing.T) {
+	buf := newReorderBuffer(8)
+	buf.push(0, []byte{0}, false)
+	buf.push(3, []byte{3}, true)
+	if !buf.finalSeen() || buf.complete() {
+		t.Fatal("final frame should be seen but incomplete while frames 1-2 are missing")
+	}
+	res := buf.push(1, []byte{1}, false)
+	if !bytes.Equal(res.PCM, []byte{1}) {
+		t.Fatalf("expected straggler 1 released, got %+v", res)
+	}
+	flushed := buf.flush()
+	if flushed.Skipped != 1 || !bytes.Equal(flushed.PCM, []byte{3}) || !buf.complete() {
+		t.Fatalf("expected flush to skip frame 2 and release final, got %+v", flushed)
+	}
+}
+
+func TestReorderBufferDisabledKeepsArrivalOrder(t *testing.T) {
+	buf := newReorderBuffer(0)
+	var got []byte
+	for _, seq := range []int{1, 0, 2} {
+		got = append(got, buf.push(seq, []byte{byte(seq)}, seq == 2).PCM...)
+	}
+	if !bytes.Equal(got, []byte{1, 0, 2}) || !buf.complete() {
+		t.Fatalf("expected arrival order with reordering disabled, got %v", got)
+	}
+}
+
+func TestReorderBufferStreamStartingAtOne(t *testing.T) {
+	// Frame 0 never comes, so the stream starts at 1 once two frames are held.
+	buf := newReorderBuffer(2)
+	var got []byte
+	for _, seq := range []int{1, 3, 2} {
+		res := buf.push(seq, []byte{byte(seq)}, seq == 3)
+		if res.Duplicate || res.Skipped != 0 {
+			t.Fatalf("unexpected result for frame %d: %+v", seq, res)
+		}
+		got = append(got, res.PCM...)
+	}
+	if !bytes.Equal(got, []byte{1, 2, 3}) || !buf.complete() {
+		t.Fatalf("expected frames 1-3 released without a gap, got %v", got)
+	}
+}
+
+func TestReorderBufferFirstFrameArrivesSecond(t *testing.T) {
+	for _, start := range []int{0, 1} {
+		buf := newReorderBuffer(3)
+		order := []int{start + 1, start, start + 2, start + 3}
+		var got []byte
+		for _, seq := range order {
+			res := buf.push(seq, []byte{byte(seq)}, seq == start+3)
+			if res.Duplicate || res.Skipped != 0 {
+				t.Fatalf("start %d: unexpected result for frame %d: %+v", start, seq, res)
+			}
+			got = append(got, res.PCM...)
+		}
+		want := []byte{byte(start), byte(start + 1), byte(start + 2), byte(start + 3)}
+		if !bytes.Equal(got, want) || !buf.complete() {
+			t.Fatalf("start %d: expected %v, got %v", start, want, got)
+		}
+	}
+}
+
+func TestReorderBufferFlushStartsAtLowestHeldFrame(t *testing.T) {
+	buf := newReorderBuffer(8)
+	buf.push(2, []byte{2}, true)
+	buf.push(1, []byte{1}, false)
+	res := buf.flush()
+	if res.Skipped != 0 || !bytes.Equal(res.PCM, []byte{1, 2}) || !buf.complete() {
+		t.Fatalf("expected a short stream to flush from its lowest frame, got %+v", res)
+	}
+}
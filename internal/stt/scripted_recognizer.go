@@ -0,0 +1,87 @@
+package stt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// scriptedRecognizer replays a fixed list of transcripts so integration tests
+// can drive the router with known text. Final transcriptions consume the next
+// entry; partials preview it without advancing.
+type scriptedRecognizer struct {
+	mu        sync.Mutex
+	responses []TranscriptResult
+	next      int
+	cycle     bool
+}
+
+// NewScriptedRecognizer returns a recognizer that yields responses in order.
+// Once the list is exhausted it starts over when cycle is true and otherwise
+// keeps returning the last entry.
+func NewScriptedRecognizer(responses []TranscriptResult, cycle bool) (Recognizer, error) {
+	if len(responses) == 0 {
+		return nil, errors.New("scripted recognizer requires at least one transcript")
+	}
+	return &scriptedRecognizer{responses: append([]TranscriptResult(nil), responses...), cycle: cycle}, nil
+}
+
+func (s *scriptedRecognizer) Transcribe(_ context.Context, _ []byte, _ int, _ int, _ string, final bool) (TranscriptResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := s.responses[s.next]
+	if final {
+		switch {
+		case s.next < len(s.responses)-1:
+			s.next++
+		case s.cycle:
+			s.next = 0
+		}
+	}
+	return result, nil
+}
+
+// LoadScript reads transcripts from path, one per line. Blank lines and lines
+// starting with # are ignored.
+func LoadScript(path string) ([]TranscriptResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open stt script: %w", err)
+	}
+	defer f.Close()
+
+	var results []TranscriptResult
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		results = append(results, TranscriptResult{Text: line, Confidence: 1})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read stt script: %w", err)
+	}
+	return results, nil
+}
+
+// ScriptFromConfig combines stt.script_transcripts with the phrases in
+// stt.script_file, in that order.
+func ScriptFromConfig(transcripts []string, file string) ([]TranscriptResult, error) {
+	results := make([]TranscriptResult, 0, len(transcripts))
+	for _, text := range transcripts {
+		results = append(results, TranscriptResult{Text: text, Confidence: 1})
+	}
+	if file != "" {
+		fromFile, err := LoadScript(file)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, fromFile...)
+	}
+	return results, nil
+}
@@ -0,0 +1,56 @@
+package stt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScriptedRecognizerOrder(t *testing.T) {
+	script := []TranscriptResult{{Text: "lights on"}, {Text: "lights off"}}
+	ctx := context.Background()
+
+	hold, err := NewScriptedRecognizer(script, false)
+	if err != nil {
+		t.Fatalf("create recognizer: %v", err)
+	}
+	if got, _ := hold.Transcribe(ctx, nil, 16000, 1, "", false); got.Text != "lights on" {
+		t.Fatalf("expected partial to preview first transcript, got %q", got.Text)
+	}
+	for i, want := range []string{"lights on", "lights off", "lights off"} {
+		if got, _ := hold.Transcribe(ctx, nil, 16000, 1, "", true); got.Text != want {
+			t.Fatalf("final %d: expected %q, got %q", i, want, got.Text)
+		}
+	}
+
+	cycle, err := NewScriptedRecognizer(script, true)
+	if err != nil {
+		t.Fatalf("create recognizer: %v", err)
+	}
+	for i, want := range []string{"lights on", "lights off", "lights on"} {
+		if got, _ := cycle.Transcribe(ctx, nil, 16000, 1, "", true); got.Text != want {
+			t.Fatalf("final %d: expected %q, got %q", i, want, got.Text)
+		}
+	}
+}
+
+func TestScriptFromConfigAppendsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.txt")
+	if err := os.WriteFile(path, []byte("# phrases\nset a timer\n\nwhat time is it\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	script, err := ScriptFromConfig([]string{"hello"}, path)
+	if err != nil {
+		t.Fatalf("load script: %v", err)
+	}
+	want := []string{"hello", "set a timer", "what time is it"}
+	if len(script) != len(want) {
+		t.Fatalf("expected %d transcripts, got %+v", len(want), script)
+	}
+	for i, w := range want {
+		if script[i].Text != w {
+			t.Fatalf("transcript %d: expected %q, got %q", i, w, script[i].Text)
+		}
+	}
+}
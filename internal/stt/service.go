@@ -2,7 +2,6 @@ package stt
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"sync"
@@ -10,6 +9,7 @@ import (
 
 	"github.com/loqalabs/loqa-core/internal/bus"
 	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/logging"
 	"github.com/loqalabs/loqa-core/internal/protocol"
 	"github.com/nats-io/nats.go"
 )
@@ -17,6 +17,7 @@ import (
 type Service struct {
 	cfg        config.STTConfig
 	bus        *bus.Client
+	subjects   protocol.Subjects
 	recognizer Recognizer
 	sessions   map[string]*sessionState
 	mu         sync.Mutex
@@ -32,13 +33,21 @@ type sessionState struct {
 	LastPartial  time.Time
 	Inflight     bool
 	PendingFinal bool
+	TraceID      string
+	SpanID       string
+	Target       string
+	Language     string
+	Reorder      *reorderBuffer
+	FinalQueued  bool
+	FinalTimer   *time.Timer
 }
 
-func NewService(parent context.Context, cfg config.STTConfig, busClient *bus.Client, recognizer Recognizer) *Service {
+func NewService(parent context.Context, cfg config.STTConfig, busClient *bus.Client, subjects protocol.Subjects, recognizer Recognizer) *Service {
 	ctx, cancel := context.WithCancel(parent)
 	return &Service{
 		cfg:        cfg,
 		bus:        busClient,
+		subjects:   subjects,
 		recognizer: recognizer,
 		sessions:   make(map[string]*sessionState),
 		ctx:        ctx,
@@ -50,8 +59,8 @@ func (s *Service) Start() error {
 	if !s.cfg.Enabled {
 		return nil
 	}
-	subject := protocol.SubjectAudioFramePrefix + ".>"
-	sub, err := s.bus.Conn().Subscribe(subject, s.handleFrame)
+	subject := s.subjects.AudioFramePrefix + ".>"
+	sub, err := s.bus.Subscribe(subject, s.handleFrame)
 	if err != nil {
 		return fmt.Errorf("subscribe audio frames: %w", err)
 	}
@@ -63,6 +72,11 @@ func (s *Service) Start() error {
 
 func (s *Service) Close() {
 	s.cancel()
+	s.mu.Lock()
+	for _, state := range s.sessions {
+		s.stopFinalTimerLocked(state)
+	}
+	s.mu.Unlock()
 	if s.sub != nil {
 		_ = s.sub.Drain()
 	}
@@ -73,26 +87,56 @@ func (s *Service) Healthy() bool {
 	return !s.cfg.Enabled || s.ready
 }
 
+// InFlight reports the number of sessions currently buffering or
+// transcribing audio.
+func (s *Service) InFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
 func (s *Service) handleFrame(msg *nats.Msg) {
-	var frame protocol.AudioFrame
-	if err := json.Unmarshal(msg.Data, &frame); err != nil {
+	frame, err := DecodeAudioFrame(s.bus, msg)
+	if err != nil {
 		s.bus.Logger().Warn("failed to decode audio frame", slogError(err))
 		return
 	}
+	if err := protocol.CheckVersion(frame.V); err != nil {
+		s.bus.Logger().Warn("rejecting audio frame", slogError(err))
+		return
+	}
+	log := logging.WithSession(s.bus.Logger(), frame.SessionID, frame.TraceID)
 
 	s.mu.Lock()
 	state := s.sessions[frame.SessionID]
 	if state == nil {
-		state = &sessionState{}
+		state = &sessionState{Reorder: newReorderBuffer(s.cfg.ReorderWindow)}
 		s.sessions[frame.SessionID] = state
-		s.bus.Logger().Info("new STT session started", slog.String("session_id", frame.SessionID))
+		log.Info("new STT session started")
+	}
+	ordered := state.Reorder.push(frame.Sequence, frame.PCM, frame.Final)
+	state.Buffer = append(state.Buffer, ordered.PCM...)
+	if state.TraceID == "" && frame.TraceID != "" {
+		state.TraceID, state.SpanID = frame.TraceID, frame.SpanID
+	}
+	if state.Target == "" && frame.Target != "" {
+		state.Target = frame.Target
+	}
+	if state.Language == "" && frame.Language != "" {
+		state.Language = frame.Language
 	}
-	state.Buffer = append(state.Buffer, frame.PCM...)
 	bufferSize := len(state.Buffer)
+	finalReady := s.finalReadyLocked(frame.SessionID, state)
 	s.mu.Unlock()
 
-	s.bus.Logger().Debug("received audio frame",
-		slog.String("session_id", frame.SessionID),
+	if ordered.Duplicate {
+		log.Debug("dropping duplicate or late audio frame", slog.Int("sequence", frame.Sequence))
+		return
+	}
+	if ordered.Skipped > 0 {
+		log.Warn("audio frames missing, skipping gap", slog.Int("sequence", frame.Sequence), slog.Int("skipped", ordered.Skipped))
+	}
+	log.Debug("received audio frame",
 		slog.Int("sequence", frame.Sequence),
 		slog.Int("pcm_bytes", len(frame.PCM)),
 		slog.Int("buffer_size", bufferSize),
@@ -101,18 +145,75 @@ func (s *Service) handleFrame(msg *nats.Msg) {
 	if s.cfg.PublishInterim && !frame.Final {
 		schedulePartial := s.shouldSchedulePartial(frame.SessionID)
 		if schedulePartial {
-			s.bus.Logger().Info("scheduling partial transcription", slog.String("session_id", frame.SessionID))
+			log.Info("scheduling partial transcription")
 			s.scheduleTranscription(frame.SessionID, false)
 		}
 	}
-	if frame.Final {
-		s.bus.Logger().Info("scheduling final transcription",
-			slog.String("session_id", frame.SessionID),
-			slog.Int("total_buffer_size", bufferSize))
+	if finalReady {
+		log.Info("scheduling final transcription", slog.Int("total_buffer_size", bufferSize))
 		s.scheduleTranscription(frame.SessionID, true)
 	}
 }
 
+// finalReadyLocked reports whether the session's final transcription should
+// be scheduled now. When the final frame arrived ahead of stragglers it arms
+// a timer that gives them the reorder window's worth of audio to catch up.
+// Callers must hold s.mu.
+func (s *Service) finalReadyLocked(sessionID string, state *sessionState) bool {
+	if state.FinalQueued || !state.Reorder.finalSeen() {
+		return false
+	}
+	if state.Reorder.complete() {
+		state.FinalQueued = true
+		s.stopFinalTimerLocked(state)
+		return true
+	}
+	if state.FinalTimer == nil {
+		wait := time.Duration(s.cfg.ReorderWindow*s.cfg.FrameDurationMS) * time.Millisecond
+		s.wg.Add(1)
+		state.FinalTimer = time.AfterFunc(wait, func() {
+			defer s.wg.Done()
+			s.flushStragglers(sessionID)
+		})
+	}
+	return false
+}
+
+// stopFinalTimerLocked stops the session's straggler timer, if armed, and
+// releases its hold on the wait group when it had not fired yet. Callers
+// must hold s.mu.
+func (s *Service) stopFinalTimerLocked(state *sessionState) {
+	if state.FinalTimer != nil && state.FinalTimer.Stop() {
+		s.wg.Done()
+	}
+	state.FinalTimer = nil
+}
+
+// flushStragglers stops waiting for missing frames and finalizes the session
+// with whatever audio arrived.
+func (s *Service) flushStragglers(sessionID string) {
+	if s.ctx.Err() != nil {
+		return
+	}
+	s.mu.Lock()
+	state := s.sessions[sessionID]
+	if state == nil || state.FinalQueued {
+		s.mu.Unlock()
+		return
+	}
+	ordered := state.Reorder.flush()
+	state.Buffer = append(state.Buffer, ordered.PCM...)
+	state.FinalQueued = true
+	bufferSize := len(state.Buffer)
+	traceID := state.TraceID
+	s.mu.Unlock()
+
+	log := logging.WithSession(s.bus.Logger(), sessionID, traceID)
+	log.Warn("final audio frame arrived before stragglers, finalizing without them", slog.Int("skipped", ordered.Skipped))
+	log.Info("scheduling final transcription", slog.Int("total_buffer_size", bufferSize))
+	s.scheduleTranscription(sessionID, true)
+}
+
 func (s *Service) shouldSchedulePartial(sessionID string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -153,8 +254,11 @@ func (s *Service) scheduleTranscription(sessionID string, final bool) {
 		return
 	}
 	pcm := append([]byte(nil), state.Buffer...)
+	origin := transcriptOrigin{TraceID: state.TraceID, SpanID: state.SpanID, Target: state.Target}
+	language := state.Language
 	state.Inflight = true
 	s.mu.Unlock()
+	log := logging.WithSession(s.bus.Logger(), sessionID, origin.TraceID)
 
 	s.wg.Add(1)
 	go func() {
@@ -162,23 +266,20 @@ func (s *Service) scheduleTranscription(sessionID string, final bool) {
 		ctx, cancel := context.WithTimeout(s.ctx, 45*time.Second)
 		defer cancel()
 
-		s.bus.Logger().Info("starting transcription",
-			slog.String("session_id", sessionID),
+		log.Info("starting transcription",
 			slog.Int("pcm_bytes", len(pcm)),
 			slog.Bool("final", final))
 
-		result, err := s.recognizer.Transcribe(ctx, pcm, s.cfg.SampleRate, s.cfg.Channels, final)
+		result, err := s.recognizer.Transcribe(ctx, pcm, s.cfg.SampleRate, s.cfg.Channels, language, final)
 		if err != nil {
-			s.bus.Logger().Warn("stt transcription failed",
-				slog.String("session_id", sessionID),
-				slogError(err))
+			log.Warn("stt transcription failed", slogError(err))
 		} else {
-			s.bus.Logger().Info("transcription completed",
-				slog.String("session_id", sessionID),
+			log.Info("transcription completed",
 				slog.String("text", result.Text),
 				slog.Float64("confidence", result.Confidence),
 				slog.Bool("final", final))
-			s.publishTranscript(sessionID, result.Text, result.Confidence, final)
+			origin.Language = transcriptLanguage(result.Language, language, s.cfg.Language)
+			s.publishTranscript(sessionID, origin, result.Text, result.Confidence, final)
 		}
 
 		s.mu.Lock()
@@ -202,32 +303,63 @@ func (s *Service) scheduleTranscription(sessionID string, final bool) {
 	}()
 }
 
-func (s *Service) publishTranscript(sessionID, text string, confidence float64, final bool) {
+// transcriptOrigin carries edge-supplied metadata copied from audio frames
+// onto the resulting transcript.
+type transcriptOrigin struct {
+	TraceID  string
+	SpanID   string
+	Target   string
+	Language string
+}
+
+// transcriptLanguage picks the language reported on a transcript: what the
+// engine detected, else the session's or configured language unless that
+// asked for detection.
+func transcriptLanguage(detected, session, configured string) string {
+	if detected != "" {
+		return detected
+	}
+	requested := session
+	if requested == "" {
+		requested = configured
+	}
+	if requested == "auto" {
+		return ""
+	}
+	return requested
+}
+
+func (s *Service) publishTranscript(sessionID string, origin transcriptOrigin, text string, confidence float64, final bool) {
+	log := logging.WithSession(s.bus.Logger(), sessionID, origin.TraceID)
 	if text == "" {
-		s.bus.Logger().Warn("skipping empty transcript", slog.String("session_id", sessionID))
+		log.Warn("skipping empty transcript")
 		return
 	}
-	subject := protocol.SubjectTranscriptPartial
+	subject := s.subjects.TranscriptPartial
 	if final {
-		subject = protocol.SubjectTranscriptFinal
+		subject = s.subjects.TranscriptFinal
 	}
 	msg := protocol.Transcript{
+		V:          protocol.SchemaVersion,
 		SessionID:  sessionID,
 		Text:       text,
 		Partial:    !final,
 		Timestamp:  time.Now().UTC(),
 		Confidence: confidence,
+		TraceID:    origin.TraceID,
+		SpanID:     origin.SpanID,
+		Target:     origin.Target,
+		Language:   origin.Language,
 	}
-	data, err := json.Marshal(msg)
+	data, err := s.bus.Codec().Marshal(msg)
 	if err != nil {
-		s.bus.Logger().Warn("failed to marshal transcript", slogError(err))
+		log.Warn("failed to marshal transcript", slogError(err))
 		return
 	}
-	if err := s.bus.Conn().Publish(subject, data); err != nil {
-		s.bus.Logger().Warn("failed to publish transcript", slogError(err))
+	if err := s.bus.Publish(subject, data); err != nil {
+		log.Warn("failed to publish transcript", slogError(err))
 	} else {
-		s.bus.Logger().Info("published transcript",
-			slog.String("session_id", sessionID),
+		log.Info("published transcript",
 			slog.String("subject", subject),
 			slog.Int("text_length", len(text)))
 	}
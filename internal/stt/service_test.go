@@ -0,0 +1,79 @@
+package stt
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+)
+
+// recordingRecognizer captures the PCM handed to final transcriptions.
+type recordingRecognizer struct {
+	finals chan []byte
+}
+
+func (r *recordingRecognizer) Transcribe(ctx context.Context, pcm []byte, sampleRate int, channels int, language string, final bool) (TranscriptResult, error) {
+	if final {
+		r.finals <- pcm
+	}
+	return TranscriptResult{Text: "ok"}, nil
+}
+
+func TestServiceReordersFramesBeforeTranscribing(t *testing.T) {
+	client := testutil.StartBus(t)
+	rec := &recordingRecognizer{finals: make(chan []byte, 1)}
+	cfg := config.STTConfig{Enabled: true, SampleRate: 16000, Channels: 1, FrameDurationMS: 20, ReorderWindow: 4}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), rec)
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start stt service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	// The final frame overtakes two stragglers and one frame is replayed.
+	for _, seq := range []int{0, 2, 4, 1, 2, 3} {
+		frame := protocol.AudioFrame{SessionID: "s1", Sequence: seq, PCM: []byte{byte(seq)}, Final: seq == 4}
+		if err := PublishAudioFrame(client, protocol.SubjectAudioFramePrefix+".kitchen", frame); err != nil {
+			t.Fatalf("publish frame: %v", err)
+		}
+	}
+
+	select {
+	case pcm := <-rec.finals:
+		if !bytes.Equal(pcm, []byte{0, 1, 2, 3, 4}) {
+			t.Fatalf("expected frames assembled in sequence order, got %v", pcm)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for final transcription")
+	}
+}
+
+func TestServiceFinalizesWithoutMissingStragglers(t *testing.T) {
+	client := testutil.StartBus(t)
+	rec := &recordingRecognizer{finals: make(chan []byte, 1)}
+	cfg := config.STTConfig{Enabled: true, SampleRate: 16000, Channels: 1, FrameDurationMS: 5, ReorderWindow: 4}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), rec)
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start stt service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	for _, seq := range []int{0, 2} {
+		frame := protocol.AudioFrame{SessionID: "s1", Sequence: seq, PCM: []byte{byte(seq)}, Final: seq == 2}
+		if err := PublishAudioFrame(client, protocol.SubjectAudioFramePrefix+".kitchen", frame); err != nil {
+			t.Fatalf("publish frame: %v", err)
+		}
+	}
+
+	select {
+	case pcm := <-rec.finals:
+		if !bytes.Equal(pcm, []byte{0, 2}) {
+			t.Fatalf("expected available frames after timeout, got %v", pcm)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for final transcription")
+	}
+}
@@ -0,0 +1,147 @@
+package stt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-audio/wav"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+)
+
+// WAV format tags for integer PCM.
+const (
+	wavFormatPCM        = 1
+	wavFormatExtensible = 0xFFFE
+)
+
+// ReadWAV decodes an integer PCM WAV file and converts it to 16-bit
+// little-endian PCM at sampleRate with the given channel count, the format
+// the STT service expects.
+func ReadWAV(r io.ReadSeeker, sampleRate, channels int) ([]byte, error) {
+	dec := wav.NewDecoder(r)
+	if !dec.IsValidFile() {
+		if err := dec.Err(); err != nil {
+			return nil, fmt.Errorf("read wav: %w", err)
+		}
+		return nil, errors.New("read wav: not a valid WAV file")
+	}
+	if dec.WavAudioFormat != wavFormatPCM && dec.WavAudioFormat != wavFormatExtensible {
+		return nil, fmt.Errorf("read wav: unsupported format %d (integer PCM only)", dec.WavAudioFormat)
+	}
+	buf, err := dec.FullPCMBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("read wav: %w", err)
+	}
+	samples := make([]int16, len(buf.Data))
+	for i, v := range buf.Data {
+		samples[i] = toInt16(v, int(dec.BitDepth))
+	}
+	return ConvertPCM(encodePCM16(samples), int(dec.SampleRate), int(dec.NumChans), sampleRate, channels), nil
+}
+
+func toInt16(v, bitDepth int) int16 {
+	switch {
+	case bitDepth == 8:
+		return int16((v - 128) << 8) // 8-bit WAV samples are unsigned
+	case bitDepth > 16:
+		return int16(v >> (bitDepth - 16))
+	default:
+		return int16(v)
+	}
+}
+
+// ConvertPCM converts 16-bit little-endian PCM between sample rates and
+// channel counts. Channels are averaged down to mono or copied up from it
+// (other layouts keep the leading channels), and rates are changed by
+// linear interpolation, which is adequate for speech recognition input.
+func ConvertPCM(pcm []byte, fromRate, fromChannels, toRate, toChannels int) []byte {
+	if fromRate == toRate && fromChannels == toChannels {
+		return pcm
+	}
+	in := decodePCM16(pcm)
+	frames := len(in) / fromChannels
+	mixed := make([]int16, frames*toChannels)
+	for f := 0; f < frames; f++ {
+		src := in[f*fromChannels : (f+1)*fromChannels]
+		dst := mixed[f*toChannels : (f+1)*toChannels]
+		switch {
+		case toChannels == 1:
+			sum := 0
+			for _, s := range src {
+				sum += int(s)
+			}
+			dst[0] = int16(sum / fromChannels)
+		case fromChannels == 1:
+			for c := range dst {
+				dst[c] = src[0]
+			}
+		default:
+			copy(dst, src)
+		}
+	}
+	if fromRate == toRate || frames == 0 {
+		return encodePCM16(mixed)
+	}
+
+	outFrames := int(int64(frames) * int64(toRate) / int64(fromRate))
+	out := make([]int16, outFrames*toChannels)
+	step := float64(fromRate) / float64(toRate)
+	for f := 0; f < outFrames; f++ {
+		pos := float64(f) * step
+		i := int(pos)
+		frac := pos - float64(i)
+		next := i + 1
+		if next >= frames {
+			next = frames - 1
+		}
+		for c := 0; c < toChannels; c++ {
+			a := float64(mixed[i*toChannels+c])
+			b := float64(mixed[next*toChannels+c])
+			out[f*toChannels+c] = int16(a + (b-a)*frac)
+		}
+	}
+	return encodePCM16(out)
+}
+
+// FramesFromPCM splits pcm into audio frames of frameMS milliseconds for
+// sessionID, marking the last one final.
+func FramesFromPCM(sessionID string, pcm []byte, sampleRate, channels, frameMS int) []protocol.AudioFrame {
+	size := sampleRate * channels * 2 * frameMS / 1000
+	if size <= 0 {
+		size = len(pcm)
+	}
+	var frames []protocol.AudioFrame
+	for offset := 0; offset < len(pcm) || len(frames) == 0; offset += size {
+		end := offset + size
+		if end > len(pcm) {
+			end = len(pcm)
+		}
+		frames = append(frames, protocol.AudioFrame{
+			SessionID:  sessionID,
+			Sequence:   len(frames),
+			SampleRate: sampleRate,
+			Channels:   channels,
+			PCM:        pcm[offset:end],
+		})
+	}
+	frames[len(frames)-1].Final = true
+	return frames
+}
+
+func decodePCM16(pcm []byte) []int16 {
+	out := make([]int16, len(pcm)/2)
+	for i := range out {
+		out[i] = int16(binary.LittleEndian.Uint16(pcm[2*i:]))
+	}
+	return out
+}
+
+func encodePCM16(samples []int16) []byte {
+	out := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(s))
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+package stt
+
+import (
+	"os"
+	"testing"
+)
+
+func TestReadWAVConvertsToServiceFormat(t *testing.T) {
+	file, err := os.Open("testdata/tone_8k_stereo.wav") // 100ms, 400Hz sine, peak 8000
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	pcm, err := ReadWAV(file, 16000, 1)
+	if err != nil {
+		t.Fatalf("read wav: %v", err)
+	}
+	if len(pcm) != 3200 {
+		t.Fatalf("expected 100ms of 16kHz mono (3200 bytes), got %d", len(pcm))
+	}
+	samples := decodePCM16(pcm)
+	peak := 0
+	for _, s := range samples {
+		if v := int(s); v > peak {
+			peak = v
+		} else if -v > peak {
+			peak = -v
+		}
+	}
+	if peak < 7500 || peak > 8000 {
+		t.Fatalf("expected the tone's amplitude to survive resampling, peak %d", peak)
+	}
+	// Interpolated samples sit between their 8kHz neighbours.
+	if samples[1] <= samples[0] || samples[1] >= samples[2] {
+		t.Fatalf("expected a rising tone at the start, got %v", samples[:4])
+	}
+}
+
+func TestReadWAVRejectsNonWAV(t *testing.T) {
+	file, err := os.Open("wavfile.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := ReadWAV(file, 16000, 1); err == nil {
+		t.Fatal("expected an error for a non-WAV file")
+	}
+}
+
+func TestFramesFromPCM(t *testing.T) {
+	pcm := make([]byte, 16000*2/10*3+100) // 300ms plus a partial frame at 16kHz mono
+	frames := FramesFromPCM("s", pcm, 16000, 1, 100)
+	if len(frames) != 4 {
+		t.Fatalf("expected 4 frames, got %d", len(frames))
+	}
+	total := 0
+	for i, frame := range frames {
+		if frame.Sequence != i || frame.Final != (i == 3) || frame.SessionID != "s" {
+			t.Fatalf("frame %d: unexpected %+v", i, frame)
+		}
+		total += len(frame.PCM)
+	}
+	if total != len(pcm) || len(frames[3].PCM) != 100 {
+		t.Fatalf("frames should cover the input exactly, got %d bytes (last %d)", total, len(frames[3].PCM))
+	}
+	if empty := FramesFromPCM("s", nil, 16000, 1, 100); len(empty) != 1 || !empty[0].Final {
+		t.Fatalf("empty input should yield one final frame, got %+v", empty)
+	}
+}
@@ -0,0 +1,63 @@
+// Package testutil holds helpers shared by the runtime's package tests: an
+// embedded NATS server, a bus client connected to it, and a quiet logger.
+package testutil
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/bus"
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// Logger returns a logger that discards everything below error.
+func Logger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+// StartServer starts an embedded NATS server on a random local port and
+// shuts it down when the test ends. opts may be nil or set extras such as
+// MaxPayload or JetStream; the host, port, logging and signal handling are
+// filled in.
+func StartServer(t testing.TB, opts *server.Options) *server.Server {
+	t.Helper()
+	var o server.Options
+	if opts != nil {
+		o = *opts
+	}
+	o.Host, o.Port, o.NoLog, o.NoSigs = "127.0.0.1", -1, true, true
+	ns, err := server.NewServer(&o)
+	if err != nil {
+		t.Fatalf("create nats server: %v", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(ns.Shutdown)
+	return ns
+}
+
+// Connect connects a bus client to ns using cfg, filling in the server
+// address and connect timeout, and closes it when the test ends.
+func Connect(t testing.TB, ns *server.Server, cfg config.BusConfig) *bus.Client {
+	t.Helper()
+	cfg.Servers = []string{ns.ClientURL()}
+	cfg.ConnectTimeout = 2000
+	client, err := bus.Connect(context.Background(), cfg, Logger())
+	if err != nil {
+		t.Fatalf("connect bus: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// StartBus starts an embedded server and returns a client connected to it.
+func StartBus(t testing.TB) *bus.Client {
+	t.Helper()
+	return Connect(t, StartServer(t, nil), config.BusConfig{})
+}
@@ -0,0 +1,68 @@
+// Package text holds transcript cleanup shared by the voice pipeline
+// services.
+package text
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Options tunes Normalize.
+type Options struct {
+	// Capitalize upper-cases the first letter of the result.
+	Capitalize bool
+}
+
+// bracketed matches a square- or round-bracketed span. Only spans naming a
+// known annotation are stripped, so "call (555) 0100" survives.
+var bracketed = regexp.MustCompile(`\[[^\]]*\]|\([^)]*\)`)
+
+// annotations are the non-speech markers STT engines emit in brackets, such
+// as "[inaudible]", "[BLANK_AUDIO]" or "(music)", lower-cased with
+// underscores read as spaces.
+var annotations = map[string]bool{
+	"applause": true, "background noise": true, "beep": true, "blank audio": true,
+	"breathing": true, "clears throat": true, "cough": true, "coughing": true,
+	"crosstalk": true, "inaudible": true, "laughing": true, "laughter": true,
+	"laughs": true, "music": true, "music playing": true, "no speech": true,
+	"noise": true, "pause": true, "sigh": true, "sighs": true, "silence": true,
+	"static": true, "unintelligible": true,
+}
+
+// stripAnnotation blanks match when it is a known annotation.
+func stripAnnotation(match string) string {
+	inner := strings.ReplaceAll(match[1:len(match)-1], "_", " ")
+	if annotations[strings.Join(strings.Fields(strings.ToLower(inner)), " ")] {
+		return " "
+	}
+	return match
+}
+
+// fillers are hesitation tokens dropped when they stand alone.
+var fillers = map[string]bool{"um": true, "umm": true, "uh": true, "uhh": true, "er": true, "erm": true, "hmm": true, "mm": true}
+
+// Normalize cleans a raw transcript: it strips bracketed annotations and
+// standalone filler words, collapses whitespace, and trims the result. An
+// utterance made only of artifacts and fillers normalizes to "".
+func Normalize(s string, opts Options) string {
+	s = bracketed.ReplaceAllStringFunc(s, stripAnnotation)
+	words := strings.Fields(s)
+	kept := words[:0]
+	for _, word := range words {
+		if fillers[strings.ToLower(strings.TrimRight(word, ",.!?;:"))] {
+			continue
+		}
+		kept = append(kept, word)
+	}
+	s = strings.Join(kept, " ")
+	// Removing a word can leave its neighbour's punctuation dangling.
+	s = strings.TrimLeft(s, ",.;: ")
+	s = strings.TrimRight(s, ",;: ")
+	if opts.Capitalize && s != "" {
+		r, size := utf8.DecodeRuneInString(s)
+		s = string(unicode.ToUpper(r)) + s[size:]
+	}
+	return s
+}
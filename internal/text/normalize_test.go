@@ -0,0 +1,30 @@
+package text
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		opts Options
+		want string
+	}{
+		{"trims and collapses whitespace", "  turn   on\tthe\nlights  ", Options{}, "turn on the lights"},
+		{"strips bracketed artifacts", "[inaudible] set a timer (music) for ten minutes [BLANK_AUDIO]", Options{}, "set a timer for ten minutes"},
+		{"drops standalone fillers", "um, what's uh the weather, hmm", Options{}, "what's the weather"},
+		{"keeps words containing fillers", "umbrella summer", Options{}, "umbrella summer"},
+		{"capitalizes first letter", "éclairs please", Options{Capitalize: true}, "Éclairs please"},
+		{"leaves case alone by default", "hello", Options{}, "hello"},
+		{"artifacts only", " [noise] (cough) um ", Options{Capitalize: true}, ""},
+		{"matches annotations loosely", "[ Music Playing ] (Background_Noise) stop", Options{}, "stop"},
+		{"keeps other bracketed text", "call (555) 0100 about [the] order (tomorrow)", Options{}, "call (555) 0100 about [the] order (tomorrow)"},
+		{"empty", "", Options{}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Normalize(tc.in, tc.opts); got != tc.want {
+				t.Fatalf("Normalize(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
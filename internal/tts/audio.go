@@ -0,0 +1,78 @@
+package tts
+
+import (
+	"fmt"
+
+	"github.com/loqalabs/loqa-core/internal/bus"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/nats-io/nats.go"
+)
+
+// PublishAudioChunk sends chunk on subject, as raw PCM with header metadata
+// when bus.raw_audio is enabled and through the bus codec otherwise.
+func PublishAudioChunk(client *bus.Client, subject string, chunk protocol.AudioChunk) error {
+	if client.RawAudio() {
+		return client.PublishMsg(protocol.RawAudioChunkMsg(subject, chunk))
+	}
+	data, err := client.Codec().Marshal(chunk)
+	if err != nil {
+		return fmt.Errorf("marshal audio chunk: %w", err)
+	}
+	return client.Publish(subject, data)
+}
+
+// chunkOverhead is reserved in each message for the non-PCM fields and
+// headers of an AudioChunk. On servers whose max_payload is too small for
+// the full reservation, half the payload is reserved instead.
+const chunkOverhead = 4 << 10
+
+// minChunkPCM is the smallest PCM budget maxChunkPCM returns, so a tiny
+// max_payload still yields a usable split size rather than none.
+const minChunkPCM = 256
+
+// maxChunkPCM returns the largest PCM slice that fits in one AudioChunk
+// message on client's server. Codec-encoded chunks are budgeted for base64
+// expansion (4 bytes per 3), which JSON applies to byte slices.
+func maxChunkPCM(client *bus.Client) int {
+	return chunkPCMBudget(int(client.MaxPayload()), client.RawAudio())
+}
+
+func chunkPCMBudget(maxPayload int, raw bool) int {
+	limit := maxPayload - min(chunkOverhead, maxPayload/2)
+	if !raw {
+		limit = limit / 4 * 3
+	}
+	return max(limit, minChunkPCM)
+}
+
+// splitChunk breaks chunk into pieces whose PCM is at most limit bytes,
+// aligned to 16-bit sample frames. Only the last piece keeps Final. A chunk
+// that already fits, or a non-positive limit, is returned unchanged.
+func splitChunk(chunk SynthChunk, limit int) []SynthChunk {
+	frame := 2 * max(chunk.Channels, 1)
+	limit -= limit % frame
+	if limit <= 0 || len(chunk.PCM) <= limit {
+		return []SynthChunk{chunk}
+	}
+	var parts []SynthChunk
+	for offset := 0; offset < len(chunk.PCM); offset += limit {
+		end := min(offset+limit, len(chunk.PCM))
+		part := chunk
+		part.PCM = chunk.PCM[offset:end]
+		part.Final = chunk.Final && end == len(chunk.PCM)
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// DecodeAudioChunk reads an audio chunk in either wire format.
+func DecodeAudioChunk(client *bus.Client, msg *nats.Msg) (protocol.AudioChunk, error) {
+	if protocol.IsRawAudio(msg) {
+		return protocol.AudioChunkFromRaw(msg)
+	}
+	var chunk protocol.AudioChunk
+	if err := client.Codec().Unmarshal(msg.Data, &chunk); err != nil {
+		return chunk, err
+	}
+	return chunk, nil
+}
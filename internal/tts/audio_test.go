@@ -0,0 +1,99 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestRawAudioChunksRoundTripByteExact(t *testing.T) {
+	client := testutil.Connect(t, testutil.StartServer(t, nil), config.BusConfig{RawAudio: true})
+	ttsCfg := config.TTSConfig{Enabled: true, SampleRate: 16000, Channels: 1, ChunkDurationMS: 100, MaxConcurrentSynth: 1, MockToneHz: 440, MockDurationMS: 250}
+
+	// Render the expected tone independently of the service.
+	var want [][]byte
+	expected, _ := NewMockSynth(ttsCfg).Synthesize(context.Background(), SynthRequest{SessionID: "s1"})
+	for c := range expected {
+		want = append(want, c.PCM)
+	}
+
+	received := make(chan *nats.Msg, len(want))
+	sub, err := client.Conn().ChanSubscribe(protocol.SubjectTTSAudio, received)
+	if err != nil {
+		t.Fatalf("subscribe audio: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	svc := NewService(context.Background(), ttsCfg, client, protocol.DefaultSubjects(), NewMockSynth(ttsCfg), testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start tts service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+	data, _ := json.Marshal(protocol.TTSRequest{SessionID: "s1", Target: "kitchen", Text: "hello"})
+	if err := client.Conn().Publish(protocol.SubjectTTSRequest, data); err != nil {
+		t.Fatalf("publish request: %v", err)
+	}
+
+	for i := range want {
+		select {
+		case msg := <-received:
+			if !protocol.IsRawAudio(msg) {
+				t.Fatalf("expected raw audio message, got %q", msg.Data)
+			}
+			chunk, err := DecodeAudioChunk(client, msg)
+			if err != nil {
+				t.Fatalf("decode chunk: %v", err)
+			}
+			if chunk.SessionID != "s1" || chunk.Target != "kitchen" || chunk.Sequence != i || chunk.SampleRate != 16000 || chunk.Channels != 1 {
+				t.Fatalf("unexpected chunk metadata %+v", chunk)
+			}
+			if chunk.Final != (i == len(want)-1) {
+				t.Fatalf("chunk %d final=%v", i, chunk.Final)
+			}
+			if !bytes.Equal(chunk.PCM, want[i]) {
+				t.Fatalf("chunk %d PCM differs from synthesized audio", i)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for chunk %d", i)
+		}
+	}
+}
+
+func TestDecodeAudioChunkAcceptsCodecPayload(t *testing.T) {
+	client := testutil.StartBus(t)
+	in := protocol.AudioChunk{V: protocol.SchemaVersion, SessionID: "s1", Sequence: 2, PCM: []byte{0, 1, 2, 255}}
+	data, _ := client.Codec().Marshal(in)
+	out, err := DecodeAudioChunk(client, &nats.Msg{Data: data})
+	if err != nil {
+		t.Fatalf("decode chunk: %v", err)
+	}
+	if out.Sequence != 2 || !bytes.Equal(out.PCM, in.PCM) {
+		t.Fatalf("unexpected chunk %+v", out)
+	}
+}
+
+func TestChunkPCMBudgetStaysPositive(t *testing.T) {
+	cases := []struct {
+		maxPayload int
+		raw        bool
+		want       int
+	}{
+		{1 << 20, true, 1<<20 - 4<<10},
+		{1 << 20, false, (1<<20 - 4<<10) / 4 * 3},
+		{2 << 10, true, 1 << 10},
+		{2 << 10, false, 768},
+		{0, false, minChunkPCM},
+	}
+	for _, tc := range cases {
+		if got := chunkPCMBudget(tc.maxPayload, tc.raw); got != tc.want {
+			t.Errorf("chunkPCMBudget(%d, %v) = %d, want %d", tc.maxPayload, tc.raw, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package tts
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cacheKey identifies a synthesized utterance. SSML is part of the key so
+// markup variants of the same text are cached separately.
+type cacheKey struct {
+	text       string
+	ssml       string
+	voice      string
+	sampleRate int
+}
+
+type cacheEntry struct {
+	key    cacheKey
+	chunks []SynthChunk
+	size   int
+}
+
+// phraseCache is an LRU of complete utterances bounded by total PCM bytes
+// (tts.cache_bytes). Chunks are stored as the synthesizer produced them;
+// sequence numbers are reassigned on replay.
+type phraseCache struct {
+	mu       sync.Mutex
+	capacity int
+	size     int
+	order    *list.List // front is most recently used
+	entries  map[cacheKey]*list.Element
+}
+
+func newPhraseCache(capacity int) *phraseCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &phraseCache{capacity: capacity, order: list.New(), entries: make(map[cacheKey]*list.Element)}
+}
+
+func (c *phraseCache) get(key cacheKey) ([]SynthChunk, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).chunks, true
+}
+
+// put stores chunks under key, evicting the least recently used entries to
+// stay within capacity. Utterances larger than the whole cache are skipped.
+func (c *phraseCache) put(key cacheKey, chunks []SynthChunk) {
+	if c == nil {
+		return
+	}
+	size := 0
+	for _, chunk := range chunks {
+		size += len(chunk.PCM)
+	}
+	if size > c.capacity {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.size -= elem.Value.(*cacheEntry).size
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+	for c.size+size > c.capacity {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*cacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.size -= entry.size
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, chunks: chunks, size: size})
+	c.size += size
+}
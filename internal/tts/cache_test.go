@@ -0,0 +1,28 @@
+package tts
+
+import "testing"
+
+func TestPhraseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newPhraseCache(10)
+	chunk := func(n int) []SynthChunk { return []SynthChunk{{PCM: make([]byte, n), Final: true}} }
+	a, b, c := cacheKey{text: "a"}, cacheKey{text: "b"}, cacheKey{text: "c"}
+	cache.put(a, chunk(4))
+	cache.put(b, chunk(4))
+	if _, ok := cache.get(a); !ok {
+		t.Fatal("expected a cached")
+	}
+	cache.put(c, chunk(4)) // evicts b, the least recently used
+	if _, ok := cache.get(b); ok {
+		t.Fatal("expected b evicted")
+	}
+	if _, ok := cache.get(a); !ok {
+		t.Fatal("expected a to survive eviction")
+	}
+	cache.put(cacheKey{text: "huge"}, chunk(11))
+	if _, ok := cache.get(cacheKey{text: "huge"}); ok {
+		t.Fatal("utterance larger than the cache should not be stored")
+	}
+	if cache.size != 8 {
+		t.Fatalf("expected 8 cached bytes, got %d", cache.size)
+	}
+}
@@ -1,19 +1,18 @@
 package tts
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
-	"os/exec"
+	"strconv"
 	"sync"
 
-	"github.com/mattn/go-shellwords"
+	loqaexec "github.com/loqalabs/loqa-core/internal/exec"
 )
 
 type execSynth struct {
-	cmd        []string
+	runner     *loqaexec.Runner
 	sampleRate int
 	channels   int
 	mu         sync.Mutex
@@ -21,6 +20,7 @@ type execSynth struct {
 
 type execRequest struct {
 	Text       string `json:"text"`
+	SSML       string `json:"ssml,omitempty"`
 	Voice      string `json:"voice"`
 	SampleRate int    `json:"sample_rate"`
 	Channels   int    `json:"channels"`
@@ -31,21 +31,28 @@ type execResponse struct {
 	Final     bool   `json:"final"`
 }
 
-func NewExecSynth(command string, sampleRate, channels int) (Synthesizer, error) {
-	parser := shellwords.NewParser()
-	args, err := parser.Parse(command)
+// execArgNames are the placeholders available to tts.command_args.
+var execArgNames = []string{"text", "voice", "sample_rate", "channels"}
+
+// NewExecSynth runs command once per request, writing the request as JSON
+// to stdin. args, if any, is an argument template appended to command (see
+// execArgNames); env is the command's complete environment.
+func NewExecSynth(command string, args, env []string, sampleRate, channels int) (Synthesizer, error) {
+	runner, err := loqaexec.NewRunner("tts", loqaexec.Spec{
+		Command:  command,
+		Args:     args,
+		ArgNames: execArgNames,
+		Env:      env,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("parse tts command: %w", err)
-	}
-	if len(args) == 0 {
-		return nil, fmt.Errorf("tts command empty")
+		return nil, err
 	}
-	return &execSynth{cmd: args, sampleRate: sampleRate, channels: channels}, nil
+	return &execSynth{runner: runner, sampleRate: sampleRate, channels: channels}, nil
 }
 
 func (e *execSynth) Synthesize(ctx context.Context, req SynthRequest) (<-chan SynthChunk, <-chan error) {
 	e.mu.Lock()
-	schunks := make(chan SynthChunk)
+	schunks := make(chan SynthChunk, chunkBuffer)
 	errs := make(chan error, 1)
 	go func() {
 		defer close(schunks)
@@ -54,6 +61,7 @@ func (e *execSynth) Synthesize(ctx context.Context, req SynthRequest) (<-chan Sy
 
 		reqPayload := execRequest{
 			Text:       req.Text,
+			SSML:       req.SSML,
 			Voice:      req.Voice,
 			SampleRate: e.sampleRate,
 			Channels:   e.channels,
@@ -63,52 +71,24 @@ func (e *execSynth) Synthesize(ctx context.Context, req SynthRequest) (<-chan Sy
 			errs <- err
 			return
 		}
+		args := e.runner.Render(map[string]string{
+			"text":        req.Text,
+			"voice":       req.Voice,
+			"sample_rate": strconv.Itoa(e.sampleRate),
+			"channels":    strconv.Itoa(e.channels),
+		})
 
-		base := e.cmd[0]
-		args := append([]string{}, e.cmd[1:]...)
-		cmd := exec.CommandContext(ctx, base, args...)
-		stdin, err := cmd.StdinPipe()
-		if err != nil {
-			errs <- err
-			return
-		}
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			errs <- err
-			return
-		}
-		if err := cmd.Start(); err != nil {
-			errs <- err
-			return
-		}
-
-		if _, err := stdin.Write(data); err != nil {
-			errs <- err
-			cmd.Wait()
-			return
-		}
-		stdin.Close()
-
-		scanner := bufio.NewScanner(stdout)
 		sequence := 0
-		for scanner.Scan() {
-			line := scanner.Bytes()
-			if len(line) == 0 {
-				continue
-			}
+		err = e.runner.Stream(ctx, args, bytes.NewReader(data), func(line []byte) error {
 			var resp execResponse
 			if err := json.Unmarshal(line, &resp); err != nil {
-				errs <- err
-				cmd.Wait()
-				return
+				return err
 			}
 			pcm, err := base64.StdEncoding.DecodeString(resp.PCMBase64)
 			if err != nil {
-				errs <- err
-				cmd.Wait()
-				return
+				return err
 			}
-			schunks <- SynthChunk{
+			chunk := SynthChunk{
 				SessionID:  req.SessionID,
 				Sequence:   sequence,
 				SampleRate: e.sampleRate,
@@ -116,15 +96,18 @@ func (e *execSynth) Synthesize(ctx context.Context, req SynthRequest) (<-chan Sy
 				PCM:        pcm,
 				Final:      resp.Final,
 			}
+			// A consumer that gives up stops reading; without the ctx case
+			// the send would block forever and hold e.mu.
+			select {
+			case schunks <- chunk:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 			sequence++
-		}
-		err = cmd.Wait()
+			return nil
+		})
 		if err != nil {
 			errs <- err
-			return
-		}
-		if scanErr := scanner.Err(); scanErr != nil {
-			errs <- scanErr
 		}
 	}()
 	return schunks, errs
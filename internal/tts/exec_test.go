@@ -0,0 +1,56 @@
+package tts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExecSynthRecoversFromAbandonedStream(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "synth.sh")
+	body := `#!/bin/sh
+cat >/dev/null
+i=0
+while [ $i -lt 32 ]; do
+  echo '{"pcm_base64":"AAAA"}'
+  i=$((i+1))
+done
+echo '{"pcm_base64":"AAAA","final":true}'
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	synth, err := NewExecSynth("sh "+script, nil, nil, 16000, 1)
+	if err != nil {
+		t.Fatalf("new exec synth: %v", err)
+	}
+
+	// The consumer reads one chunk and then walks away, leaving the
+	// synthesizer with more chunks than the channel buffers.
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks, _ := synth.Synthesize(ctx, SynthRequest{SessionID: "first", Text: "hello"})
+	select {
+	case <-chunks:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no chunk from first synthesis")
+	}
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		chunks, errs := synth.Synthesize(context.Background(), SynthRequest{SessionID: "second", Text: "hello"})
+		for range chunks {
+		}
+		done <- <-errs
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("second synthesis: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("second synthesis blocked behind the abandoned stream")
+	}
+}
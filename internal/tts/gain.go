@@ -0,0 +1,92 @@
+package tts
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+)
+
+// levelStage adjusts the loudness of 16-bit little-endian PCM so engines
+// with different output levels sound alike.
+//
+// Normalization works in place, chunk by chunk, so audio still streams as it
+// is synthesized: each chunk is scaled so the running peak of the utterance
+// so far, that chunk included, hits the target. Unlike whole-utterance
+// normalization the factor can only fall as louder passages arrive, so a
+// quiet opening is boosted more than the rest and levels may step down
+// between chunks. The factor is capped so a near-silent utterance is not
+// blown up to full scale.
+type levelStage struct {
+	gain       float64 // linear factor from tts.gain_db; 1 is unity
+	targetPeak float64 // in sample units from tts.normalize_peak_dbfs; 0 when tts.normalize is off
+	maxBoost   float64 // linear cap on the normalization factor from tts.normalize_max_gain_db
+}
+
+func newLevelStage(cfg config.TTSConfig) levelStage {
+	stage := levelStage{gain: dbToFactor(cfg.GainDB)}
+	if cfg.Normalize {
+		stage.targetPeak = dbToFactor(cfg.NormalizePeakDBFS) * math.MaxInt16
+		stage.maxBoost = dbToFactor(cfg.NormalizeMaxGainDB)
+	}
+	return stage
+}
+
+func (l levelStage) enabled() bool { return l.gain != 1 || l.normalizes() }
+
+func (l levelStage) normalizes() bool { return l.targetPeak != 0 }
+
+// utterance returns the leveling state for one utterance.
+func (l levelStage) utterance() *utteranceLevel {
+	return &utteranceLevel{stage: l}
+}
+
+// utteranceLevel levels the chunks of one utterance in order, tracking the
+// running peak that normalization scales against.
+type utteranceLevel struct {
+	stage levelStage
+	peak  int
+}
+
+// apply scales pcm in place, normalizing it against the utterance's running
+// peak and then applying the fixed gain.
+func (u *utteranceLevel) apply(pcm []byte) {
+	factor := u.stage.gain
+	if u.stage.normalizes() {
+		u.peak = max(u.peak, peakSample(pcm))
+		if u.peak == 0 {
+			return // silence stays silent
+		}
+		factor *= math.Min(u.stage.targetPeak/float64(u.peak), u.stage.maxBoost)
+	}
+	scalePCM(pcm, factor)
+}
+
+// scalePCM multiplies every sample by factor, clipping to the int16 range.
+func scalePCM(pcm []byte, factor float64) {
+	if factor == 1 {
+		return
+	}
+	for i := 0; i+1 < len(pcm); i += 2 {
+		sample := float64(int16(binary.LittleEndian.Uint16(pcm[i:]))) * factor
+		sample = math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(sample)))
+		binary.LittleEndian.PutUint16(pcm[i:], uint16(int16(sample)))
+	}
+}
+
+// peakSample returns the largest absolute sample value in pcm.
+func peakSample(pcm []byte) int {
+	peak := 0
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := int(int16(binary.LittleEndian.Uint16(pcm[i:])))
+		if v < 0 {
+			v = -v
+		}
+		if v > peak {
+			peak = v
+		}
+	}
+	return peak
+}
+
+func dbToFactor(db float64) float64 { return math.Pow(10, db/20) }
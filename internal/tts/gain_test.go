@@ -0,0 +1,88 @@
+package tts
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+)
+
+func pcm16(samples ...int16) []byte {
+	out := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(s))
+	}
+	return out
+}
+
+func samples16(pcm []byte) []int16 {
+	out := make([]int16, len(pcm)/2)
+	for i := range out {
+		out[i] = int16(binary.LittleEndian.Uint16(pcm[2*i:]))
+	}
+	return out
+}
+
+func TestLevelStageFixedGain(t *testing.T) {
+	pcm := pcm16(1000, -1000, 20000, -20000)
+	newLevelStage(config.TTSConfig{GainDB: 6.0206}).utterance().apply(pcm) // +6.02 dB doubles amplitude
+	want := []int16{2000, -2000, math.MaxInt16, math.MinInt16}
+	for i, got := range samples16(pcm) {
+		if got != want[i] {
+			t.Fatalf("sample %d: got %d, want %d (clipped)", i, got, want[i])
+		}
+	}
+}
+
+func TestLevelStageNormalizesPeak(t *testing.T) {
+	quiet := pcm16(100, -400, 200)
+	stage := newLevelStage(config.TTSConfig{Normalize: true, NormalizePeakDBFS: -6.0206, NormalizeMaxGainDB: 60}) // half of full scale
+	stage.utterance().apply(quiet)
+	got := samples16(quiet)
+	if peak := peakSample(quiet); math.Abs(float64(peak)-math.MaxInt16/2) > 1 {
+		t.Fatalf("expected peak near %d, got %d (%v)", math.MaxInt16/2, peak, got)
+	}
+	if math.Abs(float64(got[0])*4+float64(got[1])) > 4 || math.Abs(float64(got[2])*2+float64(got[1])) > 2 {
+		t.Fatalf("normalization should preserve sample ratios, got %v", got)
+	}
+
+	silence := pcm16(0, 0, 0)
+	newLevelStage(config.TTSConfig{GainDB: 12, Normalize: true, NormalizePeakDBFS: -3, NormalizeMaxGainDB: 20}).utterance().apply(silence)
+	for _, s := range samples16(silence) {
+		if s != 0 {
+			t.Fatalf("silence should stay silent, got %v", samples16(silence))
+		}
+	}
+	if newLevelStage(config.TTSConfig{NormalizePeakDBFS: -3}).enabled() {
+		t.Fatal("zero gain and no normalization should be disabled")
+	}
+}
+
+func TestLevelStageNormalizesAgainstRunningPeak(t *testing.T) {
+	level := newLevelStage(config.TTSConfig{Normalize: true, NormalizeMaxGainDB: 60}).utterance()
+	opening, loud, closing := pcm16(1000), pcm16(-8000), pcm16(1000)
+	for _, pcm := range [][]byte{opening, loud, closing} {
+		level.apply(pcm)
+	}
+	// The opening is leveled on its own; once the loud chunk raises the
+	// running peak, later chunks keep their level relative to it.
+	if got := samples16(opening)[0]; got != math.MaxInt16 {
+		t.Fatalf("expected the opening chunk at full scale, got %d", got)
+	}
+	if got := samples16(loud)[0]; got != -math.MaxInt16 {
+		t.Fatalf("expected the loud chunk at full scale, got %d", got)
+	}
+	if got := samples16(closing)[0]; got != 4096 {
+		t.Fatalf("expected the closing chunk scaled against the running peak, got %d", got)
+	}
+}
+
+func TestLevelStageCapsNormalizationBoost(t *testing.T) {
+	stage := newLevelStage(config.TTSConfig{Normalize: true, NormalizePeakDBFS: 0, NormalizeMaxGainDB: 20.0})
+	faint := pcm16(10, -20)
+	stage.utterance().apply(faint)
+	if got := samples16(faint); got[0] != 100 || got[1] != -200 {
+		t.Fatalf("expected a 20 dB (10x) boost at most, got %v", got)
+	}
+}
@@ -2,20 +2,38 @@ package tts
 
 import (
 	"context"
+	"encoding/binary"
+	"math"
 	"time"
+
+	"github.com/loqalabs/loqa-core/internal/config"
 )
 
+// mockAmplitude keeps the generated tone well below clipping.
+const mockAmplitude = 0.3 * math.MaxInt16
+
+// mockSynth emits a sine tone as 16-bit little-endian PCM, split into
+// chunk_duration_ms pieces. A zero duration yields a single empty final chunk.
 type mockSynth struct {
 	sampleRate int
 	channels   int
+	toneHz     float64
+	durationMS int
+	chunkMS    int
 }
 
-func NewMockSynth(sampleRate, channels int) Synthesizer {
-	return &mockSynth{sampleRate: sampleRate, channels: channels}
+func NewMockSynth(cfg config.TTSConfig) Synthesizer {
+	return &mockSynth{
+		sampleRate: cfg.SampleRate,
+		channels:   cfg.Channels,
+		toneHz:     cfg.MockToneHz,
+		durationMS: cfg.MockDurationMS,
+		chunkMS:    cfg.ChunkDurationMS,
+	}
 }
 
 func (m *mockSynth) Synthesize(ctx context.Context, req SynthRequest) (<-chan SynthChunk, <-chan error) {
-	chunks := make(chan SynthChunk, 1)
+	chunks := make(chan SynthChunk, chunkBuffer)
 	errs := make(chan error, 1)
 	go func() {
 		defer close(chunks)
@@ -26,14 +44,54 @@ func (m *mockSynth) Synthesize(ctx context.Context, req SynthRequest) (<-chan Sy
 			return
 		case <-time.After(50 * time.Millisecond):
 		}
-		chunks <- SynthChunk{
-			SessionID:  req.SessionID,
-			Sequence:   0,
-			SampleRate: m.sampleRate,
-			Channels:   m.channels,
-			PCM:        []byte{},
-			Final:      true,
+
+		totalFrames := m.sampleRate * m.durationMS / 1000
+		chunkFrames := m.sampleRate * m.chunkMS / 1000
+		if chunkFrames <= 0 || chunkFrames > totalFrames {
+			chunkFrames = totalFrames
+		}
+		sequence := 0
+		offset := 0
+		for {
+			frames := totalFrames - offset
+			if frames > chunkFrames {
+				frames = chunkFrames
+			}
+			final := offset+frames >= totalFrames
+			chunk := SynthChunk{
+				SessionID:  req.SessionID,
+				Sequence:   sequence,
+				SampleRate: m.sampleRate,
+				Channels:   m.channels,
+				PCM:        m.tone(offset, frames),
+				Final:      final,
+			}
+			select {
+			case chunks <- chunk:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+			if final {
+				return
+			}
+			offset += frames
+			sequence++
 		}
 	}()
 	return chunks, errs
 }
+
+// tone renders frames samples per channel starting at frame offset so that
+// consecutive chunks join without phase discontinuities.
+func (m *mockSynth) tone(offset, frames int) []byte {
+	pcm := make([]byte, frames*m.channels*2)
+	for i := 0; i < frames; i++ {
+		t := float64(offset+i) / float64(m.sampleRate)
+		sample := int16(mockAmplitude * math.Sin(2*math.Pi*m.toneHz*t))
+		for c := 0; c < m.channels; c++ {
+			binary.LittleEndian.PutUint16(pcm[(i*m.channels+c)*2:], uint16(sample))
+		}
+	}
+	return pcm
+}
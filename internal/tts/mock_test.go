@@ -0,0 +1,66 @@
+package tts
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/loqalabs/loqa-core/internal/config"
+)
+
+func TestMockSynthEmitsChunkedTone(t *testing.T) {
+	synth := NewMockSynth(config.TTSConfig{
+		SampleRate:      16000,
+		Channels:        2,
+		ChunkDurationMS: 100,
+		MockToneHz:      440,
+		MockDurationMS:  250,
+	})
+	chunks, errs := synth.Synthesize(context.Background(), SynthRequest{SessionID: "s1", Text: "hi"})
+
+	var got []SynthChunk
+	for c := range chunks {
+		got = append(got, c)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("synthesize: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(got))
+	}
+	total := 0
+	nonZero := false
+	for i, c := range got {
+		if c.Sequence != i || c.SessionID != "s1" || c.SampleRate != 16000 || c.Channels != 2 {
+			t.Fatalf("unexpected chunk header %+v", c)
+		}
+		if c.Final != (i == len(got)-1) {
+			t.Fatalf("chunk %d final=%v", i, c.Final)
+		}
+		for j := 0; j+1 < len(c.PCM); j += 2 {
+			if binary.LittleEndian.Uint16(c.PCM[j:]) != 0 {
+				nonZero = true
+			}
+		}
+		total += len(c.PCM)
+	}
+	// 250ms at 16kHz, 2 channels, 2 bytes per sample.
+	if want := 4000 * 2 * 2; total != want {
+		t.Fatalf("expected %d PCM bytes, got %d", want, total)
+	}
+	if !nonZero {
+		t.Fatalf("expected non-silent PCM")
+	}
+}
+
+func TestMockSynthZeroDuration(t *testing.T) {
+	synth := NewMockSynth(config.TTSConfig{SampleRate: 22050, Channels: 1, ChunkDurationMS: 400, MockToneHz: 440})
+	chunks, _ := synth.Synthesize(context.Background(), SynthRequest{SessionID: "s1"})
+	var got []SynthChunk
+	for c := range chunks {
+		got = append(got, c)
+	}
+	if len(got) != 1 || !got[0].Final || len(got[0].PCM) != 0 {
+		t.Fatalf("expected a single empty final chunk, got %+v", got)
+	}
+}
@@ -2,37 +2,52 @@ package tts
 
 import (
 	"context"
-	"encoding/json"
 	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/loqalabs/loqa-core/internal/bus"
 	"github.com/loqalabs/loqa-core/internal/config"
+	loqaexec "github.com/loqalabs/loqa-core/internal/exec"
+	"github.com/loqalabs/loqa-core/internal/logging"
 	"github.com/loqalabs/loqa-core/internal/protocol"
 	"github.com/nats-io/nats.go"
 )
 
 type Service struct {
-	cfg    config.TTSConfig
-	bus    *bus.Client
-	synth  Synthesizer
-	sub    *nats.Subscription
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
-	logger *slog.Logger
+	cfg      config.TTSConfig
+	bus      *bus.Client
+	subjects protocol.Subjects
+	synth    Synthesizer
+	sub      *nats.Subscription
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	sema     chan struct{}
+	logger   *slog.Logger
+	// voiceErr is set when the startup voice probe rejects tts.voice; the
+	// service keeps running but reports unhealthy.
+	voiceErr error
+	cache    *phraseCache
+	level    levelStage
 }
 
-func NewService(parent context.Context, cfg config.TTSConfig, busClient *bus.Client, synth Synthesizer, log *slog.Logger) *Service {
+func NewService(parent context.Context, cfg config.TTSConfig, busClient *bus.Client, subjects protocol.Subjects, synth Synthesizer, log *slog.Logger) *Service {
 	ctx, cancel := context.WithCancel(parent)
+	if cfg.MaxConcurrentSynth <= 0 {
+		cfg.MaxConcurrentSynth = 1
+	}
 	return &Service{
-		cfg:    cfg,
-		bus:    busClient,
-		synth:  synth,
-		ctx:    ctx,
-		cancel: cancel,
-		logger: log.With(slog.String("component", "tts-service")),
+		cfg:      cfg,
+		bus:      busClient,
+		subjects: subjects,
+		synth:    synth,
+		ctx:      ctx,
+		cancel:   cancel,
+		sema:     make(chan struct{}, cfg.MaxConcurrentSynth),
+		logger:   log.With(slog.String("component", "tts-service")),
+		cache:    newPhraseCache(cfg.CacheBytes),
+		level:    newLevelStage(cfg),
 	}
 }
 
@@ -40,11 +55,17 @@ func (s *Service) Start() error {
 	if !s.cfg.Enabled {
 		return nil
 	}
-	sub, err := s.bus.Conn().Subscribe(protocol.SubjectTTSRequest, s.handleRequest)
+	sub, err := s.bus.Subscribe(s.subjects.TTSRequest, s.handleRequest)
 	if err != nil {
 		return err
 	}
 	s.sub = sub
+	if s.cfg.VoicesCommand != "" && s.cfg.Voice != "" {
+		if err := checkVoice(s.ctx, s.cfg.VoicesCommand, s.cfg.Voice, loqaexec.Environ(s.cfg.ExecEnvPassthrough, s.cfg.ExecEnv)); err != nil {
+			s.voiceErr = err
+			s.logger.Error("tts voice check failed; reporting not ready", slogError(err))
+		}
+	}
 	return nil
 }
 
@@ -56,41 +77,86 @@ func (s *Service) Close() {
 	s.wg.Wait()
 }
 
-func (s *Service) Healthy() bool { return !s.cfg.Enabled || s.sub != nil }
+func (s *Service) Healthy() bool { return !s.cfg.Enabled || (s.sub != nil && s.voiceErr == nil) }
+
+// InFlight reports the number of requests currently synthesizing.
+func (s *Service) InFlight() int { return len(s.sema) }
 
 func (s *Service) handleRequest(msg *nats.Msg) {
 	var req protocol.TTSRequest
-	if err := json.Unmarshal(msg.Data, &req); err != nil {
+	if err := s.bus.Codec().Unmarshal(msg.Data, &req); err != nil {
 		s.logger.Warn("failed to decode tts request", slogError(err))
 		return
 	}
+	if err := protocol.CheckVersion(req.V); err != nil {
+		s.logger.Warn("rejecting tts request", slogError(err))
+		return
+	}
+	log := logging.WithSession(s.logger, req.SessionID, req.TraceID)
+	synthReq := synthRequest(req, s.cfg.SSML)
+	key := cacheKey{text: synthReq.Text, ssml: synthReq.SSML, voice: synthReq.Voice, sampleRate: s.cfg.SampleRate}
+	if cached, ok := s.cache.get(key); ok {
+		log.Debug("tts cache hit", slog.Int("chunks", len(cached)))
+		sequence := 0
+		for _, chunk := range cached {
+			s.publishSplit(log, req, chunk, &sequence)
+		}
+		return
+	}
 
+	// Acquire a synthesis slot before spawning so a burst of requests queues
+	// in the subscription instead of piling up goroutines.
+	select {
+	case s.sema <- struct{}{}:
+	case <-s.ctx.Done():
+		return
+	}
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
+		defer func() { <-s.sema }()
 
 		ctx, cancel := context.WithTimeout(s.ctx, 45*time.Second)
 		defer cancel()
 
-		chunks, errs := s.synth.Synthesize(ctx, SynthRequest{SessionID: req.SessionID, Text: req.Text, Voice: req.Voice})
+		chunks, errs := s.synth.Synthesize(ctx, synthReq)
 		sequence := 0
+		// produced collects the utterance for the cache. It is stored when
+		// the final chunk arrives, before tts.done goes out, so a repeat
+		// sent after completion hits; an earlier synthesis error or a
+		// missing final chunk leaves it uncached.
+		var produced []SynthChunk
+		failed := false
+		emit := func(chunk SynthChunk) {
+			if s.cache != nil {
+				produced = append(produced, chunk)
+				if chunk.Final && !failed {
+					s.cache.put(key, produced)
+				}
+			}
+			s.publishSplit(log, req, chunk, &sequence)
+		}
+		// Leveling happens before caching so replays are not adjusted twice.
+		level := s.level.utterance()
 		for {
 			select {
 			case chunk, ok := <-chunks:
 				if !ok {
 					chunks = nil
-					continue
+					break
+				}
+				if s.level.enabled() {
+					level.apply(chunk.PCM)
 				}
-				chunk.Sequence = sequence
-				sequence++
-				s.publishChunk(req, chunk)
+				emit(chunk)
 			case err, ok := <-errs:
 				if ok && err != nil {
-					s.logger.Warn("tts synthesis error", slogError(err))
+					log.Warn("tts synthesis error", slogError(err))
+					failed = true
 				}
 				errs = nil
 			case <-ctx.Done():
-				s.logger.Warn("tts synthesis cancelled", slogError(ctx.Err()))
+				log.Warn("tts synthesis cancelled", slogError(ctx.Err()))
 				return
 			}
 			if chunks == nil && errs == nil {
@@ -100,29 +166,38 @@ func (s *Service) handleRequest(msg *nats.Msg) {
 	}()
 }
 
-func (s *Service) publishChunk(req protocol.TTSRequest, chunk SynthChunk) {
-	packet := protocol.AudioChunk{
-		SessionID:  req.SessionID,
-		Target:     req.Target,
-		SampleRate: chunk.SampleRate,
-		Channels:   chunk.Channels,
-		Sequence:   chunk.Sequence,
-		PCM:        chunk.PCM,
-		Final:      chunk.Final,
-	}
-	data, err := json.Marshal(packet)
-	if err != nil {
-		s.logger.Warn("failed to marshal tts chunk", slogError(err))
-		return
+// publishSplit publishes chunk in pieces that fit the bus payload limit,
+// numbering them from *sequence.
+func (s *Service) publishSplit(log *slog.Logger, req protocol.TTSRequest, chunk SynthChunk, sequence *int) {
+	for _, part := range splitChunk(chunk, maxChunkPCM(s.bus)) {
+		part.Sequence = *sequence
+		*sequence++
+		s.publishChunk(log, req, part)
 	}
-	subject := protocol.SubjectTTSAudio
-	if err := s.bus.Conn().Publish(subject, data); err != nil {
-		s.logger.Warn("failed to publish tts chunk", slogError(err))
-	}
-	if chunk.Final {
-		finalMsg := protocol.TTSStatus{SessionID: req.SessionID, Target: req.Target, Completed: true, Timestamp: time.Now().UTC()}
-		if data, err := json.Marshal(finalMsg); err == nil {
-			_ = s.bus.Conn().Publish(protocol.SubjectTTSDone, data)
+}
+
+// publishChunk fans chunk out to each of the request's targets, followed by
+// a per-target completion status after the final chunk.
+func (s *Service) publishChunk(log *slog.Logger, req protocol.TTSRequest, chunk SynthChunk) {
+	for _, target := range req.AllTargets() {
+		packet := protocol.AudioChunk{
+			V:          protocol.SchemaVersion,
+			SessionID:  req.SessionID,
+			Target:     target,
+			SampleRate: chunk.SampleRate,
+			Channels:   chunk.Channels,
+			Sequence:   chunk.Sequence,
+			PCM:        chunk.PCM,
+			Final:      chunk.Final,
+		}
+		if err := PublishAudioChunk(s.bus, s.subjects.TTSAudio, packet); err != nil {
+			log.Warn("failed to publish tts chunk", slog.String("target", target), slogError(err))
+		}
+		if chunk.Final {
+			finalMsg := protocol.TTSStatus{V: protocol.SchemaVersion, SessionID: req.SessionID, Target: target, Completed: true, TraceID: req.TraceID, Timestamp: time.Now().UTC()}
+			if data, err := s.bus.Codec().Marshal(finalMsg); err == nil {
+				_ = s.bus.Publish(s.subjects.TTSDone, data)
+			}
 		}
 	}
 }
@@ -0,0 +1,346 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loqalabs/loqa-core/internal/bus"
+	"github.com/loqalabs/loqa-core/internal/config"
+	"github.com/loqalabs/loqa-core/internal/protocol"
+	"github.com/loqalabs/loqa-core/internal/testutil"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// countingSynth records the peak number of concurrent Synthesize calls.
+type countingSynth struct {
+	active atomic.Int32
+	peak   atomic.Int32
+	done   atomic.Int32
+}
+
+func (c *countingSynth) Synthesize(ctx context.Context, req SynthRequest) (<-chan SynthChunk, <-chan error) {
+	chunks := make(chan SynthChunk, 1)
+	errs := make(chan error, 1)
+	n := c.active.Add(1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	go func() {
+		defer close(chunks)
+		defer close(errs)
+		time.Sleep(20 * time.Millisecond)
+		c.active.Add(-1)
+		c.done.Add(1)
+		chunks <- SynthChunk{SessionID: req.SessionID, Final: true}
+	}()
+	return chunks, errs
+}
+
+func TestServiceCapsConcurrentSynthesis(t *testing.T) {
+	client := testutil.StartBus(t)
+	synth := &countingSynth{}
+	svc := NewService(context.Background(), config.TTSConfig{Enabled: true, MaxConcurrentSynth: 2}, client, protocol.DefaultSubjects(), synth, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start tts service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	const requests = 12
+	for i := 0; i < requests; i++ {
+		data, _ := json.Marshal(protocol.TTSRequest{SessionID: "s", Text: "hello"})
+		if err := client.Conn().Publish(protocol.SubjectTTSRequest, data); err != nil {
+			t.Fatalf("publish request: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for synth.done.Load() < requests {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d requests synthesized", synth.done.Load(), requests)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if peak := synth.peak.Load(); peak > 2 {
+		t.Fatalf("expected at most 2 concurrent syntheses, saw %d", peak)
+	}
+}
+
+// bigSynth emits a single chunk of size bytes.
+type bigSynth struct{ size int }
+
+func (b bigSynth) Synthesize(ctx context.Context, req SynthRequest) (<-chan SynthChunk, <-chan error) {
+	chunks := make(chan SynthChunk, 1)
+	errs := make(chan error)
+	pcm := make([]byte, b.size)
+	for i := range pcm {
+		pcm[i] = byte(i)
+	}
+	chunks <- SynthChunk{SessionID: req.SessionID, SampleRate: 22050, Channels: 1, PCM: pcm, Final: true}
+	close(chunks)
+	close(errs)
+	return chunks, errs
+}
+
+func TestServiceSplitsChunksAboveMaxPayload(t *testing.T) {
+	// 2 KiB is below the usual per-chunk overhead reservation.
+	for _, maxPayload := range []int32{16 << 10, 2 << 10} {
+		t.Run(fmt.Sprint(maxPayload), func(t *testing.T) { testSplitsChunks(t, maxPayload) })
+	}
+}
+
+func testSplitsChunks(t *testing.T, maxPayload int32) {
+	client := testutil.Connect(t, testutil.StartServer(t, &server.Options{MaxPayload: maxPayload}), config.BusConfig{})
+
+	const size = 50 << 10
+	chunks := make(chan protocol.AudioChunk, 64)
+	sub, err := client.Conn().Subscribe(protocol.SubjectTTSAudio, func(msg *nats.Msg) {
+		if len(msg.Data) > int(maxPayload) {
+			t.Errorf("message of %d bytes exceeds max payload", len(msg.Data))
+		}
+		if chunk, err := DecodeAudioChunk(client, msg); err == nil {
+			chunks <- chunk
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe audio: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+
+	svc := NewService(context.Background(), config.TTSConfig{Enabled: true}, client, protocol.DefaultSubjects(), bigSynth{size: size}, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start tts service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+	data, _ := json.Marshal(protocol.TTSRequest{SessionID: "s", Text: "a long utterance"})
+	if err := client.Conn().Publish(protocol.SubjectTTSRequest, data); err != nil {
+		t.Fatalf("publish request: %v", err)
+	}
+
+	var pcm []byte
+	for seq := 0; ; seq++ {
+		select {
+		case chunk := <-chunks:
+			if chunk.Sequence != seq {
+				t.Fatalf("expected sequence %d, got %d", seq, chunk.Sequence)
+			}
+			pcm = append(pcm, chunk.PCM...)
+			if !chunk.Final {
+				continue
+			}
+			if seq == 0 || len(pcm) != size || pcm[size-1] != byte((size-1)%256) {
+				t.Fatalf("expected %d bytes split over several chunks, got %d bytes in %d chunks", size, len(pcm), seq+1)
+			}
+			return
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after %d chunks (%d bytes)", seq, len(pcm))
+		}
+	}
+}
+
+// subscribeOutput collects the chunks published on tts.audio and the
+// statuses published on tts.done.
+func subscribeOutput(t *testing.T, client *bus.Client) (<-chan protocol.AudioChunk, <-chan protocol.TTSStatus) {
+	t.Helper()
+	chunks := make(chan protocol.AudioChunk, 16)
+	sub, err := client.Conn().Subscribe(protocol.SubjectTTSAudio, func(msg *nats.Msg) {
+		if chunk, err := DecodeAudioChunk(client, msg); err == nil {
+			chunks <- chunk
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe audio: %v", err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+	statuses := make(chan protocol.TTSStatus, 16)
+	doneSub, err := client.Conn().Subscribe(protocol.SubjectTTSDone, func(msg *nats.Msg) {
+		var status protocol.TTSStatus
+		if err := json.Unmarshal(msg.Data, &status); err == nil {
+			statuses <- status
+		}
+	})
+	if err != nil {
+		t.Fatalf("subscribe done: %v", err)
+	}
+	t.Cleanup(func() { _ = doneSub.Unsubscribe() })
+	return chunks, statuses
+}
+
+func TestServiceFansOutToEveryTarget(t *testing.T) {
+	client := testutil.StartBus(t)
+	chunks, statuses := subscribeOutput(t, client)
+
+	svc := NewService(context.Background(), config.TTSConfig{Enabled: true}, client, protocol.DefaultSubjects(), bigSynth{size: 1024}, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start tts service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+	data, _ := json.Marshal(protocol.TTSRequest{SessionID: "s", Text: "dinner is ready", Target: "kitchen", Targets: []string{"den", "kitchen"}})
+	if err := client.Conn().Publish(protocol.SubjectTTSRequest, data); err != nil {
+		t.Fatalf("publish request: %v", err)
+	}
+
+	gotChunks := map[string]int{}
+	gotDone := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for len(gotChunks) < 2 || len(gotDone) < 2 {
+		select {
+		case chunk := <-chunks:
+			if !chunk.Final || len(chunk.PCM) != 1024 {
+				t.Fatalf("unexpected chunk for %q: final=%v bytes=%d", chunk.Target, chunk.Final, len(chunk.PCM))
+			}
+			gotChunks[chunk.Target]++
+		case status := <-statuses:
+			gotDone[status.Target] = status.Completed
+		case <-timeout:
+			t.Fatalf("timed out: chunks %v, done %v", gotChunks, gotDone)
+		}
+	}
+	if gotChunks["kitchen"] != 1 || gotChunks["den"] != 1 || !gotDone["kitchen"] || !gotDone["den"] {
+		t.Fatalf("expected one chunk and a completed status per target, got chunks %v, done %v", gotChunks, gotDone)
+	}
+}
+
+func TestServiceVoiceProbe(t *testing.T) {
+	client := testutil.StartBus(t)
+	listing := filepath.Join(t.TempDir(), "voices.txt")
+	if err := os.WriteFile(listing, []byte("# voices\nen-US  English (US)\n\nde-DE  German\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stub := "cat " + listing
+	for _, tc := range []struct {
+		voice   string
+		healthy bool
+	}{
+		{"de-DE", true},
+		{"fr-FR", false},
+	} {
+		cfg := config.TTSConfig{Enabled: true, Voice: tc.voice, VoicesCommand: stub}
+		svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), &countingSynth{}, testutil.Logger())
+		if err := svc.Start(); err != nil {
+			t.Fatalf("start tts service: %v", err)
+		}
+		if got := svc.Healthy(); got != tc.healthy {
+			t.Fatalf("voice %s: expected healthy=%v, got %v (probe error: %v)", tc.voice, tc.healthy, got, svc.voiceErr)
+		}
+		svc.Close()
+	}
+}
+
+// twoChunkSynth counts calls and emits a two-chunk utterance.
+type twoChunkSynth struct{ calls atomic.Int32 }
+
+func (s *twoChunkSynth) Synthesize(ctx context.Context, req SynthRequest) (<-chan SynthChunk, <-chan error) {
+	s.calls.Add(1)
+	chunks := make(chan SynthChunk, 2)
+	errs := make(chan error)
+	chunks <- SynthChunk{SessionID: req.SessionID, SampleRate: 22050, Channels: 1, PCM: []byte{1, 2}}
+	chunks <- SynthChunk{SessionID: req.SessionID, SampleRate: 22050, Channels: 1, PCM: []byte{3, 4}, Final: true}
+	close(chunks)
+	close(errs)
+	return chunks, errs
+}
+
+func TestServiceReplaysCachedPhrases(t *testing.T) {
+	client := testutil.StartBus(t)
+	chunks, statuses := subscribeOutput(t, client)
+
+	synth := &twoChunkSynth{}
+	svc := NewService(context.Background(), config.TTSConfig{Enabled: true, SampleRate: 22050, CacheBytes: 1 << 20}, client, protocol.DefaultSubjects(), synth, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start tts service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+
+	for _, session := range []string{"first", "second"} {
+		data, _ := json.Marshal(protocol.TTSRequest{SessionID: session, Text: "timer complete", Voice: "en-US"})
+		if err := client.Conn().Publish(protocol.SubjectTTSRequest, data); err != nil {
+			t.Fatalf("publish request: %v", err)
+		}
+		var pcm []byte
+		for seq := 0; seq < 2; seq++ {
+			select {
+			case chunk := <-chunks:
+				if chunk.SessionID != session || chunk.Sequence != seq || chunk.Final != (seq == 1) {
+					t.Fatalf("%s: unexpected chunk %+v", session, chunk)
+				}
+				pcm = append(pcm, chunk.PCM...)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s: timed out waiting for chunk %d", session, seq)
+			}
+		}
+		if string(pcm) != "\x01\x02\x03\x04" {
+			t.Fatalf("%s: unexpected audio %v", session, pcm)
+		}
+		select {
+		case status := <-statuses:
+			if status.SessionID != session || !status.Completed {
+				t.Fatalf("%s: unexpected status %+v", session, status)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no tts.done status", session)
+		}
+	}
+	if calls := synth.calls.Load(); calls != 1 {
+		t.Fatalf("expected the repeat to hit the cache, synthesizer ran %d times", calls)
+	}
+}
+
+// levelSynth emits a quiet chunk followed by a louder final chunk.
+type levelSynth struct{}
+
+func (levelSynth) Synthesize(ctx context.Context, req SynthRequest) (<-chan SynthChunk, <-chan error) {
+	chunks := make(chan SynthChunk, 2)
+	errs := make(chan error)
+	chunks <- SynthChunk{SessionID: req.SessionID, SampleRate: 22050, Channels: 1, PCM: pcm16(1000, -500)}
+	chunks <- SynthChunk{SessionID: req.SessionID, SampleRate: 22050, Channels: 1, PCM: pcm16(-8000), Final: true}
+	close(chunks)
+	close(errs)
+	return chunks, errs
+}
+
+func TestServiceNormalizesChunksAsTheyStream(t *testing.T) {
+	client := testutil.StartBus(t)
+	chunks, _ := subscribeOutput(t, client)
+
+	cfg := config.TTSConfig{Enabled: true, SampleRate: 22050, Normalize: true, NormalizeMaxGainDB: 20}
+	svc := NewService(context.Background(), cfg, client, protocol.DefaultSubjects(), levelSynth{}, testutil.Logger())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("start tts service: %v", err)
+	}
+	t.Cleanup(svc.Close)
+	data, _ := json.Marshal(protocol.TTSRequest{SessionID: "s", Text: "lights on"})
+	if err := client.Conn().Publish(protocol.SubjectTTSRequest, data); err != nil {
+		t.Fatalf("publish request: %v", err)
+	}
+
+	// The quiet chunk gets the 20 dB cap; the louder one is scaled against
+	// the new running peak (32767/8000).
+	want := [][]int16{{10000, -5000}, {-math.MaxInt16}}
+	for seq, samples := range want {
+		select {
+		case chunk := <-chunks:
+			got := samples16(chunk.PCM)
+			if chunk.Sequence != seq || len(got) != len(samples) {
+				t.Fatalf("unexpected chunk %+v", chunk)
+			}
+			for i := range samples {
+				if got[i] != samples[i] {
+					t.Fatalf("chunk %d: got samples %v, want %v", seq, got, samples)
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for chunk %d", seq)
+		}
+	}
+}
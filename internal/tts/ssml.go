@@ -0,0 +1,66 @@
+package tts
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/loqalabs/loqa-core/internal/protocol"
+)
+
+// pauseElements separate words when their markup is removed.
+var pauseElements = map[string]bool{"break": true, "p": true, "s": true, "speak": true}
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// StripSSML returns the spoken text of an SSML document for engines that
+// only accept plain text: element content is kept, tags are dropped, and
+// entities are decoded. Markup that does not parse falls back to removing
+// anything between angle brackets.
+func StripSSML(markup string) string {
+	dec := xml.NewDecoder(strings.NewReader(markup))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	var b strings.Builder
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return strings.Join(strings.Fields(tagPattern.ReplaceAllString(markup, " ")), " ")
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			b.Write(t)
+		case xml.StartElement:
+			if pauseElements[t.Name.Local] {
+				b.WriteByte(' ')
+			}
+		case xml.EndElement:
+			if pauseElements[t.Name.Local] {
+				b.WriteByte(' ')
+			}
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// synthRequest builds the synthesizer input for req. Engines without SSML
+// support (tts.ssml false) get the markup's plain text; SSML engines get
+// the markup with the plain text alongside.
+func synthRequest(req protocol.TTSRequest, ssml bool) SynthRequest {
+	out := SynthRequest{SessionID: req.SessionID, Text: req.Text, Voice: req.Voice}
+	if req.SSML == "" {
+		return out
+	}
+	if out.Text == "" {
+		out.Text = StripSSML(req.SSML)
+	}
+	if ssml {
+		out.SSML = req.SSML
+	}
+	return out
+}
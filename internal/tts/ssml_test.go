@@ -0,0 +1,73 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loqalabs/loqa-core/internal/protocol"
+)
+
+func TestStripSSML(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{"plain text", "hello there", "hello there"},
+		{"speak wrapper", `<speak>Dinner is <emphasis level="strong">ready</emphasis>.</speak>`, "Dinner is ready."},
+		{"breaks separate words", `<speak>One<break time="500ms"/>two<break/>three</speak>`, "One two three"},
+		{"sentences and entities", `<speak><p><s>Fish &amp; chips</s><s>at 5 &lt; 6</s></p></speak>`, "Fish & chips at 5 < 6"},
+		{"prosody", `<speak><prosody rate="slow" pitch="+2st">Good night</prosody></speak>`, "Good night"},
+		{"unclosed elements", `<speak>Hello <emphasis>world`, "Hello world"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := StripSSML(tc.in); got != tc.want {
+				t.Fatalf("StripSSML(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSynthRequestHonorsSSMLCapability(t *testing.T) {
+	req := protocol.TTSRequest{SessionID: "s", SSML: `<speak>Hi<break/>there</speak>`, Voice: "en-US"}
+	plain := synthRequest(req, false)
+	if plain.Text != "Hi there" || plain.SSML != "" {
+		t.Fatalf("plain engine: expected stripped text only, got %+v", plain)
+	}
+	capable := synthRequest(req, true)
+	if capable.Text != "Hi there" || capable.SSML != req.SSML {
+		t.Fatalf("ssml engine: expected markup and text, got %+v", capable)
+	}
+	req.Text = "explicit"
+	if got := synthRequest(req, false); got.Text != "explicit" {
+		t.Fatalf("explicit text should win over stripped markup, got %q", got.Text)
+	}
+}
+
+func TestExecSynthSendsSSML(t *testing.T) {
+	dir := t.TempDir()
+	captured := filepath.Join(dir, "request.json")
+	synth, err := NewExecSynth(`sh -c "cat > '`+captured+`'; echo '{\"pcm_base64\":\"\",\"final\":true}'"`, nil, nil, 22050, 1)
+	if err != nil {
+		t.Fatalf("new exec synth: %v", err)
+	}
+	chunks, errs := synth.Synthesize(context.Background(), SynthRequest{SessionID: "s", Text: "Hi there", SSML: "<speak>Hi there</speak>", Voice: "en-US"})
+	for range chunks {
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("synthesize: %v", err)
+	}
+	data, err := os.ReadFile(captured)
+	if err != nil {
+		t.Fatalf("read captured request: %v", err)
+	}
+	var got execRequest
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decode captured request %q: %v", data, err)
+	}
+	if got.Text != "Hi there" || got.SSML != "<speak>Hi there</speak>" {
+		t.Fatalf("unexpected exec request %+v", got)
+	}
+}
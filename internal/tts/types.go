@@ -2,11 +2,18 @@ package tts
 
 import "context"
 
+// chunkBuffer bounds how many synthesized chunks a synthesizer may queue
+// ahead of the publisher before it blocks.
+const chunkBuffer = 4
+
 // SynthRequest contains parameters to synthesize speech.
 type SynthRequest struct {
 	SessionID string
 	Text      string
 	Voice     string
+	// SSML is the request's markup, set only for engines configured with
+	// tts.ssml; Text always holds the plain text.
+	SSML string
 }
 
 // SynthChunk contains PCM data.
@@ -0,0 +1,56 @@
+package tts
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	loqaexec "github.com/loqalabs/loqa-core/internal/exec"
+)
+
+// voiceProbeTimeout bounds the tts.voices_command run at startup.
+const voiceProbeTimeout = 10 * time.Second
+
+// ListVoices runs command and returns the voices it prints, one per line.
+// Only the first field of each line counts, so engines may follow the name
+// with a description; blank lines and lines starting with # are skipped.
+// env is the command's complete environment.
+func ListVoices(ctx context.Context, command string, env []string) ([]string, error) {
+	runner, err := loqaexec.NewRunner("voices", loqaexec.Spec{Command: command, Env: env})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, voiceProbeTimeout)
+	defer cancel()
+	out, err := runner.Run(ctx, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var voices []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		voices = append(voices, fields[0])
+	}
+	return voices, scanner.Err()
+}
+
+// checkVoice verifies that voice is among those listed by command.
+func checkVoice(ctx context.Context, command, voice string, env []string) error {
+	voices, err := ListVoices(ctx, command, env)
+	if err != nil {
+		return err
+	}
+	for _, v := range voices {
+		if v == voice {
+			return nil
+		}
+	}
+	return fmt.Errorf("tts.voice %q is not offered by the engine (available: %s)", voice, strings.Join(voices, ", "))
+}
@@ -0,0 +1,85 @@
+package host
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by Publish, mirroring the host_publish result codes.
+var (
+	ErrNoPermission      = errors.New("host: manifest lacks bus:publish permission")
+	ErrRuntime           = errors.New("host: publish failed")
+	ErrSubjectUndeclared = errors.New("host: subject not declared in capabilities.bus.publish")
+	ErrPayloadTooLarge   = errors.New("host: payload exceeds host limit")
+	ErrThrottled         = errors.New("host: publish rate exceeded, message dropped")
+)
+
+func publishError(code uint32) error {
+	switch code {
+	case 0:
+		return nil
+	case 1:
+		return ErrNoPermission
+	case 2:
+		return ErrRuntime
+	case 3:
+		return ErrSubjectUndeclared
+	case 4:
+		return ErrPayloadTooLarge
+	case 5:
+		return ErrThrottled
+	default:
+		return fmt.Errorf("host: unknown publish result code %d", code)
+	}
+}
+
+// Errors returned by HTTP, mirroring the host_http result codes.
+var (
+	ErrHTTPNoPermission     = errors.New("host: manifest lacks network:http permission")
+	ErrHTTPRuntime          = errors.New("host: http request failed")
+	ErrHTTPHostNotAllowed   = errors.New("host: host not listed in capabilities.network.http.allow")
+	ErrHTTPTooLarge         = errors.New("host: http body exceeds host limit")
+	ErrHTTPMethodNotAllowed = errors.New("host: method not listed in capabilities.network.http.methods")
+	ErrHTTPBadRequest       = errors.New("host: invalid http request")
+)
+
+func httpError(code uint32) error {
+	switch code {
+	case 0:
+		return nil
+	case 1:
+		return ErrHTTPNoPermission
+	case 2:
+		return ErrHTTPRuntime
+	case 3:
+		return ErrHTTPHostNotAllowed
+	case 4:
+		return ErrHTTPTooLarge
+	case 5:
+		return ErrHTTPMethodNotAllowed
+	case 6:
+		return ErrHTTPBadRequest
+	default:
+		return fmt.Errorf("host: unknown http result code %d", code)
+	}
+}
+
+// Errors returned by Config, mirroring the negative host_config_get results.
+var (
+	ErrConfigNotFound     = errors.New("host: config key not declared or unset")
+	ErrConfigNoPermission = errors.New("host: manifest lacks config:read permission")
+	ErrConfigRuntime      = errors.New("host: config read failed")
+)
+
+func configError(code int32) error {
+	switch code {
+	case -1:
+		return ErrConfigNotFound
+	case -2:
+		return ErrConfigNoPermission
+	case -3:
+		return ErrConfigRuntime
+	default:
+		return fmt.Errorf("host: unknown config result code %d", code)
+	}
+}
@@ -2,7 +2,11 @@
 
 package host
 
-import "unsafe"
+import (
+	"encoding/json"
+	"time"
+	"unsafe"
+)
 
 // Log forwards text to the host runtime via the imported host_log function.
 func Log(msg string) {
@@ -13,10 +17,11 @@ func Log(msg string) {
 	hostLog(unsafe.Pointer(&b[0]), uint32(len(b)))
 }
 
-// Publish sends a message to the host bus if permitted by the manifest.
-func Publish(subject string, payload []byte) bool {
+// Publish sends a message to the host bus if permitted by the manifest. The
+// returned error identifies why the host rejected the message.
+func Publish(subject string, payload []byte) error {
 	if len(subject) == 0 {
-		return false
+		return ErrSubjectUndeclared
 	}
 	subjectBuf := []byte(subject)
 	var payloadPtr unsafe.Pointer
@@ -26,7 +31,72 @@ func Publish(subject string, payload []byte) bool {
 		payloadLen = uint32(len(payload))
 	}
 	code := hostPublish(unsafe.Pointer(&subjectBuf[0]), uint32(len(subjectBuf)), payloadPtr, payloadLen)
-	return code == 0
+	return publishError(code)
+}
+
+// Metric records a value on a host-side counter, gauge, or histogram. The host
+// namespaces name by skill and requires the metrics:emit permission.
+func Metric(name string, value float64, kind MetricKind) {
+	if len(name) == 0 {
+		return
+	}
+	b := []byte(name)
+	hostMetric(unsafe.Pointer(&b[0]), uint32(len(b)), value, int32(kind))
+}
+
+// HTTP performs req on the host, which enforces the manifest's
+// capabilities.network.http allowlist and the network:http permission.
+func HTTP(req Request) (Response, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, err
+	}
+	buf := make([]byte, 16<<10)
+	for {
+		var needed uint32
+		code := hostHTTP(unsafe.Pointer(&data[0]), uint32(len(data)), unsafe.Pointer(&buf[0]), uint32(len(buf)), unsafe.Pointer(&needed))
+		if code == httpBufferTooSmall && int(needed) > len(buf) {
+			buf = make([]byte, needed)
+			continue
+		}
+		if err := httpError(code); err != nil {
+			return Response{}, err
+		}
+		var resp Response
+		err := json.Unmarshal(buf[:needed], &resp)
+		return resp, err
+	}
+}
+
+// Config returns the value of a key declared in the manifest's config
+// section. Secret keys need the config:read permission.
+func Config(key string) (string, error) {
+	if len(key) == 0 {
+		return "", ErrConfigNotFound
+	}
+	k := []byte(key)
+	buf := make([]byte, 256)
+	for {
+		n := hostConfigGet(unsafe.Pointer(&k[0]), uint32(len(k)), unsafe.Pointer(&buf[0]), uint32(len(buf)))
+		if n < 0 {
+			return "", configError(n)
+		}
+		if int(n) > len(buf) {
+			buf = make([]byte, n)
+			continue
+		}
+		return string(buf[:n]), nil
+	}
+}
+
+// Remaining reports how long the current invocation has before the host
+// cancels it, and false when the host set no deadline.
+func Remaining() (time.Duration, bool) {
+	ms := hostDeadline()
+	if ms < 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
 }
 
 //go:wasmimport env host_log
@@ -34,3 +104,15 @@ func hostLog(ptr unsafe.Pointer, length uint32)
 
 //go:wasmimport env host_publish
 func hostPublish(subjectPtr unsafe.Pointer, subjectLen uint32, payloadPtr unsafe.Pointer, payloadLen uint32) uint32
+
+//go:wasmimport env host_metric
+func hostMetric(namePtr unsafe.Pointer, nameLen uint32, value float64, kind int32)
+
+//go:wasmimport env host_config_get
+func hostConfigGet(keyPtr unsafe.Pointer, keyLen uint32, outPtr unsafe.Pointer, outCap uint32) int32
+
+//go:wasmimport env host_deadline
+func hostDeadline() int64
+
+//go:wasmimport env host_http
+func hostHTTP(reqPtr unsafe.Pointer, reqLen uint32, respPtr unsafe.Pointer, respCap uint32, respLenPtr unsafe.Pointer) uint32
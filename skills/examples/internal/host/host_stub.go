@@ -2,8 +2,22 @@
 
 package host
 
+import "time"
+
 // Log is a no-op stub for non-wasm builds so that `go test` succeeds.
 func Log(string) {}
 
 // Publish is a no-op stub for non-wasm builds.
-func Publish(string, []byte) bool { return false }
+func Publish(string, []byte) error { return ErrRuntime }
+
+// Metric is a no-op stub for non-wasm builds.
+func Metric(string, float64, MetricKind) {}
+
+// HTTP is a no-op stub for non-wasm builds.
+func HTTP(Request) (Response, error) { return Response{}, ErrHTTPRuntime }
+
+// Remaining is a stub for non-wasm builds; it reports no deadline.
+func Remaining() (time.Duration, bool) { return 0, false }
+
+// Config is a stub for non-wasm builds; no keys are available.
+func Config(string) (string, error) { return "", ErrConfigNotFound }
@@ -0,0 +1,20 @@
+package host
+
+// Request is an outbound HTTP request performed by the host.
+type Request struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// Response is the host's reply to a Request.
+type Response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// httpBufferTooSmall is the host_http code asking the guest to retry with a
+// buffer of the reported length.
+const httpBufferTooSmall = 7
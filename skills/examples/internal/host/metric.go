@@ -0,0 +1,11 @@
+package host
+
+// MetricKind selects the instrument the host records a metric on.
+type MetricKind int32
+
+// Metric kinds understood by the host_metric import.
+const (
+	Counter   MetricKind = 0
+	Gauge     MetricKind = 1
+	Histogram MetricKind = 2
+)
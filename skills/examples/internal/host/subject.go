@@ -0,0 +1,42 @@
+package host
+
+import (
+	"os"
+	"strings"
+)
+
+// MatchSubject reports whether subject matches a NATS pattern and returns
+// the text each wildcard matched, in order ("*" yields one token, ">" the
+// remaining tokens joined with ".").
+func MatchSubject(pattern, subject string) ([]string, bool) {
+	pt := strings.Split(pattern, ".")
+	st := strings.Split(subject, ".")
+	var matched []string
+	for i, tok := range pt {
+		switch {
+		case tok == ">":
+			if i >= len(st) {
+				return nil, false
+			}
+			return append(matched, strings.Join(st[i:], ".")), true
+		case i >= len(st):
+			return nil, false
+		case tok == "*":
+			matched = append(matched, st[i])
+		case tok != st[i]:
+			return nil, false
+		}
+	}
+	if len(st) != len(pt) {
+		return nil, false
+	}
+	return matched, true
+}
+
+// EventTokens returns the wildcard tokens of the current event's subject
+// (LOQA_EVENT_SUBJECT) matched against the subscription that delivered it
+// (LOQA_EVENT_PATTERN). It returns nil for exact subscriptions.
+func EventTokens() []string {
+	tokens, _ := MatchSubject(os.Getenv("LOQA_EVENT_PATTERN"), os.Getenv("LOQA_EVENT_SUBJECT"))
+	return tokens
+}
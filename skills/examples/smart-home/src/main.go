@@ -3,6 +3,8 @@ package main
 import (
 	"encoding/json"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/loqalabs/loqa-core/skills/examples/internal/host"
 )
@@ -56,37 +58,51 @@ func run() {
 		return
 	}
 
-	host.Log("would call Home Assistant at " + endpoint)
-	host.Log("authorization token present: " + boolText(token != ""))
-	host.Log("request body: " + string(body))
+	// Home Assistant services are addressed by the entity's domain, e.g.
+	// light.kitchen + turn_on -> /api/services/light/turn_on.
+	domain, _, _ := strings.Cut(cmd.Device, ".")
+	req := host.Request{
+		Method:  "POST",
+		URL:     strings.TrimSuffix(endpoint, "/") + "/api/services/" + domain + "/" + cmd.Action,
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    string(body),
+	}
+	if token != "" {
+		req.Headers["Authorization"] = "Bearer " + token
+	}
+	resp, err := host.HTTP(req)
+	state := "forwarded"
+	switch {
+	case err != nil:
+		host.Log("Home Assistant call failed: " + err.Error())
+		state = "failed"
+	case resp.Status >= 300:
+		host.Log("Home Assistant returned status " + strconv.Itoa(resp.Status))
+		state = "failed"
+	}
 
 	sendStatus(intent{
 		Room:    cmd.Room,
 		Device:  cmd.Device,
 		Action:  cmd.Action,
 		Payload: cmd.Payload,
-	})
-}
-
-func boolText(v bool) string {
-	if v {
-		return "yes"
-	}
-	return "no"
+	}, state)
 }
 
-func sendStatus(st intent) {
+func sendStatus(st intent, state string) {
 	status := map[string]string{
 		"device": st.Device,
 		"action": st.Action,
 		"room":   st.Room,
-		"state":  "forwarded",
+		"state":  state,
 	}
 	if st.Payload != "" {
 		status["payload"] = st.Payload
 	}
 	if data, err := json.Marshal(status); err == nil {
-		host.Publish("skill.home.status", data)
+		if err := host.Publish("skill.home.status", data); err != nil {
+			host.Log("failed to publish status: " + err.Error())
+		}
 	}
 }
 
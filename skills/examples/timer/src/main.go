@@ -66,19 +66,24 @@ func handleStart(payload string) {
 	time.Sleep(delay)
 	reportStatus(timerStatus{Label: label, State: "completed"})
 	host.Log(fmt.Sprintf("%s complete", label))
+	host.Metric("timers_completed", 1, host.Counter)
 	announceCompletion(label)
 }
 
 func reportStatus(status timerStatus) {
 	if data, err := json.Marshal(status); err == nil {
-		host.Publish("skill.timer.status", data)
+		if err := host.Publish("skill.timer.status", data); err != nil {
+			host.Log("failed to publish timer status: " + err.Error())
+		}
 	}
 }
 
 func announceCompletion(label string) {
 	msg := ttsRequest{Text: fmt.Sprintf("%s timer complete", label)}
 	if data, err := json.Marshal(msg); err == nil {
-		host.Publish("tts.request", data)
+		if err := host.Publish("tts.request", data); err != nil {
+			host.Log("failed to publish tts request: " + err.Error())
+		}
 	}
 }
 
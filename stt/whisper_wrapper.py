@@ -19,13 +19,19 @@ except ImportError as exc:  # pragma: no cover - import error messaging
 def build_args() -> argparse.Namespace:
     parser = argparse.ArgumentParser(description="Loqa faster-whisper wrapper")
     parser.add_argument("--model", required=True, help="Path to the Whisper model file")
-    parser.add_argument("--audio", required=True, help="Path to the input WAV file")
-    parser.add_argument("--language", default=None, help="Two-letter language code (optional)")
+    parser.add_argument("--audio", default=None, help="Path to the input WAV file")
+    parser.add_argument("--stdin-pcm", action="store_true", help="Read raw s16le PCM from stdin instead of --audio")
+    parser.add_argument("--sample-rate", type=int, default=16000, help="Sample rate of stdin PCM")
+    parser.add_argument("--channels", type=int, default=1, help="Channel count of stdin PCM")
+    parser.add_argument("--language", default=None, help="Two-letter language code, or auto to detect (optional)")
     parser.add_argument("--compute-type", default="int8", help="faster-whisper compute type (int8, float16, float32)")
     parser.add_argument("--beam-size", type=int, default=1)
     parser.add_argument("--temperature", type=float, default=0.0)
     parser.add_argument("--partial", action="store_true", help="Hint that this request is for an interim transcript")
-    return parser.parse_args()
+    args = parser.parse_args()
+    if not args.stdin_pcm and not args.audio:
+        parser.error("--audio is required unless --stdin-pcm is set")
+    return args
 
 
 _model_cache = {}
@@ -40,13 +46,38 @@ def load_model(path: str, compute_type: str) -> WhisperModel:
     return model
 
 
+def read_stdin_pcm(sample_rate: int, channels: int):
+    """Decode raw s16le PCM from stdin into mono float32 samples at 16 kHz."""
+    import numpy as np
+
+    raw = sys.stdin.buffer.read()
+    samples = np.frombuffer(raw, dtype="<i2").astype(np.float32) / 32768.0
+    if channels > 1:
+        samples = samples[: len(samples) - len(samples) % channels]
+        samples = samples.reshape(-1, channels).mean(axis=1)
+    if sample_rate != 16000 and len(samples) > 0:
+        target = int(len(samples) * 16000 / sample_rate)
+        samples = np.interp(
+            np.linspace(0, len(samples), target, endpoint=False),
+            np.arange(len(samples)),
+            samples,
+        ).astype(np.float32)
+    return samples
+
+
 def main() -> int:
     args = build_args()
     model = load_model(args.model, args.compute_type)
 
+    audio = args.audio
+    if args.stdin_pcm:
+        audio = read_stdin_pcm(args.sample_rate, args.channels)
+
+    # "auto" (or no flag) lets the model detect the spoken language.
+    language = None if args.language in (None, "", "auto") else args.language
     segments, info = model.transcribe(
-        args.audio,
-        language=args.language,
+        audio,
+        language=language,
         beam_size=args.beam_size,
         temperature=args.temperature,
     )
@@ -70,6 +101,7 @@ def main() -> int:
     result = {
         "text": text,
         "confidence": confidence,
+        "language": getattr(info, "language", None) or language or "",
     }
 
     print(json.dumps(result))
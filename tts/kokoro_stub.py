@@ -5,7 +5,14 @@ import base64
 import json
 import sys
 
+VOICES = ["en-US", "en-GB"]
+
 def main() -> int:
+    if "--list-voices" in sys.argv[1:]:
+        for voice in VOICES:
+            print(voice)
+        return 0
+
     try:
         payload = json.load(sys.stdin)
     except json.JSONDecodeError as exc: